@@ -4,21 +4,40 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"strconv"
 
 	"github.com/nachoconques0/schwarz-challenge/internal/app"
+	"github.com/nachoconques0/schwarz-challenge/internal/config"
 )
 
 func main() {
-	opts := []app.Option{
-		app.WithHTTPPort(os.Getenv("HTTP_PORT")),
-		app.WithDBHost(os.Getenv("DB_HOST")),
-		app.WithDBPort(os.Getenv("DB_PORT")),
-		app.WithDBName(os.Getenv("DB_NAME")),
-		app.WithDBUser(os.Getenv("DB_USER")),
-		app.WithDBPassword(os.Getenv("DB_PASSWORD")),
+	cfg, err := config.Load(os.Getenv(config.FileEnv), os.LookupEnv)
+	if err != nil {
+		log.Fatal(nil, fmt.Sprintf("error schwarz-challenge config: %s", err.Error()))
+	}
+	logger, err := cfg.Logger(os.Stderr)
+	if err != nil {
+		log.Fatal(nil, fmt.Sprintf("error schwarz-challenge logger: %s", err.Error()))
+	}
+	slog.SetDefault(logger)
+	slog.Info("Config: loaded", slog.Any("config", json.RawMessage(cfg.String())))
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrations(os.Args[2:], cfg.DBOptions()); err != nil {
+			log.Fatal(nil, fmt.Sprintf("error schwarz-challenge migrations: %s", err.Error()))
+		}
+		return
+	}
+
+	opts, err := cfg.Options()
+	if err != nil {
+		log.Fatal(nil, fmt.Sprintf("error schwarz-challenge options: %s", err.Error()))
 	}
 
 	application, err := app.New(opts...)
@@ -29,20 +48,48 @@ func main() {
 	application.Start()
 }
 
-func LoadOrDefault(env, def string) string {
-	val, ok := os.LookupEnv(env)
-	if !ok {
-		return def
+// runMigrations runs the migrate subcommand with the given arguments:
+// `up`, `down [N]` reverting one migration by default, or `status`
+func runMigrations(args []string, opts []app.Option) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down [N]|status")
 	}
-
-	return val
-}
-
-func LoadOrPanic(env string) string {
-	val, ok := os.LookupEnv(env)
-	if !ok {
-		panic(fmt.Sprintf("missing '%s' environment variable\n", env))
+	runner, err := app.NewMigrationRunner(opts...)
+	if err != nil {
+		return err
 	}
+	ctx := context.Background()
 
-	return val
+	switch args[0] {
+	case "up":
+		applied, err := runner.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid number of migrations %q", args[1])
+			}
+		}
+		reverted, err := runner.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		status, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version: %d\ndirty: %t\nlatest: %d\n", status.Version, status.Dirty, status.Latest)
+		for _, m := range status.Pending {
+			fmt.Printf("pending %d_%s\n", m.Version, m.Name)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown migrate command %q, usage: migrate up|down [N]|status", args[0])
 }
@@ -1,3 +1,7 @@
 
 mockgen --source=internal/coupon/coupon.go --destination=internal/mocks/mock_coupon.go --package=mocks --mock_names=Repository=MockCouponRepository,Service=MockCouponService,Server=MockCouponServer
 mockgen --source=internal/shopping_cart/shopping_cart.go --destination=internal/mocks/mock_shopping_cart.go --package=mocks --mock_names=Repository=MockShoppingCartRepository,Service=MockShoppingCartService,Server=MockShoppingCartServer
+mockgen --source=internal/transaction/transaction.go --destination=internal/mocks/mock_transaction.go --package=mocks --mock_names=Manager=MockTxManager
+mockgen --source=internal/outbox/outbox.go --destination=internal/mocks/mock_outbox.go --package=mocks --mock_names=Repository=MockOutboxRepository,Publisher=MockOutboxPublisher
+mockgen --source=internal/webhook/webhook.go --destination=internal/mocks/mock_webhook.go --package=mocks --mock_names=Repository=MockWebhookRepository,Service=MockWebhookService,Server=MockWebhookServer
+mockgen --source=internal/audit/audit.go --destination=internal/mocks/mock_audit.go --package=mocks --mock_names=Repository=MockAuditRepository,Service=MockAuditService,Server=MockAuditServer
@@ -0,0 +1,156 @@
+// Package abuse protects the service against clients probing
+// coupon identifiers. It tracks failed lookups per key in a sliding
+// window and locks out the keys exceeding the allowed failures
+package abuse
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+)
+
+var (
+	// ErrTooManyFailedAttempts used when a key is locked out
+	ErrTooManyFailedAttempts = internalErrors.NewTooManyRequests("too many failed attempts, try again later")
+	// ErrMissingStore used when the guard store is nil
+	ErrMissingStore = internalErrors.NewWrongInput("abuse guard store is missing")
+	// ErrInvalidConfig used when the guard config has non positive values
+	ErrInvalidConfig = internalErrors.NewWrongInput("abuse guard max failures, window and lockout must be positive")
+)
+
+// Types of events recorded for review
+const (
+	EventFailure = "failure"
+	EventLockout = "lockout"
+	EventBlocked = "blocked"
+)
+
+// Event is something a client did that is worth reviewing
+type Event struct {
+	Type   string    `json:"type"`
+	Key    string    `json:"key"`
+	Reason string    `json:"reason,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// Store keeps the failures, lockouts and events of the guard. The in memory
+// implementation is enough for a single instance, a shared store is needed
+// once the service runs replicated
+type Store interface {
+	// AddFailure records a failure of key and returns how many failures
+	// the key had since the given moment, the new one included
+	AddFailure(key string, at time.Time, since time.Time) (int, error)
+	// Lock locks key out until the given moment
+	Lock(key string, until time.Time) error
+	// LockedUntil returns until when key is locked out, zero if it is not
+	LockedUntil(key string, now time.Time) (time.Time, error)
+	// RecordEvent stores an event for review
+	RecordEvent(Event) error
+	// Events returns the recorded events, oldest first
+	Events() ([]Event, error)
+}
+
+// Config defines when a key gets locked out
+type Config struct {
+	// MaxFailures is the number of failures allowed within Window
+	MaxFailures int
+	// Window is the sliding window failures are counted in
+	Window time.Duration
+	// Lockout is how long a key is locked out once it exceeds MaxFailures
+	Lockout time.Duration
+}
+
+// DefaultConfig locks a key out for 15 minutes after 10 failures in a minute
+func DefaultConfig() Config {
+	return Config{
+		MaxFailures: 10,
+		Window:      time.Minute,
+		Lockout:     15 * time.Minute,
+	}
+}
+
+// Guard decides whether a client can keep trying
+type Guard struct {
+	store  Store
+	config Config
+	now    func() time.Time
+}
+
+// NewGuard builds a new Guard with the given store and config
+func NewGuard(store Store, config Config) (*Guard, error) {
+	if store == nil {
+		return nil, ErrMissingStore
+	}
+	if config.MaxFailures <= 0 || config.Window <= 0 || config.Lockout <= 0 {
+		return nil, ErrInvalidConfig
+	}
+	return &Guard{
+		store:  store,
+		config: config,
+		now:    time.Now,
+	}, nil
+}
+
+// Check returns ErrTooManyFailedAttempts and how long to wait if
+// any of the given keys is locked out
+func (g *Guard) Check(keys ...string) (time.Duration, error) {
+	now := g.now()
+	var retryAfter time.Duration
+	for _, key := range keys {
+		until, err := g.store.LockedUntil(key, now)
+		if err != nil {
+			return 0, err
+		}
+		if wait := until.Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	if retryAfter <= 0 {
+		return 0, nil
+	}
+
+	for _, key := range keys {
+		g.record(Event{Type: EventBlocked, Key: key, At: now})
+	}
+	return retryAfter, ErrTooManyFailedAttempts
+}
+
+// RecordFailure records a failure for every given key, locking
+// out the ones exceeding the allowed failures
+func (g *Guard) RecordFailure(reason string, keys ...string) error {
+	now := g.now()
+	for _, key := range keys {
+		failures, err := g.store.AddFailure(key, now, now.Add(-g.config.Window))
+		if err != nil {
+			return err
+		}
+		g.record(Event{Type: EventFailure, Key: key, Reason: reason, At: now})
+		if failures < g.config.MaxFailures {
+			continue
+		}
+
+		if err := g.store.Lock(key, now.Add(g.config.Lockout)); err != nil {
+			return err
+		}
+		g.record(Event{Type: EventLockout, Key: key, Reason: reason, At: now})
+		slog.Warn("abuse guard: key locked out",
+			slog.String("key", key),
+			slog.Int("failures", failures),
+			slog.Duration("lockout", g.config.Lockout),
+		)
+	}
+	return nil
+}
+
+// Events returns the recorded events for review
+func (g *Guard) Events() ([]Event, error) {
+	return g.store.Events()
+}
+
+func (g *Guard) record(e Event) {
+	if err := g.store.RecordEvent(e); err != nil {
+		slog.Error(fmt.Sprintf("abuse guard: recording event: %s", err))
+	}
+}
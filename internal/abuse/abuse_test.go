@@ -0,0 +1,146 @@
+package abuse_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/abuse"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testClientKey = "ip:127.0.0.1"
+	testCartKey   = "cart:1"
+)
+
+func TestNewGuard(t *testing.T) {
+	tests := map[string]struct {
+		store  abuse.Store
+		config abuse.Config
+		err    error
+	}{
+		"success": {
+			store:  abuse.NewMemoryStore(),
+			config: abuse.DefaultConfig(),
+		},
+		"missing store": {
+			config: abuse.DefaultConfig(),
+			err:    abuse.ErrMissingStore,
+		},
+		"invalid max failures": {
+			store:  abuse.NewMemoryStore(),
+			config: abuse.Config{Window: time.Minute, Lockout: time.Minute},
+			err:    abuse.ErrInvalidConfig,
+		},
+		"invalid window": {
+			store:  abuse.NewMemoryStore(),
+			config: abuse.Config{MaxFailures: 1, Lockout: time.Minute},
+			err:    abuse.ErrInvalidConfig,
+		},
+		"invalid lockout": {
+			store:  abuse.NewMemoryStore(),
+			config: abuse.Config{MaxFailures: 1, Window: time.Minute},
+			err:    abuse.ErrInvalidConfig,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			guard, err := abuse.NewGuard(tc.store, tc.config)
+			assert.Equal(t, tc.err, err)
+			if tc.err == nil {
+				assert.NotNil(t, guard)
+			}
+		})
+	}
+}
+
+func TestGuard_RecordFailure(t *testing.T) {
+	config := abuse.Config{MaxFailures: 3, Window: time.Minute, Lockout: time.Hour}
+
+	t.Run("locks out after max failures", func(t *testing.T) {
+		guard, err := abuse.NewGuard(abuse.NewMemoryStore(), config)
+		assert.Nil(t, err)
+
+		for i := 0; i < config.MaxFailures-1; i++ {
+			assert.Nil(t, guard.RecordFailure("not found", testClientKey, testCartKey))
+			_, err := guard.Check(testClientKey, testCartKey)
+			assert.Nil(t, err)
+		}
+		assert.Nil(t, guard.RecordFailure("not found", testClientKey, testCartKey))
+
+		retryAfter, err := guard.Check(testClientKey)
+		assert.Equal(t, abuse.ErrTooManyFailedAttempts, err)
+		assert.True(t, retryAfter > 0 && retryAfter <= config.Lockout)
+	})
+
+	t.Run("locks out only the failing keys", func(t *testing.T) {
+		guard, err := abuse.NewGuard(abuse.NewMemoryStore(), config)
+		assert.Nil(t, err)
+
+		for i := 0; i < config.MaxFailures; i++ {
+			assert.Nil(t, guard.RecordFailure("not found", testClientKey))
+		}
+
+		_, err = guard.Check(testCartKey)
+		assert.Nil(t, err)
+		_, err = guard.Check("ip:10.0.0.1", testCartKey)
+		assert.Nil(t, err)
+		_, err = guard.Check("ip:10.0.0.1", testClientKey)
+		assert.Equal(t, abuse.ErrTooManyFailedAttempts, err)
+	})
+
+	t.Run("records events for review", func(t *testing.T) {
+		guard, err := abuse.NewGuard(abuse.NewMemoryStore(), abuse.Config{MaxFailures: 1, Window: time.Minute, Lockout: time.Hour})
+		assert.Nil(t, err)
+
+		assert.Nil(t, guard.RecordFailure("not found", testClientKey))
+		_, err = guard.Check(testClientKey)
+		assert.Equal(t, abuse.ErrTooManyFailedAttempts, err)
+
+		events, err := guard.Events()
+		assert.Nil(t, err)
+		assert.Len(t, events, 3)
+		assert.Equal(t, abuse.EventFailure, events[0].Type)
+		assert.Equal(t, "not found", events[0].Reason)
+		assert.Equal(t, abuse.EventLockout, events[1].Type)
+		assert.Equal(t, abuse.EventBlocked, events[2].Type)
+		for _, e := range events {
+			assert.Equal(t, testClientKey, e.Key)
+		}
+	})
+}
+
+func TestMemoryStore_AddFailure(t *testing.T) {
+	store := abuse.NewMemoryStore()
+	now := time.Now()
+
+	failures, err := store.AddFailure(testClientKey, now.Add(-2*time.Minute), now.Add(-3*time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, failures)
+
+	failures, err = store.AddFailure(testClientKey, now.Add(-30*time.Second), now.Add(-time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, failures, "failures out of the window are not counted")
+
+	failures, err = store.AddFailure(testClientKey, now, now.Add(-time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, failures)
+}
+
+func TestMemoryStore_LockedUntil(t *testing.T) {
+	store := abuse.NewMemoryStore()
+	now := time.Now()
+
+	until, err := store.LockedUntil(testClientKey, now)
+	assert.Nil(t, err)
+	assert.True(t, until.IsZero())
+
+	assert.Nil(t, store.Lock(testClientKey, now.Add(time.Minute)))
+	until, err = store.LockedUntil(testClientKey, now)
+	assert.Nil(t, err)
+	assert.Equal(t, now.Add(time.Minute), until)
+
+	until, err = store.LockedUntil(testClientKey, now.Add(time.Minute))
+	assert.Nil(t, err)
+	assert.True(t, until.IsZero(), "expired locks are released")
+}
@@ -0,0 +1,119 @@
+package abuse
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMaxEvents is the number of events kept by the memory store
+	defaultMaxEvents = 1000
+	// sweepEvery is the number of failures between sweeps of stale keys
+	sweepEvery = 1000
+)
+
+// memoryStore is an in memory Store, safe for concurrent use
+type memoryStore struct {
+	mu        sync.Mutex
+	failures  map[string][]time.Time
+	locks     map[string]time.Time
+	events    []Event
+	maxEvents int
+	additions int
+}
+
+// NewMemoryStore returns an in memory Store keeping the last 1000 events
+func NewMemoryStore() Store {
+	return &memoryStore{
+		failures:  make(map[string][]time.Time),
+		locks:     make(map[string]time.Time),
+		maxEvents: defaultMaxEvents,
+	}
+}
+
+// AddFailure records a failure of key and returns how many failures
+// the key had since the given moment, the new one included
+func (m *memoryStore) AddFailure(key string, at time.Time, since time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.additions++
+	if m.additions%sweepEvery == 0 {
+		m.sweep(at, since)
+	}
+
+	failures := append(prune(m.failures[key], since), at)
+	m.failures[key] = failures
+	return len(failures), nil
+}
+
+// Lock locks key out until the given moment
+func (m *memoryStore) Lock(key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.locks[key] = until
+	delete(m.failures, key)
+	return nil
+}
+
+// LockedUntil returns until when key is locked out, zero if it is not
+func (m *memoryStore) LockedUntil(key string, now time.Time) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	until, ok := m.locks[key]
+	if !ok {
+		return time.Time{}, nil
+	}
+	if !until.After(now) {
+		delete(m.locks, key)
+		return time.Time{}, nil
+	}
+	return until, nil
+}
+
+// RecordEvent stores an event, dropping the oldest one once full
+func (m *memoryStore) RecordEvent(e Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.events) == m.maxEvents {
+		m.events = m.events[1:]
+	}
+	m.events = append(m.events, e)
+	return nil
+}
+
+// Events returns the recorded events, oldest first
+func (m *memoryStore) Events() ([]Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events := make([]Event, len(m.events))
+	copy(events, m.events)
+	return events, nil
+}
+
+// sweep drops the keys without recent failures or active locks
+func (m *memoryStore) sweep(now time.Time, since time.Time) {
+	for key, failures := range m.failures {
+		if len(prune(failures, since)) == 0 {
+			delete(m.failures, key)
+		}
+	}
+	for key, until := range m.locks {
+		if !until.After(now) {
+			delete(m.locks, key)
+		}
+	}
+}
+
+// prune drops the failures that happened before the given moment
+func prune(failures []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(failures) && failures[i].Before(since) {
+		i++
+	}
+	return failures[i:]
+}
@@ -17,13 +17,27 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/nachoconques0/schwarz-challenge/internal/abuse"
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
 	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	"github.com/nachoconques0/schwarz-challenge/internal/health"
 	"github.com/nachoconques0/schwarz-challenge/internal/http"
+	"github.com/nachoconques0/schwarz-challenge/internal/idempotency"
+	"github.com/nachoconques0/schwarz-challenge/internal/metrics"
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
 	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/tracing"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
+	"gorm.io/gorm"
 )
 
 const defaultTimeout = 5 * time.Second
 
+// DefaultDrainDelay gives the load balancers a few health checks to
+// notice that the application is not ready before it stops serving
+const DefaultDrainDelay = 5 * time.Second
+
 // Application holds the basic structure that
 // defines the application
 type Application struct {
@@ -34,23 +48,81 @@ type Application struct {
 	// required for shutdown the application
 	timeout time.Duration
 
+	// postgres connection, nil with the in memory storage
+	db *gorm.DB
+
+	// metrics of the http requests, DB queries and business KPIs
+	metrics *metrics.Metrics
+
+	// export of the spans, flushed and stopped by tracingShutdown
+	tracingConfig   tracing.Config
+	tracingShutdown func(context.Context) error
+
+	// readiness of the application, turned off as soon as the shutdown
+	// starts and kept off for drainDelay before stopping the http server
+	healthChecker *health.Checker
+	drainDelay    time.Duration
+
 	// DB configuration
-	dbHost     string
-	dbPort     string
-	dbUser     string
-	dbPassword string
-	dbName     string
+	inMemoryStorage bool
+	dbHost          string
+	dbPort          string
+	dbUser          string
+	dbPassword      string
+	dbName          string
+	dbSSLMode       string
+
+	// DB connection pool and tuning, zero keeps the postgres defaults
+	dbMaxOpenConns     int
+	dbMaxIdleConns     int
+	dbConnMaxLifetime  time.Duration
+	dbStatementTimeout time.Duration
+	dbLogLevel         string
 
 	// HTTP Endpoints
 	ShoppingCartHTTPEndpoint string
 
+	// abuse protection of the apply coupon endpoint
+	abuseGuardConfig abuse.Config
+
+	// value used for bound the work of every http request,
+	// zero keeps the http server default
+	requestTimeout time.Duration
+
+	// how long the idempotency keys of the retried requests are remembered
+	idempotencyTTL time.Duration
+
+	// secret shared with the gateway setting the staff ID,
+	// the staff routes are unavailable without it
+	staffToken string
+
+	// header set by the trusted proxy with the client IP,
+	// the IP of the connection is used without it
+	clientIPHeader string
+
 	// internal domain services
 	shoppingCartService shoppingcart.Service
 	couponService       coupon.Service
+	webhookService      webhook.Service
+	auditService        audit.Service
 
 	// domain repositories
 	shoppingCartRepo shoppingcart.Repository
 	couponRepo       coupon.Repository
+	txManager        transaction.Manager
+	idempotencyStore idempotency.Store
+	outboxRepo       outbox.Repository
+	webhookRepo      webhook.Repository
+	auditRepo        audit.Repository
+
+	// delivery of the domain events stored in the outbox
+	outboxPublisher   outbox.Publisher
+	outboxRelayConfig outbox.RelayConfig
+	outboxRelay       *outbox.Relay
+
+	// delivery of the events to the webhook subscriptions
+	webhookDispatcherConfig webhook.DispatcherConfig
+	webhookDispatcher       *webhook.Dispatcher
 
 	// HTTP Controllers
 	shoppingCartCtrl shoppingcart.Server
@@ -60,20 +132,58 @@ type Application struct {
 // the given application options
 func New(opts ...Option) (*Application, error) {
 	a := &Application{
-		timeout: defaultTimeout,
+		timeout:           defaultTimeout,
+		drainDelay:        DefaultDrainDelay,
+		abuseGuardConfig:  abuse.DefaultConfig(),
+		idempotencyTTL:    idempotency.DefaultTTL,
+		outboxPublisher:   outbox.NewLogPublisher(),
+		outboxRelayConfig: outbox.DefaultRelayConfig(),
+
+		webhookDispatcherConfig: webhook.DefaultDispatcherConfig(),
+
+		metrics:       metrics.New(),
+		tracingConfig: tracing.DefaultConfig(),
 	}
 	for _, o := range opts {
 		o(a)
 	}
-	db, err := a.setupInfra()
+	shutdown, err := tracing.Setup(context.Background(), a.tracingConfig)
 	if err != nil {
-		return nil, fmt.Errorf("error while setting up the infra - %w", err)
+		return nil, fmt.Errorf("error while setting up the tracing - %w", err)
+	}
+	a.tracingShutdown = shutdown
+	if a.inMemoryStorage {
+		if err := a.setupInMemoryStorage(); err != nil {
+			return nil, fmt.Errorf("error while setting up the in memory storage - %w", err)
+		}
+	} else {
+		db, err := a.setupInfra()
+		if err != nil {
+			return nil, fmt.Errorf("error while setting up the infra - %w", err)
+		}
+		if err := a.checkSchema(db); err != nil {
+			return nil, fmt.Errorf("error while checking the database schema - %w", err)
+		}
+		if err := a.metrics.InstrumentDB(db); err != nil {
+			return nil, fmt.Errorf("error while instrumenting the database - %w", err)
+		}
+		if err := tracing.InstrumentDB(db); err != nil {
+			return nil, fmt.Errorf("error while tracing the database - %w", err)
+		}
+		a.db = db
+		if err := a.setupStorage(db); err != nil {
+			return nil, fmt.Errorf("error while setting up the storage - %w", err)
+		}
 	}
 
-	if err := a.setupDomain(db); err != nil {
+	if err := a.setupDomain(); err != nil {
 		return nil, fmt.Errorf("error while setting up the domain - %w", err)
 	}
 
+	if err := a.setupHealth(); err != nil {
+		return nil, fmt.Errorf("error while setting up the health checks - %w", err)
+	}
+
 	err = a.setupHTTPServer()
 	if err != nil {
 		return nil, fmt.Errorf("error while setting up the http server - %w", err)
@@ -94,6 +204,31 @@ func (a *Application) Start() {
 		}
 	}()
 
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		slog.Info("Outbox relay: starting")
+		a.healthChecker.SetRunning(outboxRelayWorker, true)
+		defer a.healthChecker.SetRunning(outboxRelayWorker, false)
+		a.outboxRelay.Run(relayCtx)
+	}()
+	dispatcherDone := make(chan struct{})
+	go func() {
+		defer close(dispatcherDone)
+		slog.Info("Webhook dispatcher: starting")
+		a.healthChecker.SetRunning(webhookDispatcherWorker, true)
+		defer a.healthChecker.SetRunning(webhookDispatcherWorker, false)
+		a.webhookDispatcher.Run(relayCtx)
+	}()
+	sweeperDone := make(chan struct{})
+	go func() {
+		defer close(sweeperDone)
+		slog.Info("Idempotency sweeper: starting")
+		idempotency.RunSweeper(relayCtx, a.idempotencyStore, a.idempotencyTTL, idempotency.DefaultSweepInterval)
+	}()
+	a.healthChecker.SetReady(true)
+
 	quitCh := make(chan os.Signal, 1)
 	signal.Notify(quitCh, syscall.SIGTERM, os.Interrupt)
 	defer signal.Stop(quitCh)
@@ -103,6 +238,13 @@ func (a *Application) Start() {
 	<-quitCh
 	slog.Info("Application: stopping")
 
+	// the load balancers stop sending traffic once they see the service not ready
+	a.healthChecker.SetReady(false)
+	if a.drainDelay > 0 {
+		slog.Info(fmt.Sprintf("Application: draining traffic for %s...\n", a.drainDelay.String()))
+		time.Sleep(a.drainDelay)
+	}
+
 	// Start context with cancellation set for the defined timeout
 	slog.Info(fmt.Sprintf("Application: stopping in %s...\n", a.timeout.String()))
 
@@ -112,6 +254,14 @@ func (a *Application) Start() {
 	if err := a.server.Stop(ctx); err != nil {
 		slog.Error(fmt.Sprintf("Application: error stopping application: %s", err))
 	}
+	// the events and deliveries not sent yet are kept for the next start
+	stopRelay()
+	<-relayDone
+	<-dispatcherDone
+	<-sweeperDone
+	if err := a.tracingShutdown(ctx); err != nil {
+		slog.Error(fmt.Sprintf("Application: error flushing the spans: %s", err))
+	}
 
 	<-ctx.Done()
 	slog.Info("Application: stopped")
@@ -1,15 +1,26 @@
 package app
 
 import (
+	"github.com/nachoconques0/schwarz-challenge/internal/idempotency"
+	"github.com/nachoconques0/schwarz-challenge/internal/memory"
+	"github.com/nachoconques0/schwarz-challenge/internal/metrics"
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
 	"github.com/nachoconques0/schwarz-challenge/internal/repo"
 	"github.com/nachoconques0/schwarz-challenge/internal/service"
+	"github.com/nachoconques0/schwarz-challenge/internal/tracing"
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
 	"gorm.io/gorm"
 )
 
-// setupDomain will take the db as a given parameters, and with it will
-// start all the dependency injections required for start up our business domain, the outcome
-// will be a ready domain service or an error
-func (a *Application) setupDomain(db *gorm.DB) error {
+// setupStorage will take the db as a given parameter and with it will
+// start the postgres repositories, transaction manager and idempotency store
+func (a *Application) setupStorage(db *gorm.DB) error {
+	txManager, err := repo.NewTransactionManager(db)
+	if err != nil {
+		return err
+	}
+	a.txManager = txManager
+
 	couponRepo, err := repo.NewCouponRepository(db)
 	if err != nil {
 		return err
@@ -22,20 +33,127 @@ func (a *Application) setupDomain(db *gorm.DB) error {
 	}
 	a.shoppingCartRepo = shoppingCartRepoRepo
 
-	couponSvc, err := service.NewCouponService(a.couponRepo)
+	idempotencyStore, err := repo.NewIdempotencyStore(db)
+	if err != nil {
+		return err
+	}
+	a.idempotencyStore = idempotencyStore
+
+	outboxRepo, err := repo.NewOutboxRepository(db)
+	if err != nil {
+		return err
+	}
+	a.outboxRepo = outboxRepo
+
+	webhookRepo, err := repo.NewWebhookRepository(db)
+	if err != nil {
+		return err
+	}
+	a.webhookRepo = webhookRepo
+
+	auditRepo, err := repo.NewAuditRepository(db)
+	if err != nil {
+		return err
+	}
+	a.auditRepo = auditRepo
+
+	return nil
+}
+
+// setupInMemoryStorage starts the in memory repositories and transaction
+// manager, nothing is persisted once the application stops
+func (a *Application) setupInMemoryStorage() error {
+	store := memory.NewStore()
+	txManager, err := memory.NewTransactionManager(store)
+	if err != nil {
+		return err
+	}
+	a.txManager = txManager
+
+	couponRepo, err := memory.NewCouponRepository(store)
+	if err != nil {
+		return err
+	}
+	a.couponRepo = couponRepo
+
+	shoppingCartRepo, err := memory.NewShoppingCartRepository(store)
+	if err != nil {
+		return err
+	}
+	a.shoppingCartRepo = shoppingCartRepo
+	a.idempotencyStore = idempotency.NewMemoryStore()
+
+	outboxRepo, err := memory.NewOutboxRepository(store)
+	if err != nil {
+		return err
+	}
+	a.outboxRepo = outboxRepo
+
+	webhookRepo, err := memory.NewWebhookRepository(store)
+	if err != nil {
+		return err
+	}
+	a.webhookRepo = webhookRepo
+
+	auditRepo, err := memory.NewAuditRepository(store)
 	if err != nil {
 		return err
 	}
-	a.couponService = couponSvc
+	a.auditRepo = auditRepo
+
+	return nil
+}
+
+// setupDomain will take the repositories already set up, and with them will
+// start all the dependency injections required for start up our business domain, the outcome
+// will be ready domain services, outbox relay and webhook dispatcher or an error
+func (a *Application) setupDomain() error {
+	couponSvc, err := service.NewCouponService(a.couponRepo, a.txManager, a.auditRepo)
+	if err != nil {
+		return err
+	}
+	a.couponService = tracing.NewCouponService(couponSvc)
 
 	scService, err := service.NewShoppingCartService(
 		a.shoppingCartRepo,
 		a.couponRepo,
+		a.txManager,
+		a.outboxRepo,
+		a.auditRepo,
 	)
 	if err != nil {
 		return err
 	}
-	a.shoppingCartService = scService
+	a.shoppingCartService = metrics.NewShoppingCartService(tracing.NewShoppingCartService(scService), a.metrics)
+
+	webhookSvc, err := service.NewWebhookService(a.webhookRepo)
+	if err != nil {
+		return err
+	}
+	a.webhookService = webhookSvc
+
+	auditSvc, err := service.NewAuditService(a.auditRepo)
+	if err != nil {
+		return err
+	}
+	a.auditService = auditSvc
+
+	// every event is queued for the webhook subscriptions before reaching the configured publisher
+	webhookPublisher, err := webhook.NewPublisher(a.webhookRepo)
+	if err != nil {
+		return err
+	}
+	relay, err := outbox.NewRelay(a.outboxRepo, outbox.NewMultiPublisher(webhookPublisher, a.outboxPublisher), a.outboxRelayConfig)
+	if err != nil {
+		return err
+	}
+	a.outboxRelay = relay
+
+	dispatcher, err := webhook.NewDispatcher(a.webhookRepo, nil, a.webhookDispatcherConfig)
+	if err != nil {
+		return err
+	}
+	a.webhookDispatcher = dispatcher
 
 	return nil
 }
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"github.com/nachoconques0/schwarz-challenge/internal/health"
+)
+
+// names of the readiness checks and background workers
+const (
+	postgresCheck           = "postgres"
+	migrationsCheck         = "migrations"
+	outboxRelayWorker       = "outbox_relay"
+	webhookDispatcherWorker = "webhook_dispatcher"
+)
+
+// setupHealth registers the readiness checks of the DB, when there is one,
+// and the background workers, reported as running by Start
+func (a *Application) setupHealth() error {
+	a.healthChecker = health.NewChecker()
+	a.healthChecker.AddWorker(outboxRelayWorker)
+	a.healthChecker.AddWorker(webhookDispatcherWorker)
+	if a.db == nil {
+		return nil
+	}
+
+	sqlDB, err := a.db.DB()
+	if err != nil {
+		return err
+	}
+	runner, err := newMigrationRunner(a.db)
+	if err != nil {
+		return err
+	}
+	a.healthChecker.AddCheck(postgresCheck, sqlDB.PingContext)
+	a.healthChecker.AddCheck(migrationsCheck, runner.Check)
+	return nil
+}
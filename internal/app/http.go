@@ -3,6 +3,7 @@ package app
 import (
 	"fmt"
 
+	"github.com/nachoconques0/schwarz-challenge/internal/abuse"
 	"github.com/nachoconques0/schwarz-challenge/internal/http"
 )
 
@@ -12,8 +13,30 @@ func (a *Application) setupHTTPServer() (err error) {
 	scCtrl := http.NewShopppingCartCtrl(a.shoppingCartService)
 	cCtrl := http.NewCouponCtrl(a.couponService)
 
+	guard, err := abuse.NewGuard(abuse.NewMemoryStore(), a.abuseGuardConfig)
+	if err != nil {
+		return fmt.Errorf("app: error setting up the abuse guard %s", err)
+	}
+
 	// a.server = &wrf.Server{}
-	res, err := http.NewServer(a.httpPort, scCtrl, cCtrl)
+	opts := []http.Option{
+		http.WithAbuseGuard(guard),
+		http.WithIdempotency(a.idempotencyStore, a.idempotencyTTL),
+		http.WithWebhooks(http.NewWebhookCtrl(a.webhookService)),
+		http.WithAudit(http.NewAuditCtrl(a.auditService)),
+		http.WithHealth(a.healthChecker),
+		http.WithMetrics(a.metrics),
+	}
+	if a.requestTimeout > 0 {
+		opts = append(opts, http.WithRequestTimeout(a.requestTimeout))
+	}
+	if a.clientIPHeader != "" {
+		opts = append(opts, http.WithClientIPHeader(a.clientIPHeader))
+	}
+	if a.staffToken != "" {
+		opts = append(opts, http.WithStaffToken(a.staffToken))
+	}
+	res, err := http.NewServer(a.httpPort, scCtrl, cCtrl, opts...)
 	if err != nil {
 		return fmt.Errorf("app: error setting up the http server %s", err)
 	}
@@ -1,12 +1,19 @@
 package app
 
 import (
+	"context"
 	"fmt"
+	"time"
 
+	"github.com/nachoconques0/schwarz-challenge/internal/migrate"
 	"github.com/nachoconques0/schwarz-challenge/internal/postgres"
+	"github.com/nachoconques0/schwarz-challenge/migrations"
 	"gorm.io/gorm"
 )
 
+// schemaCheckTimeout bounds the schema version check made at startup
+const schemaCheckTimeout = 10 * time.Second
+
 // setupInfra will start all the infra components for this service, and
 // it will return the postgres db instance
 func (a *Application) setupInfra() (*gorm.DB, error) {
@@ -17,6 +24,13 @@ func (a *Application) setupInfra() (*gorm.DB, error) {
 			User:     a.dbUser,
 			Password: a.dbPassword,
 			Database: a.dbName,
+			SSLMode:  a.dbSSLMode,
+
+			MaxOpenConns:     a.dbMaxOpenConns,
+			MaxIdleConns:     a.dbMaxIdleConns,
+			ConnMaxLifetime:  a.dbConnMaxLifetime,
+			StatementTimeout: a.dbStatementTimeout,
+			LogLevel:         a.dbLogLevel,
 		},
 	)
 	if err != nil {
@@ -24,3 +38,38 @@ func (a *Application) setupInfra() (*gorm.DB, error) {
 	}
 	return db, nil
 }
+
+// checkSchema refuses a database whose schema does not match the
+// migrations embedded in the binary
+func (a *Application) checkSchema(db *gorm.DB) error {
+	runner, err := newMigrationRunner(db)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), schemaCheckTimeout)
+	defer cancel()
+	return runner.Check(ctx)
+}
+
+// NewMigrationRunner returns the runner of the embedded migrations for the
+// database given in the options
+func NewMigrationRunner(opts ...Option) (*migrate.Runner, error) {
+	a := &Application{}
+	for _, o := range opts {
+		o(a)
+	}
+	db, err := a.setupInfra()
+	if err != nil {
+		return nil, err
+	}
+	return newMigrationRunner(db)
+}
+
+// newMigrationRunner returns the runner of the embedded migrations for db
+func newMigrationRunner(db *gorm.DB) (*migrate.Runner, error) {
+	all, err := migrate.Load(migrations.FS)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading the migrations - %w", err)
+	}
+	return migrate.NewRunner(db, all)
+}
@@ -1,5 +1,14 @@
 package app
 
+import (
+	"time"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/abuse"
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
+	"github.com/nachoconques0/schwarz-challenge/internal/tracing"
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
+)
+
 // Option defines the function used for
 // setup an application option config
 type Option func(a *Application)
@@ -12,6 +21,14 @@ func WithHTTPPort(port string) Option {
 	}
 }
 
+// WithInMemoryStorage function makes the application keep its
+// data in memory instead of postgres, so it runs with no database
+func WithInMemoryStorage() Option {
+	return func(a *Application) {
+		a.inMemoryStorage = true
+	}
+}
+
 // WithDBHost function adds the given db
 // host into the application base config
 func WithDBHost(host string) Option {
@@ -52,6 +69,54 @@ func WithDBName(name string) Option {
 	}
 }
 
+// WithDBSSLMode function adds the given db
+// SSL mode into the application base config
+func WithDBSSLMode(mode string) Option {
+	return func(a *Application) {
+		a.dbSSLMode = mode
+	}
+}
+
+// WithDBMaxOpenConns function bounds the connections
+// the application keeps open to the db at once
+func WithDBMaxOpenConns(n int) Option {
+	return func(a *Application) {
+		a.dbMaxOpenConns = n
+	}
+}
+
+// WithDBMaxIdleConns function bounds the connections
+// kept idle in the db connection pool
+func WithDBMaxIdleConns(n int) Option {
+	return func(a *Application) {
+		a.dbMaxIdleConns = n
+	}
+}
+
+// WithDBConnMaxLifetime function sets how long
+// a db connection is reused before closing it
+func WithDBConnMaxLifetime(lifetime time.Duration) Option {
+	return func(a *Application) {
+		a.dbConnMaxLifetime = lifetime
+	}
+}
+
+// WithDBStatementTimeout function makes the db abort
+// the statements running longer than the given timeout
+func WithDBStatementTimeout(timeout time.Duration) Option {
+	return func(a *Application) {
+		a.dbStatementTimeout = timeout
+	}
+}
+
+// WithDBLogLevel function sets the log level of the
+// db queries: silent, error, warn or info
+func WithDBLogLevel(level string) Option {
+	return func(a *Application) {
+		a.dbLogLevel = level
+	}
+}
+
 // WithShoppingCartHTTPEndpoint function adds the given HTTP endpoint
 // into the application base config
 func WithShoppingCartHTTPEndpoint(port string) Option {
@@ -59,3 +124,85 @@ func WithShoppingCartHTTPEndpoint(port string) Option {
 		a.ShoppingCartHTTPEndpoint = port
 	}
 }
+
+// WithAbuseGuardConfig function sets when clients failing
+// coupon lookups get locked out of applying coupons
+func WithAbuseGuardConfig(config abuse.Config) Option {
+	return func(a *Application) {
+		a.abuseGuardConfig = config
+	}
+}
+
+// WithRequestTimeout function sets how long the work of an
+// http request, DB queries included, can take before being canceled
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(a *Application) {
+		a.requestTimeout = timeout
+	}
+}
+
+// WithStaffToken function sets the secret the gateway sends
+// along the staff ID, which is rejected without it
+func WithStaffToken(token string) Option {
+	return func(a *Application) {
+		a.staffToken = token
+	}
+}
+
+// WithClientIPHeader function sets the header the trusted
+// proxy in front of the service sets with the client IP
+func WithClientIPHeader(header string) Option {
+	return func(a *Application) {
+		a.clientIPHeader = header
+	}
+}
+
+// WithIdempotencyTTL function sets how long the Idempotency-Key
+// of the retried requests are remembered
+func WithIdempotencyTTL(ttl time.Duration) Option {
+	return func(a *Application) {
+		a.idempotencyTTL = ttl
+	}
+}
+
+// WithOutboxPublisher function sets where the domain events are
+// delivered, they are logged by default
+func WithOutboxPublisher(publisher outbox.Publisher) Option {
+	return func(a *Application) {
+		a.outboxPublisher = publisher
+	}
+}
+
+// WithOutboxRelayConfig function sets how often the domain
+// events are delivered and how the failed ones are retried
+func WithOutboxRelayConfig(config outbox.RelayConfig) Option {
+	return func(a *Application) {
+		a.outboxRelayConfig = config
+	}
+}
+
+// WithWebhookDispatcherConfig function sets how often the webhook
+// deliveries are sent and how the failed ones are retried
+func WithWebhookDispatcherConfig(config webhook.DispatcherConfig) Option {
+	return func(a *Application) {
+		a.webhookDispatcherConfig = config
+	}
+}
+
+// WithDrainDelay function sets how long the application keeps serving
+// once it is reported not ready, so the load balancers drain its traffic
+// before the http server stops, DefaultDrainDelay by default and zero
+// stops it right away
+func WithDrainDelay(delay time.Duration) Option {
+	return func(a *Application) {
+		a.drainDelay = delay
+	}
+}
+
+// WithTracing function sets where the spans of the
+// requests, services and DB queries are exported
+func WithTracing(config tracing.Config) Option {
+	return func(a *Application) {
+		a.tracingConfig = config
+	}
+}
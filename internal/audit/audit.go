@@ -0,0 +1,256 @@
+// Package audit keeps an append-only log of every state change made to the
+// shopping carts and coupons. The services add the entries within the same
+// transaction as the change, so an entry is stored if and only if its change
+// is committed. Every entry records who made the change, the request doing
+// it and the entity before and after it.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/auth"
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/requestid"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+var (
+	// ErrInvalidEntry used when the entry misses its entity or action
+	ErrInvalidEntry = internalErrors.NewInternalError("audit entry must have an entity and an action")
+	// ErrUnknownEntity used when the requested entity type is not audited
+	ErrUnknownEntity = internalErrors.NewWrongInput("audit entity must be coupon or shopping_cart")
+	// ErrMissingEntityID used when the requested entity ID is not an UUID
+	ErrMissingEntityID = internalErrors.NewWrongInput("audit entity ID is invalid")
+)
+
+// Audited entities, named as the aggregates of the domain events
+const (
+	EntityCoupon       = "coupon"
+	EntityShoppingCart = "shopping_cart"
+)
+
+// Audited actions
+const (
+	ActionCreate      = "create"
+	ActionUpdate      = "update"
+	ActionDeactivate  = "deactivate"
+	ActionReactivate  = "reactivate"
+	ActionDelete      = "delete"
+	ActionImport      = "import"
+	ActionApplyCoupon = "apply_coupon"
+	ActionRedeem      = "redeem"
+)
+
+// Entities lists the audited entities
+var Entities = []string{EntityCoupon, EntityShoppingCart}
+
+// Entry is a state change stored in the audit log
+type Entry struct {
+	// ID Unique Identifier of the entry
+	ID uuid.UUID `json:"id"`
+	// Seq orders the entries as they were stored
+	Seq int64 `json:"-" gorm:"->"`
+	// EntityType names the kind of entity changed
+	EntityType string `json:"entity_type"`
+	// EntityID is the ID of the entity changed
+	EntityID uuid.UUID `json:"entity_id"`
+	// Action is the operation changing the entity
+	Action string `json:"action"`
+	// Actor identifies who made the change
+	Actor string `json:"actor"`
+	// RequestID is the ID of the request making the change, empty when not made by a request
+	RequestID string `json:"request_id,omitempty"`
+	// Before is the entity encoded as json before the change, null once created
+	Before json.RawMessage `json:"before"`
+	// After is the entity encoded as json after the change, null once deleted
+	After json.RawMessage `json:"after"`
+	// Diff holds the fields whose value changed
+	Diff Diff `json:"diff"`
+	// CreatedAt is the moment of the change
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewEntry returns the entry of the given change made by the caller of ctx,
+// before or after are nil when the change creates or deletes the entity
+func NewEntry(ctx context.Context, entityType string, entityID uuid.UUID, action string, before, after interface{}) (Entry, error) {
+	if entityType == "" || entityID == uuid.Nil || action == "" {
+		return Entry{}, ErrInvalidEntry
+	}
+	encodedBefore, err := encode(before)
+	if err != nil {
+		return Entry{}, err
+	}
+	encodedAfter, err := encode(after)
+	if err != nil {
+		return Entry{}, err
+	}
+	diff, err := NewDiff(encodedBefore, encodedAfter)
+	if err != nil {
+		return Entry{}, err
+	}
+	requestID, _ := requestid.FromContext(ctx)
+	return Entry{
+		ID:         uuid.New(),
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		Actor:      auth.Actor(ctx),
+		RequestID:  requestID,
+		Before:     encodedBefore,
+		After:      encodedAfter,
+		Diff:       diff,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// encode returns the given entity encoded as json, nil when there is no
+// entity, nil pointers included
+func encode(entity interface{}) (json.RawMessage, error) {
+	if entity == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(encoded, []byte("null")) {
+		return nil, nil
+	}
+	return encoded, nil
+}
+
+// Change is the value of a field before and after a change
+type Change struct {
+	From json.RawMessage `json:"from"`
+	To   json.RawMessage `json:"to"`
+}
+
+// Diff holds the changed fields of an entity by their json name
+type Diff map[string]Change
+
+// NewDiff compares the fields of the given json objects, a missing object
+// is empty so every field of the other one is changed
+func NewDiff(before, after json.RawMessage) (Diff, error) {
+	beforeFields, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := Diff{}
+	for name, from := range beforeFields {
+		to, ok := afterFields[name]
+		if !ok {
+			diff[name] = Change{From: from}
+			continue
+		}
+		if !sameJSON(from, to) {
+			diff[name] = Change{From: from, To: to}
+		}
+	}
+	for name, to := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			diff[name] = Change{To: to}
+		}
+	}
+	return diff, nil
+}
+
+// fields returns the fields of the given json object
+func fields(object json.RawMessage) (map[string]json.RawMessage, error) {
+	result := map[string]json.RawMessage{}
+	if len(object) == 0 {
+		return result, nil
+	}
+	if err := json.Unmarshal(object, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// sameJSON checks if both values are equal once decoded, so formatting and key order are ignored
+func sameJSON(a, b json.RawMessage) bool {
+	var decodedA, decodedB interface{}
+	if json.Unmarshal(a, &decodedA) != nil || json.Unmarshal(b, &decodedB) != nil {
+		return bytes.Equal(a, b)
+	}
+	encodedA, errA := json.Marshal(decodedA)
+	encodedB, errB := json.Marshal(decodedB)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+// Value for DB
+func (d Diff) Value() (driver.Value, error) {
+	if d == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(d)
+}
+
+// Scan will unmarshall the diff
+func (d *Diff) Scan(src interface{}) error {
+	switch t := src.(type) {
+	case string:
+		return json.Unmarshal([]byte(t), d)
+	case []byte:
+		return json.Unmarshal(t, d)
+	case nil:
+		*d = nil
+		return nil
+	}
+	return errors.New("err unmarshal audit diff")
+}
+
+// ListRequest holds the entity whose audit log is requested
+type ListRequest struct {
+	Entity string
+	ID     uuid.UUID
+}
+
+// Validate validates a list request
+func (r ListRequest) Validate() error {
+	known := false
+	for _, entity := range Entities {
+		if r.Entity == entity {
+			known = true
+		}
+	}
+	if !known {
+		return ErrUnknownEntity
+	}
+	if r.ID == uuid.Nil {
+		return ErrMissingEntityID
+	}
+	return nil
+}
+
+// Service defines the available functions for the audit service
+type Service interface {
+	// ListEntries returns the audit log of an entity, oldest change first
+	ListEntries(context.Context, ListRequest) ([]Entry, error)
+}
+
+// Repository defines the available functions for the audit repository
+type Repository interface {
+	// Add stores the entries within the transaction of the state change producing them
+	Add(context.Context, transaction.Tx, ...Entry) error
+	// List returns the entries of an entity in the order they were stored
+	List(ctx context.Context, entityType string, entityID uuid.UUID) ([]Entry, error)
+}
+
+// Server defines the available functions for the audit server
+type Server interface {
+	// ListEntries returns the audit log of the entity given in the query
+	ListEntries(w http.ResponseWriter, r *http.Request)
+}
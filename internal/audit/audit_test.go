@@ -0,0 +1,155 @@
+package audit_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
+	"github.com/nachoconques0/schwarz-challenge/internal/auth"
+	"github.com/nachoconques0/schwarz-challenge/internal/requestid"
+)
+
+type entity struct {
+	Name   string   `json:"name,omitempty"`
+	Amount float32  `json:"amount"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+func TestNewDiff(t *testing.T) {
+	testCases := map[string]struct {
+		before   string
+		after    string
+		expected audit.Diff
+	}{
+		"created": {
+			after: `{"name":"FREE30","amount":30}`,
+			expected: audit.Diff{
+				"name":   {To: json.RawMessage(`"FREE30"`)},
+				"amount": {To: json.RawMessage(`30`)},
+			},
+		},
+		"deleted": {
+			before: `{"amount":30}`,
+			expected: audit.Diff{
+				"amount": {From: json.RawMessage(`30`)},
+			},
+		},
+		"updated": {
+			before: `{"name":"FREE30","amount":30,"tags":["a","b"]}`,
+			after:  `{"amount":40, "tags":[ "a", "b" ],"code":"X"}`,
+			expected: audit.Diff{
+				"name":   {From: json.RawMessage(`"FREE30"`)},
+				"amount": {From: json.RawMessage(`30`), To: json.RawMessage(`40`)},
+				"code":   {To: json.RawMessage(`"X"`)},
+			},
+		},
+		"unchanged": {
+			before:   `{"amount":30}`,
+			after:    `{"amount":30.0}`,
+			expected: audit.Diff{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var before, after json.RawMessage
+			if tc.before != "" {
+				before = json.RawMessage(tc.before)
+			}
+			if tc.after != "" {
+				after = json.RawMessage(tc.after)
+			}
+			diff, err := audit.NewDiff(before, after)
+			require.Nil(t, err)
+			assert.Equal(t, tc.expected, diff)
+		})
+	}
+
+	t.Run("not an object", func(t *testing.T) {
+		_, err := audit.NewDiff(json.RawMessage(`[1]`), nil)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestNewEntry(t *testing.T) {
+	entityID := uuid.New()
+
+	t.Run("missing entity", func(t *testing.T) {
+		_, err := audit.NewEntry(context.Background(), audit.EntityCoupon, uuid.Nil, audit.ActionCreate, nil, entity{})
+		assert.Equal(t, audit.ErrInvalidEntry, err)
+	})
+
+	t.Run("anonymous change", func(t *testing.T) {
+		e, err := audit.NewEntry(context.Background(), audit.EntityCoupon, entityID, audit.ActionCreate, (*entity)(nil), &entity{Name: "FREE30", Amount: 30})
+		require.Nil(t, err)
+		assert.NotEqual(t, uuid.Nil, e.ID)
+		assert.Equal(t, auth.AnonymousActor, e.Actor)
+		assert.Empty(t, e.RequestID)
+		assert.Nil(t, e.Before, "nil pointers are stored as null")
+		assert.JSONEq(t, `{"name":"FREE30","amount":30}`, string(e.After))
+		assert.Len(t, e.Diff, 2)
+	})
+
+	t.Run("customer change within a request", func(t *testing.T) {
+		customerID := uuid.New()
+		ctx := requestid.WithID(auth.WithCustomerID(context.Background(), customerID), "req-1")
+		e, err := audit.NewEntry(ctx, audit.EntityShoppingCart, entityID, audit.ActionApplyCoupon, entity{Amount: 30}, entity{Amount: 20})
+		require.Nil(t, err)
+		assert.Equal(t, "customer:"+customerID.String(), e.Actor)
+		assert.Equal(t, "req-1", e.RequestID)
+		assert.Equal(t, audit.Diff{"amount": {From: json.RawMessage(`30`), To: json.RawMessage(`20`)}}, e.Diff)
+	})
+
+	t.Run("staff change", func(t *testing.T) {
+		ctx := auth.WithStaffID(auth.WithCustomerID(context.Background(), uuid.New()), "alice")
+		e, err := audit.NewEntry(ctx, audit.EntityCoupon, entityID, audit.ActionDelete, entity{}, nil)
+		require.Nil(t, err)
+		assert.Equal(t, "staff:alice", e.Actor)
+		assert.Nil(t, e.After)
+	})
+}
+
+func TestDiff_Scan(t *testing.T) {
+	diff := audit.Diff{"amount": {From: json.RawMessage(`30`), To: json.RawMessage(`40`)}}
+	value, err := diff.Value()
+	require.Nil(t, err)
+
+	var scanned audit.Diff
+	require.Nil(t, scanned.Scan(value))
+	assert.Equal(t, diff, scanned)
+
+	value, err = audit.Diff(nil).Value()
+	require.Nil(t, err)
+	assert.Equal(t, []byte("{}"), value)
+	assert.NotNil(t, scanned.Scan(1))
+}
+
+func TestListRequest_Validate(t *testing.T) {
+	testCases := map[string]struct {
+		req      audit.ListRequest
+		expected error
+	}{
+		"unknown entity": {
+			req:      audit.ListRequest{Entity: "webhook", ID: uuid.New()},
+			expected: audit.ErrUnknownEntity,
+		},
+		"missing id": {
+			req:      audit.ListRequest{Entity: audit.EntityCoupon},
+			expected: audit.ErrMissingEntityID,
+		},
+		"valid": {
+			req: audit.ListRequest{Entity: audit.EntityShoppingCart, ID: uuid.New()},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.req.Validate())
+		})
+	}
+}
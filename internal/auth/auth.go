@@ -0,0 +1,67 @@
+// Package auth carries the identity of the authenticated caller
+// through the request context so the domain can rely on it
+package auth
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// CustomerIDHeader is the header set by the gateway once the
+// customer has been authenticated
+const CustomerIDHeader = "X-Customer-ID"
+
+// StaffIDHeader is the header set by the gateway once a member
+// of the support staff has been authenticated
+const StaffIDHeader = "X-Staff-ID"
+
+// StaffTokenHeader is the header carrying the secret shared with the
+// gateway, proving the staff ID header was set by it
+const StaffTokenHeader = "X-Staff-Token"
+
+// AnonymousActor names the callers that are neither customers nor staff
+const AnonymousActor = "anonymous"
+
+type customerIDKey struct{}
+
+type staffIDKey struct{}
+
+// WithCustomerID returns a copy of ctx holding the given customer ID
+func WithCustomerID(ctx context.Context, customerID uuid.UUID) context.Context {
+	return context.WithValue(ctx, customerIDKey{}, customerID)
+}
+
+// CustomerID returns the authenticated customer ID stored in ctx, if any
+func CustomerID(ctx context.Context) (uuid.UUID, bool) {
+	customerID, ok := ctx.Value(customerIDKey{}).(uuid.UUID)
+	if !ok || customerID == uuid.Nil {
+		return uuid.Nil, false
+	}
+	return customerID, true
+}
+
+// WithStaffID returns a copy of ctx holding the given staff ID
+func WithStaffID(ctx context.Context, staffID string) context.Context {
+	return context.WithValue(ctx, staffIDKey{}, staffID)
+}
+
+// StaffID returns the authenticated staff ID stored in ctx, if any
+func StaffID(ctx context.Context) (string, bool) {
+	staffID, ok := ctx.Value(staffIDKey{}).(string)
+	if !ok || staffID == "" {
+		return "", false
+	}
+	return staffID, true
+}
+
+// Actor names the caller stored in ctx, the staff taking precedence over the customer
+func Actor(ctx context.Context) string {
+	if staffID, ok := StaffID(ctx); ok {
+		return "staff:" + staffID
+	}
+	if customerID, ok := CustomerID(ctx); ok {
+		return "customer:" + customerID.String()
+	}
+	return AnonymousActor
+}
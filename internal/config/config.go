@@ -0,0 +1,455 @@
+// Package config loads the configuration of the service from an optional
+// YAML or JSON file, overridden by the environment variables. The result
+// is validated before it is turned into the application options, so the
+// service never starts with a wrong configuration.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/app"
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/logging"
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
+	"github.com/nachoconques0/schwarz-challenge/internal/tracing"
+)
+
+// FileEnv is the environment variable holding the path of the config file
+const FileEnv = "CONFIG_FILE"
+
+// redacted replaces the secrets of the printed config
+const redacted = "******"
+
+// Storages
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
+// Outbox publishers
+const (
+	PublisherLog     = "log"
+	PublisherWebhook = "webhook"
+	PublisherFile    = "file"
+)
+
+// minStaffTokenLength keeps the staff token from being guessed
+const minStaffTokenLength = 16
+
+// webhookPublisherTimeout bounds every delivery of the webhook outbox publisher
+const webhookPublisherTimeout = 10 * time.Second
+
+var (
+	sslModes    = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	dbLogLevels = []string{"silent", "error", "warn", "info"}
+	logLevels   = []string{"debug", "info", "warn", "error"}
+)
+
+// LookupEnv returns the value of an environment variable and whether it is set
+type LookupEnv func(string) (string, bool)
+
+// Config is the configuration of the service
+type Config struct {
+	Storage string        `json:"storage" yaml:"storage"`
+	HTTP    HTTPConfig    `json:"http" yaml:"http"`
+	DB      DBConfig      `json:"db" yaml:"db"`
+	Outbox  OutboxConfig  `json:"outbox" yaml:"outbox"`
+	Tracing TracingConfig `json:"tracing" yaml:"tracing"`
+	Log     LogConfig     `json:"log" yaml:"log"`
+}
+
+// HTTPConfig configures the http server
+type HTTPConfig struct {
+	Port           string   `json:"port" yaml:"port"`
+	RequestTimeout Duration `json:"request_timeout" yaml:"request_timeout"`
+	DrainDelay     Duration `json:"drain_delay" yaml:"drain_delay"`
+	StaffToken     string   `json:"staff_token" yaml:"staff_token"`
+	ClientIPHeader string   `json:"client_ip_header" yaml:"client_ip_header"`
+}
+
+// DBConfig configures the postgres connection, the pool options
+// keep the postgres defaults when zero
+type DBConfig struct {
+	Host             string   `json:"host" yaml:"host"`
+	Port             string   `json:"port" yaml:"port"`
+	Name             string   `json:"name" yaml:"name"`
+	User             string   `json:"user" yaml:"user"`
+	Password         string   `json:"password" yaml:"password"`
+	SSLMode          string   `json:"ssl_mode" yaml:"ssl_mode"`
+	MaxOpenConns     int      `json:"max_open_conns" yaml:"max_open_conns"`
+	MaxIdleConns     int      `json:"max_idle_conns" yaml:"max_idle_conns"`
+	ConnMaxLifetime  Duration `json:"conn_max_lifetime" yaml:"conn_max_lifetime"`
+	StatementTimeout Duration `json:"statement_timeout" yaml:"statement_timeout"`
+	LogLevel         string   `json:"log_level" yaml:"log_level"`
+}
+
+// OutboxConfig configures where the domain events are published
+type OutboxConfig struct {
+	Publisher  string `json:"publisher" yaml:"publisher"`
+	WebhookURL string `json:"webhook_url" yaml:"webhook_url"`
+	File       string `json:"file" yaml:"file"`
+}
+
+// TracingConfig configures where the spans are exported
+type TracingConfig struct {
+	Exporter     string  `json:"exporter" yaml:"exporter"`
+	OTLPEndpoint string  `json:"otlp_endpoint" yaml:"otlp_endpoint"`
+	SampleRatio  float64 `json:"sample_ratio" yaml:"sample_ratio"`
+	ServiceName  string  `json:"service_name" yaml:"service_name"`
+}
+
+// LogConfig configures the log lines
+type LogConfig struct {
+	Format string `json:"format" yaml:"format"`
+	Level  string `json:"level" yaml:"level"`
+}
+
+// Default returns the config used for everything not given
+func Default() Config {
+	return Config{
+		Storage: StoragePostgres,
+		HTTP: HTTPConfig{
+			DrainDelay: Duration(app.DefaultDrainDelay),
+		},
+		Outbox: OutboxConfig{
+			Publisher: PublisherLog,
+		},
+		Tracing: TracingConfig{
+			Exporter:    tracing.ExporterNone,
+			SampleRatio: 1,
+			ServiceName: tracing.DefaultServiceName,
+		},
+		Log: LogConfig{
+			Format: logging.FormatText,
+			Level:  "info",
+		},
+	}
+}
+
+// Load returns the config of the given file, YAML or JSON by its extension,
+// overridden by the environment. No file is read when path is empty.
+func Load(path string, lookupEnv LookupEnv) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := cfg.override(lookupEnv); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// readFile decodes the given file into the config
+func (c *Config) readFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file - %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		err = decoder.Decode(c)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+	default:
+		return internalErrors.NewWrongInput(fmt.Sprintf("config file %s must be .yaml, .yml or .json", path))
+	}
+	if err != nil {
+		return fmt.Errorf("error decoding config file %s - %w", path, err)
+	}
+	return nil
+}
+
+// override sets the values given in the environment
+func (c *Config) override(lookupEnv LookupEnv) error {
+	texts := map[string]*string{
+		"STORAGE":               &c.Storage,
+		"HTTP_PORT":             &c.HTTP.Port,
+		"HTTP_STAFF_TOKEN":      &c.HTTP.StaffToken,
+		"HTTP_CLIENT_IP_HEADER": &c.HTTP.ClientIPHeader,
+		"DB_HOST":               &c.DB.Host,
+		"DB_PORT":               &c.DB.Port,
+		"DB_NAME":               &c.DB.Name,
+		"DB_USER":               &c.DB.User,
+		"DB_PASSWORD":           &c.DB.Password,
+		"DB_SSL_MODE":           &c.DB.SSLMode,
+		"DB_LOG_LEVEL":          &c.DB.LogLevel,
+		"OUTBOX_PUBLISHER":      &c.Outbox.Publisher,
+		"OUTBOX_WEBHOOK_URL":    &c.Outbox.WebhookURL,
+		"OUTBOX_FILE":           &c.Outbox.File,
+
+		"TRACING_EXPORTER":      &c.Tracing.Exporter,
+		"TRACING_OTLP_ENDPOINT": &c.Tracing.OTLPEndpoint,
+		"TRACING_SERVICE_NAME":  &c.Tracing.ServiceName,
+
+		"LOG_FORMAT": &c.Log.Format,
+		"LOG_LEVEL":  &c.Log.Level,
+	}
+	for env, field := range texts {
+		if val, ok := lookupEnv(env); ok {
+			*field = val
+		}
+	}
+
+	ints := map[string]*int{
+		"DB_MAX_OPEN_CONNS": &c.DB.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS": &c.DB.MaxIdleConns,
+	}
+	for env, field := range ints {
+		val, ok := lookupEnv(env)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return internalErrors.NewWrongInput(fmt.Sprintf("%s must be an integer, got %q", env, val))
+		}
+		*field = n
+	}
+
+	if val, ok := lookupEnv("TRACING_SAMPLE_RATIO"); ok {
+		ratio, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return internalErrors.NewWrongInput(fmt.Sprintf("TRACING_SAMPLE_RATIO must be a number, got %q", val))
+		}
+		c.Tracing.SampleRatio = ratio
+	}
+
+	durations := map[string]*Duration{
+		"HTTP_REQUEST_TIMEOUT": &c.HTTP.RequestTimeout,
+		"HTTP_DRAIN_DELAY":     &c.HTTP.DrainDelay,
+		"DB_CONN_MAX_LIFETIME": &c.DB.ConnMaxLifetime,
+		"DB_STATEMENT_TIMEOUT": &c.DB.StatementTimeout,
+	}
+	for env, field := range durations {
+		val, ok := lookupEnv(env)
+		if !ok {
+			continue
+		}
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return internalErrors.NewWrongInput(fmt.Sprintf("%s must be a duration such as 30s, got %q", env, val))
+		}
+		*field = Duration(d)
+	}
+	return nil
+}
+
+// Validate checks the required fields and the ranges of the config,
+// reporting every problem found at once
+func (c Config) Validate() error {
+	problems := []string{}
+	check := func(ok bool, problem string) {
+		if !ok {
+			problems = append(problems, problem)
+		}
+	}
+
+	check(validPort(c.HTTP.Port), "http port must be a number between 1 and 65535")
+	check(c.HTTP.RequestTimeout >= 0, "http request timeout must not be negative")
+	check(c.HTTP.DrainDelay >= 0, "http drain delay must not be negative")
+	check(c.HTTP.StaffToken == "" || len(c.HTTP.StaffToken) >= minStaffTokenLength, fmt.Sprintf("http staff token must be at least %d characters", minStaffTokenLength))
+
+	check(oneOf(c.Storage, StoragePostgres, StorageMemory), "storage must be postgres or memory")
+	if c.Storage == StoragePostgres {
+		check(c.DB.Host != "", "db host is required")
+		check(validPort(c.DB.Port), "db port must be a number between 1 and 65535")
+		check(c.DB.Name != "", "db name is required")
+		check(c.DB.User != "", "db user is required")
+		check(c.DB.Password != "", "db password is required")
+	}
+	check(c.DB.SSLMode == "" || oneOf(c.DB.SSLMode, sslModes...), "db ssl mode must be one of "+strings.Join(sslModes, ", "))
+	check(c.DB.LogLevel == "" || oneOf(c.DB.LogLevel, dbLogLevels...), "db log level must be one of "+strings.Join(dbLogLevels, ", "))
+	check(c.DB.MaxOpenConns >= 0, "db max open connections must not be negative")
+	check(c.DB.MaxOpenConns != 1, "db max open connections must be at least 2, the background loops hold one")
+	check(c.DB.MaxIdleConns >= 0, "db max idle connections must not be negative")
+	check(c.DB.MaxOpenConns == 0 || c.DB.MaxIdleConns <= c.DB.MaxOpenConns, "db max idle connections must not exceed max open connections")
+	check(c.DB.ConnMaxLifetime >= 0, "db connection max lifetime must not be negative")
+	check(c.DB.StatementTimeout == 0 || time.Duration(c.DB.StatementTimeout) >= time.Millisecond, "db statement timeout must be at least 1ms")
+
+	switch c.Outbox.Publisher {
+	case PublisherLog:
+	case PublisherWebhook:
+		check(c.Outbox.WebhookURL != "", "outbox webhook url is required by the webhook publisher")
+	case PublisherFile:
+		check(c.Outbox.File != "", "outbox file is required by the file publisher")
+	default:
+		problems = append(problems, "outbox publisher must be log, webhook or file")
+	}
+
+	check(oneOf(c.Tracing.Exporter, tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP), "tracing exporter must be none, stdout or otlp")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing sample ratio must be between 0 and 1")
+
+	check(oneOf(c.Log.Format, logging.FormatText, logging.FormatJSON), "log format must be text or json")
+	check(oneOf(c.Log.Level, logLevels...), "log level must be one of "+strings.Join(logLevels, ", "))
+
+	if len(problems) > 0 {
+		return internalErrors.NewWrongInput("invalid config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Redacted returns a copy of the config whose secrets are hidden, safe to print
+func (c Config) Redacted() Config {
+	if c.DB.Password != "" {
+		c.DB.Password = redacted
+	}
+	if c.HTTP.StaffToken != "" {
+		c.HTTP.StaffToken = redacted
+	}
+	c.Outbox.WebhookURL = redactURL(c.Outbox.WebhookURL)
+	c.Tracing.OTLPEndpoint = redactURL(c.Tracing.OTLPEndpoint)
+	return c
+}
+
+// redactURL hides the credentials of the given url
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	u.User = url.User(redacted)
+	return u.String()
+}
+
+// String returns the redacted config encoded as json
+func (c Config) String() string {
+	encoded, err := json.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("config: %s", err)
+	}
+	return string(encoded)
+}
+
+// Logger returns the logger of the log config
+func (c Config) Logger(w io.Writer) (*slog.Logger, error) {
+	return logging.NewLogger(w, c.Log.Format, c.Log.Level)
+}
+
+// DBOptions returns the application options of the postgres connection
+func (c Config) DBOptions() []app.Option {
+	return []app.Option{
+		app.WithDBHost(c.DB.Host),
+		app.WithDBPort(c.DB.Port),
+		app.WithDBName(c.DB.Name),
+		app.WithDBUser(c.DB.User),
+		app.WithDBPassword(c.DB.Password),
+		app.WithDBSSLMode(c.DB.SSLMode),
+		app.WithDBMaxOpenConns(c.DB.MaxOpenConns),
+		app.WithDBMaxIdleConns(c.DB.MaxIdleConns),
+		app.WithDBConnMaxLifetime(time.Duration(c.DB.ConnMaxLifetime)),
+		app.WithDBStatementTimeout(time.Duration(c.DB.StatementTimeout)),
+		app.WithDBLogLevel(c.DB.LogLevel),
+	}
+}
+
+// Options returns the application options of the config
+func (c Config) Options() ([]app.Option, error) {
+	publisher, err := c.outboxPublisher()
+	if err != nil {
+		return nil, fmt.Errorf("error while setting up the outbox publisher - %w", err)
+	}
+	opts := append(c.DBOptions(),
+		app.WithHTTPPort(c.HTTP.Port),
+		app.WithOutboxPublisher(publisher),
+		app.WithDrainDelay(time.Duration(c.HTTP.DrainDelay)),
+		app.WithStaffToken(c.HTTP.StaffToken),
+		app.WithClientIPHeader(c.HTTP.ClientIPHeader),
+		app.WithTracing(tracing.Config{
+			Exporter:     c.Tracing.Exporter,
+			OTLPEndpoint: c.Tracing.OTLPEndpoint,
+			SampleRatio:  c.Tracing.SampleRatio,
+			ServiceName:  c.Tracing.ServiceName,
+		}),
+	)
+	if c.HTTP.RequestTimeout > 0 {
+		opts = append(opts, app.WithRequestTimeout(time.Duration(c.HTTP.RequestTimeout)))
+	}
+	if c.Storage == StorageMemory {
+		opts = append(opts, app.WithInMemoryStorage())
+	}
+	return opts, nil
+}
+
+// outboxPublisher returns the publisher of the domain events
+func (c Config) outboxPublisher() (outbox.Publisher, error) {
+	switch c.Outbox.Publisher {
+	case PublisherLog:
+		return outbox.NewLogPublisher(), nil
+	case PublisherWebhook:
+		return outbox.NewWebhookPublisher(c.Outbox.WebhookURL, &http.Client{Timeout: webhookPublisherTimeout})
+	case PublisherFile:
+		return outbox.NewFilePublisher(c.Outbox.File)
+	}
+	return nil, fmt.Errorf("unknown outbox publisher %q", c.Outbox.Publisher)
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+func oneOf(val string, options ...string) bool {
+	for _, o := range options {
+		if val == o {
+			return true
+		}
+	}
+	return false
+}
+
+// Duration is a time.Duration written as a string such as 30s in the config files
+type Duration time.Duration
+
+// MarshalJSON encodes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decodes a duration string such as 30s
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as 30s - %w", err)
+	}
+	return d.parse(s)
+}
+
+// UnmarshalYAML decodes a duration string such as 30s
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return fmt.Errorf("duration must be a string such as 30s - %w", err)
+	}
+	return d.parse(s)
+}
+
+func (d *Duration) parse(s string) error {
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
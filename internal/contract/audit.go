@@ -0,0 +1,86 @@
+package contract
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+// TestAuditRepository verifies the given backend honors the audit.Repository contract
+func TestAuditRepository(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	t.Run("lists nothing when empty", func(t *testing.T) {
+		b := factory(t)
+		entries, err := b.AuditRepo.List(ctx, audit.EntityCoupon, uuid.New())
+		require.Nil(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("lists the committed entries of an entity in order", func(t *testing.T) {
+		b := factory(t)
+		entityID := uuid.New()
+		created := newAuditEntry(t, audit.EntityCoupon, entityID, audit.ActionCreate, nil, map[string]interface{}{"name": "FREE30"})
+		updated := newAuditEntry(t, audit.EntityCoupon, entityID, audit.ActionUpdate,
+			map[string]interface{}{"name": "FREE30"}, map[string]interface{}{"name": "FREE40"})
+		addAuditEntries(t, b, created, updated)
+		addAuditEntries(t, b, newAuditEntry(t, audit.EntityCoupon, uuid.New(), audit.ActionCreate, nil, map[string]interface{}{}))
+		addAuditEntries(t, b, newAuditEntry(t, audit.EntityShoppingCart, entityID, audit.ActionCreate, nil, map[string]interface{}{}))
+
+		entries, err := b.AuditRepo.List(ctx, audit.EntityCoupon, entityID)
+		require.Nil(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, created.ID, entries[0].ID)
+		assert.Equal(t, updated.ID, entries[1].ID)
+		assert.Less(t, entries[0].Seq, entries[1].Seq)
+
+		assert.Equal(t, audit.ActionUpdate, entries[1].Action)
+		assert.Equal(t, updated.Actor, entries[1].Actor)
+		assert.Equal(t, updated.RequestID, entries[1].RequestID)
+		assert.JSONEq(t, string(updated.Before), string(entries[1].Before))
+		assert.JSONEq(t, string(updated.After), string(entries[1].After))
+		assert.Equal(t, updated.Diff, entries[1].Diff)
+		assert.Empty(t, entries[0].Before)
+	})
+
+	t.Run("discards rolled back entries", func(t *testing.T) {
+		b := factory(t)
+		entityID := uuid.New()
+		err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			entry := newAuditEntry(t, audit.EntityShoppingCart, entityID, audit.ActionCreate, nil, map[string]interface{}{})
+			if err := b.AuditRepo.Add(ctx, tx, entry); err != nil {
+				return err
+			}
+			return audit.ErrInvalidEntry
+		})
+		assert.Equal(t, audit.ErrInvalidEntry, err)
+
+		entries, err := b.AuditRepo.List(ctx, audit.EntityShoppingCart, entityID)
+		require.Nil(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+// newAuditEntry returns an audit entry not stored yet
+func newAuditEntry(t *testing.T, entityType string, entityID uuid.UUID, action string, before, after interface{}) audit.Entry {
+	t.Helper()
+	e, err := audit.NewEntry(context.Background(), entityType, entityID, action, before, after)
+	require.Nil(t, err)
+	e.RequestID = "request-" + e.ID.String()
+	return e
+}
+
+// addAuditEntries stores the given entries within a committed transaction
+func addAuditEntries(t *testing.T, b Backend, entries ...audit.Entry) {
+	t.Helper()
+	err := b.TxManager.WithinTx(context.Background(), func(tx transaction.Tx) error {
+		return b.AuditRepo.Add(context.Background(), tx, entries...)
+	})
+	require.Nil(t, err)
+}
@@ -0,0 +1,34 @@
+// Package contract holds the behavior every storage backend must honor. Its
+// suites are run from the tests of each backend, so all of them are held to
+// the same create, list, lock, update and not found semantics.
+package contract
+
+import (
+	"testing"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	"github.com/nachoconques0/schwarz-challenge/internal/idempotency"
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
+)
+
+// concurrentWorkers is the number of goroutines racing in the concurrency checks
+const concurrentWorkers = 10
+
+// Backend holds the repositories of the storage backend under test
+type Backend struct {
+	CouponRepo       coupon.Repository
+	ShoppingCartRepo shoppingcart.Repository
+	TxManager        transaction.Manager
+	IdempotencyStore idempotency.Store
+	OutboxRepo       outbox.Repository
+	WebhookRepo      webhook.Repository
+	AuditRepo        audit.Repository
+}
+
+// Factory returns a new empty backend for every test, it is
+// expected to register its own cleanup with t.Cleanup
+type Factory func(t *testing.T) Backend
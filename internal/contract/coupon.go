@@ -0,0 +1,404 @@
+package contract
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+// TestCouponRepository verifies the given backend honors the coupon.Repository contract
+func TestCouponRepository(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	t.Run("lists nothing when empty", func(t *testing.T) {
+		b := factory(t)
+		_, err := b.CouponRepo.ListCoupons(ctx)
+		assert.Equal(t, coupon.ErrCouponNotFound, err)
+	})
+
+	t.Run("creates and lists coupons", func(t *testing.T) {
+		b := factory(t)
+		c := newCoupon("FREE30")
+		res, err := storeCoupon(b, c)
+		require.Nil(t, err)
+		assert.Equal(t, c.ID, res.ID)
+		assert.False(t, res.CreatedAt.IsZero())
+		assert.False(t, res.UpdatedAt.IsZero())
+
+		list, err := b.CouponRepo.ListCoupons(ctx)
+		require.Nil(t, err)
+		require.Len(t, list, 1)
+		assertSameCoupon(t, c, &list[0])
+	})
+
+	t.Run("rejects duplicated ids and codes", func(t *testing.T) {
+		b := factory(t)
+		c := createCoupon(t, b, "FREE30")
+
+		_, err := storeCoupon(b, c)
+		assert.NotNil(t, err)
+		_, err = storeCoupon(b, newCoupon("FREE30"))
+		assert.Equal(t, coupon.ErrCouponCodeAlreadyExists, err)
+		_, err = storeCoupon(b, newCoupon(""))
+		assert.Nil(t, err)
+		_, err = storeCoupon(b, newCoupon(""))
+		assert.Nil(t, err, "coupons without code do not clash")
+	})
+
+	t.Run("locks coupons for update", func(t *testing.T) {
+		b := factory(t)
+		c := createCoupon(t, b, "FREE30")
+
+		err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			_, err := b.CouponRepo.GetCouponForUpdate(ctx, tx, uuid.Nil)
+			assert.Equal(t, coupon.ErrCouponMissingID, err)
+			_, err = b.CouponRepo.GetCouponForUpdate(ctx, tx, uuid.New())
+			assert.Equal(t, coupon.ErrCouponNotFound, err)
+
+			res, err := b.CouponRepo.GetCouponForUpdate(ctx, tx, c.ID)
+			require.Nil(t, err)
+			assertSameCoupon(t, c, res)
+			return nil
+		})
+		assert.Nil(t, err)
+	})
+
+	t.Run("keeps committed updates", func(t *testing.T) {
+		b := factory(t)
+		c := createCoupon(t, b, "FREE30")
+
+		err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			toUpdate, err := b.CouponRepo.GetCouponForUpdate(ctx, tx, c.ID)
+			if err != nil {
+				return err
+			}
+			toUpdate.Name = "updated"
+			toUpdate.Deactivate(time.Now())
+			_, err = b.CouponRepo.UpdateCoupon(ctx, tx, toUpdate)
+			return err
+		})
+		require.Nil(t, err)
+
+		res := getCoupon(t, b, c.ID)
+		assert.Equal(t, "updated", res.Name)
+		assert.False(t, res.IsActive())
+	})
+
+	t.Run("discards rolled back updates", func(t *testing.T) {
+		b := factory(t)
+		c := createCoupon(t, b, "FREE30")
+
+		err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			toUpdate, err := b.CouponRepo.GetCouponForUpdate(ctx, tx, c.ID)
+			if err != nil {
+				return err
+			}
+			toUpdate.Name = "updated"
+			if _, err := b.CouponRepo.UpdateCoupon(ctx, tx, toUpdate); err != nil {
+				return err
+			}
+			return coupon.ErrCouponInactive
+		})
+		assert.Equal(t, coupon.ErrCouponInactive, err)
+		assert.Equal(t, c.Name, getCoupon(t, b, c.ID).Name)
+	})
+
+	t.Run("increases the version on every update", func(t *testing.T) {
+		b := factory(t)
+		c := createCoupon(t, b, "FREE30")
+		assert.Equal(t, int64(1), c.Version)
+
+		for _, expected := range []int64{2, 3} {
+			err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+				toUpdate, err := b.CouponRepo.GetCouponForUpdate(ctx, tx, c.ID)
+				if err != nil {
+					return err
+				}
+				res, err := b.CouponRepo.UpdateCoupon(ctx, tx, toUpdate)
+				if err != nil {
+					return err
+				}
+				assert.Equal(t, expected, res.Version)
+				return nil
+			})
+			require.Nil(t, err)
+			assert.Equal(t, expected, getCoupon(t, b, c.ID).Version)
+		}
+	})
+
+	t.Run("rejects updates of stale versions", func(t *testing.T) {
+		b := factory(t)
+		c := createCoupon(t, b, "FREE30")
+
+		err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			stale, err := b.CouponRepo.GetCouponForUpdate(ctx, tx, c.ID)
+			if err != nil {
+				return err
+			}
+			stale.Version--
+			stale.Name = "stale"
+			_, err = b.CouponRepo.UpdateCoupon(ctx, tx, stale)
+			assert.Equal(t, int64(0), stale.Version, "the version is kept when the update fails")
+			return err
+		})
+		assert.Equal(t, coupon.ErrCouponVersionConflict, err)
+
+		res := getCoupon(t, b, c.ID)
+		assert.Equal(t, c.Name, res.Name)
+		assert.Equal(t, int64(1), res.Version)
+	})
+
+	t.Run("soft deletes coupons", func(t *testing.T) {
+		b := factory(t)
+		c := createCoupon(t, b, "FREE30")
+		other := createCoupon(t, b, "FREE40")
+
+		err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			assert.Equal(t, coupon.ErrCouponMissingID, b.CouponRepo.DeleteCoupon(ctx, tx, &coupon.Coupon{}))
+			assert.Equal(t, coupon.ErrCouponNotFound, b.CouponRepo.DeleteCoupon(ctx, tx, newCoupon("")))
+			stale := *c
+			stale.Version--
+			assert.Equal(t, coupon.ErrCouponVersionConflict, b.CouponRepo.DeleteCoupon(ctx, tx, &stale))
+			return nil
+		})
+		require.Nil(t, err)
+		deleteCoupon(t, b, c.ID)
+		assert.Equal(t, coupon.ErrCouponNotFound, b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			return b.CouponRepo.DeleteCoupon(ctx, tx, c)
+		}), "the coupon is deleted already")
+
+		list, err := b.CouponRepo.ListCoupons(ctx)
+		require.Nil(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, other.ID, list[0].ID)
+
+		err = b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			_, err := b.CouponRepo.GetCouponForUpdate(ctx, tx, c.ID)
+			return err
+		})
+		assert.Equal(t, coupon.ErrCouponNotFound, err)
+
+		_, err = storeCoupon(b, newCoupon("FREE30"))
+		assert.Nil(t, err, "the code of a deleted coupon is free again")
+	})
+
+	t.Run("counts redemptions", func(t *testing.T) {
+		b := factory(t)
+		c := createCoupon(t, b, "FREE30")
+		createRedeemedCart(t, b, c.ID, uuid.Nil, time.Now())
+		createRedeemedCart(t, b, c.ID, uuid.Nil, time.Now())
+		createRedeemedCart(t, b, uuid.New(), uuid.Nil, time.Now())
+
+		err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			count, err := b.CouponRepo.CountCouponRedemptions(ctx, tx, c.ID)
+			assert.Equal(t, int64(2), count)
+			return err
+		})
+		assert.Nil(t, err)
+	})
+
+	t.Run("aggregates stats", func(t *testing.T) {
+		b := factory(t)
+		c := createCoupon(t, b, "FREE30")
+		day := time.Date(2024, time.June, 10, 10, 0, 0, 0, time.UTC)
+		createRedeemedCart(t, b, c.ID, uuid.Nil, day)
+		createRedeemedCart(t, b, c.ID, uuid.Nil, day.Add(time.Hour))
+		createRedeemedCart(t, b, c.ID, uuid.Nil, day.AddDate(0, 0, 1))
+
+		_, err := b.CouponRepo.GetCouponStats(ctx, uuid.Nil, coupon.StatsRequest{Interval: coupon.StatsIntervalDay})
+		assert.Equal(t, coupon.ErrCouponMissingID, err)
+		_, err = b.CouponRepo.GetCouponStats(ctx, uuid.New(), coupon.StatsRequest{Interval: coupon.StatsIntervalDay})
+		assert.Equal(t, coupon.ErrCouponNotFound, err)
+
+		stats, err := b.CouponRepo.GetCouponStats(ctx, c.ID, coupon.StatsRequest{Interval: coupon.StatsIntervalDay})
+		require.Nil(t, err)
+		assert.Equal(t, int64(3), stats.Redemptions)
+		assert.InDelta(t, 30, stats.TotalDiscount, 0.01)
+		assert.InDelta(t, 100, stats.AverageCartValue, 0.01)
+		require.Len(t, stats.Buckets, 2)
+		assert.Equal(t, int64(2), stats.Buckets[0].Redemptions)
+		assert.Equal(t, int64(1), stats.Buckets[1].Redemptions)
+
+		from := day.AddDate(0, 0, 1)
+		stats, err = b.CouponRepo.GetCouponStats(ctx, c.ID, coupon.StatsRequest{Interval: coupon.StatsIntervalDay, From: &from})
+		require.Nil(t, err)
+		assert.Equal(t, int64(1), stats.Redemptions)
+	})
+
+	t.Run("imports coupons discarding only the failing ones", func(t *testing.T) {
+		b := factory(t)
+		createCoupon(t, b, "FREE30")
+
+		err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			_, err := b.CouponRepo.ImportCoupon(ctx, tx, newCoupon("FREE30"))
+			assert.Equal(t, coupon.ErrCouponCodeAlreadyExists, err)
+			_, err = b.CouponRepo.ImportCoupon(ctx, tx, newCoupon("FREE40"))
+			return err
+		})
+		require.Nil(t, err)
+
+		list, err := b.CouponRepo.ListCoupons(ctx)
+		require.Nil(t, err)
+		assert.Len(t, list, 2)
+	})
+
+	t.Run("exports every coupon not deleted", func(t *testing.T) {
+		b := factory(t)
+		createCoupon(t, b, "FREE30")
+		createCoupon(t, b, "FREE40")
+		deleted := createCoupon(t, b, "FREE50")
+		deleteCoupon(t, b, deleted.ID)
+
+		exported := map[uuid.UUID]bool{}
+		err := b.CouponRepo.ExportCoupons(ctx, func(c coupon.Coupon) error {
+			exported[c.ID] = true
+			return nil
+		})
+		require.Nil(t, err)
+		assert.Len(t, exported, 2)
+		assert.False(t, exported[deleted.ID])
+	})
+
+	t.Run("serializes concurrent updates of a locked coupon", func(t *testing.T) {
+		b := factory(t)
+		c := createCoupon(t, b, "FREE30")
+
+		var wg sync.WaitGroup
+		for i := 0; i < concurrentWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+					toUpdate, err := b.CouponRepo.GetCouponForUpdate(ctx, tx, c.ID)
+					if err != nil {
+						return err
+					}
+					toUpdate.Amount++
+					_, err = b.CouponRepo.UpdateCoupon(ctx, tx, toUpdate)
+					return err
+				})
+				assert.Nil(t, err)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, c.Amount+concurrentWorkers, getCoupon(t, b, c.ID).Amount, "no update is lost")
+	})
+
+	t.Run("stops waiting for a lock once the context is done", func(t *testing.T) {
+		b := factory(t)
+		c := createCoupon(t, b, "FREE30")
+
+		locked := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+				_, err := b.CouponRepo.GetCouponForUpdate(ctx, tx, c.ID)
+				close(locked)
+				<-release
+				return err
+			})
+		}()
+		<-locked
+		defer func() {
+			close(release)
+			<-done
+		}()
+
+		waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		err := b.TxManager.WithinTx(waitCtx, func(tx transaction.Tx) error {
+			_, err := b.CouponRepo.GetCouponForUpdate(waitCtx, tx, c.ID)
+			return err
+		})
+		assert.NotNil(t, err)
+	})
+}
+
+// newCoupon returns a coupon not stored yet
+func newCoupon(code string) *coupon.Coupon {
+	return coupon.New(coupon.CreateRequest{Name: "coupon " + code, Code: code, Amount: 10})
+}
+
+// createCoupon stores a new coupon with the given code
+func createCoupon(t *testing.T, b Backend, code string) *coupon.Coupon {
+	t.Helper()
+	c, err := storeCoupon(b, newCoupon(code))
+	require.Nil(t, err)
+	return c
+}
+
+// storeCoupon creates the given coupon within its own transaction, a savepoint
+// when the backend already runs within one, so a failed insert does not abort
+// the statements that follow
+func storeCoupon(b Backend, c *coupon.Coupon) (*coupon.Coupon, error) {
+	ctx := context.Background()
+	var res *coupon.Coupon
+	err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		var err error
+		res, err = b.CouponRepo.CreateCoupon(ctx, tx, c)
+		return err
+	})
+	return res, err
+}
+
+// getCoupon returns the stored coupon with the given ID
+func getCoupon(t *testing.T, b Backend, couponID uuid.UUID) *coupon.Coupon {
+	t.Helper()
+	list, err := b.CouponRepo.ListCoupons(context.Background())
+	require.Nil(t, err)
+	for _, c := range list {
+		if c.ID == couponID {
+			return &c
+		}
+	}
+	require.FailNow(t, "coupon not found")
+	return nil
+}
+
+// deleteCoupon soft deletes the coupon with the given ID
+func deleteCoupon(t *testing.T, b Backend, couponID uuid.UUID) {
+	t.Helper()
+	ctx := context.Background()
+	err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		c, err := b.CouponRepo.GetCouponForUpdate(ctx, tx, couponID)
+		if err != nil {
+			return err
+		}
+		return b.CouponRepo.DeleteCoupon(ctx, tx, c)
+	})
+	require.Nil(t, err)
+}
+
+// createRedeemedCart stores a shopping cart of 100 with a discount of 10 by the given coupon
+func createRedeemedCart(t *testing.T, b Backend, couponID uuid.UUID, customerID uuid.UUID, appliedAt time.Time) *shoppingcart.ShoppingCart {
+	t.Helper()
+	sc := newShoppingCart(customerID)
+	require.Nil(t, sc.ApplyCoupon(couponID, 10))
+	sc.CouponAppliedAt = &appliedAt
+	res, err := storeShoppingCart(b, sc)
+	require.Nil(t, err)
+	return res
+}
+
+// assertSameCoupon compares the stored fields of two coupons
+func assertSameCoupon(t *testing.T, expected *coupon.Coupon, actual *coupon.Coupon) {
+	t.Helper()
+	assert.Equal(t, expected.ID, actual.ID)
+	assert.Equal(t, expected.Name, actual.Name)
+	assert.Equal(t, expected.Code, actual.Code)
+	assert.Equal(t, expected.Type, actual.Type)
+	assert.Equal(t, expected.Amount, actual.Amount)
+	assert.Equal(t, expected.Used, actual.Used)
+}
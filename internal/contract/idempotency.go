@@ -0,0 +1,195 @@
+package contract
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/idempotency"
+)
+
+// TestIdempotencyStore verifies the given backend honors the idempotency.Store contract
+func TestIdempotencyStore(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	t.Run("reserves unused keys once", func(t *testing.T) {
+		b := factory(t)
+		rec := newIdempotencyRecord("customer:1")
+
+		stored, err := b.IdempotencyStore.Reserve(ctx, rec, rec.CreatedAt.Add(-time.Hour), rec.CreatedAt.Add(-time.Hour))
+		require.Nil(t, err)
+		assert.Nil(t, stored)
+
+		stored, err = b.IdempotencyStore.Reserve(ctx, rec, rec.CreatedAt.Add(-time.Hour), rec.CreatedAt.Add(-time.Hour))
+		require.Nil(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, rec.Fingerprint, stored.Fingerprint)
+		assert.False(t, stored.IsCompleted())
+	})
+
+	t.Run("returns the completed response", func(t *testing.T) {
+		b := factory(t)
+		rec := reserveIdempotencyRecord(t, b, "customer:1")
+		completeIdempotencyRecord(t, b, rec)
+
+		stored, err := b.IdempotencyStore.Reserve(ctx, rec, rec.CreatedAt.Add(-time.Hour), rec.CreatedAt.Add(-time.Hour))
+		require.Nil(t, err)
+		require.NotNil(t, stored)
+		assert.True(t, stored.IsCompleted())
+		assert.Equal(t, http.StatusOK, stored.StatusCode)
+		assert.Equal(t, []byte(`{"id":1}`), stored.Body)
+		assert.Equal(t, "application/json", http.Header(stored.Header).Get("Content-Type"))
+		assert.NotNil(t, stored.CompletedAt)
+	})
+
+	t.Run("isolates the keys of every scope", func(t *testing.T) {
+		b := factory(t)
+		rec := reserveIdempotencyRecord(t, b, "customer:1")
+
+		other := rec
+		other.Scope = "customer:2"
+		stored, err := b.IdempotencyStore.Reserve(ctx, other, rec.CreatedAt.Add(-time.Hour), rec.CreatedAt.Add(-time.Hour))
+		require.Nil(t, err)
+		assert.Nil(t, stored)
+	})
+
+	t.Run("reserves expired keys again", func(t *testing.T) {
+		b := factory(t)
+		rec := reserveIdempotencyRecord(t, b, "customer:1")
+		completeIdempotencyRecord(t, b, rec)
+
+		retry := rec
+		retry.Fingerprint = "other"
+		retry.CreatedAt = rec.CreatedAt.Add(2 * time.Hour)
+		stored, err := b.IdempotencyStore.Reserve(ctx, retry, rec.CreatedAt.Add(time.Hour), rec.CreatedAt.Add(time.Hour))
+		require.Nil(t, err)
+		assert.Nil(t, stored)
+
+		stored, err = b.IdempotencyStore.Reserve(ctx, retry, rec.CreatedAt.Add(time.Hour), rec.CreatedAt.Add(time.Hour))
+		require.Nil(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, "other", stored.Fingerprint)
+		assert.False(t, stored.IsCompleted())
+	})
+
+	t.Run("takes over abandoned keys in progress only", func(t *testing.T) {
+		b := factory(t)
+		rec := reserveIdempotencyRecord(t, b, "customer:1")
+		expiredBefore := rec.CreatedAt.Add(-time.Hour)
+
+		retry := rec
+		retry.CreatedAt = rec.CreatedAt.Add(time.Minute)
+		stored, err := b.IdempotencyStore.Reserve(ctx, retry, expiredBefore, rec.CreatedAt.Add(time.Second))
+		require.Nil(t, err)
+		assert.Nil(t, stored, "the lease of the first request is over")
+
+		completeIdempotencyRecord(t, b, retry)
+		stored, err = b.IdempotencyStore.Reserve(ctx, retry, expiredBefore, retry.CreatedAt.Add(time.Second))
+		require.Nil(t, err)
+		require.NotNil(t, stored)
+		assert.True(t, stored.IsCompleted(), "completed keys are kept until they expire")
+	})
+
+	t.Run("sweeps expired keys", func(t *testing.T) {
+		b := factory(t)
+		expired := reserveIdempotencyRecord(t, b, "customer:1")
+		completeIdempotencyRecord(t, b, expired)
+		kept := newIdempotencyRecord("customer:1")
+		kept.CreatedAt = expired.CreatedAt.Add(2 * time.Hour)
+		stored, err := b.IdempotencyStore.Reserve(ctx, kept, kept.CreatedAt.Add(-time.Hour), kept.CreatedAt.Add(-time.Hour))
+		require.Nil(t, err)
+		require.Nil(t, stored)
+
+		require.Nil(t, b.IdempotencyStore.Sweep(ctx, expired.CreatedAt.Add(time.Hour)))
+
+		// with nothing expired for the store, only swept keys can be reserved again
+		farPast := expired.CreatedAt.Add(-time.Hour)
+		stored, err = b.IdempotencyStore.Reserve(ctx, expired, farPast, farPast)
+		require.Nil(t, err)
+		assert.Nil(t, stored, "the expired key was swept")
+		stored, err = b.IdempotencyStore.Reserve(ctx, kept, farPast, farPast)
+		require.Nil(t, err)
+		assert.NotNil(t, stored, "the key reserved later is kept")
+	})
+
+	t.Run("releases keys in progress only", func(t *testing.T) {
+		b := factory(t)
+		rec := reserveIdempotencyRecord(t, b, "customer:1")
+		require.Nil(t, b.IdempotencyStore.Release(ctx, rec.Scope, rec.Key))
+
+		stored, err := b.IdempotencyStore.Reserve(ctx, rec, rec.CreatedAt.Add(-time.Hour), rec.CreatedAt.Add(-time.Hour))
+		require.Nil(t, err)
+		assert.Nil(t, stored, "released keys can be reserved again")
+
+		completeIdempotencyRecord(t, b, rec)
+		require.Nil(t, b.IdempotencyStore.Release(ctx, rec.Scope, rec.Key))
+		stored, err = b.IdempotencyStore.Reserve(ctx, rec, rec.CreatedAt.Add(-time.Hour), rec.CreatedAt.Add(-time.Hour))
+		require.Nil(t, err)
+		require.NotNil(t, stored)
+		assert.True(t, stored.IsCompleted())
+	})
+
+	t.Run("reserves a key once under concurrent requests", func(t *testing.T) {
+		b := factory(t)
+		rec := newIdempotencyRecord("customer:1")
+
+		var wg sync.WaitGroup
+		reserved := make(chan bool, concurrentWorkers)
+		for i := 0; i < concurrentWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				stored, err := b.IdempotencyStore.Reserve(ctx, rec, rec.CreatedAt.Add(-time.Hour), rec.CreatedAt.Add(-time.Hour))
+				assert.Nil(t, err)
+				reserved <- err == nil && stored == nil
+			}()
+		}
+		wg.Wait()
+		close(reserved)
+
+		count := 0
+		for ok := range reserved {
+			if ok {
+				count++
+			}
+		}
+		assert.Equal(t, 1, count)
+	})
+}
+
+// newIdempotencyRecord returns a record not reserved yet
+func newIdempotencyRecord(scope string) idempotency.Record {
+	return idempotency.Record{
+		Scope:       scope,
+		Key:         uuid.NewString(),
+		Fingerprint: idempotency.Fingerprint(http.MethodPost, "/shopping-cart", []byte(`{}`)),
+		CreatedAt:   time.Now(),
+	}
+}
+
+// reserveIdempotencyRecord reserves a new record of the given scope
+func reserveIdempotencyRecord(t *testing.T, b Backend, scope string) idempotency.Record {
+	t.Helper()
+	rec := newIdempotencyRecord(scope)
+	stored, err := b.IdempotencyStore.Reserve(context.Background(), rec, rec.CreatedAt.Add(-time.Hour), rec.CreatedAt.Add(-time.Hour))
+	require.Nil(t, err)
+	require.Nil(t, stored)
+	return rec
+}
+
+// completeIdempotencyRecord records a 200 json response for the given record
+func completeIdempotencyRecord(t *testing.T, b Backend, rec idempotency.Record) {
+	t.Helper()
+	completedAt := time.Now()
+	rec.StatusCode = http.StatusOK
+	rec.Header = idempotency.Header{"Content-Type": {"application/json"}}
+	rec.Body = []byte(`{"id":1}`)
+	rec.CompletedAt = &completedAt
+	require.Nil(t, b.IdempotencyStore.Complete(context.Background(), rec))
+}
@@ -0,0 +1,189 @@
+package contract
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+// TestOutboxRepository verifies the given backend honors the outbox.Repository contract
+func TestOutboxRepository(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	t.Run("returns committed events in order", func(t *testing.T) {
+		b := factory(t)
+		aggregateID := uuid.New()
+		first := newOutboxEvent(t, aggregateID, "first")
+		second := newOutboxEvent(t, aggregateID, "second")
+		third := newOutboxEvent(t, uuid.New(), "third")
+		addOutboxEvents(t, b, first, second)
+		addOutboxEvents(t, b, third)
+
+		events, err := b.OutboxRepo.Pending(ctx, time.Now(), 10)
+		require.Nil(t, err)
+		require.Len(t, events, 3)
+		assert.Equal(t, first.ID, events[0].ID)
+		assert.Equal(t, second.ID, events[1].ID)
+		assert.Equal(t, third.ID, events[2].ID)
+		assert.Equal(t, aggregateID, events[0].AggregateID)
+		assert.JSONEq(t, string(first.Payload), string(events[0].Payload))
+		assert.Less(t, events[0].Seq, events[1].Seq)
+
+		events, err = b.OutboxRepo.Pending(ctx, time.Now(), 2)
+		require.Nil(t, err)
+		assert.Len(t, events, 2)
+	})
+
+	t.Run("discards rolled back events", func(t *testing.T) {
+		b := factory(t)
+		err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			if err := b.OutboxRepo.Add(ctx, tx, newOutboxEvent(t, uuid.New(), "rolled back")); err != nil {
+				return err
+			}
+			return outbox.ErrInvalidEvent
+		})
+		assert.Equal(t, outbox.ErrInvalidEvent, err)
+
+		events, err := b.OutboxRepo.Pending(ctx, time.Now(), 10)
+		require.Nil(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("hides published events", func(t *testing.T) {
+		b := factory(t)
+		published := newOutboxEvent(t, uuid.New(), "published")
+		pending := newOutboxEvent(t, uuid.New(), "pending")
+		addOutboxEvents(t, b, published, pending)
+
+		require.Nil(t, b.OutboxRepo.MarkPublished(ctx, published.ID, time.Now()))
+		events, err := b.OutboxRepo.Pending(ctx, time.Now(), 10)
+		require.Nil(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, pending.ID, events[0].ID)
+	})
+
+	t.Run("records failed deliveries", func(t *testing.T) {
+		b := factory(t)
+		e := newOutboxEvent(t, uuid.New(), "failed")
+		addOutboxEvents(t, b, e)
+
+		next := time.Now().Add(time.Minute)
+		require.Nil(t, b.OutboxRepo.MarkFailed(ctx, e.ID, next, "boom"))
+		require.Nil(t, b.OutboxRepo.MarkFailed(ctx, e.ID, next, "boom again"))
+
+		events, err := b.OutboxRepo.Pending(ctx, time.Now(), 10)
+		require.Nil(t, err)
+		assert.Empty(t, events, "the event is not due yet")
+
+		events, err = b.OutboxRepo.Pending(ctx, next, 10)
+		require.Nil(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, 2, events[0].Attempts)
+		assert.Equal(t, "boom again", events[0].LastError)
+		assert.WithinDuration(t, next, events[0].NextAttemptAt, time.Millisecond)
+	})
+
+	t.Run("holds back the aggregates of events not due", func(t *testing.T) {
+		b := factory(t)
+		aggregateID := uuid.New()
+		failed := newOutboxEvent(t, aggregateID, "failed")
+		later := newOutboxEvent(t, aggregateID, "later")
+		other := newOutboxEvent(t, uuid.New(), "other")
+		addOutboxEvents(t, b, failed, later, other)
+		require.Nil(t, b.OutboxRepo.MarkFailed(ctx, failed.ID, time.Now().Add(time.Minute), "boom"))
+
+		events, err := b.OutboxRepo.Pending(ctx, time.Now(), 10)
+		require.Nil(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, other.ID, events[0].ID)
+	})
+
+	t.Run("hides dead events", func(t *testing.T) {
+		b := factory(t)
+		aggregateID := uuid.New()
+		dead := newOutboxEvent(t, aggregateID, "dead")
+		later := newOutboxEvent(t, aggregateID, "later")
+		addOutboxEvents(t, b, dead, later)
+
+		require.Nil(t, b.OutboxRepo.MarkDead(ctx, dead.ID, time.Now(), "boom"))
+		events, err := b.OutboxRepo.Pending(ctx, time.Now().Add(time.Hour), 10)
+		require.Nil(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, later.ID, events[0].ID, "a dead event does not hold back its aggregate")
+	})
+
+	t.Run("fails to mark unknown events", func(t *testing.T) {
+		b := factory(t)
+		assert.NotNil(t, b.OutboxRepo.MarkPublished(ctx, uuid.New(), time.Now()))
+		assert.NotNil(t, b.OutboxRepo.MarkFailed(ctx, uuid.New(), time.Now(), "boom"))
+		assert.NotNil(t, b.OutboxRepo.MarkDead(ctx, uuid.New(), time.Now(), "boom"))
+	})
+
+	t.Run("claims the events for a single relay", func(t *testing.T) {
+		b := factory(t)
+		aggregateID := uuid.New()
+		first := newOutboxEvent(t, aggregateID, "first")
+		second := newOutboxEvent(t, aggregateID, "second")
+		addOutboxEvents(t, b, first, second)
+
+		now := time.Now()
+		until := now.Add(time.Minute)
+		claimed, err := b.OutboxRepo.Claim(ctx, now, until, 1)
+		require.Nil(t, err)
+		require.Len(t, claimed, 1)
+		assert.Equal(t, first.ID, claimed[0].ID)
+		assert.WithinDuration(t, until, claimed[0].NextAttemptAt, time.Millisecond)
+
+		claimed, err = b.OutboxRepo.Claim(ctx, now, until, 10)
+		require.Nil(t, err)
+		assert.Empty(t, claimed, "the aggregate of a claimed event is held back")
+
+		claimed, err = b.OutboxRepo.Claim(ctx, until, until.Add(time.Minute), 10)
+		require.Nil(t, err)
+		require.Len(t, claimed, 2, "the claim is over")
+		assert.Equal(t, first.ID, claimed[0].ID)
+	})
+
+	t.Run("releases claimed events", func(t *testing.T) {
+		b := factory(t)
+		e := newOutboxEvent(t, uuid.New(), "released")
+		addOutboxEvents(t, b, e)
+
+		now := time.Now()
+		claimed, err := b.OutboxRepo.Claim(ctx, now, now.Add(time.Hour), 10)
+		require.Nil(t, err)
+		require.Len(t, claimed, 1)
+
+		require.Nil(t, b.OutboxRepo.Release(ctx, e.ID, now))
+		claimed, err = b.OutboxRepo.Claim(ctx, now, now.Add(time.Hour), 10)
+		require.Nil(t, err)
+		require.Len(t, claimed, 1)
+		assert.Equal(t, e.ID, claimed[0].ID)
+		assert.NotNil(t, b.OutboxRepo.Release(ctx, uuid.New(), now))
+	})
+}
+
+// newOutboxEvent returns an event of the given aggregate not stored yet
+func newOutboxEvent(t *testing.T, aggregateID uuid.UUID, name string) outbox.Event {
+	t.Helper()
+	e, err := outbox.NewEvent("test", aggregateID, "test.happened", map[string]string{"name": name})
+	require.Nil(t, err)
+	return e
+}
+
+// addOutboxEvents stores the given events within their own transaction
+func addOutboxEvents(t *testing.T, b Backend, events ...outbox.Event) {
+	t.Helper()
+	ctx := context.Background()
+	err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		return b.OutboxRepo.Add(ctx, tx, events...)
+	})
+	require.Nil(t, err)
+}
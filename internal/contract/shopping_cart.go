@@ -0,0 +1,264 @@
+package contract
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+// TestShoppingCartRepository verifies the given backend honors the shoppingcart.Repository contract
+func TestShoppingCartRepository(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	t.Run("lists nothing when empty", func(t *testing.T) {
+		b := factory(t)
+		_, err := b.ShoppingCartRepo.ListShoppingCarts(ctx)
+		assert.Equal(t, shoppingcart.ErrShoppingCartsNotFound, err)
+	})
+
+	t.Run("creates and lists shopping carts", func(t *testing.T) {
+		b := factory(t)
+		sc := newShoppingCart(uuid.New())
+		res, err := storeShoppingCart(b, sc)
+		require.Nil(t, err)
+		assert.Equal(t, sc.ID, res.ID)
+		assert.False(t, res.CreatedAt.IsZero())
+
+		list, err := b.ShoppingCartRepo.ListShoppingCarts(ctx)
+		require.Nil(t, err)
+		require.Len(t, list, 1)
+		assertSameShoppingCart(t, sc, &list[0])
+	})
+
+	t.Run("rejects duplicated ids", func(t *testing.T) {
+		b := factory(t)
+		sc := createShoppingCart(t, b)
+		_, err := storeShoppingCart(b, sc)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("discards rolled back creations", func(t *testing.T) {
+		b := factory(t)
+		err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			if _, err := b.ShoppingCartRepo.CreateShoppingCart(ctx, tx, newShoppingCart(uuid.New())); err != nil {
+				return err
+			}
+			return shoppingcart.ErrShoppingCartEmptyItems
+		})
+		assert.Equal(t, shoppingcart.ErrShoppingCartEmptyItems, err)
+
+		_, err = b.ShoppingCartRepo.ListShoppingCarts(ctx)
+		assert.Equal(t, shoppingcart.ErrShoppingCartsNotFound, err)
+	})
+
+	t.Run("locks shopping carts for update", func(t *testing.T) {
+		b := factory(t)
+		sc := createShoppingCart(t, b)
+
+		err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			_, err := b.ShoppingCartRepo.GetShoppingCartForUpdate(ctx, tx, uuid.New())
+			assert.Equal(t, shoppingcart.ErrShoppingCartNotFound, err)
+
+			res, err := b.ShoppingCartRepo.GetShoppingCartForUpdate(ctx, tx, sc.ID)
+			require.Nil(t, err)
+			assertSameShoppingCart(t, sc, res)
+			return nil
+		})
+		assert.Nil(t, err)
+	})
+
+	t.Run("keeps committed updates", func(t *testing.T) {
+		b := factory(t)
+		sc := createShoppingCart(t, b)
+		couponID := uuid.New()
+
+		err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			toUpdate, err := b.ShoppingCartRepo.GetShoppingCartForUpdate(ctx, tx, sc.ID)
+			if err != nil {
+				return err
+			}
+			if err := toUpdate.ApplyCoupon(couponID, 10); err != nil {
+				return err
+			}
+			_, err = b.ShoppingCartRepo.UpdateShoppingCart(ctx, tx, toUpdate)
+			return err
+		})
+		require.Nil(t, err)
+
+		res := getShoppingCart(t, b, sc.ID)
+		assert.Equal(t, couponID, res.CouponID)
+		assert.Equal(t, float32(90), res.Total)
+		assert.NotNil(t, res.CouponAppliedAt)
+	})
+
+	t.Run("discards rolled back updates", func(t *testing.T) {
+		b := factory(t)
+		sc := createShoppingCart(t, b)
+
+		err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			toUpdate, err := b.ShoppingCartRepo.GetShoppingCartForUpdate(ctx, tx, sc.ID)
+			if err != nil {
+				return err
+			}
+			if err := toUpdate.ApplyCoupon(uuid.New(), 10); err != nil {
+				return err
+			}
+			if _, err := b.ShoppingCartRepo.UpdateShoppingCart(ctx, tx, toUpdate); err != nil {
+				return err
+			}
+			return shoppingcart.ErrShoppinCartCouponAlreadyApplied
+		})
+		assert.Equal(t, shoppingcart.ErrShoppinCartCouponAlreadyApplied, err)
+
+		res := getShoppingCart(t, b, sc.ID)
+		assert.Equal(t, uuid.Nil, res.CouponID)
+		assert.Equal(t, sc.Total, res.Total)
+	})
+
+	t.Run("rejects updates of stale versions", func(t *testing.T) {
+		b := factory(t)
+		sc := createShoppingCart(t, b)
+		assert.Equal(t, int64(1), sc.Version)
+
+		err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			toUpdate, err := b.ShoppingCartRepo.GetShoppingCartForUpdate(ctx, tx, sc.ID)
+			if err != nil {
+				return err
+			}
+			res, err := b.ShoppingCartRepo.UpdateShoppingCart(ctx, tx, toUpdate)
+			if err != nil {
+				return err
+			}
+			assert.Equal(t, int64(2), res.Version)
+			return nil
+		})
+		require.Nil(t, err)
+
+		err = b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			_, err := b.ShoppingCartRepo.UpdateShoppingCart(ctx, tx, sc)
+			return err
+		})
+		assert.Equal(t, shoppingcart.ErrShoppingCartVersionConflict, err)
+		assert.Equal(t, int64(2), getShoppingCart(t, b, sc.ID).Version)
+	})
+
+	t.Run("counts the coupon uses of a customer", func(t *testing.T) {
+		b := factory(t)
+		customerID := uuid.New()
+		couponID := uuid.New()
+		createRedeemedCart(t, b, couponID, customerID, time.Now())
+		createRedeemedCart(t, b, couponID, customerID, time.Now())
+		createRedeemedCart(t, b, couponID, uuid.New(), time.Now())
+		createRedeemedCart(t, b, uuid.New(), customerID, time.Now())
+
+		err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+			count, err := b.ShoppingCartRepo.CountCustomerCouponUses(ctx, tx, customerID, couponID)
+			assert.Equal(t, int64(2), count)
+			return err
+		})
+		assert.Nil(t, err)
+	})
+
+	t.Run("applies a coupon once under concurrent updates", func(t *testing.T) {
+		b := factory(t)
+		sc := createShoppingCart(t, b)
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var applied int
+		for i := 0; i < concurrentWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+					toUpdate, err := b.ShoppingCartRepo.GetShoppingCartForUpdate(ctx, tx, sc.ID)
+					if err != nil {
+						return err
+					}
+					if err := toUpdate.ApplyCoupon(uuid.New(), 10); err != nil {
+						return err
+					}
+					_, err = b.ShoppingCartRepo.UpdateShoppingCart(ctx, tx, toUpdate)
+					return err
+				})
+				if err == nil {
+					mu.Lock()
+					applied++
+					mu.Unlock()
+					return
+				}
+				assert.Equal(t, shoppingcart.ErrShoppinCartCouponAlreadyApplied, err)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 1, applied)
+		assert.Equal(t, float32(90), getShoppingCart(t, b, sc.ID).Total)
+	})
+}
+
+// newShoppingCart returns a shopping cart of 100 not stored yet
+func newShoppingCart(customerID uuid.UUID) *shoppingcart.ShoppingCart {
+	return shoppingcart.New(shoppingcart.CreateRequest{
+		Items: shoppingcart.Items{
+			{Name: "item", Description: "description", Price: 60},
+			{Name: "other item", Description: "description", Price: 40},
+		},
+		CustomerID: customerID,
+	})
+}
+
+// createShoppingCart stores a new shopping cart of 100
+func createShoppingCart(t *testing.T, b Backend) *shoppingcart.ShoppingCart {
+	t.Helper()
+	sc, err := storeShoppingCart(b, newShoppingCart(uuid.New()))
+	require.Nil(t, err)
+	return sc
+}
+
+// storeShoppingCart creates the given shopping cart within its own transaction,
+// a savepoint when the backend already runs within one, so a failed insert
+// does not abort the statements that follow
+func storeShoppingCart(b Backend, sc *shoppingcart.ShoppingCart) (*shoppingcart.ShoppingCart, error) {
+	ctx := context.Background()
+	var res *shoppingcart.ShoppingCart
+	err := b.TxManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		var err error
+		res, err = b.ShoppingCartRepo.CreateShoppingCart(ctx, tx, sc)
+		return err
+	})
+	return res, err
+}
+
+// getShoppingCart returns the stored shopping cart with the given ID
+func getShoppingCart(t *testing.T, b Backend, scID uuid.UUID) *shoppingcart.ShoppingCart {
+	t.Helper()
+	list, err := b.ShoppingCartRepo.ListShoppingCarts(context.Background())
+	require.Nil(t, err)
+	for _, sc := range list {
+		if sc.ID == scID {
+			return &sc
+		}
+	}
+	require.FailNow(t, "shopping cart not found")
+	return nil
+}
+
+// assertSameShoppingCart compares the stored fields of two shopping carts
+func assertSameShoppingCart(t *testing.T, expected *shoppingcart.ShoppingCart, actual *shoppingcart.ShoppingCart) {
+	t.Helper()
+	assert.Equal(t, expected.ID, actual.ID)
+	assert.Equal(t, expected.Items, actual.Items)
+	assert.Equal(t, expected.Amount, actual.Amount)
+	assert.Equal(t, expected.Total, actual.Total)
+	assert.Equal(t, expected.CouponID, actual.CouponID)
+	assert.Equal(t, expected.CustomerID, actual.CustomerID)
+}
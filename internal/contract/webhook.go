@@ -0,0 +1,201 @@
+package contract
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
+)
+
+// TestWebhookRepository verifies the given backend honors the webhook.Repository contract
+func TestWebhookRepository(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	t.Run("creates, updates and deletes subscriptions", func(t *testing.T) {
+		b := factory(t)
+		s := createSubscription(t, b, shoppingcart.EventCreated)
+
+		found, err := b.WebhookRepo.GetSubscription(ctx, s.ID)
+		require.Nil(t, err)
+		assert.Equal(t, s.URL, found.URL)
+		assert.Equal(t, s.Secret, found.Secret)
+		assert.Equal(t, webhook.EventTypeList{shoppingcart.EventCreated}, found.EventTypes)
+
+		found.URL = "https://other.test/hook"
+		found.EventTypes = webhook.EventTypeList{coupon.EventExhausted}
+		updated, err := b.WebhookRepo.UpdateSubscription(ctx, found)
+		require.Nil(t, err)
+		assert.Equal(t, "https://other.test/hook", updated.URL)
+		assert.Equal(t, webhook.EventTypeList{coupon.EventExhausted}, updated.EventTypes)
+
+		list, err := b.WebhookRepo.ListSubscriptions(ctx)
+		require.Nil(t, err)
+		assert.Len(t, list, 1)
+
+		require.Nil(t, b.WebhookRepo.DeleteSubscription(ctx, s.ID))
+		_, err = b.WebhookRepo.GetSubscription(ctx, s.ID)
+		assert.NotNil(t, err)
+		assert.NotNil(t, b.WebhookRepo.DeleteSubscription(ctx, s.ID))
+
+		_, err = b.WebhookRepo.UpdateSubscription(ctx, &webhook.Subscription{ID: uuid.New(), EventTypes: webhook.EventTypeList{}})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("lists the subscriptions of an event type", func(t *testing.T) {
+		b := factory(t)
+		created := createSubscription(t, b, shoppingcart.EventCreated, coupon.EventExhausted)
+		createSubscription(t, b, shoppingcart.EventCouponApplied)
+
+		list, err := b.WebhookRepo.ListSubscriptionsForEvent(ctx, coupon.EventExhausted)
+		require.Nil(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, created.ID, list[0].ID)
+
+		list, err = b.WebhookRepo.ListSubscriptionsForEvent(ctx, "unknown")
+		require.Nil(t, err)
+		assert.Empty(t, list)
+	})
+
+	t.Run("queues every event once per subscription", func(t *testing.T) {
+		b := factory(t)
+		s := createSubscription(t, b, shoppingcart.EventCreated)
+		d := newDelivery(t, *s)
+		require.Nil(t, b.WebhookRepo.AddDeliveries(ctx, d))
+		duplicate := d
+		duplicate.ID = uuid.New()
+		require.Nil(t, b.WebhookRepo.AddDeliveries(ctx, duplicate))
+
+		pending, err := b.WebhookRepo.PendingDeliveries(ctx, time.Now(), 10)
+		require.Nil(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, d.ID, pending[0].ID)
+		assert.JSONEq(t, string(d.Payload), string(pending[0].Payload))
+	})
+
+	t.Run("tracks the delivery attempts", func(t *testing.T) {
+		b := factory(t)
+		s := createSubscription(t, b, shoppingcart.EventCreated)
+		failed := newDelivery(t, *s)
+		delivered := newDelivery(t, *s)
+		require.Nil(t, b.WebhookRepo.AddDeliveries(ctx, failed, delivered))
+
+		next := time.Now().Add(time.Minute)
+		require.Nil(t, b.WebhookRepo.MarkFailed(ctx, failed.ID, next, "boom"))
+		require.Nil(t, b.WebhookRepo.MarkDelivered(ctx, delivered.ID, time.Now()))
+
+		pending, err := b.WebhookRepo.PendingDeliveries(ctx, time.Now(), 10)
+		require.Nil(t, err)
+		assert.Empty(t, pending, "failed deliveries wait until they are due")
+
+		pending, err = b.WebhookRepo.PendingDeliveries(ctx, next, 10)
+		require.Nil(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, failed.ID, pending[0].ID)
+		assert.Equal(t, 1, pending[0].Attempts)
+		assert.Equal(t, "boom", pending[0].LastError)
+
+		assert.NotNil(t, b.WebhookRepo.MarkDelivered(ctx, uuid.New(), time.Now()))
+		assert.NotNil(t, b.WebhookRepo.MarkFailed(ctx, uuid.New(), next, "boom"))
+	})
+
+	t.Run("claims the deliveries for a single dispatcher", func(t *testing.T) {
+		b := factory(t)
+		s := createSubscription(t, b, shoppingcart.EventCreated)
+		first := newDelivery(t, *s)
+		second := newDelivery(t, *s)
+		require.Nil(t, b.WebhookRepo.AddDeliveries(ctx, first, second))
+
+		now := time.Now()
+		until := now.Add(time.Minute)
+		claimed, err := b.WebhookRepo.ClaimDeliveries(ctx, now, until, 1)
+		require.Nil(t, err)
+		require.Len(t, claimed, 1)
+		assert.WithinDuration(t, until, claimed[0].NextAttemptAt, time.Millisecond)
+
+		claimed2, err := b.WebhookRepo.ClaimDeliveries(ctx, now, until, 10)
+		require.Nil(t, err)
+		require.Len(t, claimed2, 1, "a claimed delivery is left to its dispatcher")
+		assert.NotEqual(t, claimed[0].ID, claimed2[0].ID)
+
+		claimed, err = b.WebhookRepo.ClaimDeliveries(ctx, now, until, 10)
+		require.Nil(t, err)
+		assert.Empty(t, claimed)
+
+		claimed, err = b.WebhookRepo.ClaimDeliveries(ctx, until, until.Add(time.Minute), 10)
+		require.Nil(t, err)
+		assert.Len(t, claimed, 2, "the claim is over")
+	})
+
+	t.Run("keeps and retries dead letters", func(t *testing.T) {
+		b := factory(t)
+		s := createSubscription(t, b, shoppingcart.EventCreated)
+		d := newDelivery(t, *s)
+		require.Nil(t, b.WebhookRepo.AddDeliveries(ctx, d))
+
+		_, err := b.WebhookRepo.RetryDeadLetter(ctx, d.ID, time.Now())
+		assert.NotNil(t, err, "only dead letters are retried")
+
+		require.Nil(t, b.WebhookRepo.MarkDead(ctx, d.ID, "gone"))
+		pending, err := b.WebhookRepo.PendingDeliveries(ctx, time.Now().Add(time.Hour), 10)
+		require.Nil(t, err)
+		assert.Empty(t, pending)
+
+		dead, err := b.WebhookRepo.ListDeadLetters(ctx)
+		require.Nil(t, err)
+		require.Len(t, dead, 1)
+		assert.Equal(t, webhook.StatusDead, dead[0].Status)
+		assert.Equal(t, 1, dead[0].Attempts)
+		assert.Equal(t, "gone", dead[0].LastError)
+
+		retried, err := b.WebhookRepo.RetryDeadLetter(ctx, d.ID, time.Now())
+		require.Nil(t, err)
+		assert.Equal(t, webhook.StatusPending, retried.Status)
+		assert.Equal(t, 0, retried.Attempts)
+
+		pending, err = b.WebhookRepo.PendingDeliveries(ctx, time.Now(), 10)
+		require.Nil(t, err)
+		assert.Len(t, pending, 1)
+		dead, err = b.WebhookRepo.ListDeadLetters(ctx)
+		require.Nil(t, err)
+		assert.Empty(t, dead)
+	})
+
+	t.Run("deletes the deliveries of deleted subscriptions", func(t *testing.T) {
+		b := factory(t)
+		s := createSubscription(t, b, shoppingcart.EventCreated)
+		require.Nil(t, b.WebhookRepo.AddDeliveries(ctx, newDelivery(t, *s)))
+		require.Nil(t, b.WebhookRepo.DeleteSubscription(ctx, s.ID))
+
+		pending, err := b.WebhookRepo.PendingDeliveries(ctx, time.Now(), 10)
+		require.Nil(t, err)
+		assert.Empty(t, pending)
+	})
+}
+
+// createSubscription stores a new subscription to the given event types
+func createSubscription(t *testing.T, b Backend, eventTypes ...string) *webhook.Subscription {
+	t.Helper()
+	s, err := webhook.New(webhook.CreateRequest{URL: "https://203.0.113.10/hook", EventTypes: eventTypes})
+	require.Nil(t, err)
+	s, err = b.WebhookRepo.CreateSubscription(context.Background(), s)
+	require.Nil(t, err)
+	return s
+}
+
+// newDelivery returns a pending delivery of a new event to the subscription, not stored yet
+func newDelivery(t *testing.T, s webhook.Subscription) webhook.Delivery {
+	t.Helper()
+	e, err := outbox.NewEvent("test", uuid.New(), shoppingcart.EventCreated, map[string]string{"name": "test"})
+	require.Nil(t, err)
+	d, err := webhook.NewDelivery(s, e)
+	require.Nil(t, err)
+	return d
+}
@@ -2,14 +2,16 @@
 package coupon
 
 import (
+	"context"
 	"math"
 	"net/http"
+	"regexp"
 	"time"
 
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 
 	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
 )
 
 var (
@@ -19,6 +21,65 @@ var (
 	ErrCouponEmptyName = internalErrors.NewWrongInput("coupon empty name")
 	// ErrCouponInvalidAmount used when coupon has invalid amount
 	ErrCouponInvalidAmount = internalErrors.NewWrongInput("coupon invalid amount")
+	// ErrCouponInvalidMaxUsesPerCustomer used when coupon has a negative max uses per customer
+	ErrCouponInvalidMaxUsesPerCustomer = internalErrors.NewWrongInput("coupon invalid max uses per customer")
+	// ErrCouponInvalidMaxUses used when coupon has a negative max uses
+	ErrCouponInvalidMaxUses = internalErrors.NewWrongInput("coupon invalid max uses")
+	// ErrCouponMissingMaxUses used when a coupon limited per customer and open to every customer has no max uses
+	ErrCouponMissingMaxUses = internalErrors.NewWrongInput("coupon limited per customer and not assigned to one needs max uses")
+	// ErrCouponNotAssignedToCustomer used when the coupon belongs to another customer
+	ErrCouponNotAssignedToCustomer = internalErrors.NewForbidden("coupon is not assigned to customer")
+	// ErrCouponRequiresCustomer used when the coupon can only be redeemed by a known customer
+	ErrCouponRequiresCustomer = internalErrors.NewForbidden("coupon requires a customer")
+	// ErrCouponCustomerLimitReached used when the customer already reached the coupon max uses
+	ErrCouponCustomerLimitReached = internalErrors.NewConflict("coupon max uses per customer reached")
+	// ErrCouponInvalidValidity used when coupon validity ends before it starts
+	ErrCouponInvalidValidity = internalErrors.NewWrongInput("coupon valid until must be after valid from")
+	// ErrCouponEmptyUpdate used when an update request does not change anything
+	ErrCouponEmptyUpdate = internalErrors.NewWrongInput("coupon update request is empty")
+	// ErrCouponDiscountLocked used when the amount of a coupon with redemptions is changed
+	ErrCouponDiscountLocked = internalErrors.NewConflict("coupon amount can not be changed once redeemed")
+	// ErrCouponInactive used when the coupon has been deactivated
+	ErrCouponInactive = internalErrors.NewConflict("coupon is not active")
+	// ErrCouponNotYetValid used when the coupon validity has not started
+	ErrCouponNotYetValid = internalErrors.NewConflict("coupon is not valid yet")
+	// ErrCouponExpired used when the coupon validity is over
+	ErrCouponExpired = internalErrors.NewConflict("coupon expired")
+	// ErrCouponInvalidStatsInterval used when the stats interval is not supported
+	ErrCouponInvalidStatsInterval = internalErrors.NewWrongInput("coupon stats interval must be hour, day, week or month")
+	// ErrCouponInvalidStatsRange used when the stats range ends before it starts
+	ErrCouponInvalidStatsRange = internalErrors.NewWrongInput("coupon stats until must be after from")
+	// ErrCouponInvalidCode used when coupon code contains unsupported characters
+	ErrCouponInvalidCode = internalErrors.NewWrongInput("coupon code must only contain letters, digits, - and _")
+	// ErrCouponInvalidType used when coupon type is not supported
+	ErrCouponInvalidType = internalErrors.NewWrongInput("coupon type must be fixed or percentage")
+	// ErrCouponInvalidPercentage used when a percentage coupon amount is over 100
+	ErrCouponInvalidPercentage = internalErrors.NewWrongInput("coupon percentage amount can not be over 100")
+	// ErrCouponCodeAlreadyExists used when another coupon already has the same code
+	ErrCouponCodeAlreadyExists = internalErrors.NewConflict("coupon code already exists")
+	// ErrCouponVersionConflict used when the coupon was updated since it was read
+	ErrCouponVersionConflict = internalErrors.NewConflict("coupon was modified concurrently, retry")
+	// ErrCouponNotFound used when coupon is not found
+	ErrCouponNotFound = internalErrors.NewNotFound("coupon not found")
+	// ErrCouponMissingID used when coupon id is missing
+	ErrCouponMissingID = internalErrors.NewWrongInput("coupon id is missing")
+)
+
+// Types of coupon, a fixed coupon deducts its amount from the shopping
+// cart total while a percentage one deducts that percentage of the total
+const (
+	TypeFixed      = "fixed"
+	TypePercentage = "percentage"
+)
+
+var codeRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Intervals used to group the coupon redemptions over time
+const (
+	StatsIntervalHour  = "hour"
+	StatsIntervalDay   = "day"
+	StatsIntervalWeek  = "week"
+	StatsIntervalMonth = "month"
 )
 
 // Coupon defines the asset of a coupon in our service
@@ -27,10 +88,32 @@ type Coupon struct {
 	ID uuid.UUID `json:"id,omitempty"`
 	// Name will be the name of the Coupon
 	Name string `json:"name,omitempty"`
+	// Code is the unique code customers use to redeem the coupon, if any
+	Code string `json:"code,omitempty"`
+	// Type defines how the amount is deducted, fixed or percentage
+	Type string `json:"type,omitempty"`
 	// Amount that will be used to deduct from shopping cart
 	Amount float32 `json:"amount,omitempty"`
 	// Used will represent if the coupon had been used
 	Used bool `json:"used,omitempty"`
+	// CustomerID is the customer the coupon is assigned to, if any
+	CustomerID uuid.UUID `json:"customer_id,omitempty"`
+	// MaxUsesPerCustomer limits how many times a customer can redeem the coupon.
+	// Zero means the coupon is single use
+	MaxUsesPerCustomer int `json:"max_uses_per_customer,omitempty"`
+	// MaxUses caps the redemptions of the coupon by every customer together,
+	// the coupon is used once they are reached
+	MaxUses int `json:"max_uses,omitempty"`
+	// ValidFrom is the moment the coupon starts to be redeemable, if any
+	ValidFrom *time.Time `json:"valid_from,omitempty"`
+	// ValidUntil is the moment the coupon stops being redeemable, if any
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	// DeactivatedAt is set while the coupon is deactivated
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+	// DeletedAt is set once the coupon is soft deleted
+	DeletedAt *time.Time `json:"-"`
+	// Version is increased on every update, it is exposed as the ETag of the coupon
+	Version int64 `json:"version,omitempty" gorm:"default:1"`
 	// Timestamp when it was created
 	CreatedAt time.Time `json:"created_at,omitempty"`
 	// Timestamp of the last update
@@ -39,11 +122,29 @@ type Coupon struct {
 
 // New return a new Coupon instance
 func New(req CreateRequest) *Coupon {
+	couponType := req.Type
+	if couponType == "" {
+		couponType = TypeFixed
+	}
+	maxUses := req.MaxUses
+	if maxUses == 0 {
+		// only the coupons assigned to a customer can be limited per customer
+		// without max uses, that customer is the only one redeeming them
+		maxUses = max(req.MaxUsesPerCustomer, 1)
+	}
 	return &Coupon{
-		ID:     uuid.MustParse(uuid.NewString()),
-		Name:   req.Name,
-		Amount: float32(math.Round(float64(req.Amount))),
-		Used:   false,
+		ID:                 uuid.MustParse(uuid.NewString()),
+		Name:               req.Name,
+		Code:               req.Code,
+		Type:               couponType,
+		Amount:             float32(math.Round(float64(req.Amount))),
+		Used:               false,
+		CustomerID:         req.CustomerID,
+		MaxUsesPerCustomer: req.MaxUsesPerCustomer,
+		MaxUses:            maxUses,
+		ValidFrom:          req.ValidFrom,
+		ValidUntil:         req.ValidUntil,
+		Version:            1,
 	}
 }
 
@@ -52,10 +153,126 @@ func (c *Coupon) IsUsed() bool {
 	return c.Used
 }
 
+// IsPercentage checks if the coupon amount is a percentage of the shopping cart total
+func (c *Coupon) IsPercentage() bool {
+	return c.Type == TypePercentage
+}
+
+// Discount returns the amount to deduct from the given shopping cart total
+func (c *Coupon) Discount(total float32) float32 {
+	if !c.IsPercentage() {
+		return c.Amount
+	}
+	discount := total * c.Amount / 100
+	return float32(int(discount*100)) / 100
+}
+
+// IsActive checks if coupon has not been deactivated
+func (c *Coupon) IsActive() bool {
+	return c.DeactivatedAt == nil
+}
+
+// Deactivate stops the coupon from being redeemed
+func (c *Coupon) Deactivate(now time.Time) {
+	if c.IsActive() {
+		c.DeactivatedAt = &now
+	}
+}
+
+// Reactivate allows a deactivated coupon to be redeemed again
+func (c *Coupon) Reactivate() {
+	c.DeactivatedAt = nil
+}
+
+// CheckRedeemable checks if the coupon is active and
+// within its validity at the given moment
+func (c *Coupon) CheckRedeemable(now time.Time) error {
+	if !c.IsActive() {
+		return ErrCouponInactive
+	}
+	if c.ValidFrom != nil && now.Before(*c.ValidFrom) {
+		return ErrCouponNotYetValid
+	}
+	if c.ValidUntil != nil && !now.Before(*c.ValidUntil) {
+		return ErrCouponExpired
+	}
+	return nil
+}
+
+// Update applies the given request to the coupon. The amount of a coupon
+// can not change once it has been redeemed
+func (c *Coupon) Update(req UpdateRequest, redemptions int64) error {
+	if req.Amount != nil {
+		amount := float32(math.Round(float64(*req.Amount)))
+		if amount != c.Amount && redemptions > 0 {
+			return ErrCouponDiscountLocked
+		}
+		c.Amount = amount
+	}
+	if req.Name != nil {
+		c.Name = *req.Name
+	}
+	if req.ValidFrom != nil {
+		c.ValidFrom = req.ValidFrom
+	}
+	if req.ValidUntil != nil {
+		c.ValidUntil = req.ValidUntil
+	}
+	if c.IsPercentage() && c.Amount > 100 {
+		return ErrCouponInvalidPercentage
+	}
+	return validateValidity(c.ValidFrom, c.ValidUntil)
+}
+
+// HasCustomerLimit checks if the coupon redemptions are limited per customer
+func (c *Coupon) HasCustomerLimit() bool {
+	return c.MaxUsesPerCustomer > 0
+}
+
+// CheckCustomer checks if the given customer is allowed to redeem the coupon
+func (c *Coupon) CheckCustomer(customerID uuid.UUID) error {
+	if c.CustomerID != uuid.Nil && c.CustomerID != customerID {
+		return ErrCouponNotAssignedToCustomer
+	}
+	if c.HasCustomerLimit() && customerID == uuid.Nil {
+		return ErrCouponRequiresCustomer
+	}
+	return nil
+}
+
+// CheckCustomerUses checks if a customer that already redeemed
+// the coupon the given times can redeem it once more
+func (c *Coupon) CheckCustomerUses(uses int64) error {
+	if c.HasCustomerLimit() && uses >= int64(c.MaxUsesPerCustomer) {
+		return ErrCouponCustomerLimitReached
+	}
+	return nil
+}
+
+// IsMultiUse checks if the coupon can be redeemed more than once
+func (c *Coupon) IsMultiUse() bool {
+	return c.MaxUses > 1
+}
+
+// MarkAsRedeemed records one more redemption of a coupon redeemed the given
+// times before, flagging it as used once it reaches its max uses
+func (c *Coupon) MarkAsRedeemed(redemptions int64) {
+	if redemptions+1 >= int64(c.MaxUses) {
+		c.Used = true
+	}
+}
+
 // CreateRequest defines needed field to create a coupon
 type CreateRequest struct {
-	Name   string  `json:"name,omitempty"`
-	Amount float32 `json:"amount,omitempty"`
+	Name               string     `json:"name,omitempty" jsonschema:"required,minLength=1"`
+	Code               string     `json:"code,omitempty" jsonschema:"pattern=^[A-Za-z0-9_-]+$"`
+	Type               string     `json:"type,omitempty" jsonschema:"enum=fixed|percentage"`
+	Amount             float32    `json:"amount,omitempty" jsonschema:"required,exclusiveMinimum=0"`
+	CustomerID         uuid.UUID  `json:"customer_id,omitempty" jsonschema:"format=uuid"`
+	MaxUsesPerCustomer int        `json:"max_uses_per_customer,omitempty" jsonschema:"minimum=0"`
+	MaxUses            int        `json:"max_uses,omitempty" jsonschema:"minimum=0"`
+	ValidFrom          *time.Time `json:"valid_from,omitempty"`
+	ValidUntil         *time.Time `json:"valid_until,omitempty"`
 }
 
 // Validate validates the create request
@@ -63,30 +280,151 @@ func (r CreateRequest) Validate() error {
 	if r.Name == "" {
 		return ErrCouponEmptyName
 	}
+	if r.Code != "" && !codeRegexp.MatchString(r.Code) {
+		return ErrCouponInvalidCode
+	}
 	if r.Amount <= 0 {
 		return ErrCouponInvalidAmount
 	}
+	switch r.Type {
+	case "", TypeFixed:
+	case TypePercentage:
+		if r.Amount > 100 {
+			return ErrCouponInvalidPercentage
+		}
+	default:
+		return ErrCouponInvalidType
+	}
+	if r.MaxUsesPerCustomer < 0 {
+		return ErrCouponInvalidMaxUsesPerCustomer
+	}
+	if r.MaxUses < 0 {
+		return ErrCouponInvalidMaxUses
+	}
+	if r.MaxUsesPerCustomer > 0 && r.CustomerID == uuid.Nil && r.MaxUses == 0 {
+		return ErrCouponMissingMaxUses
+	}
+	return validateValidity(r.ValidFrom, r.ValidUntil)
+}
+
+// UpdateRequest defines the fields that can be changed on a coupon,
+// only the given ones are updated
+type UpdateRequest struct {
+	Name       *string    `json:"name,omitempty" jsonschema:"minLength=1"`
+	Amount     *float32   `json:"amount,omitempty" jsonschema:"exclusiveMinimum=0"`
+	ValidFrom  *time.Time `json:"valid_from,omitempty"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+}
+
+// Validate validates the update request
+func (r UpdateRequest) Validate() error {
+	if r.Name == nil && r.Amount == nil && r.ValidFrom == nil && r.ValidUntil == nil {
+		return ErrCouponEmptyUpdate
+	}
+	if r.Name != nil && *r.Name == "" {
+		return ErrCouponEmptyName
+	}
+	if r.Amount != nil && *r.Amount <= 0 {
+		return ErrCouponInvalidAmount
+	}
+	return validateValidity(r.ValidFrom, r.ValidUntil)
+}
+
+func validateValidity(from, until *time.Time) error {
+	if from != nil && until != nil && !until.After(*from) {
+		return ErrCouponInvalidValidity
+	}
 	return nil
 }
 
+// StatsRequest defines how the coupon redemptions are aggregated
+type StatsRequest struct {
+	// Interval used to group redemptions over time, day by default
+	Interval string
+	// From and Until optionally restrict the redemptions taken into account
+	From  *time.Time
+	Until *time.Time
+}
+
+// Validate validates the stats request
+func (r StatsRequest) Validate() error {
+	switch r.Interval {
+	case StatsIntervalHour, StatsIntervalDay, StatsIntervalWeek, StatsIntervalMonth:
+	default:
+		return ErrCouponInvalidStatsInterval
+	}
+	if r.From != nil && r.Until != nil && !r.Until.After(*r.From) {
+		return ErrCouponInvalidStatsRange
+	}
+	return nil
+}
+
+// Stats holds how a coupon performed
+type Stats struct {
+	CouponID uuid.UUID `json:"coupon_id"`
+	// Redemptions is the number of shopping carts the coupon was applied to
+	Redemptions int64 `json:"redemptions"`
+	// TotalDiscount is the sum of the discounts granted by the coupon
+	TotalDiscount float64 `json:"total_discount"`
+	// AverageCartValue is the average amount of the carts before the discount
+	AverageCartValue float64 `json:"average_cart_value"`
+	// Interval used to group the buckets
+	Interval string `json:"interval"`
+	// Buckets holds the redemptions grouped over time
+	Buckets []StatsBucket `json:"buckets"`
+}
+
+// StatsBucket holds the coupon redemptions of a period of time
+type StatsBucket struct {
+	Start            time.Time `json:"start"`
+	Redemptions      int64     `json:"redemptions"`
+	TotalDiscount    float64   `json:"total_discount"`
+	AverageCartValue float64   `json:"average_cart_value"`
+}
+
 // Service defines the available functions for the Coupon Service
 type Service interface {
 	// CreateCoupon returns a new coupon
-	CreateCoupon(CreateRequest) (*Coupon, error)
+	CreateCoupon(context.Context, CreateRequest) (*Coupon, error)
 	// ListCoupons returns a list of coupons
-	ListCoupons() ([]Coupon, error)
+	ListCoupons(context.Context) ([]Coupon, error)
+	// UpdateCoupon updates the name, amount or validity of a coupon
+	UpdateCoupon(context.Context, uuid.UUID, UpdateRequest) (*Coupon, error)
+	// DeactivateCoupon stops a coupon from being redeemed
+	DeactivateCoupon(context.Context, uuid.UUID) (*Coupon, error)
+	// ReactivateCoupon allows a deactivated coupon to be redeemed again
+	ReactivateCoupon(context.Context, uuid.UUID) (*Coupon, error)
+	// DeleteCoupon soft deletes a coupon
+	DeleteCoupon(context.Context, uuid.UUID) error
+	// GetCouponStats returns the redemption stats of a coupon
+	GetCouponStats(context.Context, uuid.UUID, StatsRequest) (*Stats, error)
+	// ImportCoupons validates and creates the given coupons using the given import mode
+	ImportCoupons(context.Context, []ImportRow, string) (*ImportResult, error)
+	// ExportCoupons calls the given function for every coupon
+	ExportCoupons(context.Context, func(Coupon) error) error
 }
 
 // Repository defines the available functions for the Coupon repository
 type Repository interface {
-	// CreateCoupon returns a new coupon
-	CreateCoupon(*Coupon) (*Coupon, error)
+	// CreateCoupon creates a coupon within the given transaction
+	CreateCoupon(context.Context, transaction.Tx, *Coupon) (*Coupon, error)
 	// ListCoupons returns a list of coupons
-	ListCoupons() ([]Coupon, error)
+	ListCoupons(context.Context) ([]Coupon, error)
 	// GetCouponForUpdate returns an specific  and it will lock the row in order to update it
-	GetCouponForUpdate(*gorm.DB, uuid.UUID) (*Coupon, error)
-	// UpdateCoupon updates coupon entity
-	UpdateCoupon(*gorm.DB, *Coupon) (*Coupon, error)
+	GetCouponForUpdate(context.Context, transaction.Tx, uuid.UUID) (*Coupon, error)
+	// UpdateCoupon updates coupon entity increasing its version, it fails
+	// with ErrCouponVersionConflict if the stored version is not the given one
+	UpdateCoupon(context.Context, transaction.Tx, *Coupon) (*Coupon, error)
+	// DeleteCoupon soft deletes a coupon, read within the same transaction
+	DeleteCoupon(context.Context, transaction.Tx, *Coupon) error
+	// CountCouponRedemptions returns how many shopping carts have the coupon applied
+	CountCouponRedemptions(context.Context, transaction.Tx, uuid.UUID) (int64, error)
+	// GetCouponStats aggregates the redemptions of a coupon
+	GetCouponStats(context.Context, uuid.UUID, StatsRequest) (*Stats, error)
+	// ImportCoupon creates a coupon within the given transaction, a failure only discards this coupon
+	ImportCoupon(context.Context, transaction.Tx, *Coupon) (*Coupon, error)
+	// ExportCoupons calls the given function for every coupon, reading them in batches
+	ExportCoupons(context.Context, func(Coupon) error) error
 }
 
 // Server defines what are the different allowed http
@@ -96,4 +434,18 @@ type Server interface {
 	CreateCoupon(w http.ResponseWriter, r *http.Request)
 	// ListCoupons returns a list of coupons
 	LisCoupons(w http.ResponseWriter, r *http.Request)
+	// UpdateCoupon receives a request in order to update a coupon
+	UpdateCoupon(w http.ResponseWriter, r *http.Request)
+	// DeactivateCoupon receives a request in order to deactivate a coupon
+	DeactivateCoupon(w http.ResponseWriter, r *http.Request)
+	// ReactivateCoupon receives a request in order to reactivate a coupon
+	ReactivateCoupon(w http.ResponseWriter, r *http.Request)
+	// DeleteCoupon receives a request in order to delete a coupon
+	DeleteCoupon(w http.ResponseWriter, r *http.Request)
+	// GetCouponStats returns the redemption stats of a coupon as JSON or CSV
+	GetCouponStats(w http.ResponseWriter, r *http.Request)
+	// ImportCoupons receives a CSV in order to create coupons
+	ImportCoupons(w http.ResponseWriter, r *http.Request)
+	// ExportCoupons streams every coupon as CSV
+	ExportCoupons(w http.ResponseWriter, r *http.Request)
 }
@@ -2,7 +2,9 @@ package coupon_test
 
 import (
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
 	"github.com/stretchr/testify/assert"
 )
@@ -19,6 +21,15 @@ func TestCouponNew(t *testing.T) {
 	})
 	assert.Equal(t, c.Amount, float32(testAmount))
 	assert.Equal(t, c.Name, testName)
+	assert.Equal(t, 1, c.MaxUses, "coupons are single use by default")
+
+	c = coupon.New(coupon.CreateRequest{
+		Name:               testName,
+		Amount:             float32(testAmount),
+		CustomerID:         uuid.New(),
+		MaxUsesPerCustomer: 3,
+	})
+	assert.Equal(t, 3, c.MaxUses, "an assigned coupon is capped by the uses of its customer")
 }
 
 func TestCouponCreateValidate(t *testing.T) {
@@ -38,4 +49,238 @@ func TestCouponCreateValidate(t *testing.T) {
 		err := req.Validate()
 		assert.Equal(t, coupon.ErrCouponInvalidAmount, err)
 	})
+	t.Run("invalid max uses per customer", func(t *testing.T) {
+		req.Amount = float32(testAmount)
+		req.MaxUsesPerCustomer = -1
+		err := req.Validate()
+		assert.Equal(t, coupon.ErrCouponInvalidMaxUsesPerCustomer, err)
+	})
+	t.Run("invalid max uses", func(t *testing.T) {
+		req.MaxUsesPerCustomer = 0
+		req.MaxUses = -1
+		err := req.Validate()
+		assert.Equal(t, coupon.ErrCouponInvalidMaxUses, err)
+	})
+	t.Run("coupon limited per customer without max uses", func(t *testing.T) {
+		req.MaxUsesPerCustomer = 2
+		req.MaxUses = 0
+		err := req.Validate()
+		assert.Equal(t, coupon.ErrCouponMissingMaxUses, err)
+
+		assigned := req
+		assigned.CustomerID = uuid.New()
+		assert.Nil(t, assigned.Validate())
+		capped := req
+		capped.MaxUses = 100
+		assert.Nil(t, capped.Validate())
+	})
+}
+
+func TestCouponCheckCustomer(t *testing.T) {
+	customerID := uuid.New()
+
+	t.Run("coupon assigned to another customer", func(t *testing.T) {
+		c := coupon.Coupon{CustomerID: uuid.New()}
+		err := c.CheckCustomer(customerID)
+		assert.Equal(t, coupon.ErrCouponNotAssignedToCustomer, err)
+	})
+	t.Run("coupon assigned to the customer", func(t *testing.T) {
+		c := coupon.Coupon{CustomerID: customerID}
+		err := c.CheckCustomer(customerID)
+		assert.Nil(t, err)
+	})
+	t.Run("coupon limited per customer without customer", func(t *testing.T) {
+		c := coupon.Coupon{MaxUsesPerCustomer: 1}
+		err := c.CheckCustomer(uuid.Nil)
+		assert.Equal(t, coupon.ErrCouponRequiresCustomer, err)
+	})
+	t.Run("coupon without restrictions", func(t *testing.T) {
+		c := coupon.Coupon{}
+		err := c.CheckCustomer(uuid.Nil)
+		assert.Nil(t, err)
+	})
+}
+
+func TestCouponCheckCustomerUses(t *testing.T) {
+	c := coupon.Coupon{MaxUsesPerCustomer: 2}
+
+	t.Run("customer below the limit", func(t *testing.T) {
+		assert.Nil(t, c.CheckCustomerUses(1))
+	})
+	t.Run("customer reached the limit", func(t *testing.T) {
+		assert.Equal(t, coupon.ErrCouponCustomerLimitReached, c.CheckCustomerUses(2))
+	})
+}
+
+func TestCouponMarkAsRedeemed(t *testing.T) {
+	t.Run("single use coupon", func(t *testing.T) {
+		c := coupon.Coupon{MaxUses: 1}
+		assert.False(t, c.IsMultiUse())
+		c.MarkAsRedeemed(0)
+		assert.True(t, c.IsUsed())
+	})
+	t.Run("coupon limited per customer below its max uses", func(t *testing.T) {
+		c := coupon.Coupon{MaxUsesPerCustomer: 1, MaxUses: 3}
+		assert.True(t, c.IsMultiUse())
+		c.MarkAsRedeemed(1)
+		assert.False(t, c.IsUsed())
+	})
+	t.Run("coupon limited per customer reaching its max uses", func(t *testing.T) {
+		c := coupon.Coupon{MaxUsesPerCustomer: 1, MaxUses: 3}
+		c.MarkAsRedeemed(2)
+		assert.True(t, c.IsUsed())
+	})
+}
+
+func TestCouponCheckRedeemable(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	testCases := map[string]struct {
+		coupon        coupon.Coupon
+		expectedError error
+	}{
+		"active coupon without validity": {
+			coupon:        coupon.Coupon{},
+			expectedError: nil,
+		},
+		"deactivated coupon": {
+			coupon:        coupon.Coupon{DeactivatedAt: &past},
+			expectedError: coupon.ErrCouponInactive,
+		},
+		"coupon not valid yet": {
+			coupon:        coupon.Coupon{ValidFrom: &future},
+			expectedError: coupon.ErrCouponNotYetValid,
+		},
+		"expired coupon": {
+			coupon:        coupon.Coupon{ValidUntil: &past},
+			expectedError: coupon.ErrCouponExpired,
+		},
+		"coupon within validity": {
+			coupon:        coupon.Coupon{ValidFrom: &past, ValidUntil: &future},
+			expectedError: nil,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedError, tc.coupon.CheckRedeemable(now))
+		})
+	}
+}
+
+func TestCouponDeactivate(t *testing.T) {
+	c := coupon.Coupon{}
+	c.Deactivate(time.Now())
+	assert.False(t, c.IsActive())
+	c.Reactivate()
+	assert.True(t, c.IsActive())
+}
+
+func TestCouponUpdate(t *testing.T) {
+	newName := "new name"
+	newAmount := float32(50)
+	now := time.Now()
+	before := now.Add(-time.Hour)
+
+	t.Run("amount of a redeemed coupon", func(t *testing.T) {
+		c := coupon.Coupon{Amount: 10}
+		err := c.Update(coupon.UpdateRequest{Amount: &newAmount}, 1)
+		assert.Equal(t, coupon.ErrCouponDiscountLocked, err)
+		assert.Equal(t, float32(10), c.Amount)
+	})
+	t.Run("name of a redeemed coupon", func(t *testing.T) {
+		c := coupon.Coupon{Name: testName}
+		err := c.Update(coupon.UpdateRequest{Name: &newName}, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, newName, c.Name)
+	})
+	t.Run("amount of a coupon without redemptions", func(t *testing.T) {
+		c := coupon.Coupon{Amount: 10}
+		err := c.Update(coupon.UpdateRequest{Amount: &newAmount}, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, newAmount, c.Amount)
+	})
+	t.Run("validity ending before it starts", func(t *testing.T) {
+		c := coupon.Coupon{ValidFrom: &now}
+		err := c.Update(coupon.UpdateRequest{ValidUntil: &before}, 0)
+		assert.Equal(t, coupon.ErrCouponInvalidValidity, err)
+	})
+}
+
+func TestCouponUpdateValidate(t *testing.T) {
+	emptyName := ""
+	invalidAmount := float32(0)
+
+	t.Run("empty request", func(t *testing.T) {
+		assert.Equal(t, coupon.ErrCouponEmptyUpdate, coupon.UpdateRequest{}.Validate())
+	})
+	t.Run("invalid name", func(t *testing.T) {
+		assert.Equal(t, coupon.ErrCouponEmptyName, coupon.UpdateRequest{Name: &emptyName}.Validate())
+	})
+	t.Run("invalid amount", func(t *testing.T) {
+		assert.Equal(t, coupon.ErrCouponInvalidAmount, coupon.UpdateRequest{Amount: &invalidAmount}.Validate())
+	})
+}
+
+func TestCouponStatsValidate(t *testing.T) {
+	now := time.Now()
+	before := now.Add(-time.Hour)
+
+	t.Run("invalid interval", func(t *testing.T) {
+		err := coupon.StatsRequest{Interval: "year"}.Validate()
+		assert.Equal(t, coupon.ErrCouponInvalidStatsInterval, err)
+	})
+	t.Run("invalid range", func(t *testing.T) {
+		err := coupon.StatsRequest{Interval: coupon.StatsIntervalDay, From: &now, Until: &before}.Validate()
+		assert.Equal(t, coupon.ErrCouponInvalidStatsRange, err)
+	})
+	t.Run("valid request", func(t *testing.T) {
+		err := coupon.StatsRequest{Interval: coupon.StatsIntervalWeek, From: &before, Until: &now}.Validate()
+		assert.Nil(t, err)
+	})
+}
+
+func TestCouponDiscount(t *testing.T) {
+	t.Run("fixed coupon", func(t *testing.T) {
+		c := coupon.New(coupon.CreateRequest{Name: testName, Amount: 30})
+		assert.Equal(t, coupon.TypeFixed, c.Type)
+		assert.Equal(t, float32(30), c.Discount(200))
+	})
+	t.Run("percentage coupon", func(t *testing.T) {
+		c := coupon.New(coupon.CreateRequest{Name: testName, Amount: 15, Type: coupon.TypePercentage})
+		assert.Equal(t, float32(30), c.Discount(200))
+		assert.Equal(t, float32(1.51), c.Discount(10.1))
+	})
+}
+
+func TestCouponCreateValidateTypeAndCode(t *testing.T) {
+	testCases := map[string]struct {
+		req           coupon.CreateRequest
+		expectedError error
+	}{
+		"invalid code": {
+			req:           coupon.CreateRequest{Name: testName, Code: "FREE 30", Amount: 30},
+			expectedError: coupon.ErrCouponInvalidCode,
+		},
+		"invalid type": {
+			req:           coupon.CreateRequest{Name: testName, Type: "gift", Amount: 30},
+			expectedError: coupon.ErrCouponInvalidType,
+		},
+		"percentage over 100": {
+			req:           coupon.CreateRequest{Name: testName, Type: coupon.TypePercentage, Amount: 120},
+			expectedError: coupon.ErrCouponInvalidPercentage,
+		},
+		"valid percentage coupon": {
+			req:           coupon.CreateRequest{Name: testName, Code: "TEN_OFF-1", Type: coupon.TypePercentage, Amount: 10},
+			expectedError: nil,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedError, tc.req.Validate())
+		})
+	}
 }
@@ -0,0 +1,204 @@
+package coupon
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+)
+
+// MaxImportRows is the maximum number of coupons that can be imported at once
+const MaxImportRows = 10000
+
+// Import modes, partial imports every valid row while all or nothing
+// imports nothing as soon as a single row fails
+const (
+	ImportModePartial      = "partial"
+	ImportModeAllOrNothing = "all_or_nothing"
+)
+
+var (
+	// ErrCouponInvalidCSV used when the coupons CSV can not be read
+	ErrCouponInvalidCSV = internalErrors.NewWrongInput("coupons csv is not valid")
+	// ErrCouponInvalidCSVHeader used when the coupons CSV misses a required column
+	ErrCouponInvalidCSVHeader = internalErrors.NewWrongInput("coupons csv header must contain name and amount")
+	// ErrCouponTooManyImportRows used when the coupons CSV exceeds MaxImportRows
+	ErrCouponTooManyImportRows = internalErrors.NewWrongInput("coupons csv exceeds the max number of rows")
+	// ErrCouponInvalidImportMode used when the import mode is not supported
+	ErrCouponInvalidImportMode = internalErrors.NewWrongInput("coupons import mode must be partial or all_or_nothing")
+)
+
+// csvExportHeader lists the columns of an exported coupon, the
+// importable ones are name, code, amount, type, valid_from and valid_until
+var csvExportHeader = []string{
+	"id", "name", "code", "amount", "type", "valid_from", "valid_until",
+	"customer_id", "max_uses_per_customer", "max_uses", "used", "active", "created_at",
+}
+
+// ImportRow is a parsed line of a coupons CSV, Err is set
+// when the line could not be turned into a CreateRequest
+type ImportRow struct {
+	Line    int
+	Request CreateRequest
+	Err     error
+}
+
+// ImportResult summarizes a coupons import
+type ImportResult struct {
+	Mode     string        `json:"mode"`
+	Total    int           `json:"total"`
+	Imported int           `json:"imported"`
+	Errors   []ImportError `json:"errors"`
+}
+
+// ImportError describes why a line of a coupons CSV was not imported
+type ImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ParseCSV reads a coupons CSV. The first line must be a header naming the
+// columns, unknown columns are ignored so exported files can be imported back
+func ParseCSV(r io.Reader) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, ErrCouponInvalidCSV
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, ErrCouponInvalidCSVHeader
+	}
+	if _, ok := columns["amount"]; !ok {
+		return nil, ErrCouponInvalidCSVHeader
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, ErrCouponInvalidCSV
+		}
+		if len(rows) == MaxImportRows {
+			return nil, ErrCouponTooManyImportRows
+		}
+		line, _ := reader.FieldPos(0)
+		req, err := parseCSVRecord(columns, record)
+		rows = append(rows, ImportRow{Line: line, Request: req, Err: err})
+	}
+	return rows, nil
+}
+
+func parseCSVRecord(columns map[string]int, record []string) (CreateRequest, error) {
+	value := func(column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	req := CreateRequest{
+		Name: value("name"),
+		Code: value("code"),
+		Type: value("type"),
+	}
+	amount, err := strconv.ParseFloat(value("amount"), 32)
+	if err != nil {
+		return req, ErrCouponInvalidAmount
+	}
+	req.Amount = float32(amount)
+	if req.ValidFrom, err = parseCSVTime(value("valid_from")); err != nil {
+		return req, ErrCouponInvalidValidity
+	}
+	if req.ValidUntil, err = parseCSVTime(value("valid_until")); err != nil {
+		return req, ErrCouponInvalidValidity
+	}
+	return req, nil
+}
+
+func parseCSVTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CSVWriter writes coupons as CSV, starting with a header line
+type CSVWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+// NewCSVWriter returns a CSVWriter writing into w
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// Write writes the given coupon as a CSV line
+func (cw *CSVWriter) Write(c Coupon) error {
+	if !cw.headerWritten {
+		if err := cw.w.Write(csvExportHeader); err != nil {
+			return err
+		}
+		cw.headerWritten = true
+	}
+	customerID := ""
+	if c.CustomerID != uuid.Nil {
+		customerID = c.CustomerID.String()
+	}
+	return cw.w.Write([]string{
+		c.ID.String(),
+		c.Name,
+		c.Code,
+		strconv.FormatFloat(float64(c.Amount), 'f', -1, 32),
+		c.Type,
+		formatCSVTime(c.ValidFrom),
+		formatCSVTime(c.ValidUntil),
+		customerID,
+		strconv.Itoa(c.MaxUsesPerCustomer),
+		strconv.Itoa(c.MaxUses),
+		strconv.FormatBool(c.Used),
+		strconv.FormatBool(c.IsActive()),
+		c.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+// Flush writes the header, if nothing was written yet, and
+// any buffered data to the underlying writer
+func (cw *CSVWriter) Flush() error {
+	if !cw.headerWritten {
+		if err := cw.w.Write(csvExportHeader); err != nil {
+			return err
+		}
+		cw.headerWritten = true
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func formatCSVTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,95 @@
+package coupon_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCSV(t *testing.T) {
+	t.Run("valid csv", func(t *testing.T) {
+		rows, err := coupon.ParseCSV(strings.NewReader(
+			"name,code,amount,type,valid_from,valid_until\n" +
+				"FREE30,FREE30,30,fixed,,\n" +
+				"TENOFF,TEN_OFF,10,percentage,2026-01-01T00:00:00Z,2026-12-31T00:00:00Z\n" +
+				"BROKEN,BROKEN,abc,fixed,,\n",
+		))
+		assert.Nil(t, err)
+		assert.Len(t, rows, 3)
+
+		assert.Equal(t, 2, rows[0].Line)
+		assert.Nil(t, rows[0].Err)
+		assert.Equal(t, coupon.CreateRequest{Name: "FREE30", Code: "FREE30", Amount: 30, Type: coupon.TypeFixed}, rows[0].Request)
+
+		assert.Nil(t, rows[1].Err)
+		assert.Equal(t, coupon.TypePercentage, rows[1].Request.Type)
+		assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), rows[1].Request.ValidFrom.UTC())
+
+		assert.Equal(t, 4, rows[2].Line)
+		assert.Equal(t, coupon.ErrCouponInvalidAmount, rows[2].Err)
+	})
+
+	t.Run("columns in any order", func(t *testing.T) {
+		rows, err := coupon.ParseCSV(strings.NewReader("amount,id,name\n30,ignored,FREE30\n"))
+		assert.Nil(t, err)
+		assert.Len(t, rows, 1)
+		assert.Equal(t, coupon.CreateRequest{Name: "FREE30", Amount: 30}, rows[0].Request)
+	})
+
+	t.Run("missing required column", func(t *testing.T) {
+		_, err := coupon.ParseCSV(strings.NewReader("name,code\nFREE30,FREE30\n"))
+		assert.Equal(t, coupon.ErrCouponInvalidCSVHeader, err)
+	})
+
+	t.Run("empty csv", func(t *testing.T) {
+		_, err := coupon.ParseCSV(strings.NewReader(""))
+		assert.Equal(t, coupon.ErrCouponInvalidCSV, err)
+	})
+}
+
+func TestCSVWriter(t *testing.T) {
+	createdAt := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	c := coupon.Coupon{
+		ID:        uuid.MustParse("1b4e28ba-2fa1-11d2-883f-0016d3cca427"),
+		Name:      "FREE30",
+		Code:      "FREE30",
+		Type:      coupon.TypeFixed,
+		Amount:    30,
+		MaxUses:   1,
+		CreatedAt: createdAt,
+	}
+
+	t.Run("writes the header and the coupons", func(t *testing.T) {
+		var buf bytes.Buffer
+		cw := coupon.NewCSVWriter(&buf)
+		assert.Nil(t, cw.Write(c))
+		assert.Nil(t, cw.Flush())
+		assert.Equal(t, "id,name,code,amount,type,valid_from,valid_until,customer_id,max_uses_per_customer,max_uses,used,active,created_at\n"+
+			"1b4e28ba-2fa1-11d2-883f-0016d3cca427,FREE30,FREE30,30,fixed,,,,0,1,false,true,2026-10-19T10:00:00Z\n", buf.String())
+	})
+
+	t.Run("exported coupons can be imported back", func(t *testing.T) {
+		var buf bytes.Buffer
+		cw := coupon.NewCSVWriter(&buf)
+		assert.Nil(t, cw.Write(c))
+		assert.Nil(t, cw.Flush())
+
+		rows, err := coupon.ParseCSV(&buf)
+		assert.Nil(t, err)
+		assert.Len(t, rows, 1)
+		assert.Nil(t, rows[0].Request.Validate())
+		assert.Equal(t, c.Code, rows[0].Request.Code)
+	})
+
+	t.Run("writes the header without coupons", func(t *testing.T) {
+		var buf bytes.Buffer
+		cw := coupon.NewCSVWriter(&buf)
+		assert.Nil(t, cw.Flush())
+		assert.Equal(t, "id,name,code,amount,type,valid_from,valid_until,customer_id,max_uses_per_customer,max_uses,used,active,created_at\n", buf.String())
+	})
+}
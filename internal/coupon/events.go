@@ -0,0 +1,34 @@
+package coupon
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
+)
+
+// AggregateType names the coupons in the domain events
+const AggregateType = "coupon"
+
+// Domain events of the coupons
+const (
+	// EventExhausted is emitted once a coupon can not be redeemed anymore
+	EventExhausted = "coupon.exhausted"
+)
+
+// ExhaustedEvent is the payload of EventExhausted
+type ExhaustedEvent struct {
+	CouponID    uuid.UUID `json:"coupon_id"`
+	Code        string    `json:"code,omitempty"`
+	ExhaustedAt time.Time `json:"exhausted_at"`
+}
+
+// ExhaustedEvent returns the event emitted once the coupon is redeemed for the last time
+func (c *Coupon) ExhaustedEvent(at time.Time) (outbox.Event, error) {
+	return outbox.NewEvent(AggregateType, c.ID, EventExhausted, ExhaustedEvent{
+		CouponID:    c.ID,
+		Code:        c.Code,
+		ExhaustedAt: at,
+	})
+}
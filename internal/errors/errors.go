@@ -8,10 +8,70 @@ import (
 	"net/http"
 )
 
+// ProblemContentType is the content type of the RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypeBase prefixes the kind of an error to build its problem type
+const ProblemTypeBase = "/problems/"
+
+// StatusClientClosedRequest is the non standard status of the requests
+// the client went away from before they completed
+const StatusClientClosedRequest = 499
+
+// Kinds of errors, the machine-readable code clients can rely on
+const (
+	KindWrongInput          = "wrong_input"
+	KindForbidden           = "forbidden"
+	KindNotFound            = "not_found"
+	KindConflict            = "conflict"
+	KindPreconditionFailed  = "precondition_failed"
+	KindPayloadTooLarge     = "payload_too_large"
+	KindUnprocessableEntity = "unprocessable_entity"
+	KindTooManyRequests     = "too_many_requests"
+	KindCanceled            = "canceled"
+	KindUnavailable         = "unavailable"
+	KindInternal            = "internal"
+)
+
+var kinds = map[int]string{
+	http.StatusBadRequest:            KindWrongInput,
+	http.StatusForbidden:             KindForbidden,
+	http.StatusNotFound:              KindNotFound,
+	http.StatusConflict:              KindConflict,
+	http.StatusPreconditionFailed:    KindPreconditionFailed,
+	http.StatusRequestEntityTooLarge: KindPayloadTooLarge,
+	http.StatusUnprocessableEntity:   KindUnprocessableEntity,
+	http.StatusTooManyRequests:       KindTooManyRequests,
+	StatusClientClosedRequest:        KindCanceled,
+	http.StatusServiceUnavailable:    KindUnavailable,
+}
+
 // Error is an error that formats as the given text.
 type Error struct {
-	Code    int    `json:"code,omitempty"`
-	Message string `json:"message,omitempty"`
+	Code    int          `json:"code,omitempty"`
+	Message string       `json:"message,omitempty"`
+	Fields  []FieldError `json:"fields,omitempty"`
+	// parent is the error this one was copied from by WithFields
+	parent *Error
+}
+
+// FieldError is a problem found on a single field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Problem is the RFC 7807 problem details body of an error
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
 }
 
 // Error returns a formatted string including the error code, error message.
@@ -20,15 +80,63 @@ func (e *Error) Error() string {
 	return errMsg
 }
 
+// Unwrap returns the error this one was copied from, so errors.Is still
+// matches the original error once fields are attached
+func (e *Error) Unwrap() error {
+	if e.parent == nil {
+		return nil
+	}
+	return e.parent
+}
+
+// Kind returns the machine-readable code of the error
+func (e *Error) Kind() string {
+	if kind, ok := kinds[e.Code]; ok {
+		return kind
+	}
+	return KindInternal
+}
+
+// WithFields returns a copy of the error holding the given field errors
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	return &Error{Code: e.Code, Message: e.Message, Fields: fields, parent: e}
+}
+
+// Problem returns the problem details of the error
+func (e *Error) Problem() Problem {
+	return Problem{
+		Type:   ProblemTypeBase + e.Kind(),
+		Title:  title(e.Code),
+		Status: e.Code,
+		Detail: e.Message,
+		Code:   e.Kind(),
+		Errors: e.Fields,
+	}
+}
+
+// title returns the title of the given status, http.StatusText misses
+// the non standard ones
+func title(status int) string {
+	if status == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(status)
+}
+
 // MarshalJSON satisfies the json.Marshaler interface.
 func (e *Error) MarshalJSON() ([]byte, error) {
-	return json.Marshal(struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	}{
-		Code:    e.Code,
-		Message: e.Message,
-	})
+	return json.Marshal(e.Problem())
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface, reading back
+// the problem details of an error.
+func (e *Error) UnmarshalJSON(data []byte) error {
+	var problem Problem
+	if err := json.Unmarshal(data, &problem); err != nil {
+		return err
+	}
+	*e = Error{Code: problem.Status, Message: problem.Detail, Fields: problem.Errors}
+	return nil
 }
 
 // HTTPStatus returns the http status code for the given error.
@@ -38,28 +146,64 @@ func (e *Error) HTTPStatus() int {
 
 // NewWrongInput returns a new WrongInput error with the given message.
 func NewWrongInput(text string) *Error {
-	return &Error{http.StatusBadRequest, text}
+	return &Error{Code: http.StatusBadRequest, Message: text}
 }
 
 // NewInternalError returns a new Internal error with the given message.
 func NewInternalError(text string) *Error {
-	return &Error{http.StatusInternalServerError, text}
+	return &Error{Code: http.StatusInternalServerError, Message: text}
 }
 
 // NewNotFound returns a new Not Found error with the given message.
 func NewNotFound(text string) *Error {
-	return &Error{http.StatusNotFound, text}
+	return &Error{Code: http.StatusNotFound, Message: text}
 }
 
 // NewConflict returns a new Conflict error with the given message.
 func NewConflict(text string) *Error {
-	return &Error{http.StatusConflict, text}
+	return &Error{Code: http.StatusConflict, Message: text}
+}
+
+// NewPreconditionFailed returns a new Precondition Failed error with the given message.
+func NewPreconditionFailed(text string) *Error {
+	return &Error{Code: http.StatusPreconditionFailed, Message: text}
+}
+
+// NewPayloadTooLarge returns a new Payload Too Large error with the given message.
+func NewPayloadTooLarge(text string) *Error {
+	return &Error{Code: http.StatusRequestEntityTooLarge, Message: text}
+}
+
+// NewUnprocessableEntity returns a new Unprocessable Entity error with the given message.
+func NewUnprocessableEntity(text string) *Error {
+	return &Error{Code: http.StatusUnprocessableEntity, Message: text}
+}
+
+// NewTooManyRequests returns a new Too Many Requests error with the given message.
+func NewTooManyRequests(text string) *Error {
+	return &Error{Code: http.StatusTooManyRequests, Message: text}
+}
+
+// NewForbidden returns a new Forbidden error with the given message.
+func NewForbidden(text string) *Error {
+	return &Error{Code: http.StatusForbidden, Message: text}
+}
+
+// NewServiceUnavailable returns a new Service Unavailable error with the given message.
+func NewServiceUnavailable(text string) *Error {
+	return &Error{Code: http.StatusServiceUnavailable, Message: text}
+}
+
+// NewClientClosedRequest returns a new Client Closed Request error with the given message.
+func NewClientClosedRequest(text string) *Error {
+	return &Error{Code: StatusClientClosedRequest, Message: text}
 }
 
 // Encode uses the given http.ResponseWriter as a json
 // encoder to response back with the appropriate http.Status
 // and error body
 func (e Error) Encode(ctx context.Context, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ProblemContentType)
 	w.WriteHeader(e.Code)
 	if err := json.NewEncoder(w).Encode(&e); err != nil {
 		slog.Error(fmt.Sprintf("error encoding json error response: %s", err))
@@ -0,0 +1,76 @@
+package errors_test
+
+import (
+	"encoding/json"
+	stdErrors "errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/errors"
+)
+
+func TestError_Problem(t *testing.T) {
+	testCases := map[string]struct {
+		err      *errors.Error
+		expected errors.Problem
+	}{
+		"wrong input with fields": {
+			err: errors.NewWrongInput("invalid request").WithFields(errors.FieldError{
+				Field:   "items",
+				Code:    "required",
+				Message: "items is required",
+			}),
+			expected: errors.Problem{
+				Type:   "/problems/wrong_input",
+				Title:  "Bad Request",
+				Status: http.StatusBadRequest,
+				Detail: "invalid request",
+				Code:   errors.KindWrongInput,
+				Errors: []errors.FieldError{{Field: "items", Code: "required", Message: "items is required"}},
+			},
+		},
+		"not found": {
+			err: errors.NewNotFound("coupon not found"),
+			expected: errors.Problem{
+				Type:   "/problems/not_found",
+				Title:  "Not Found",
+				Status: http.StatusNotFound,
+				Detail: "coupon not found",
+				Code:   errors.KindNotFound,
+			},
+		},
+		"internal": {
+			err: errors.NewInternalError("boom"),
+			expected: errors.Problem{
+				Type:   "/problems/internal",
+				Title:  "Internal Server Error",
+				Status: http.StatusInternalServerError,
+				Detail: "boom",
+				Code:   errors.KindInternal,
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.err.Problem())
+
+			body, err := json.Marshal(tc.err)
+			assert.Nil(t, err)
+			decoded := &errors.Error{}
+			assert.Nil(t, json.Unmarshal(body, decoded))
+			assert.Equal(t, tc.expected, decoded.Problem())
+		})
+	}
+}
+
+func TestError_WithFields(t *testing.T) {
+	errInvalid := errors.NewWrongInput("invalid request")
+	withFields := errInvalid.WithFields(errors.FieldError{Field: "name"})
+
+	assert.True(t, stdErrors.Is(withFields, errInvalid))
+	assert.Nil(t, errInvalid.Fields)
+	assert.Len(t, withFields.Fields, 1)
+}
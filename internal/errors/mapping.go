@@ -0,0 +1,20 @@
+package errors
+
+// Domain errors the infrastructure errors are translated to before
+// reaching the client, see the error mapping of the http server
+var (
+	// ErrResourceNotFound used when the requested record does not exist
+	ErrResourceNotFound = NewNotFound("resource not found")
+	// ErrResourceExists used when a record with the same unique key already exists
+	ErrResourceExists = NewConflict("resource already exists")
+	// ErrConcurrentUpdate used when the transaction lost against a concurrent one, it can be retried
+	ErrConcurrentUpdate = NewConflict("resource was updated concurrently, retry the request")
+	// ErrResourceLocked used when the record stayed locked by another request for too long
+	ErrResourceLocked = NewConflict("resource is locked by another request, retry the request")
+	// ErrRequestTimeout used when the request did not complete in time
+	ErrRequestTimeout = NewServiceUnavailable("request timed out")
+	// ErrRequestCanceled used when the client went away before the request completed
+	ErrRequestCanceled = NewClientClosedRequest("request canceled")
+	// ErrInternal is the sanitized error sent for any other failure
+	ErrInternal = NewInternalError("internal server error")
+)
@@ -0,0 +1,71 @@
+// Package etag exposes the version of the entities as ETags and carries
+// the version expected by the caller, given in the If-Match header,
+// through the request context so the services can check it
+package etag
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+)
+
+// Headers used for the optimistic concurrency control
+const (
+	Header        = "ETag"
+	IfMatchHeader = "If-Match"
+)
+
+var (
+	// ErrInvalidIfMatch used when the If-Match header is not a version ETag
+	ErrInvalidIfMatch = internalErrors.NewWrongInput("If-Match header must be the ETag of the entity")
+	// ErrPreconditionFailed used when the entity version is not the one given in If-Match
+	ErrPreconditionFailed = internalErrors.NewPreconditionFailed("entity was modified, fetch it again and retry")
+)
+
+type ifMatchKey struct{}
+
+// Format returns the ETag of the given version
+func Format(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// Parse returns the version of the given If-Match value. A wildcard
+// matches any version, so false is returned as nothing has to be checked
+func Parse(value string) (int64, bool, error) {
+	value = strings.TrimSpace(value)
+	if value == "*" {
+		return 0, false, nil
+	}
+	unquoted, err := strconv.Unquote(strings.TrimPrefix(value, "W/"))
+	if err != nil {
+		return 0, false, ErrInvalidIfMatch
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, false, ErrInvalidIfMatch
+	}
+	return version, true, nil
+}
+
+// WithIfMatch returns a copy of ctx holding the version expected by the caller
+func WithIfMatch(ctx context.Context, version int64) context.Context {
+	return context.WithValue(ctx, ifMatchKey{}, version)
+}
+
+// IfMatch returns the version expected by the caller stored in ctx, if any
+func IfMatch(ctx context.Context) (int64, bool) {
+	version, ok := ctx.Value(ifMatchKey{}).(int64)
+	return version, ok
+}
+
+// Check returns ErrPreconditionFailed when the caller expects
+// a version different from the current one
+func Check(ctx context.Context, current int64) error {
+	expected, ok := IfMatch(ctx)
+	if ok && expected != current {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package etag_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/etag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		value    string
+		version  int64
+		hasMatch bool
+		err      error
+	}{
+		"strong etag": {
+			value:    `"3"`,
+			version:  3,
+			hasMatch: true,
+		},
+		"weak etag": {
+			value:    `W/"3"`,
+			version:  3,
+			hasMatch: true,
+		},
+		"wildcard": {
+			value: "*",
+		},
+		"unquoted": {
+			value: "3",
+			err:   etag.ErrInvalidIfMatch,
+		},
+		"not a version": {
+			value: `"abc"`,
+			err:   etag.ErrInvalidIfMatch,
+		},
+		"zero version": {
+			value: `"0"`,
+			err:   etag.ErrInvalidIfMatch,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			version, ok, err := etag.Parse(tc.value)
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.hasMatch, ok)
+			assert.Equal(t, tc.version, version)
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	version, ok, err := etag.Parse(etag.Format(42))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), version)
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, etag.Check(ctx, 2), "no If-Match given")
+
+	ctx = etag.WithIfMatch(ctx, 2)
+	assert.Nil(t, etag.Check(ctx, 2))
+	assert.Equal(t, etag.ErrPreconditionFailed, etag.Check(ctx, 3))
+}
@@ -0,0 +1,143 @@
+// Package health reports whether the service is alive and ready to serve
+// traffic. Liveness only needs the process to answer, while readiness runs
+// the registered checks, such as the DB ping, requires every background
+// worker to be running and is turned off once the shutdown starts, so the
+// load balancers drain the traffic before the server stops.
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Statuses of a report and of its checks
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// checkTimeout bounds every readiness check
+const checkTimeout = 2 * time.Second
+
+// statusNotRunning is the status of a worker not running
+const statusNotRunning = "not running"
+
+// statusNotReady is the status of a service not started or shutting down
+const statusNotReady = "not ready"
+
+// Check returns an error when the dependency it checks is not usable
+type Check func(context.Context) error
+
+// Report is the result of the readiness checks
+type Report struct {
+	// Status is ok when every check passed
+	Status string `json:"status"`
+	// Checks holds the status of every check and worker by name, the
+	// error message of the failed ones
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// OK checks if the service is ready
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Checker holds the readiness checks and background workers of the service
+type Checker struct {
+	mu      sync.RWMutex
+	ready   bool
+	checks  map[string]Check
+	workers map[string]bool
+}
+
+// NewChecker returns a Checker not ready yet, with no check nor worker
+func NewChecker() *Checker {
+	return &Checker{
+		checks:  map[string]Check{},
+		workers: map[string]bool{},
+	}
+}
+
+// AddCheck registers a readiness check with the given name
+func (c *Checker) AddCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// AddWorker registers a background worker with the given name, the
+// service is not ready until it is running
+func (c *Checker) AddWorker(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.workers[name] = false
+}
+
+// SetRunning records whether the given worker is running
+func (c *Checker) SetRunning(name string, running bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.workers[name] = running
+}
+
+// SetReady turns the readiness on once the service is started, and off
+// as soon as it starts shutting down
+func (c *Checker) SetReady(ready bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ready = ready
+}
+
+// Ready runs every check and returns the readiness report
+func (c *Checker) Ready(ctx context.Context) Report {
+	c.mu.RLock()
+	ready := c.ready
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	report := Report{Status: StatusOK, Checks: map[string]string{}}
+	for name, running := range c.workers {
+		report.Checks[name] = StatusOK
+		if !running {
+			report.Status = StatusUnavailable
+			report.Checks[name] = statusNotRunning
+		}
+	}
+	c.mu.RUnlock()
+
+	if !ready {
+		report.Status = StatusUnavailable
+		report.Checks["service"] = statusNotReady
+	}
+
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			results[i] = check(checkCtx)
+		}(i, checks[name])
+	}
+	wg.Wait()
+
+	for i, name := range names {
+		report.Checks[name] = StatusOK
+		if results[i] != nil {
+			report.Status = StatusUnavailable
+			report.Checks[name] = results[i].Error()
+		}
+	}
+	return report
+}
@@ -0,0 +1,101 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/health"
+)
+
+func TestChecker_Ready(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	failing := func(context.Context) error { return errors.New("connection refused") }
+
+	testCases := map[string]struct {
+		setup    func(*health.Checker)
+		expected health.Report
+	}{
+		"ready": {
+			setup: func(c *health.Checker) {
+				c.AddCheck("postgres", ok)
+				c.AddWorker("relay")
+				c.SetRunning("relay", true)
+				c.SetReady(true)
+			},
+			expected: health.Report{
+				Status: health.StatusOK,
+				Checks: map[string]string{"postgres": "ok", "relay": "ok"},
+			},
+		},
+		"not started": {
+			setup: func(c *health.Checker) {
+				c.AddCheck("postgres", ok)
+			},
+			expected: health.Report{
+				Status: health.StatusUnavailable,
+				Checks: map[string]string{"postgres": "ok", "service": "not ready"},
+			},
+		},
+		"failing check": {
+			setup: func(c *health.Checker) {
+				c.AddCheck("postgres", failing)
+				c.SetReady(true)
+			},
+			expected: health.Report{
+				Status: health.StatusUnavailable,
+				Checks: map[string]string{"postgres": "connection refused"},
+			},
+		},
+		"worker stopped": {
+			setup: func(c *health.Checker) {
+				c.AddWorker("relay")
+				c.SetRunning("relay", true)
+				c.SetRunning("relay", false)
+				c.SetReady(true)
+			},
+			expected: health.Report{
+				Status: health.StatusUnavailable,
+				Checks: map[string]string{"relay": "not running"},
+			},
+		},
+		"shutting down": {
+			setup: func(c *health.Checker) {
+				c.SetReady(true)
+				c.SetReady(false)
+			},
+			expected: health.Report{
+				Status: health.StatusUnavailable,
+				Checks: map[string]string{"service": "not ready"},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			checker := health.NewChecker()
+			tc.setup(checker)
+			report := checker.Ready(context.Background())
+			assert.Equal(t, tc.expected, report)
+			assert.Equal(t, tc.expected.Status == health.StatusOK, report.OK())
+		})
+	}
+}
+
+func TestChecker_Ready_CheckTimeout(t *testing.T) {
+	checker := health.NewChecker()
+	checker.SetReady(true)
+	checker.AddCheck("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	report := checker.Ready(ctx)
+	assert.False(t, report.OK())
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"])
+}
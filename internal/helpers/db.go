@@ -2,28 +2,34 @@ package helpers
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/nachoconques0/schwarz-challenge/internal/errors"
 	"github.com/nachoconques0/schwarz-challenge/internal/postgres"
 	"gorm.io/gorm"
 )
 
+// testPoolMaxOpenConns leaves room for the goroutines of the concurrency checks
+const testPoolMaxOpenConns = 20
+
+// testTables are emptied around every test running on a test pool
+var testTables = []string{
+	"schwarz.audit_log",
+	"schwarz.webhook_delivery",
+	"schwarz.webhook_subscription",
+	"schwarz.outbox",
+	"schwarz.idempotency_key",
+	"schwarz.shopping_cart",
+	"schwarz.coupon",
+}
+
 // Teardown is used to close db Connection and cleanup
 type Teardown func()
 
 // NewTestDB will be used to create a test TX
 // and teardown logic for cleanup
 func NewTestDB() (*gorm.DB, Teardown, error) {
-	opts := &postgres.DBOptions{
-		Host:     "127.0.0.1",
-		Port:     "5435",
-		User:     "schwarz_svc",
-		Password: "schwarz_svc",
-		Database: "schwarz_svc",
-		SSLMode:  "disable",
-	}
-
-	db, err := postgres.NewDB(opts)
+	db, err := postgres.NewDB(testDBOptions())
 	if err != nil {
 		return nil, nil, errors.NewInternalError(fmt.Sprintf("error generating test db connection: %s", err))
 	}
@@ -46,3 +52,40 @@ func NewTestDB() (*gorm.DB, Teardown, error) {
 
 	return tx, teardown, nil
 }
+
+// NewTestPool returns a connection pool that is not wrapped in a transaction,
+// for the tests that need several connections such as the concurrency ones.
+// The tables are emptied when it is created and on teardown, so the tests
+// using it must not run in parallel
+func NewTestPool() (*gorm.DB, Teardown, error) {
+	opts := testDBOptions()
+	opts.MaxOpenConns = testPoolMaxOpenConns
+	db, err := postgres.NewDB(opts)
+	if err != nil {
+		return nil, nil, errors.NewInternalError(fmt.Sprintf("error generating test db connection: %s", err))
+	}
+
+	truncate := "TRUNCATE " + strings.Join(testTables, ", ")
+	teardown := func() {
+		_ = db.Exec(truncate).Error
+		sqlDB, _ := db.DB()
+		_ = sqlDB.Close()
+	}
+
+	if err := db.Exec(truncate).Error; err != nil {
+		return nil, teardown, errors.NewInternalError(fmt.Sprintf("error emptying test db tables: %s", err))
+	}
+
+	return db, teardown, nil
+}
+
+func testDBOptions() *postgres.DBOptions {
+	return &postgres.DBOptions{
+		Host:     "127.0.0.1",
+		Port:     "5435",
+		User:     "schwarz_svc",
+		Password: "schwarz_svc",
+		Database: "schwarz_svc",
+		SSLMode:  "disable",
+	}
+}
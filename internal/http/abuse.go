@@ -0,0 +1,108 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/abuse"
+	"github.com/nachoconques0/schwarz-challenge/internal/auth"
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/logging"
+)
+
+// failedLookupReason is the reason recorded when a coupon or cart is not found
+const failedLookupReason = "not found"
+
+// ErrAbuseStaffOnly used when the abuse events are requested by someone not in the support staff
+var ErrAbuseStaffOnly = internalErrors.NewForbidden("abuse events are only available to the support staff")
+
+// abuseGuardMiddleware locks out the clients that keep failing coupon
+// lookups, answering 429 with a Retry-After header while locked
+func (s *Server) abuseGuardMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := s.clientKey(r)
+		retryAfter, err := s.abuseGuard.Check(key)
+		if errors.Is(err, abuse.ErrTooManyFailedAttempts) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			responseError(w, r, err)
+			return
+		}
+		if err != nil {
+			// the guard must not take the endpoint down with it
+			logging.FromContext(r.Context()).Error("ctrl: checking abuse guard", slog.Any("error", err))
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next(sw, r)
+		if sw.status != http.StatusNotFound {
+			return
+		}
+		if err := s.abuseGuard.RecordFailure(failedLookupReason, key); err != nil {
+			logging.FromContext(r.Context()).Error("ctrl: recording abuse failure", slog.Any("error", err))
+		}
+	}
+}
+
+// listAbuseEvents returns the failures, lockouts and blocked requests
+// recorded by the abuse guard for review
+func (s *Server) listAbuseEvents(w http.ResponseWriter, r *http.Request) {
+	events, err := s.abuseGuard.Events()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: listing abuse events", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+	encodeResponse(w, r, events)
+}
+
+// clientKey identifies the client of the request, by customer or by IP when
+// anonymous. The cart of the request is never part of it, anyone knowing the
+// ID of a cart could lock its owner out otherwise
+func (s *Server) clientKey(r *http.Request) string {
+	if customerID, ok := auth.CustomerID(r.Context()); ok {
+		return "customer:" + customerID.String()
+	}
+	return "ip:" + s.clientIP(r)
+}
+
+// clientIP returns the IP of the client. Behind the trusted proxy it is the
+// last address of its client IP header, the one the proxy added, since the
+// ones before come from the client. Otherwise it is the IP of the connection,
+// headers like X-Forwarded-For are not trusted since the client sets them freely
+func (s *Server) clientIP(r *http.Request) string {
+	if s.clientIPHeader != "" {
+		if values := r.Header.Values(s.clientIPHeader); len(values) > 0 {
+			addrs := strings.Split(values[len(values)-1], ",")
+			if ip := net.ParseIP(strings.TrimSpace(addrs[len(addrs)-1])); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// statusWriter records the status code written by a handler
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader records the status code before writing it, only the first
+// one counts since it is the one sent
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,168 @@
+package http_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nachoconques0/schwarz-challenge/internal/abuse"
+	"github.com/nachoconques0/schwarz-challenge/internal/auth"
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	"github.com/nachoconques0/schwarz-challenge/internal/errors"
+	internalHTTP "github.com/nachoconques0/schwarz-challenge/internal/http"
+	"github.com/nachoconques0/schwarz-challenge/internal/mocks"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_ApplyCouponAbuseGuard(t *testing.T) {
+	config := abuse.Config{MaxFailures: 2, Window: time.Minute, Lockout: time.Minute}
+
+	buildServer := func(t *testing.T, opts ...internalHTTP.Option) (*internalHTTP.Server, *mocks.MockShoppingCartService) {
+		ctrl := gomock.NewController(t)
+		svc := mocks.NewMockShoppingCartService(ctrl)
+		guard, err := abuse.NewGuard(abuse.NewMemoryStore(), config)
+		assert.Nil(t, err)
+		server, err := internalHTTP.NewServer(
+			"8080",
+			internalHTTP.NewShopppingCartCtrl(svc),
+			mocks.NewMockCouponServer(ctrl),
+			append(opts, internalHTTP.WithAbuseGuard(guard), internalHTTP.WithStaffToken(testStaffToken))...,
+		)
+		assert.Nil(t, err)
+		return server, svc
+	}
+	apply := func(server *internalHTTP.Server, cartID uuid.UUID, remoteAddr string, header http.Header) *http.Response {
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/shopping-cart/%s/apply-coupon/%s", cartID, uuid.New()), nil)
+		req.RemoteAddr = remoteAddr
+		for k := range header {
+			req.Header.Set(k, header.Get(k))
+		}
+		recorder := httptest.NewRecorder()
+		server.Handler.ServeHTTP(recorder, req)
+		return recorder.Result()
+	}
+
+	t.Run("locks out client after failed lookups", func(t *testing.T) {
+		server, svc := buildServer(t)
+		svc.EXPECT().ApplyCoupon(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, coupon.ErrCouponNotFound).Times(config.MaxFailures)
+
+		for i := 0; i < config.MaxFailures; i++ {
+			resp := apply(server, uuid.New(), "10.0.0.1:1234", nil)
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		}
+
+		resp := apply(server, uuid.New(), "10.0.0.1:4321", nil)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "60", resp.Header.Get("Retry-After"))
+		errResponse := &errors.Error{}
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(errResponse))
+		assert.Equal(t, abuse.ErrTooManyFailedAttempts.Message, errResponse.Message)
+		_ = resp.Body.Close()
+	})
+
+	t.Run("does not lock out the cart of the probing clients", func(t *testing.T) {
+		server, svc := buildServer(t)
+		svc.EXPECT().ApplyCoupon(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, coupon.ErrCouponNotFound).Times(config.MaxFailures + 1)
+
+		cartID := uuid.New()
+		for i := 0; i < config.MaxFailures; i++ {
+			resp := apply(server, cartID, fmt.Sprintf("10.0.0.%d:1234", i), nil)
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		}
+
+		resp := apply(server, cartID, "10.0.0.100:1234", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, "the owner of the cart can still apply coupons")
+	})
+
+	t.Run("reads the client IP from the trusted proxy header", func(t *testing.T) {
+		server, svc := buildServer(t, internalHTTP.WithClientIPHeader("X-Forwarded-For"))
+		svc.EXPECT().ApplyCoupon(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, coupon.ErrCouponNotFound).Times(config.MaxFailures + 1)
+
+		for i := 0; i < config.MaxFailures; i++ {
+			header := http.Header{}
+			header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d, 203.0.113.7", i))
+			resp := apply(server, uuid.New(), "10.0.0.1:1234", header)
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		}
+
+		header := http.Header{}
+		header.Set("X-Forwarded-For", "203.0.113.7")
+		resp := apply(server, uuid.New(), "10.0.0.1:1234", header)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode, "the addresses set by the client are ignored")
+
+		header.Set("X-Forwarded-For", "203.0.113.8")
+		resp = apply(server, uuid.New(), "10.0.0.1:1234", header)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, "clients behind the same proxy are told apart")
+	})
+
+	t.Run("ignores the proxy header when no proxy is trusted", func(t *testing.T) {
+		server, svc := buildServer(t)
+		svc.EXPECT().ApplyCoupon(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, coupon.ErrCouponNotFound).Times(config.MaxFailures)
+
+		for i := 0; i < config.MaxFailures; i++ {
+			header := http.Header{}
+			header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i))
+			resp := apply(server, uuid.New(), "10.0.0.1:1234", header)
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		}
+
+		resp := apply(server, uuid.New(), "10.0.0.1:1234", nil)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	})
+
+	t.Run("lists the events to the staff", func(t *testing.T) {
+		server, svc := buildServer(t)
+		svc.EXPECT().ApplyCoupon(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, coupon.ErrCouponNotFound).Times(1)
+		resp := apply(server, uuid.New(), "10.0.0.1:1234", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		recorder := httptest.NewRecorder()
+		server.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/abuse/events", nil))
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+		req := httptest.NewRequest(http.MethodGet, "/abuse/events", nil)
+		req.Header.Set(auth.StaffIDHeader, "alice")
+		req.Header.Set(auth.StaffTokenHeader, testStaffToken)
+		recorder = httptest.NewRecorder()
+		server.Handler.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var events []abuse.Event
+		assert.Nil(t, json.NewDecoder(recorder.Body).Decode(&events))
+		assert.Len(t, events, 1)
+		assert.Equal(t, abuse.EventFailure, events[0].Type)
+		assert.Equal(t, "ip:10.0.0.1", events[0].Key)
+	})
+
+	t.Run("tracks authenticated customers across IPs", func(t *testing.T) {
+		server, svc := buildServer(t)
+		svc.EXPECT().ApplyCoupon(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, coupon.ErrCouponNotFound).Times(config.MaxFailures + 1)
+
+		header := http.Header{}
+		header.Set(auth.CustomerIDHeader, uuid.NewString())
+		for i := 0; i < config.MaxFailures; i++ {
+			resp := apply(server, uuid.New(), fmt.Sprintf("10.0.0.%d:1234", i), header)
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		}
+
+		resp := apply(server, uuid.New(), "10.0.0.100:1234", header)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		resp = apply(server, uuid.New(), "10.0.0.100:1234", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("successful applies are not counted", func(t *testing.T) {
+		server, svc := buildServer(t)
+		svc.EXPECT().ApplyCoupon(gomock.Any(), gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{Version: 2}, nil).Times(config.MaxFailures + 1)
+
+		for i := 0; i <= config.MaxFailures; i++ {
+			resp := apply(server, uuid.New(), "10.0.0.1:1234", nil)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
+	"github.com/nachoconques0/schwarz-challenge/internal/auth"
+	"github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/logging"
+)
+
+// ErrAuditStaffOnly used when the audit log is requested by someone not in the support staff
+var ErrAuditStaffOnly = errors.NewForbidden("audit log is only available to the support staff")
+
+// NewAuditCtrl creates a new HTTP Controller
+// with the given audit.Service
+func NewAuditCtrl(svc audit.Service) audit.Server {
+	return &auditController{svc: svc}
+}
+
+// auditController holds the required dependencies
+// in order to implement the service Request
+type auditController struct {
+	svc audit.Service
+}
+
+// ListEntries returns the audit log of the entity given in the query
+func (aCtrl *auditController) ListEntries(w http.ResponseWriter, r *http.Request) {
+	if _, ok := auth.StaffID(r.Context()); !ok {
+		logging.FromContext(r.Context()).Error("ctrl: listing audit entries", slog.Any("error", ErrAuditStaffOnly))
+		responseError(w, r, ErrAuditStaffOnly)
+		return
+	}
+
+	query := r.URL.Query()
+	req := audit.ListRequest{Entity: query.Get("entity")}
+	if id, err := uuid.Parse(query.Get("id")); err == nil {
+		req.ID = id
+	}
+
+	res, err := aCtrl.svc.ListEntries(r.Context(), req)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: listing audit entries", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+	encodeResponse(w, r, res)
+}
@@ -0,0 +1,116 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
+	"github.com/nachoconques0/schwarz-challenge/internal/auth"
+	"github.com/nachoconques0/schwarz-challenge/internal/errors"
+	internalHTTP "github.com/nachoconques0/schwarz-challenge/internal/http"
+	"github.com/nachoconques0/schwarz-challenge/internal/mocks"
+)
+
+func TestController_ListAuditEntries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := mocks.NewMockAuditService(ctrl)
+	controller := internalHTTP.NewAuditCtrl(svc)
+	entityID := uuid.New()
+
+	testCases := map[string]struct {
+		query          string
+		staff          bool
+		mocks          func()
+		expectedStatus int
+		expectedError  error
+	}{
+		"not support staff": {
+			query:          "?entity=coupon&id=" + entityID.String(),
+			mocks:          func() {},
+			expectedStatus: http.StatusForbidden,
+			expectedError:  internalHTTP.ErrAuditStaffOnly,
+		},
+		"invalid id": {
+			query: "?entity=coupon&id=some",
+			staff: true,
+			mocks: func() {
+				svc.EXPECT().ListEntries(gomock.Any(), audit.ListRequest{Entity: audit.EntityCoupon}).Return(nil, audit.ErrMissingEntityID)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  audit.ErrMissingEntityID,
+		},
+		"fail": {
+			query: "?entity=coupon&id=" + entityID.String(),
+			staff: true,
+			mocks: func() {
+				svc.EXPECT().ListEntries(gomock.Any(), gomock.Any()).Return(nil, errTest)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  errTest,
+		},
+		"success": {
+			query: "?entity=shopping_cart&id=" + entityID.String(),
+			staff: true,
+			mocks: func() {
+				svc.EXPECT().ListEntries(gomock.Any(), audit.ListRequest{Entity: audit.EntityShoppingCart, ID: entityID}).Return([]audit.Entry{{
+					EntityType: audit.EntityShoppingCart,
+					EntityID:   entityID,
+					Action:     audit.ActionCreate,
+				}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tc.mocks()
+			req := httptest.NewRequest(http.MethodGet, "/audit"+tc.query, nil)
+			if tc.staff {
+				req = req.WithContext(auth.WithStaffID(req.Context(), "alice"))
+			}
+
+			recorder := httptest.NewRecorder()
+			controller.ListEntries(recorder, req)
+			resp := recorder.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedStatus, recorder.Code)
+			if tc.expectedError != nil {
+				responseErr := &errors.Error{}
+				assert.Nil(t, json.NewDecoder(resp.Body).Decode(responseErr))
+				assert.Equal(t, tc.expectedError, responseErr)
+				return
+			}
+			var entries []audit.Entry
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&entries))
+			assert.Len(t, entries, 1)
+			assert.Equal(t, entityID, entries[0].EntityID)
+		})
+	}
+}
+
+func TestServer_AuditRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	aSrv := mocks.NewMockAuditServer(ctrl)
+	server, err := internalHTTP.NewServer("8080", mocks.NewMockShoppingCartServer(ctrl), mocks.NewMockCouponServer(ctrl), internalHTTP.WithAudit(aSrv), internalHTTP.WithStaffToken(testStaffToken))
+	assert.Nil(t, err)
+
+	aSrv.EXPECT().ListEntries(gomock.Any(), gomock.Any()).Do(func(_ http.ResponseWriter, r *http.Request) {
+		staffID, ok := auth.StaffID(r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, "alice", staffID)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/audit?entity=coupon&id="+uuid.NewString(), nil)
+	req.Header.Set(auth.StaffIDHeader, "alice")
+	req.Header.Set(auth.StaffTokenHeader, testStaffToken)
+	server.Handler.ServeHTTP(httptest.NewRecorder(), req)
+}
@@ -1,26 +1,38 @@
 package http
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
-	"strings"
+	"strconv"
+	"time"
 
-	// embed used for loading request cases
-	_ "embed"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 
 	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
 	"github.com/nachoconques0/schwarz-challenge/internal/errors"
-	"github.com/xeipuuv/gojsonschema"
+	"github.com/nachoconques0/schwarz-challenge/internal/etag"
+	"github.com/nachoconques0/schwarz-challenge/internal/logging"
 )
 
-// ErrInvalidCreateCouponRequest used when create coupon request contains invalid data
-var ErrInvalidCreateCouponRequest = errors.NewWrongInput("invalid create coupon request")
+var (
+	// ErrInvalidCreateCouponRequest used when create coupon request contains invalid data
+	ErrInvalidCreateCouponRequest = errors.NewWrongInput("invalid create coupon request")
+	// ErrInvalidUpdateCouponRequest used when update coupon request contains invalid data
+	ErrInvalidUpdateCouponRequest = errors.NewWrongInput("invalid update coupon request")
+	// ErrInvalidCouponStatsRange used when coupon stats from or until are not RFC 3339 timestamps
+	ErrInvalidCouponStatsRange = errors.NewWrongInput("coupon stats from and until must be RFC 3339 timestamps")
+)
 
-//go:embed schemas/coupon/create.json
-var createRequestSchema []byte
+const (
+	// csvFormat is the value of the format query param used to export as CSV
+	csvFormat = "csv"
+	// maxImportBodySize limits the size of an imported coupons CSV
+	maxImportBodySize = 10 << 20
+)
 
 // NewCouponCtrl creates a new HTTP Controller
 // with the given coupon.Service
@@ -36,63 +48,259 @@ type couponController struct {
 
 // CreateCoupon receives a request in order to create a coupon
 func (cCtrl *couponController) CreateCoupon(w http.ResponseWriter, r *http.Request) {
-	createSchema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(createRequestSchema))
+	payload, err := decodeRequest[coupon.CreateRequest](r, createCouponSchema, ErrInvalidCreateCouponRequest)
 	if err != nil {
-		slog.Error(fmt.Sprintf("creating create coupon schema : %s\n", err))
+		logging.FromContext(r.Context()).Error("ctrl: decoding create coupon request", slog.Any("error", err))
 		responseError(w, r, err)
 		return
 	}
-	requestBytes, err := io.ReadAll(r.Body)
+	res, err := cCtrl.svc.CreateCoupon(r.Context(), payload)
 	if err != nil {
-		slog.Error(fmt.Sprintf("reading create coupon body : %s\n", err))
+		logging.FromContext(r.Context()).Error("creating coupon cart", slog.Any("error", err))
 		responseError(w, r, err)
 		return
 	}
 
-	requestJSON := gojsonschema.NewBytesLoader(requestBytes)
-	result, err := createSchema.Validate(requestJSON)
+	w.Header().Set(etag.Header, etag.Format(res.Version))
+	encodeResponse(w, r, res)
+}
+
+// LisCoupons receives a request in order to list coupons
+func (cCtrl *couponController) LisCoupons(w http.ResponseWriter, r *http.Request) {
+	res, err := cCtrl.svc.ListCoupons(r.Context())
 	if err != nil {
-		slog.Error(fmt.Sprintf("error validating request : %s\n", err))
+		logging.FromContext(r.Context()).Error("listing coupons", slog.Any("error", err))
 		responseError(w, r, err)
 		return
 	}
+	encodeResponse(w, r, res)
+}
 
-	if !result.Valid() {
-		details := make([]string, 0, len(result.Errors()))
-		for _, err := range result.Errors() {
-			details = append(details, fmt.Sprintf("Field:%s, with error:%s:", err.Field(), err.Description()))
-		}
-		slog.Error("create coupon request data is not valid:",
-			slog.String("error_details", strings.Join(details, "\n")),
-		)
-		responseError(w, r, ErrInvalidCreateCouponRequest)
+// UpdateCoupon receives a request in order to update a coupon
+func (cCtrl *couponController) UpdateCoupon(w http.ResponseWriter, r *http.Request) {
+	couponID, err := couponIDFromRequest(r)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: updating coupon", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+
+	payload, err := decodeRequest[coupon.UpdateRequest](r, updateCouponSchema, ErrInvalidUpdateCouponRequest)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: decoding update coupon request", slog.Any("error", err))
+		responseError(w, r, err)
 		return
 	}
 
-	var payload coupon.CreateRequest
-	err = json.Unmarshal(requestBytes, &payload)
+	res, err := cCtrl.svc.UpdateCoupon(r.Context(), couponID, payload)
 	if err != nil {
-		slog.Error(fmt.Sprintf("decoding create coupon request: %s\n", err))
+		logging.FromContext(r.Context()).Error("ctrl: updating coupon", slog.Any("error", err))
 		responseError(w, r, err)
 		return
 	}
-	res, err := cCtrl.svc.CreateCoupon(payload)
+	w.Header().Set(etag.Header, etag.Format(res.Version))
+	encodeResponse(w, r, res)
+}
+
+// DeactivateCoupon receives a request in order to deactivate a coupon
+func (cCtrl *couponController) DeactivateCoupon(w http.ResponseWriter, r *http.Request) {
+	couponID, err := couponIDFromRequest(r)
 	if err != nil {
-		slog.Error(fmt.Sprintf("creating coupon cart: %s\n", err))
+		logging.FromContext(r.Context()).Error("ctrl: deactivating coupon", slog.Any("error", err))
 		responseError(w, r, err)
 		return
 	}
 
-	encodeResponse(w, res)
+	res, err := cCtrl.svc.DeactivateCoupon(r.Context(), couponID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: deactivating coupon", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+	w.Header().Set(etag.Header, etag.Format(res.Version))
+	encodeResponse(w, r, res)
 }
 
-// LisCoupons receives a request in order to list coupons
-func (cCtrl *couponController) LisCoupons(w http.ResponseWriter, r *http.Request) {
-	res, err := cCtrl.svc.ListCoupons()
+// ReactivateCoupon receives a request in order to reactivate a coupon
+func (cCtrl *couponController) ReactivateCoupon(w http.ResponseWriter, r *http.Request) {
+	couponID, err := couponIDFromRequest(r)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: reactivating coupon", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+
+	res, err := cCtrl.svc.ReactivateCoupon(r.Context(), couponID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: reactivating coupon", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+	w.Header().Set(etag.Header, etag.Format(res.Version))
+	encodeResponse(w, r, res)
+}
+
+// DeleteCoupon receives a request in order to delete a coupon
+func (cCtrl *couponController) DeleteCoupon(w http.ResponseWriter, r *http.Request) {
+	couponID, err := couponIDFromRequest(r)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: deleting coupon", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+
+	err = cCtrl.svc.DeleteCoupon(r.Context(), couponID)
 	if err != nil {
-		slog.Error(fmt.Sprintf("listing coupons: %s\n", err))
+		logging.FromContext(r.Context()).Error("ctrl: deleting coupon", slog.Any("error", err))
 		responseError(w, r, err)
 		return
 	}
-	encodeResponse(w, res)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetCouponStats returns the redemption stats of a coupon as JSON,
+// or as CSV when the format query param is csv
+func (cCtrl *couponController) GetCouponStats(w http.ResponseWriter, r *http.Request) {
+	couponID, err := couponIDFromRequest(r)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: getting coupon stats", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	req := coupon.StatsRequest{Interval: query.Get("interval")}
+	req.From, err = parseTimeParam(query.Get("from"))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: parsing coupon stats from", slog.Any("error", err))
+		responseError(w, r, ErrInvalidCouponStatsRange)
+		return
+	}
+	req.Until, err = parseTimeParam(query.Get("until"))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: parsing coupon stats until", slog.Any("error", err))
+		responseError(w, r, ErrInvalidCouponStatsRange)
+		return
+	}
+
+	res, err := cCtrl.svc.GetCouponStats(r.Context(), couponID, req)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: getting coupon stats", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+
+	if query.Get("format") == csvFormat {
+		encodeStatsCSV(w, r, res)
+		return
+	}
+	encodeResponse(w, r, res)
+}
+
+// encodeStatsCSV writes one row per bucket of the given stats
+// followed by a total row
+func encodeStatsCSV(w http.ResponseWriter, r *http.Request, stats *coupon.Stats) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"coupon-%s-stats.csv\"", stats.CouponID))
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	records := [][]string{{"bucket_start", "redemptions", "total_discount", "average_cart_value"}}
+	for _, b := range stats.Buckets {
+		records = append(records, statsCSVRecord(b.Start.Format(time.RFC3339), b.Redemptions, b.TotalDiscount, b.AverageCartValue))
+	}
+	records = append(records, statsCSVRecord("total", stats.Redemptions, stats.TotalDiscount, stats.AverageCartValue))
+	if err := cw.WriteAll(records); err != nil {
+		logging.FromContext(r.Context()).Error("encoding csv response", slog.Any("error", err))
+	}
+}
+
+func statsCSVRecord(start string, redemptions int64, totalDiscount, averageCartValue float64) []string {
+	return []string{
+		start,
+		strconv.FormatInt(redemptions, 10),
+		strconv.FormatFloat(totalDiscount, 'f', 2, 64),
+		strconv.FormatFloat(averageCartValue, 'f', 2, 64),
+	}
+}
+
+// ImportCoupons receives a CSV in order to create coupons, the mode query
+// param selects between partial (default) and all_or_nothing imports
+func (cCtrl *couponController) ImportCoupons(w http.ResponseWriter, r *http.Request) {
+	rows, err := coupon.ParseCSV(http.MaxBytesReader(w, r.Body, maxImportBodySize))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: parsing coupons csv", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+
+	res, err := cCtrl.svc.ImportCoupons(r.Context(), rows, r.URL.Query().Get("mode"))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: importing coupons", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+
+	if res.Mode == coupon.ImportModeAllOrNothing && len(res.Errors) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			logging.FromContext(r.Context()).Error("encoding response", slog.Any("error", err))
+		}
+		return
+	}
+	encodeResponse(w, r, res)
+}
+
+// ExportCoupons streams every coupon as CSV
+func (cCtrl *couponController) ExportCoupons(w http.ResponseWriter, r *http.Request) {
+	tw := &writeTracker{ResponseWriter: w}
+	cw := coupon.NewCSVWriter(tw)
+	tw.Header().Set("Content-Type", "text/csv")
+	tw.Header().Set("Content-Disposition", `attachment; filename="coupons.csv"`)
+
+	err := cCtrl.svc.ExportCoupons(r.Context(), cw.Write)
+	if err == nil {
+		err = cw.Flush()
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: exporting coupons", slog.Any("error", err))
+		// once the first rows are sent the status can not change anymore
+		if !tw.written {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Del("Content-Disposition")
+			responseError(w, r, err)
+		}
+	}
+}
+
+// writeTracker records whether anything was written into the response
+type writeTracker struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (wt *writeTracker) Write(b []byte) (int, error) {
+	wt.written = true
+	return wt.ResponseWriter.Write(b)
+}
+
+// parseTimeParam parses an optional RFC 3339 query param
+func parseTimeParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// couponIDFromRequest returns the coupon ID set in the request path
+func couponIDFromRequest(r *http.Request) (uuid.UUID, error) {
+	couponID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		return uuid.Nil, ErrCouponEmptyID
+	}
+	return couponID, nil
 }
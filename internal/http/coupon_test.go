@@ -2,11 +2,15 @@ package http_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
 	"github.com/nachoconques0/schwarz-challenge/internal/errors"
 	internalHTTP "github.com/nachoconques0/schwarz-challenge/internal/http"
@@ -34,7 +38,7 @@ func TestController_CreateCoupon(t *testing.T) {
 	}
 
 	t.Run("success", func(t *testing.T) {
-		svc.EXPECT().CreateCoupon(gomock.Any()).Return(&coupon.Coupon{
+		svc.EXPECT().CreateCoupon(gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
 			Name:   testName,
 			Amount: float32(testAmount),
 		}, nil)
@@ -56,7 +60,7 @@ func TestController_CreateCoupon(t *testing.T) {
 	})
 
 	t.Run("fail", func(t *testing.T) {
-		svc.EXPECT().CreateCoupon(gomock.Any()).Return(nil, errTest)
+		svc.EXPECT().CreateCoupon(gomock.Any(), gomock.Any()).Return(nil, errTest)
 		body, _ := json.Marshal(bodyParams)
 		req, err := http.NewRequest(http.MethodPost, "http://www.test.com", bytes.NewBuffer(body))
 		assert.Nil(t, err)
@@ -81,7 +85,7 @@ func TestController_LisCoupons(t *testing.T) {
 	controller := internalHTTP.NewCouponCtrl(svc)
 
 	t.Run("success", func(t *testing.T) {
-		svc.EXPECT().ListCoupons().Return([]coupon.Coupon{
+		svc.EXPECT().ListCoupons(gomock.Any()).Return([]coupon.Coupon{
 			{
 				Name:   testName,
 				Amount: float32(testAmount),
@@ -103,7 +107,7 @@ func TestController_LisCoupons(t *testing.T) {
 	})
 
 	t.Run("fail", func(t *testing.T) {
-		svc.EXPECT().ListCoupons().Return(nil, errTest)
+		svc.EXPECT().ListCoupons(gomock.Any()).Return(nil, errTest)
 		req, err := http.NewRequest(http.MethodPost, "http://www.test.com", nil)
 		assert.Nil(t, err)
 
@@ -118,3 +122,374 @@ func TestController_LisCoupons(t *testing.T) {
 		_ = resp.Body.Close()
 	})
 }
+
+func TestController_UpdateCoupon(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := mocks.NewMockCouponService(ctrl)
+	controller := internalHTTP.NewCouponCtrl(svc)
+	couponID := uuid.New()
+
+	t.Run("success", func(t *testing.T) {
+		svc.EXPECT().UpdateCoupon(gomock.Any(), couponID, gomock.Any()).DoAndReturn(func(_ context.Context, _ uuid.UUID, req coupon.UpdateRequest) (*coupon.Coupon, error) {
+			assert.Equal(t, testName, *req.Name)
+			assert.Nil(t, req.Amount)
+			return &coupon.Coupon{ID: couponID, Name: *req.Name}, nil
+		})
+
+		body, _ := json.Marshal(map[string]interface{}{"name": testName})
+		req, err := http.NewRequest(http.MethodPatch, "http://www.test.com", bytes.NewBuffer(body))
+		assert.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": couponID.String()})
+
+		recorder := httptest.NewRecorder()
+		controller.UpdateCoupon(recorder, req)
+		resp := recorder.Result()
+
+		response := &coupon.Coupon{}
+		err = json.NewDecoder(resp.Body).Decode(response)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, testName, response.Name)
+		_ = resp.Body.Close()
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]interface{}{"used": true})
+		req, err := http.NewRequest(http.MethodPatch, "http://www.test.com", bytes.NewBuffer(body))
+		assert.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": couponID.String()})
+
+		recorder := httptest.NewRecorder()
+		controller.UpdateCoupon(recorder, req)
+		resp := recorder.Result()
+
+		responseErr := &errors.Error{}
+		err = json.NewDecoder(resp.Body).Decode(responseErr)
+		assert.Nil(t, err)
+		expected := internalHTTP.ErrInvalidUpdateCouponRequest.WithFields(errors.FieldError{
+			Field:   "used",
+			Code:    "additional_property_not_allowed",
+			Message: "Additional property used is not allowed",
+		})
+		assert.Equal(t, expected.Problem(), responseErr.Problem())
+		_ = resp.Body.Close()
+	})
+
+	t.Run("invalid coupon id", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPatch, "http://www.test.com", nil)
+		assert.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": "invalid"})
+
+		recorder := httptest.NewRecorder()
+		controller.UpdateCoupon(recorder, req)
+		resp := recorder.Result()
+
+		responseErr := &errors.Error{}
+		err = json.NewDecoder(resp.Body).Decode(responseErr)
+		assert.Nil(t, err)
+		assert.Equal(t, internalHTTP.ErrCouponEmptyID, responseErr)
+		_ = resp.Body.Close()
+	})
+}
+
+func TestController_DeactivateCoupon(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := mocks.NewMockCouponService(ctrl)
+	controller := internalHTTP.NewCouponCtrl(svc)
+	couponID := uuid.New()
+	deactivatedAt := time.Now()
+
+	t.Run("success", func(t *testing.T) {
+		svc.EXPECT().DeactivateCoupon(gomock.Any(), couponID).Return(&coupon.Coupon{ID: couponID, DeactivatedAt: &deactivatedAt}, nil)
+		req, err := http.NewRequest(http.MethodPut, "http://www.test.com", nil)
+		assert.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": couponID.String()})
+
+		recorder := httptest.NewRecorder()
+		controller.DeactivateCoupon(recorder, req)
+		resp := recorder.Result()
+
+		response := &coupon.Coupon{}
+		err = json.NewDecoder(resp.Body).Decode(response)
+		assert.Nil(t, err)
+		assert.False(t, response.IsActive())
+		_ = resp.Body.Close()
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		svc.EXPECT().DeactivateCoupon(gomock.Any(), couponID).Return(nil, errTest)
+		req, err := http.NewRequest(http.MethodPut, "http://www.test.com", nil)
+		assert.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": couponID.String()})
+
+		recorder := httptest.NewRecorder()
+		controller.DeactivateCoupon(recorder, req)
+		resp := recorder.Result()
+
+		responseErr := &errors.Error{}
+		err = json.NewDecoder(resp.Body).Decode(responseErr)
+		assert.Nil(t, err)
+		assert.Equal(t, errTest, responseErr)
+		_ = resp.Body.Close()
+	})
+}
+
+func TestController_ReactivateCoupon(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := mocks.NewMockCouponService(ctrl)
+	controller := internalHTTP.NewCouponCtrl(svc)
+	couponID := uuid.New()
+
+	svc.EXPECT().ReactivateCoupon(gomock.Any(), couponID).Return(&coupon.Coupon{ID: couponID}, nil)
+	req, err := http.NewRequest(http.MethodPut, "http://www.test.com", nil)
+	assert.Nil(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": couponID.String()})
+
+	recorder := httptest.NewRecorder()
+	controller.ReactivateCoupon(recorder, req)
+	resp := recorder.Result()
+
+	response := &coupon.Coupon{}
+	err = json.NewDecoder(resp.Body).Decode(response)
+	assert.Nil(t, err)
+	assert.True(t, response.IsActive())
+	_ = resp.Body.Close()
+}
+
+func TestController_DeleteCoupon(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := mocks.NewMockCouponService(ctrl)
+	controller := internalHTTP.NewCouponCtrl(svc)
+	couponID := uuid.New()
+
+	t.Run("success", func(t *testing.T) {
+		svc.EXPECT().DeleteCoupon(gomock.Any(), couponID).Return(nil)
+		req, err := http.NewRequest(http.MethodDelete, "http://www.test.com", nil)
+		assert.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": couponID.String()})
+
+		recorder := httptest.NewRecorder()
+		controller.DeleteCoupon(recorder, req)
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		svc.EXPECT().DeleteCoupon(gomock.Any(), couponID).Return(errTest)
+		req, err := http.NewRequest(http.MethodDelete, "http://www.test.com", nil)
+		assert.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": couponID.String()})
+
+		recorder := httptest.NewRecorder()
+		controller.DeleteCoupon(recorder, req)
+		resp := recorder.Result()
+
+		responseErr := &errors.Error{}
+		err = json.NewDecoder(resp.Body).Decode(responseErr)
+		assert.Nil(t, err)
+		assert.Equal(t, errTest, responseErr)
+		_ = resp.Body.Close()
+	})
+}
+
+func TestController_GetCouponStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := mocks.NewMockCouponService(ctrl)
+	controller := internalHTTP.NewCouponCtrl(svc)
+	couponID := uuid.New()
+	bucketStart := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	stats := &coupon.Stats{
+		CouponID:         couponID,
+		Redemptions:      2,
+		TotalDiscount:    20,
+		AverageCartValue: 55.5,
+		Interval:         coupon.StatsIntervalDay,
+		Buckets: []coupon.StatsBucket{
+			{Start: bucketStart, Redemptions: 2, TotalDiscount: 20, AverageCartValue: 55.5},
+		},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		svc.EXPECT().GetCouponStats(gomock.Any(), couponID, coupon.StatsRequest{Interval: coupon.StatsIntervalDay}).Return(stats, nil)
+		req, err := http.NewRequest(http.MethodGet, "http://www.test.com?interval=day", nil)
+		assert.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": couponID.String()})
+
+		recorder := httptest.NewRecorder()
+		controller.GetCouponStats(recorder, req)
+		resp := recorder.Result()
+
+		response := &coupon.Stats{}
+		err = json.NewDecoder(resp.Body).Decode(response)
+		assert.Nil(t, err)
+		assert.Equal(t, stats.Redemptions, response.Redemptions)
+		assert.Len(t, response.Buckets, 1)
+		_ = resp.Body.Close()
+	})
+
+	t.Run("success as csv", func(t *testing.T) {
+		svc.EXPECT().GetCouponStats(gomock.Any(), couponID, gomock.Any()).Return(stats, nil)
+		req, err := http.NewRequest(http.MethodGet, "http://www.test.com?format=csv", nil)
+		assert.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": couponID.String()})
+
+		recorder := httptest.NewRecorder()
+		controller.GetCouponStats(recorder, req)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "text/csv", recorder.Header().Get("Content-Type"))
+		assert.Equal(t, "bucket_start,redemptions,total_discount,average_cart_value\n"+
+			"2026-10-19T00:00:00Z,2,20.00,55.50\n"+
+			"total,2,20.00,55.50\n", recorder.Body.String())
+	})
+
+	t.Run("invalid range", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://www.test.com?from=yesterday", nil)
+		assert.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": couponID.String()})
+
+		recorder := httptest.NewRecorder()
+		controller.GetCouponStats(recorder, req)
+		resp := recorder.Result()
+
+		responseErr := &errors.Error{}
+		err = json.NewDecoder(resp.Body).Decode(responseErr)
+		assert.Nil(t, err)
+		assert.Equal(t, internalHTTP.ErrInvalidCouponStatsRange, responseErr)
+		_ = resp.Body.Close()
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		svc.EXPECT().GetCouponStats(gomock.Any(), couponID, gomock.Any()).Return(nil, errTest)
+		req, err := http.NewRequest(http.MethodGet, "http://www.test.com", nil)
+		assert.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": couponID.String()})
+
+		recorder := httptest.NewRecorder()
+		controller.GetCouponStats(recorder, req)
+		resp := recorder.Result()
+
+		responseErr := &errors.Error{}
+		err = json.NewDecoder(resp.Body).Decode(responseErr)
+		assert.Nil(t, err)
+		assert.Equal(t, errTest, responseErr)
+		_ = resp.Body.Close()
+	})
+}
+
+func TestController_ImportCoupons(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := mocks.NewMockCouponService(ctrl)
+	controller := internalHTTP.NewCouponCtrl(svc)
+	body := "name,code,amount,type,valid_from,valid_until\nFREE30,FREE30,30,fixed,,\n"
+
+	t.Run("success", func(t *testing.T) {
+		svc.EXPECT().ImportCoupons(gomock.Any(), gomock.Any(), coupon.ImportModePartial).DoAndReturn(func(_ context.Context, rows []coupon.ImportRow, mode string) (*coupon.ImportResult, error) {
+			assert.Len(t, rows, 1)
+			assert.Equal(t, "FREE30", rows[0].Request.Code)
+			return &coupon.ImportResult{Mode: mode, Total: 1, Imported: 1}, nil
+		})
+		req, err := http.NewRequest(http.MethodPost, "http://www.test.com?mode=partial", bytes.NewBufferString(body))
+		assert.Nil(t, err)
+
+		recorder := httptest.NewRecorder()
+		controller.ImportCoupons(recorder, req)
+		resp := recorder.Result()
+
+		response := &coupon.ImportResult{}
+		err = json.NewDecoder(resp.Body).Decode(response)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, 1, response.Imported)
+		_ = resp.Body.Close()
+	})
+
+	t.Run("all or nothing with errors", func(t *testing.T) {
+		svc.EXPECT().ImportCoupons(gomock.Any(), gomock.Any(), coupon.ImportModeAllOrNothing).Return(&coupon.ImportResult{
+			Mode:   coupon.ImportModeAllOrNothing,
+			Total:  1,
+			Errors: []coupon.ImportError{{Line: 2, Error: "coupon invalid amount"}},
+		}, nil)
+		req, err := http.NewRequest(http.MethodPost, "http://www.test.com?mode=all_or_nothing", bytes.NewBufferString(body))
+		assert.Nil(t, err)
+
+		recorder := httptest.NewRecorder()
+		controller.ImportCoupons(recorder, req)
+		resp := recorder.Result()
+
+		response := &coupon.ImportResult{}
+		err = json.NewDecoder(resp.Body).Decode(response)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		assert.Len(t, response.Errors, 1)
+		_ = resp.Body.Close()
+	})
+
+	t.Run("invalid csv", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "http://www.test.com", bytes.NewBufferString("code\nFREE30\n"))
+		assert.Nil(t, err)
+
+		recorder := httptest.NewRecorder()
+		controller.ImportCoupons(recorder, req)
+		resp := recorder.Result()
+
+		responseErr := &errors.Error{}
+		err = json.NewDecoder(resp.Body).Decode(responseErr)
+		assert.Nil(t, err)
+		assert.Equal(t, coupon.ErrCouponInvalidCSVHeader, responseErr)
+		_ = resp.Body.Close()
+	})
+}
+
+func TestController_ExportCoupons(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := mocks.NewMockCouponService(ctrl)
+	controller := internalHTTP.NewCouponCtrl(svc)
+
+	t.Run("success", func(t *testing.T) {
+		svc.EXPECT().ExportCoupons(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, fn func(coupon.Coupon) error) error {
+			return fn(coupon.Coupon{Name: testName, Code: "FREE30", Amount: float32(testAmount)})
+		})
+		req, err := http.NewRequest(http.MethodGet, "http://www.test.com", nil)
+		assert.Nil(t, err)
+
+		recorder := httptest.NewRecorder()
+		controller.ExportCoupons(recorder, req)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "text/csv", recorder.Header().Get("Content-Type"))
+		rows, err := coupon.ParseCSV(recorder.Body)
+		assert.Nil(t, err)
+		assert.Len(t, rows, 1)
+		assert.Equal(t, "FREE30", rows[0].Request.Code)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		svc.EXPECT().ExportCoupons(gomock.Any(), gomock.Any()).Return(errTest)
+		req, err := http.NewRequest(http.MethodGet, "http://www.test.com", nil)
+		assert.Nil(t, err)
+
+		recorder := httptest.NewRecorder()
+		controller.ExportCoupons(recorder, req)
+		resp := recorder.Result()
+
+		responseErr := &errors.Error{}
+		err = json.NewDecoder(resp.Body).Decode(responseErr)
+		assert.Nil(t, err)
+		assert.Equal(t, errTest, responseErr)
+		_ = resp.Body.Close()
+	})
+}
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+)
+
+// Postgres error codes mapped to domain errors,
+// see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	sqlStateUniqueViolation      = "23505"
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+	sqlStateLockNotAvailable     = "55P03"
+	sqlStateQueryCanceled        = "57014"
+)
+
+// sqlStateError is implemented by the errors returned by the postgres
+// driver, matched on the method to keep the driver out of this package
+type sqlStateError interface {
+	SQLState() string
+}
+
+// mapError translates the given error into a domain error. Domain errors
+// are returned as they are, known infrastructure errors are translated to
+// the matching domain error and any other error becomes ErrInternal, so
+// its details never reach the client.
+func mapError(err error) *internalErrors.Error {
+	var internalErr *internalErrors.Error
+	if errors.As(err, &internalErr) {
+		return internalErr
+	}
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return internalErrors.ErrResourceNotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		return internalErrors.ErrRequestTimeout
+	case errors.Is(err, context.Canceled):
+		return internalErrors.ErrRequestCanceled
+	}
+	var stateErr sqlStateError
+	if errors.As(err, &stateErr) {
+		switch stateErr.SQLState() {
+		case sqlStateUniqueViolation:
+			return internalErrors.ErrResourceExists
+		case sqlStateSerializationFailure, sqlStateDeadlockDetected:
+			return internalErrors.ErrConcurrentUpdate
+		case sqlStateLockNotAvailable:
+			return internalErrors.ErrResourceLocked
+		case sqlStateQueryCanceled:
+			return internalErrors.ErrRequestTimeout
+		}
+	}
+	return internalErrors.ErrInternal
+}
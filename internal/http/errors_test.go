@@ -0,0 +1,100 @@
+package http_test
+
+import (
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/errors"
+	internalHTTP "github.com/nachoconques0/schwarz-challenge/internal/http"
+)
+
+func TestMapError(t *testing.T) {
+	errDomain := errors.NewPreconditionFailed("coupon version is stale")
+
+	testCases := map[string]struct {
+		err      error
+		expected *errors.Error
+		status   int
+	}{
+		"domain error": {
+			err:      errDomain,
+			expected: errDomain,
+			status:   412,
+		},
+		"wrapped domain error": {
+			err:      fmt.Errorf("applying coupon: %w", errDomain),
+			expected: errDomain,
+			status:   412,
+		},
+		"record not found": {
+			err:      fmt.Errorf("finding coupon: %w", gorm.ErrRecordNotFound),
+			expected: errors.ErrResourceNotFound,
+			status:   404,
+		},
+		"unique violation": {
+			err:      &pgconn.PgError{Code: "23505", ConstraintName: "idx_coupons_code"},
+			expected: errors.ErrResourceExists,
+			status:   409,
+		},
+		"serialization failure": {
+			err:      fmt.Errorf("committing: %w", &pgconn.PgError{Code: "40001"}),
+			expected: errors.ErrConcurrentUpdate,
+			status:   409,
+		},
+		"deadlock": {
+			err:      &pgconn.PgError{Code: "40P01"},
+			expected: errors.ErrConcurrentUpdate,
+			status:   409,
+		},
+		"lock timeout": {
+			err:      &pgconn.PgError{Code: "55P03"},
+			expected: errors.ErrResourceLocked,
+			status:   409,
+		},
+		"statement timeout": {
+			err:      &pgconn.PgError{Code: "57014"},
+			expected: errors.ErrRequestTimeout,
+			status:   503,
+		},
+		"deadline exceeded": {
+			err:      fmt.Errorf("querying: %w", context.DeadlineExceeded),
+			expected: errors.ErrRequestTimeout,
+			status:   503,
+		},
+		"context canceled": {
+			err:      context.Canceled,
+			expected: errors.ErrRequestCanceled,
+			status:   errors.StatusClientClosedRequest,
+		},
+		"other postgres error": {
+			err:      &pgconn.PgError{Code: "42P01", Message: `relation "coupons" does not exist`},
+			expected: errors.ErrInternal,
+			status:   500,
+		},
+		"io error": {
+			err:      io.ErrUnexpectedEOF,
+			expected: errors.ErrInternal,
+			status:   500,
+		},
+		"plain error": {
+			err:      stdErrors.New("dial tcp 10.0.0.5:5432: connection refused"),
+			expected: errors.ErrInternal,
+			status:   500,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			mapped := internalHTTP.MapError(tc.err)
+			assert.Same(t, tc.expected, mapped)
+			assert.Equal(t, tc.status, mapped.HTTPStatus())
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package http
+
+// GenerateRequestSchemas returns the JSON schemas generated from the
+// request types by their name
+func GenerateRequestSchemas() (map[string][]byte, error) {
+	generated := map[string][]byte{}
+	for _, rs := range requestSchemas {
+		content, err := rs.generate()
+		if err != nil {
+			return nil, err
+		}
+		generated[rs.name] = content
+	}
+	return generated, nil
+}
+
+// MapError translates the given error into the domain error sent to the client
+var MapError = mapError
@@ -0,0 +1,41 @@
+package http
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/health"
+	"github.com/nachoconques0/schwarz-challenge/internal/logging"
+)
+
+// healthRouter holds the routing for the liveness and readiness endpoints
+func (s *Server) healthRouter(r *mux.Router) {
+	r.HandleFunc("/healthz", healthz).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc("/readyz", s.readyz).Methods(http.MethodGet, http.MethodHead)
+}
+
+// healthz answers as long as the process is able to serve requests
+func healthz(w http.ResponseWriter, r *http.Request) {
+	encodeResponse(w, r, health.Report{Status: health.StatusOK})
+}
+
+// readyz answers 200 when the service can take traffic and 503 otherwise,
+// with the status of every check
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	report := health.Report{Status: health.StatusOK}
+	if s.healthChecker != nil {
+		report = s.healthChecker.Ready(r.Context())
+	}
+	if report.OK() {
+		encodeResponse(w, r, report)
+		return
+	}
+	logging.FromContext(r.Context()).Error("ctrl: service not ready", slog.Any("checks", report.Checks))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logging.FromContext(r.Context()).Error("encoding response", slog.Any("error", err))
+	}
+}
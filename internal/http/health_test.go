@@ -0,0 +1,96 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/health"
+	internalHTTP "github.com/nachoconques0/schwarz-challenge/internal/http"
+	"github.com/nachoconques0/schwarz-challenge/internal/mocks"
+)
+
+func TestServer_Health(t *testing.T) {
+	checker := health.NewChecker()
+	checker.AddWorker("relay")
+	checker.AddCheck("postgres", func(context.Context) error { return errors.New("connection refused") })
+
+	ctrl := gomock.NewController(t)
+	server, err := internalHTTP.NewServer("8080", mocks.NewMockShoppingCartServer(ctrl), mocks.NewMockCouponServer(ctrl), internalHTTP.WithHealth(checker))
+	assert.Nil(t, err)
+
+	testCases := map[string]struct {
+		path     string
+		setup    func()
+		status   int
+		expected health.Report
+	}{
+		"alive": {
+			path:     "/healthz",
+			setup:    func() {},
+			status:   http.StatusOK,
+			expected: health.Report{Status: health.StatusOK},
+		},
+		"not ready": {
+			path:   "/readyz",
+			setup:  func() {},
+			status: http.StatusServiceUnavailable,
+			expected: health.Report{
+				Status: health.StatusUnavailable,
+				Checks: map[string]string{"postgres": "connection refused", "relay": "not running", "service": "not ready"},
+			},
+		},
+		"ready": {
+			path: "/readyz",
+			setup: func() {
+				checker.AddCheck("postgres", func(context.Context) error { return nil })
+				checker.SetRunning("relay", true)
+				checker.SetReady(true)
+			},
+			status: http.StatusOK,
+			expected: health.Report{
+				Status: health.StatusOK,
+				Checks: map[string]string{"postgres": "ok", "relay": "ok"},
+			},
+		},
+		"draining": {
+			path:   "/readyz",
+			setup:  func() { checker.SetReady(false) },
+			status: http.StatusServiceUnavailable,
+			expected: health.Report{
+				Status: health.StatusUnavailable,
+				Checks: map[string]string{"postgres": "ok", "relay": "ok", "service": "not ready"},
+			},
+		},
+	}
+
+	for _, name := range []string{"alive", "not ready", "ready", "draining"} {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			tc.setup()
+			rec := httptest.NewRecorder()
+			server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.status, rec.Code)
+
+			var report health.Report
+			assert.Nil(t, json.NewDecoder(rec.Body).Decode(&report))
+			assert.Equal(t, tc.expected, report)
+		})
+	}
+}
+
+func TestServer_Readyz_WithoutChecker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	server, err := internalHTTP.NewServer("8080", mocks.NewMockShoppingCartServer(ctrl), mocks.NewMockCouponServer(ctrl))
+	assert.Nil(t, err)
+
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,172 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/auth"
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/idempotency"
+	"github.com/nachoconques0/schwarz-challenge/internal/logging"
+)
+
+// ErrReadingBody used when the request body can not be read
+var ErrReadingBody = internalErrors.NewWrongInput("request body can not be read")
+
+// idempotencyMiddleware replays the recorded response to the retries of a
+// request sent with an Idempotency-Key header, instead of running it again
+func (s *Server) idempotencyMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotency.KeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if err := idempotency.ValidateKey(key); err != nil {
+			logging.FromContext(r.Context()).Error("ctrl: checking idempotency key", slog.Any("error", err))
+			responseError(w, r, err)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+		if err != nil {
+			logging.FromContext(r.Context()).Error("ctrl: reading idempotent request", slog.Any("error", err))
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				responseError(w, r, ErrRequestBodyTooLarge)
+				return
+			}
+			responseError(w, r, ErrReadingBody)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := time.Now()
+		fingerprint := idempotency.Fingerprint(r.Method, r.URL.Path, body)
+		rec := idempotency.Record{
+			Scope:       idempotencyScope(r, fingerprint),
+			Key:         key,
+			Fingerprint: fingerprint,
+			CreatedAt:   now,
+		}
+		stored, err := s.idempotencyStore.Reserve(r.Context(), rec, now.Add(-s.idempotencyTTL), now.Add(-s.idempotencyLease()))
+		if err != nil {
+			logging.FromContext(r.Context()).Error("ctrl: reserving idempotency key", slog.Any("error", err))
+			if !errors.Is(err, idempotency.ErrRequestInProgress) {
+				err = idempotency.ErrUnavailable
+			}
+			responseError(w, r, err)
+			return
+		}
+		if stored != nil {
+			replayResponse(w, r, rec, stored)
+			return
+		}
+
+		// the response is already sent, storing it must not be canceled with the request
+		ctx := context.WithoutCancel(r.Context())
+		release := func() {
+			if err := s.idempotencyStore.Release(ctx, rec.Scope, rec.Key); err != nil {
+				logging.FromContext(r.Context()).Error("ctrl: releasing idempotency key", slog.Any("error", err))
+			}
+		}
+		defer func() {
+			if p := recover(); p != nil {
+				release()
+				panic(p)
+			}
+		}()
+
+		rw := &recordingWriter{ResponseWriter: w}
+		next(rw, r)
+
+		if rw.status >= http.StatusInternalServerError {
+			release()
+			return
+		}
+		completedAt := time.Now()
+		rec.StatusCode = rw.status
+		if rec.StatusCode == 0 {
+			rec.StatusCode = http.StatusOK
+		}
+		rec.Header = idempotency.Header(rw.header)
+		rec.Body = rw.body.Bytes()
+		rec.CompletedAt = &completedAt
+		if err := s.idempotencyStore.Complete(ctx, rec); err != nil {
+			logging.FromContext(r.Context()).Error("ctrl: recording idempotent response", slog.Any("error", err))
+		}
+	}
+}
+
+// idempotencyScope isolates the keys of every authenticated caller. The
+// anonymous callers can not be told apart, so their keys are scoped by the
+// request itself and only ever replay the very same request
+func idempotencyScope(r *http.Request, fingerprint string) string {
+	actor := auth.Actor(r.Context())
+	if actor == auth.AnonymousActor {
+		return actor + ":" + fingerprint
+	}
+	return actor
+}
+
+// idempotencyLease returns how long a key in progress is held for its
+// request, always long enough for the request to time out before
+func (s *Server) idempotencyLease() time.Duration {
+	if lease := 2 * s.requestTimeout; lease > idempotency.DefaultLease {
+		return lease
+	}
+	return idempotency.DefaultLease
+}
+
+// replayResponse writes the response recorded for the key of the request,
+// as long as the request is the same one that reserved it
+func replayResponse(w http.ResponseWriter, r *http.Request, rec idempotency.Record, stored *idempotency.Record) {
+	if stored.Fingerprint != rec.Fingerprint {
+		logging.FromContext(r.Context()).Error("ctrl: replaying idempotent request", slog.Any("error", idempotency.ErrKeyReused))
+		responseError(w, r, idempotency.ErrKeyReused)
+		return
+	}
+	if !stored.IsCompleted() {
+		logging.FromContext(r.Context()).Error("ctrl: replaying idempotent request", slog.Any("error", idempotency.ErrRequestInProgress))
+		responseError(w, r, idempotency.ErrRequestInProgress)
+		return
+	}
+	for name, values := range stored.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(idempotency.ReplayedHeader, "true")
+	w.WriteHeader(stored.StatusCode)
+	if _, err := w.Write(stored.Body); err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: replaying idempotent request", slog.Any("error", err))
+	}
+}
+
+// recordingWriter records the response written by a handler
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code and headers before writing them
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body before writing it
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
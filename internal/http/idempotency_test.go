@@ -0,0 +1,187 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nachoconques0/schwarz-challenge/internal/auth"
+	"github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/etag"
+	internalHTTP "github.com/nachoconques0/schwarz-challenge/internal/http"
+	"github.com/nachoconques0/schwarz-challenge/internal/idempotency"
+	"github.com/nachoconques0/schwarz-challenge/internal/mocks"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_Idempotency(t *testing.T) {
+	const createBody = `{"items":[{"name":"item","description":"description","price":10}]}`
+
+	buildServer := func(t *testing.T) (*internalHTTP.Server, *mocks.MockShoppingCartService) {
+		ctrl := gomock.NewController(t)
+		svc := mocks.NewMockShoppingCartService(ctrl)
+		server, err := internalHTTP.NewServer(
+			"8080",
+			internalHTTP.NewShopppingCartCtrl(svc),
+			mocks.NewMockCouponServer(ctrl),
+			internalHTTP.WithIdempotency(idempotency.NewMemoryStore(), time.Hour),
+		)
+		assert.Nil(t, err)
+		return server, svc
+	}
+	send := func(server *internalHTTP.Server, method string, path string, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		for k := range header {
+			req.Header.Set(k, header.Get(k))
+		}
+		recorder := httptest.NewRecorder()
+		server.Handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+	keyHeader := func(key string) http.Header {
+		return http.Header{idempotency.KeyHeader: {key}}
+	}
+
+	t.Run("invalid server ttl", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		_, err := internalHTTP.NewServer(
+			"8080",
+			mocks.NewMockShoppingCartServer(ctrl),
+			mocks.NewMockCouponServer(ctrl),
+			internalHTTP.WithIdempotency(idempotency.NewMemoryStore(), 0),
+		)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("requests without key are not replayed", func(t *testing.T) {
+		server, svc := buildServer(t)
+		svc.EXPECT().CreateShoppingCart(gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{ID: uuid.New()}, nil).Times(2)
+
+		for i := 0; i < 2; i++ {
+			resp := send(server, http.MethodPost, "/shopping-cart", createBody, nil)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Empty(t, resp.Header().Get(idempotency.ReplayedHeader))
+		}
+	})
+
+	t.Run("retries replay the recorded response", func(t *testing.T) {
+		server, svc := buildServer(t)
+		cart := &shoppingcart.ShoppingCart{ID: uuid.New(), Version: 1}
+		svc.EXPECT().CreateShoppingCart(gomock.Any(), gomock.Any()).Return(cart, nil).Times(1)
+
+		first := send(server, http.MethodPost, "/shopping-cart", createBody, keyHeader("key-1"))
+		assert.Equal(t, http.StatusOK, first.Code)
+		assert.Empty(t, first.Header().Get(idempotency.ReplayedHeader))
+
+		retry := send(server, http.MethodPost, "/shopping-cart", createBody, keyHeader("key-1"))
+		assert.Equal(t, http.StatusOK, retry.Code)
+		assert.Equal(t, "true", retry.Header().Get(idempotency.ReplayedHeader))
+		assert.Equal(t, first.Header().Get(etag.Header), retry.Header().Get(etag.Header))
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+	})
+
+	t.Run("client errors are replayed", func(t *testing.T) {
+		server, svc := buildServer(t)
+		cartID, couponID := uuid.New(), uuid.New()
+		path := fmt.Sprintf("/shopping-cart/%s/apply-coupon/%s", cartID, couponID)
+		svc.EXPECT().ApplyCoupon(gomock.Any(), cartID, couponID).Return(nil, shoppingcart.ErrShoppinCartCouponAlreadyApplied).Times(1)
+
+		for i := 0; i < 2; i++ {
+			resp := send(server, http.MethodPut, path, "", keyHeader("key-1"))
+			assert.Equal(t, http.StatusConflict, resp.Code)
+		}
+	})
+
+	t.Run("server errors release the key", func(t *testing.T) {
+		server, svc := buildServer(t)
+		cartID, couponID := uuid.New(), uuid.New()
+		path := fmt.Sprintf("/shopping-cart/%s/apply-coupon/%s", cartID, couponID)
+		gomock.InOrder(
+			svc.EXPECT().ApplyCoupon(gomock.Any(), cartID, couponID).Return(nil, errors.NewInternalError("boom")),
+			svc.EXPECT().ApplyCoupon(gomock.Any(), cartID, couponID).Return(&shoppingcart.ShoppingCart{ID: cartID, Version: 2}, nil),
+		)
+
+		resp := send(server, http.MethodPut, path, "", keyHeader("key-1"))
+		assert.Equal(t, http.StatusInternalServerError, resp.Code)
+		resp = send(server, http.MethodPut, path, "", keyHeader("key-1"))
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Empty(t, resp.Header().Get(idempotency.ReplayedHeader))
+	})
+
+	t.Run("panics release the key", func(t *testing.T) {
+		server, svc := buildServer(t)
+		gomock.InOrder(
+			svc.EXPECT().CreateShoppingCart(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ shoppingcart.CreateRequest) (*shoppingcart.ShoppingCart, error) {
+				panic("boom")
+			}),
+			svc.EXPECT().CreateShoppingCart(gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{ID: uuid.New()}, nil),
+		)
+
+		assert.Panics(t, func() {
+			send(server, http.MethodPost, "/shopping-cart", createBody, keyHeader("key-1"))
+		})
+		resp := send(server, http.MethodPost, "/shopping-cart", createBody, keyHeader("key-1"))
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Empty(t, resp.Header().Get(idempotency.ReplayedHeader))
+	})
+
+	t.Run("oversized body", func(t *testing.T) {
+		server, _ := buildServer(t)
+		body := `{"items":[{"name":"` + strings.Repeat("a", 1<<20) + `"}]}`
+		resp := send(server, http.MethodPost, "/shopping-cart", body, keyHeader("key-1"))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.Code)
+	})
+
+	t.Run("reusing a key with a different body", func(t *testing.T) {
+		server, svc := buildServer(t)
+		svc.EXPECT().CreateShoppingCart(gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{ID: uuid.New()}, nil).Times(1)
+		header := keyHeader("key-1")
+		header.Set(auth.CustomerIDHeader, uuid.NewString())
+
+		resp := send(server, http.MethodPost, "/shopping-cart", createBody, header)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		resp = send(server, http.MethodPost, "/shopping-cart", strings.Replace(createBody, "10", "20", 1), header)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+	})
+
+	t.Run("anonymous keys are scoped by request", func(t *testing.T) {
+		server, svc := buildServer(t)
+		svc.EXPECT().CreateShoppingCart(gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{ID: uuid.New()}, nil).Times(2)
+
+		resp := send(server, http.MethodPost, "/shopping-cart", createBody, keyHeader("key-1"))
+		assert.Equal(t, http.StatusOK, resp.Code)
+		resp = send(server, http.MethodPost, "/shopping-cart", strings.Replace(createBody, "10", "20", 1), keyHeader("key-1"))
+		assert.Equal(t, http.StatusOK, resp.Code, "another anonymous client may pick the same key")
+		assert.Empty(t, resp.Header().Get(idempotency.ReplayedHeader))
+		resp = send(server, http.MethodPost, "/shopping-cart", createBody, keyHeader("key-1"))
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "true", resp.Header().Get(idempotency.ReplayedHeader))
+	})
+
+	t.Run("keys are scoped by customer", func(t *testing.T) {
+		server, svc := buildServer(t)
+		svc.EXPECT().CreateShoppingCart(gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{ID: uuid.New()}, nil).Times(2)
+
+		for i := 0; i < 2; i++ {
+			header := keyHeader("key-1")
+			header.Set(auth.CustomerIDHeader, uuid.NewString())
+			resp := send(server, http.MethodPost, "/shopping-cart", createBody, header)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Empty(t, resp.Header().Get(idempotency.ReplayedHeader))
+		}
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		server, _ := buildServer(t)
+		resp := send(server, http.MethodPost, "/shopping-cart", createBody, keyHeader(strings.Repeat("a", idempotency.MaxKeyLength+1)))
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+}
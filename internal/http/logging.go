@@ -0,0 +1,57 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/logging"
+	"github.com/nachoconques0/schwarz-challenge/internal/requestid"
+)
+
+// loggingMiddleware stores a logger holding the request ID, method, route
+// and client into the request context, and logs every request once served
+// with its status and latency
+func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		route := unknownRoute
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		attrs := []any{
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.String("client_ip", s.clientIP(r)),
+			slog.String("user_agent", r.UserAgent()),
+		}
+		if id, ok := requestid.FromContext(r.Context()); ok {
+			attrs = append(attrs, slog.String("request_id", id))
+		}
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+		}
+		logger := logging.FromContext(r.Context()).With(attrs...)
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r.WithContext(logging.WithLogger(r.Context(), logger)))
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.Log(r.Context(), level, "request served",
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+		)
+	})
+}
@@ -0,0 +1,81 @@
+package http_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	internalHTTP "github.com/nachoconques0/schwarz-challenge/internal/http"
+	"github.com/nachoconques0/schwarz-challenge/internal/logging"
+	"github.com/nachoconques0/schwarz-challenge/internal/mocks"
+	"github.com/nachoconques0/schwarz-challenge/internal/requestid"
+)
+
+func TestServer_RequestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	ctrl := gomock.NewController(t)
+	scSrv := mocks.NewMockShoppingCartServer(ctrl)
+	server, err := internalHTTP.NewServer("8080", scSrv, mocks.NewMockCouponServer(ctrl))
+	assert.Nil(t, err)
+
+	testCases := map[string]struct {
+		status int
+		level  string
+	}{
+		"served": {
+			status: http.StatusOK,
+			level:  "INFO",
+		},
+		"failed": {
+			status: http.StatusInternalServerError,
+			level:  "ERROR",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			buf.Reset()
+			scSrv.EXPECT().ListShoppingCarts(gomock.Any(), gomock.Any()).Do(func(w http.ResponseWriter, r *http.Request) {
+				logging.FromContext(r.Context()).Info("listing")
+				w.WriteHeader(tc.status)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/shopping-cart", nil)
+			req.Header.Set(requestid.Header, "gateway-42")
+			req.Header.Set("User-Agent", "tests")
+			server.Handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			var lines []map[string]any
+			dec := json.NewDecoder(&buf)
+			for dec.More() {
+				var line map[string]any
+				assert.Nil(t, dec.Decode(&line))
+				lines = append(lines, line)
+			}
+			if !assert.Len(t, lines, 2) {
+				return
+			}
+			for _, line := range lines {
+				assert.Equal(t, "gateway-42", line["request_id"])
+				assert.Equal(t, http.MethodGet, line["method"])
+				assert.Equal(t, "/shopping-cart", line["route"])
+				assert.Equal(t, "tests", line["user_agent"])
+				assert.Equal(t, "192.0.2.1", line["client_ip"])
+			}
+			assert.Equal(t, "listing", lines[0]["msg"])
+			assert.Equal(t, "request served", lines[1]["msg"])
+			assert.Equal(t, tc.level, lines[1]["level"])
+			assert.Equal(t, float64(tc.status), lines[1]["status"])
+			assert.Contains(t, lines[1], "latency")
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	internalHTTP "github.com/nachoconques0/schwarz-challenge/internal/http"
+	"github.com/nachoconques0/schwarz-challenge/internal/metrics"
+	"github.com/nachoconques0/schwarz-challenge/internal/mocks"
+)
+
+func TestServer_Metrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	cSrv := mocks.NewMockCouponServer(ctrl)
+	server, err := internalHTTP.NewServer("8080", mocks.NewMockShoppingCartServer(ctrl), cSrv, internalHTTP.WithMetrics(metrics.New()))
+	assert.Nil(t, err)
+
+	cSrv.EXPECT().DeleteCoupon(gomock.Any(), gomock.Any()).Do(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/coupon/"+uuid.NewString(), nil))
+	server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, rec.Body.String(), `schwarz_http_requests_total{method="DELETE",route="/coupon/{id}",status="404"} 1`)
+	assert.Contains(t, rec.Body.String(), `schwarz_http_requests_total{method="GET",route="/healthz",status="200"} 1`)
+}
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"time"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/abuse"
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
+	"github.com/nachoconques0/schwarz-challenge/internal/health"
+	"github.com/nachoconques0/schwarz-challenge/internal/idempotency"
+	"github.com/nachoconques0/schwarz-challenge/internal/metrics"
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
+)
+
+// Option defines the function used for
+// setup an optional server dependency
+type Option func(s *Server)
+
+// WithAbuseGuard function protects the apply coupon
+// endpoint with the given abuse guard
+func WithAbuseGuard(guard *abuse.Guard) Option {
+	return func(s *Server) {
+		s.abuseGuard = guard
+	}
+}
+
+// WithRequestTimeout function sets how long the work of a
+// request, DB queries included, can take before being canceled
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.requestTimeout = timeout
+	}
+}
+
+// WithIdempotency function lets clients retry the create and apply coupon
+// requests with an Idempotency-Key, remembered by the store for the given ttl
+func WithIdempotency(store idempotency.Store, ttl time.Duration) Option {
+	return func(s *Server) {
+		s.idempotencyStore = store
+		s.idempotencyTTL = ttl
+	}
+}
+
+// WithWebhooks function serves the webhook subscription
+// endpoints with the given controller
+func WithWebhooks(srv webhook.Server) Option {
+	return func(s *Server) {
+		s.webhookSrv = srv
+	}
+}
+
+// WithAudit function serves the audit log
+// endpoint with the given controller
+func WithAudit(srv audit.Server) Option {
+	return func(s *Server) {
+		s.auditSrv = srv
+	}
+}
+
+// WithHealth function makes the readiness endpoint
+// report the checks of the given checker
+func WithHealth(checker *health.Checker) Option {
+	return func(s *Server) {
+		s.healthChecker = checker
+	}
+}
+
+// WithMetrics function records the requests served in the
+// given metrics and serves them in the metrics endpoint
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(s *Server) {
+		s.metrics = m
+	}
+}
+
+// WithStaffToken function trusts the staff ID set by the gateway
+// when it comes with the given token in the X-Staff-Token header
+func WithStaffToken(token string) Option {
+	return func(s *Server) {
+		s.staffToken = token
+	}
+}
+
+// WithClientIPHeader function reads the client IP from the given header,
+// set by the trusted proxy in front of the server, instead of the connection
+func WithClientIPHeader(header string) Option {
+	return func(s *Server) {
+		s.clientIPHeader = header
+	}
+}
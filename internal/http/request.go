@@ -0,0 +1,253 @@
+package http
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/xeipuuv/gojsonschema"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/logging"
+	"github.com/nachoconques0/schwarz-challenge/internal/schema"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/tracing"
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
+)
+
+// maxRequestBodySize limits the size of the JSON request bodies
+const maxRequestBodySize = 1 << 20
+
+// Names of the request schemas, their path under schemas without the extension
+const (
+	createShoppingCartSchema = "shopping_cart/create"
+	createCouponSchema       = "coupon/create"
+	updateCouponSchema       = "coupon/update"
+	createWebhookSchema      = "webhook/create"
+	updateWebhookSchema      = "webhook/update"
+)
+
+// SchemaContentType is the content type of the served JSON schemas
+const SchemaContentType = "application/schema+json"
+
+var (
+	// ErrSchemaNotFound used when the requested JSON schema does not exist
+	ErrSchemaNotFound = internalErrors.NewNotFound("schema not found")
+	// ErrRequestBodyTooLarge used when a request body exceeds maxRequestBodySize
+	ErrRequestBodyTooLarge = internalErrors.NewPayloadTooLarge(fmt.Sprintf("request body must not exceed %d bytes", maxRequestBodySize))
+	// ErrUnknownSchema used when a request is decoded with a schema that was not compiled
+	ErrUnknownSchema = internalErrors.NewInternalError("unknown request schema")
+)
+
+//go:embed schemas/*/*.json
+var schemaFiles embed.FS
+
+// requests decodes the request bodies of every controller, the schemas
+// are compiled once when the package is loaded
+var requests = mustNewRequestDecoder(schemaFiles, maxRequestBodySize)
+
+// requestSchemas lists the request types the schemas under schemas are
+// generated from, TestRequestSchemas fails when they diverge
+var requestSchemas = []requestSchema{
+	{name: createShoppingCartSchema, title: "create shopping cart", request: shoppingcart.CreateRequest{}},
+	{name: createCouponSchema, title: "create coupon", request: coupon.CreateRequest{}},
+	{name: updateCouponSchema, title: "update coupon", request: coupon.UpdateRequest{}, minProperties: 1},
+	{name: createWebhookSchema, title: "create webhook subscription", request: webhook.CreateRequest{}},
+	{name: updateWebhookSchema, title: "update webhook subscription", request: webhook.UpdateRequest{}, minProperties: 1},
+}
+
+// requestSchema names the schema generated from a request type
+type requestSchema struct {
+	name string
+	// title describes the schema, it is served under its words joined by dashes
+	title   string
+	request any
+	// minProperties rejects the partial updates changing nothing
+	minProperties int
+}
+
+// servedName is the name the schema is served at, such as create-shopping-cart
+func (rs requestSchema) servedName() string {
+	return strings.ReplaceAll(rs.title, " ", "-")
+}
+
+// generate returns the JSON schema of the request type
+func (rs requestSchema) generate() ([]byte, error) {
+	s, err := schema.For(rs.request)
+	if err != nil {
+		return nil, fmt.Errorf("generating schema %s: %w", rs.name, err)
+	}
+	s.Title = rs.title
+	if rs.minProperties > 0 {
+		s.MinProperties = &rs.minProperties
+	}
+	return s.Marshal()
+}
+
+// requestDecoder validates request bodies against their JSON schema and
+// decodes them
+type requestDecoder struct {
+	schemas     map[string]*gojsonschema.Schema
+	raw         map[string][]byte
+	maxBodySize int64
+}
+
+// newRequestDecoder compiles every JSON schema found in fsys, named
+// after their path without the extension
+func newRequestDecoder(fsys fs.FS, maxBodySize int64) (*requestDecoder, error) {
+	d := &requestDecoder{
+		schemas:     map[string]*gojsonschema.Schema{},
+		raw:         map[string][]byte{},
+		maxBodySize: maxBodySize,
+	}
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || path.Ext(name) != ".json" {
+			return err
+		}
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		compiled, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(content))
+		if err != nil {
+			return fmt.Errorf("compiling schema %s: %w", name, err)
+		}
+		key := strings.TrimSuffix(strings.TrimPrefix(name, "schemas/"), ".json")
+		d.schemas[key] = compiled
+		d.raw[key] = content
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// mustNewRequestDecoder is like newRequestDecoder but panics when a
+// schema does not compile, the embedded ones always must
+func mustNewRequestDecoder(fsys fs.FS, maxBodySize int64) *requestDecoder {
+	d, err := newRequestDecoder(fsys, maxBodySize)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// decode reads the request body, validates it against the named schema
+// and decodes it into payload. invalidErr, holding the problem of every
+// field, is returned when the body does not match the schema
+func (d *requestDecoder) decode(r *http.Request, name string, invalidErr *internalErrors.Error, payload any) error {
+	compiled, ok := d.schemas[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownSchema, name)
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, d.maxBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return ErrRequestBodyTooLarge
+		}
+		return err
+	}
+	if !json.Valid(body) {
+		return invalidErr.WithFields(internalErrors.FieldError{
+			Code:    "invalid_json",
+			Message: "request body is not valid JSON",
+		})
+	}
+
+	result, err := compiled.Validate(gojsonschema.NewBytesLoader(body))
+	if err != nil {
+		return err
+	}
+	if !result.Valid() {
+		fields := fieldErrors(result)
+		logging.FromContext(r.Context()).Info("request data is not valid", slog.Any("fields", fields))
+		return invalidErr.WithFields(fields...)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if err := dec.Decode(payload); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return invalidErr.WithFields(internalErrors.FieldError{
+				Field:   typeErr.Field,
+				Code:    "invalid_type",
+				Message: fmt.Sprintf("Invalid type. Expected: %s, given: %s", typeErr.Type, typeErr.Value),
+			})
+		}
+		return err
+	}
+	return nil
+}
+
+// getSchema serves the JSON schema a request body is validated against
+func getSchema(w http.ResponseWriter, r *http.Request) {
+	var content []byte
+	for _, rs := range requestSchemas {
+		if rs.servedName() == mux.Vars(r)["name"] {
+			content = requests.raw[rs.name]
+		}
+	}
+	if content == nil {
+		responseError(w, r, ErrSchemaNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", SchemaContentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(content); err != nil {
+		logging.FromContext(r.Context()).Error("writing schema", slog.Any("error", err))
+	}
+}
+
+// decodeRequest decodes the request body into a T once validated against
+// the named schema, see requestDecoder.decode
+func decodeRequest[T any](r *http.Request, name string, invalidErr *internalErrors.Error) (payload T, err error) {
+	_, span := tracing.Start(r.Context(), "http.decodeRequest")
+	defer func() { tracing.End(span, err) }()
+
+	err = requests.decode(r, name, invalidErr, &payload)
+	return payload, err
+}
+
+// fieldErrors lists the problems found on each field by the validation
+// of a request against its schema, sorted by field since the validation
+// order is not stable
+func fieldErrors(result *gojsonschema.Result) []internalErrors.FieldError {
+	fields := make([]internalErrors.FieldError, 0, len(result.Errors()))
+	for _, resultErr := range result.Errors() {
+		field := resultErr.Field()
+		// required and additional properties are reported on their parent
+		if property, ok := resultErr.Details()["property"].(string); ok {
+			if field == gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+				field = property
+			} else {
+				field += "." + property
+			}
+		}
+		if field == gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+			field = ""
+		}
+		fields = append(fields, internalErrors.FieldError{
+			Field:   field,
+			Code:    resultErr.Type(),
+			Message: resultErr.Description(),
+		})
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].Field < fields[j].Field
+	})
+	return fields
+}
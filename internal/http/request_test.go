@@ -0,0 +1,64 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/errors"
+	internalHTTP "github.com/nachoconques0/schwarz-challenge/internal/http"
+	"github.com/nachoconques0/schwarz-challenge/internal/mocks"
+)
+
+func TestController_DecodeRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	controller := internalHTTP.NewCouponCtrl(mocks.NewMockCouponService(ctrl))
+
+	testCases := map[string]struct {
+		body     string
+		expected *errors.Error
+	}{
+		"body too large": {
+			body:     `{"name": "` + strings.Repeat("a", 1<<20) + `", "amount": 10}`,
+			expected: internalHTTP.ErrRequestBodyTooLarge,
+		},
+		"invalid json": {
+			body: `{"name": "FREE30",`,
+			expected: internalHTTP.ErrInvalidCreateCouponRequest.WithFields(errors.FieldError{
+				Code:    "invalid_json",
+				Message: "request body is not valid JSON",
+			}),
+		},
+		"missing field": {
+			body: `{"name": "FREE30"}`,
+			expected: internalHTTP.ErrInvalidCreateCouponRequest.WithFields(errors.FieldError{
+				Field:   "amount",
+				Code:    "required",
+				Message: "amount is required",
+			}),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "http://www.test.com", strings.NewReader(tc.body))
+			assert.Nil(t, err)
+
+			recorder := httptest.NewRecorder()
+			controller.CreateCoupon(recorder, req)
+			resp := recorder.Result()
+
+			assert.Equal(t, tc.expected.HTTPStatus(), resp.StatusCode)
+			responseErr := &errors.Error{}
+			err = json.NewDecoder(resp.Body).Decode(responseErr)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected.Problem(), responseErr.Problem())
+			_ = resp.Body.Close()
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package http_test
+
+import (
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/errors"
+	internalHTTP "github.com/nachoconques0/schwarz-challenge/internal/http"
+	"github.com/nachoconques0/schwarz-challenge/internal/mocks"
+)
+
+var update = flag.Bool("update", false, "rewrite the request schemas generated from the request types")
+
+// TestRequestSchemas fails when a schema under schemas diverges from the
+// request type it is generated from, run it with -update to rewrite them
+func TestRequestSchemas(t *testing.T) {
+	generated, err := internalHTTP.GenerateRequestSchemas()
+	require.NoError(t, err)
+
+	for name, content := range generated {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join("schemas", name+".json")
+			if *update {
+				require.NoError(t, os.WriteFile(path, content, 0o644))
+			}
+			written, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, string(content), string(written), "%s is stale, run go test ./internal/http -run TestRequestSchemas -update", path)
+		})
+	}
+}
+
+func TestServer_Schemas(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	server, err := internalHTTP.NewServer("8080", mocks.NewMockShoppingCartServer(ctrl), mocks.NewMockCouponServer(ctrl))
+	require.NoError(t, err)
+
+	generated, err := internalHTTP.GenerateRequestSchemas()
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		path        string
+		status      int
+		contentType string
+		body        string
+	}{
+		"shopping cart": {
+			path:        "/schemas/create-shopping-cart",
+			status:      http.StatusOK,
+			contentType: internalHTTP.SchemaContentType,
+			body:        string(generated["shopping_cart/create"]),
+		},
+		"coupon update": {
+			path:        "/schemas/update-coupon",
+			status:      http.StatusOK,
+			contentType: internalHTTP.SchemaContentType,
+			body:        string(generated["coupon/update"]),
+		},
+		"webhook create": {
+			path:        "/schemas/create-webhook-subscription",
+			status:      http.StatusOK,
+			contentType: internalHTTP.SchemaContentType,
+			body:        string(generated["webhook/create"]),
+		},
+		"unknown": {
+			path:        "/schemas/delete-coupon",
+			status:      http.StatusNotFound,
+			contentType: errors.ProblemContentType,
+		},
+		"schema file path": {
+			path:        "/schemas/coupon/update",
+			status:      http.StatusNotFound,
+			contentType: "text/plain; charset=utf-8",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			resp := rec.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.status, resp.StatusCode)
+			assert.Equal(t, tc.contentType, resp.Header.Get("Content-Type"))
+			if tc.body != "" {
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tc.body, string(body))
+			}
+		})
+	}
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/xeipuuv/gojsonschema"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
 )
 
 //go:embed testdata/fail/create.json
@@ -20,6 +22,15 @@ var createSuccessScenario []byte
 //go:embed create.json
 var createRequestSchema []byte
 
+//go:embed testdata/fail/update.json
+var updateFailScenarios []byte
+
+//go:embed testdata/success/update.json
+var updateSuccessScenario []byte
+
+//go:embed update.json
+var updateRequestSchema []byte
+
 func TestSchemaValidation_Success(t *testing.T) {
 	t.Run("Given a valid request", func(t *testing.T) {
 		var testcases []testCase
@@ -35,6 +46,11 @@ func TestSchemaValidation_Success(t *testing.T) {
 				result, err := schema.Validate(requestJSON)
 				assert.Nil(t, err)
 				assert.True(t, result.Valid())
+
+				// the domain accepts whatever the schema does
+				var req coupon.CreateRequest
+				assert.Nil(t, json.Unmarshal(tc.Payload, &req))
+				assert.Nil(t, req.Validate())
 			})
 		}
 	})
@@ -55,6 +71,69 @@ func TestSchemaValidation_Fail(t *testing.T) {
 				result, err := schema.Validate(requestJSON)
 				assert.Nil(t, err)
 				assert.False(t, result.Valid())
+
+				// and rejects what the schema does, but the unknown fields
+				// and wrong types it never gets to see
+				if tc.SchemaOnly {
+					return
+				}
+				var req coupon.CreateRequest
+				assert.Nil(t, json.Unmarshal(tc.Payload, &req))
+				assert.NotNil(t, req.Validate())
+			})
+		}
+	})
+}
+
+func TestUpdateSchemaValidation_Success(t *testing.T) {
+	t.Run("Given a valid update request", func(t *testing.T) {
+		var testcases []testCase
+		err := json.Unmarshal(updateSuccessScenario, &testcases)
+		assert.Nil(t, err)
+
+		loader := gojsonschema.NewBytesLoader(updateRequestSchema)
+		schema, err := gojsonschema.NewSchema(loader)
+		assert.Nil(t, err)
+		for _, tc := range testcases {
+			t.Run(fmt.Sprintf("Should return valid for scenario: %s", tc.Scenario), func(t *testing.T) {
+				requestJSON := gojsonschema.NewBytesLoader(tc.Payload)
+				result, err := schema.Validate(requestJSON)
+				assert.Nil(t, err)
+				assert.True(t, result.Valid())
+
+				// the domain accepts whatever the schema does
+				var req coupon.UpdateRequest
+				assert.Nil(t, json.Unmarshal(tc.Payload, &req))
+				assert.Nil(t, req.Validate())
+			})
+		}
+	})
+}
+
+func TestUpdateSchemaValidation_Fail(t *testing.T) {
+	t.Run("Given an invalid update request", func(t *testing.T) {
+		var testcases []testCase
+		err := json.Unmarshal(updateFailScenarios, &testcases)
+		assert.Nil(t, err)
+
+		loader := gojsonschema.NewBytesLoader(updateRequestSchema)
+		schema, err := gojsonschema.NewSchema(loader)
+		assert.Nil(t, err)
+		for _, tc := range testcases {
+			t.Run(fmt.Sprintf("Should return invalid for scenario: %s", tc.Scenario), func(t *testing.T) {
+				requestJSON := gojsonschema.NewBytesLoader(tc.Payload)
+				result, err := schema.Validate(requestJSON)
+				assert.Nil(t, err)
+				assert.False(t, result.Valid())
+
+				// and rejects what the schema does, but the unknown fields
+				// and wrong types it never gets to see
+				if tc.SchemaOnly {
+					return
+				}
+				var req coupon.UpdateRequest
+				assert.Nil(t, json.Unmarshal(tc.Payload, &req))
+				assert.NotNil(t, req.Validate())
 			})
 		}
 	})
@@ -63,4 +142,7 @@ func TestSchemaValidation_Fail(t *testing.T) {
 type testCase struct {
 	Scenario string          `json:"scenario"`
 	Payload  json.RawMessage `json:"payload"`
+	// SchemaOnly marks the requests only the schema can reject, the ones
+	// with unknown fields or wrong types
+	SchemaOnly bool `json:"schema_only"`
 }
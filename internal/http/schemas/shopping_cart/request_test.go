@@ -9,6 +9,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/xeipuuv/gojsonschema"
+
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
 )
 
 //go:embed testdata/fail/create.json
@@ -35,6 +37,11 @@ func TestSchemaValidation_Success(t *testing.T) {
 				result, err := schema.Validate(requestJSON)
 				assert.Nil(t, err)
 				assert.True(t, result.Valid())
+
+				// the domain accepts whatever the schema does
+				var req shoppingcart.CreateRequest
+				assert.Nil(t, json.Unmarshal(tc.Payload, &req))
+				assert.Nil(t, req.Validate())
 			})
 		}
 	})
@@ -55,6 +62,15 @@ func TestSchemaValidation_Fail(t *testing.T) {
 				result, err := schema.Validate(requestJSON)
 				assert.Nil(t, err)
 				assert.False(t, result.Valid())
+
+				// and rejects what the schema does, but the unknown fields
+				// and wrong types it never gets to see
+				if tc.SchemaOnly {
+					return
+				}
+				var req shoppingcart.CreateRequest
+				assert.Nil(t, json.Unmarshal(tc.Payload, &req))
+				assert.NotNil(t, req.Validate())
 			})
 		}
 	})
@@ -63,4 +79,7 @@ func TestSchemaValidation_Fail(t *testing.T) {
 type testCase struct {
 	Scenario string          `json:"scenario"`
 	Payload  json.RawMessage `json:"payload"`
+	// SchemaOnly marks the requests only the schema can reject, the ones
+	// with unknown fields or wrong types
+	SchemaOnly bool `json:"schema_only"`
 }
@@ -4,35 +4,77 @@ package http
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/nachoconques0/schwarz-challenge/internal/abuse"
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
+	"github.com/nachoconques0/schwarz-challenge/internal/auth"
 	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
 	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/etag"
+	"github.com/nachoconques0/schwarz-challenge/internal/health"
+	"github.com/nachoconques0/schwarz-challenge/internal/idempotency"
+	"github.com/nachoconques0/schwarz-challenge/internal/logging"
+	"github.com/nachoconques0/schwarz-challenge/internal/metrics"
+	"github.com/nachoconques0/schwarz-challenge/internal/requestid"
 	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
+)
+
+var (
+	// ErrInvalidCustomerID used when the authenticated customer ID is not valid
+	ErrInvalidCustomerID = internalErrors.NewWrongInput("customer ID is invalid")
+	// ErrUntrustedStaffID used when a staff ID is given but no gateway is trusted to set it
+	ErrUntrustedStaffID = internalErrors.NewForbidden("staff ID is not accepted, no trusted gateway is configured")
+	// ErrInvalidStaffToken used when the staff ID does not come with the token of the gateway
+	ErrInvalidStaffToken = internalErrors.NewForbidden("staff token is invalid")
+)
+
+const (
+	// defaultRequestTimeout bounds the work, DB queries included, done for a request
+	defaultRequestTimeout = 10 * time.Second
+	// streamingRoute names the routes streaming big bodies, they are
+	// only bounded by the server read and write timeouts
+	streamingRoute = "streaming"
 )
 
 // Server type holds the dependencies needed
 // for handle an http.Server
 type Server struct {
 	*http.Server
-	shoppingCartSrv shoppingcart.Server
-	couponSrv       coupon.Server
+	shoppingCartSrv  shoppingcart.Server
+	couponSrv        coupon.Server
+	webhookSrv       webhook.Server
+	auditSrv         audit.Server
+	abuseGuard       *abuse.Guard
+	requestTimeout   time.Duration
+	idempotencyStore idempotency.Store
+	idempotencyTTL   time.Duration
+	healthChecker    *health.Checker
+	metrics          *metrics.Metrics
+	staffToken       string
+	clientIPHeader   string
 }
 
 // NewServer builds a new http.Server by using the given dependencies
-// all of thoses dependencies are mandatory
+// all of thoses dependencies are mandatory, the optional ones
+// are given as options
 func NewServer(
 	port string,
 	shoppingCartSrv shoppingcart.Server,
 	couponSrv coupon.Server,
+	opts ...Option,
 ) (*Server, error) {
 	if port == "" {
 		return nil, errors.New("server port can not be empty")
@@ -46,6 +88,16 @@ func NewServer(
 	s := &Server{
 		shoppingCartSrv: shoppingCartSrv,
 		couponSrv:       couponSrv,
+		requestTimeout:  defaultRequestTimeout,
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	if s.requestTimeout <= 0 {
+		return nil, errors.New("server request timeout must be positive")
+	}
+	if s.idempotencyStore != nil && s.idempotencyTTL <= 0 {
+		return nil, errors.New("server idempotency ttl must be positive")
 	}
 	s.Server = &http.Server{
 		Addr:         ":" + port,
@@ -62,7 +114,8 @@ func NewServer(
 func (s *Server) Run() error {
 	s.Handler = handlers.CORS(
 		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"Authorization", "Content-Type"}),
+		handlers.AllowedHeaders([]string{"Authorization", "Content-Type", auth.CustomerIDHeader, auth.StaffIDHeader, auth.StaffTokenHeader, etag.IfMatchHeader, idempotency.KeyHeader, requestid.Header, "traceparent", "tracestate"}),
+		handlers.ExposedHeaders([]string{etag.Header, idempotency.ReplayedHeader, requestid.Header}),
 	)(s.Handler)
 	if err := s.ListenAndServe(); err != nil {
 		return fmt.Errorf("error while starting the http server %s", err)
@@ -84,25 +137,84 @@ func (s *Server) router() {
 	r := mux.NewRouter()
 	r.NewRoute().Subrouter()
 
+	s.healthRouter(r)
+	if s.metrics != nil {
+		r.Handle("/metrics", s.metrics.Handler()).Methods(http.MethodGet)
+	}
+	r.HandleFunc("/schemas/{name}", getSchema).Methods(http.MethodGet)
 	s.shoppingCartRouter(r)
 	s.couponRouter(r)
+	if s.webhookSrv != nil {
+		s.webhookRouter(r)
+	}
+	if s.auditSrv != nil {
+		r.HandleFunc("/audit", s.auditSrv.ListEntries).Methods(http.MethodGet)
+	}
+	if s.abuseGuard != nil {
+		r.HandleFunc("/abuse/events", staffOnly(s.listAbuseEvents, ErrAbuseStaffOnly)).Methods(http.MethodGet)
+	}
 
+	if s.metrics != nil {
+		r.Use(s.metricsMiddleware)
+	}
+	r.Use(requestIDMiddleware)
+	r.Use(tracingMiddleware)
+	r.Use(s.loggingMiddleware)
 	r.Use(contentTypeJSONMiddleware)
+	r.Use(customerMiddleware)
+	r.Use(s.staffMiddleware)
+	r.Use(ifMatchMiddleware)
+	r.Use(s.timeoutMiddleware)
 	// Pass our instance of gorilla/mux in.
 	s.Handler = r
 }
 
 // shoppingCartRouter holds the routing for the shopping cart endpoints
 func (s *Server) shoppingCartRouter(r *mux.Router) {
-	r.HandleFunc("/shopping-cart", s.shoppingCartSrv.CreateShoppingCart).Methods(http.MethodPost)
+	r.HandleFunc("/shopping-cart", s.idempotent(s.shoppingCartSrv.CreateShoppingCart)).Methods(http.MethodPost)
 	r.HandleFunc("/shopping-cart", s.shoppingCartSrv.ListShoppingCarts).Methods(http.MethodGet)
-	r.HandleFunc("/shopping-cart/{id}/apply-coupon/{coupon_id}", s.shoppingCartSrv.ApplyCoupon).Methods(http.MethodPut)
+	applyCoupon := s.idempotent(s.shoppingCartSrv.ApplyCoupon)
+	if s.abuseGuard != nil {
+		applyCoupon = s.abuseGuardMiddleware(applyCoupon)
+	}
+	r.HandleFunc("/shopping-cart/{id}/apply-coupon/{coupon_id}", applyCoupon).Methods(http.MethodPut)
 }
 
 // couponRouter holds the routing for the coupon endpoints
 func (s *Server) couponRouter(r *mux.Router) {
-	r.HandleFunc("/coupon", s.couponSrv.CreateCoupon).Methods(http.MethodPost)
+	r.HandleFunc("/coupon", s.idempotent(s.couponSrv.CreateCoupon)).Methods(http.MethodPost)
 	r.HandleFunc("/coupon", s.couponSrv.LisCoupons).Methods(http.MethodGet)
+	r.HandleFunc("/coupon/import", s.couponSrv.ImportCoupons).Methods(http.MethodPost).Name(streamingRoute + ":import")
+	r.HandleFunc("/coupon/export", s.couponSrv.ExportCoupons).Methods(http.MethodGet).Name(streamingRoute + ":export")
+	r.HandleFunc("/coupon/{id}", s.couponSrv.UpdateCoupon).Methods(http.MethodPatch)
+	r.HandleFunc("/coupon/{id}", s.couponSrv.DeleteCoupon).Methods(http.MethodDelete)
+	r.HandleFunc("/coupon/{id}/deactivate", s.couponSrv.DeactivateCoupon).Methods(http.MethodPut)
+	r.HandleFunc("/coupon/{id}/reactivate", s.couponSrv.ReactivateCoupon).Methods(http.MethodPut)
+	r.HandleFunc("/coupon/{id}/stats", s.couponSrv.GetCouponStats).Methods(http.MethodGet)
+}
+
+// webhookRouter holds the routing for the webhook subscription endpoints,
+// they are only available to the support staff
+func (s *Server) webhookRouter(r *mux.Router) {
+	staff := func(h http.HandlerFunc) http.HandlerFunc {
+		return staffOnly(h, ErrWebhookStaffOnly)
+	}
+	r.HandleFunc("/webhook", staff(s.webhookSrv.CreateSubscription)).Methods(http.MethodPost)
+	r.HandleFunc("/webhook", staff(s.webhookSrv.ListSubscriptions)).Methods(http.MethodGet)
+	r.HandleFunc("/webhook/dead-letters", staff(s.webhookSrv.ListDeadLetters)).Methods(http.MethodGet)
+	r.HandleFunc("/webhook/dead-letters/{id}/retry", staff(s.webhookSrv.RetryDeadLetter)).Methods(http.MethodPost)
+	r.HandleFunc("/webhook/{id}", staff(s.webhookSrv.GetSubscription)).Methods(http.MethodGet)
+	r.HandleFunc("/webhook/{id}", staff(s.webhookSrv.UpdateSubscription)).Methods(http.MethodPatch)
+	r.HandleFunc("/webhook/{id}", staff(s.webhookSrv.DeleteSubscription)).Methods(http.MethodDelete)
+}
+
+// idempotent lets the retries of the given handler carry an Idempotency-Key,
+// when the server has an idempotency store
+func (s *Server) idempotent(h http.HandlerFunc) http.HandlerFunc {
+	if s.idempotencyStore == nil {
+		return h
+	}
+	return s.idempotencyMiddleware(h)
 }
 
 func contentTypeJSONMiddleware(next http.Handler) http.Handler {
@@ -112,26 +224,134 @@ func contentTypeJSONMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// timeoutMiddleware sets a deadline on the request context so the DB
+// work of a request is canceled once the request takes too long
+func (s *Server) timeoutMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil && strings.HasPrefix(route.GetName(), streamingRoute) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), s.requestTimeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// customerMiddleware stores the authenticated customer ID, forwarded
+// by the gateway in the X-Customer-ID header, into the request context
+func customerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get(auth.CustomerIDHeader)
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		customerID, err := uuid.Parse(header)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("parsing customer id", slog.Any("error", err))
+			responseError(w, r, ErrInvalidCustomerID)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithCustomerID(r.Context(), customerID)))
+	})
+}
+
+// staffOnly rejects with forbidden the requests not sent by the support staff
+func staffOnly(next http.HandlerFunc, forbidden *internalErrors.Error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := auth.StaffID(r.Context()); !ok {
+			logging.FromContext(r.Context()).Error("checking staff", slog.Any("error", forbidden))
+			responseError(w, r, forbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// staffMiddleware stores the authenticated staff ID, forwarded by the gateway
+// in the X-Staff-ID header, into the request context. The header is only
+// trusted along the staff token shared with the gateway, so it is rejected
+// when the server has none
+func (s *Server) staffMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		staffID := r.Header.Get(auth.StaffIDHeader)
+		if staffID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.staffToken == "" {
+			logging.FromContext(r.Context()).Error("checking staff", slog.Any("error", ErrUntrustedStaffID))
+			responseError(w, r, ErrUntrustedStaffID)
+			return
+		}
+		token := r.Header.Get(auth.StaffTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.staffToken)) != 1 {
+			logging.FromContext(r.Context()).Error("checking staff", slog.Any("error", ErrInvalidStaffToken))
+			responseError(w, r, ErrInvalidStaffToken)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithStaffID(r.Context(), staffID)))
+	})
+}
+
+// requestIDMiddleware stores the request ID into the request context and
+// sends it back, the ID given in the X-Request-ID header is kept when valid
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.WithID(r.Context(), id)))
+	})
+}
+
+// ifMatchMiddleware stores the version given in the If-Match header into
+// the request context, the services reject the change when it is stale
+func ifMatchMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get(etag.IfMatchHeader)
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		version, ok, err := etag.Parse(header)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("parsing if-match", slog.Any("error", err))
+			responseError(w, r, err)
+			return
+		}
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(etag.WithIfMatch(r.Context(), version)))
+	})
+}
+
 // encodeResponse receives the http response writer and the response
 // to be encoded. It also sets the StatusCode to 200 unless encoding fails, in that
 // case it encodes a code 400 and the error
-func encodeResponse(w http.ResponseWriter, res interface{}) {
+func encodeResponse(w http.ResponseWriter, r *http.Request, res interface{}) {
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(res); err != nil {
-		slog.Error(fmt.Sprintf("error encoding response: %s", err))
+		logging.FromContext(r.Context()).Error("encoding response", slog.Any("error", err))
 	}
 }
 
-// responseError handles internals error http response.
+// responseError handles internals error http response, encoding them as
+// RFC 7807 problem details. Errors other than the internal ones are mapped
+// by mapError, so their details never reach the client.
 func responseError(w http.ResponseWriter, r *http.Request, err error) {
-	var internalErr *internalErrors.Error
-	if errors.As(err, &internalErr) {
-		w.WriteHeader(internalErr.HTTPStatus())
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(internalErr); err != nil {
-			slog.Error(fmt.Sprintf("error encoding response: %s", err))
-		}
-		return
+	internalErr := mapError(err)
+	problem := internalErr.Problem()
+	problem.Instance = r.URL.Path
+	problem.RequestID, _ = requestid.FromContext(r.Context())
+	w.Header().Set("Content-Type", internalErrors.ProblemContentType)
+	w.WriteHeader(internalErr.HTTPStatus())
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		logging.FromContext(r.Context()).Error("encoding response", slog.Any("error", err))
 	}
-	responseError(w, r, err)
 }
@@ -0,0 +1,254 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nachoconques0/schwarz-challenge/internal/auth"
+	"github.com/nachoconques0/schwarz-challenge/internal/etag"
+	internalHTTP "github.com/nachoconques0/schwarz-challenge/internal/http"
+	"github.com/nachoconques0/schwarz-challenge/internal/mocks"
+	"github.com/nachoconques0/schwarz-challenge/internal/requestid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+// testStaffToken is the token shared with the gateway by the test servers
+const testStaffToken = "gateway-staff-token"
+
+func TestServer_RequestTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	scSrv := mocks.NewMockShoppingCartServer(ctrl)
+	cSrv := mocks.NewMockCouponServer(ctrl)
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		_, err := internalHTTP.NewServer("8080", scSrv, cSrv, internalHTTP.WithRequestTimeout(0))
+		assert.NotNil(t, err)
+	})
+
+	server, err := internalHTTP.NewServer("8080", scSrv, cSrv, internalHTTP.WithRequestTimeout(time.Second))
+	assert.Nil(t, err)
+
+	testCases := map[string]struct {
+		method      string
+		path        string
+		mocks       func(check func(w http.ResponseWriter, r *http.Request))
+		hasDeadline bool
+	}{
+		"request bounded by the timeout": {
+			method: http.MethodGet,
+			path:   "/shopping-cart",
+			mocks: func(check func(w http.ResponseWriter, r *http.Request)) {
+				scSrv.EXPECT().ListShoppingCarts(gomock.Any(), gomock.Any()).Do(check)
+			},
+			hasDeadline: true,
+		},
+		"streaming request not bounded": {
+			method: http.MethodGet,
+			path:   "/coupon/export",
+			mocks: func(check func(w http.ResponseWriter, r *http.Request)) {
+				cSrv.EXPECT().ExportCoupons(gomock.Any(), gomock.Any()).Do(check)
+			},
+			hasDeadline: false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tc.mocks(func(w http.ResponseWriter, r *http.Request) {
+				deadline, ok := r.Context().Deadline()
+				assert.Equal(t, tc.hasDeadline, ok)
+				if ok {
+					assert.WithinDuration(t, time.Now().Add(time.Second), deadline, time.Second)
+				}
+			})
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			server.Handler.ServeHTTP(httptest.NewRecorder(), req)
+		})
+	}
+}
+
+func TestServer_IfMatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	scSrv := mocks.NewMockShoppingCartServer(ctrl)
+	cSrv := mocks.NewMockCouponServer(ctrl)
+
+	server, err := internalHTTP.NewServer("8080", scSrv, cSrv)
+	assert.Nil(t, err)
+
+	testCases := map[string]struct {
+		header   string
+		mocks    func(check func(w http.ResponseWriter, r *http.Request))
+		status   int
+		version  int64
+		hasMatch bool
+	}{
+		"without if-match": {
+			mocks: func(check func(w http.ResponseWriter, r *http.Request)) {
+				cSrv.EXPECT().DeleteCoupon(gomock.Any(), gomock.Any()).Do(check)
+			},
+			status: http.StatusOK,
+		},
+		"wildcard if-match": {
+			header: "*",
+			mocks: func(check func(w http.ResponseWriter, r *http.Request)) {
+				cSrv.EXPECT().DeleteCoupon(gomock.Any(), gomock.Any()).Do(check)
+			},
+			status: http.StatusOK,
+		},
+		"version if-match": {
+			header: `"3"`,
+			mocks: func(check func(w http.ResponseWriter, r *http.Request)) {
+				cSrv.EXPECT().DeleteCoupon(gomock.Any(), gomock.Any()).Do(check)
+			},
+			status:   http.StatusOK,
+			version:  3,
+			hasMatch: true,
+		},
+		"invalid if-match": {
+			header: "three",
+			mocks:  func(func(w http.ResponseWriter, r *http.Request)) {},
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tc.mocks(func(w http.ResponseWriter, r *http.Request) {
+				version, ok := etag.IfMatch(r.Context())
+				assert.Equal(t, tc.hasMatch, ok)
+				assert.Equal(t, tc.version, version)
+			})
+			req := httptest.NewRequest(http.MethodDelete, "/coupon/"+uuid.NewString(), nil)
+			if tc.header != "" {
+				req.Header.Set(etag.IfMatchHeader, tc.header)
+			}
+			recorder := httptest.NewRecorder()
+			server.Handler.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.status, recorder.Code)
+		})
+	}
+}
+
+func TestServer_RequestID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	scSrv := mocks.NewMockShoppingCartServer(ctrl)
+	cSrv := mocks.NewMockCouponServer(ctrl)
+
+	server, err := internalHTTP.NewServer("8080", scSrv, cSrv)
+	assert.Nil(t, err)
+
+	testCases := map[string]struct {
+		header string
+		kept   bool
+	}{
+		"without request id": {},
+		"with request id": {
+			header: "gateway-42",
+			kept:   true,
+		},
+		"invalid request id": {
+			header: "with spaces",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var id string
+			scSrv.EXPECT().ListShoppingCarts(gomock.Any(), gomock.Any()).Do(func(_ http.ResponseWriter, r *http.Request) {
+				var ok bool
+				id, ok = requestid.FromContext(r.Context())
+				assert.True(t, ok)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/shopping-cart", nil)
+			if tc.header != "" {
+				req.Header.Set(requestid.Header, tc.header)
+			}
+			recorder := httptest.NewRecorder()
+			server.Handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, id, recorder.Header().Get(requestid.Header))
+			if tc.kept {
+				assert.Equal(t, tc.header, id)
+			} else {
+				assert.NotEqual(t, tc.header, id)
+				assert.NotEmpty(t, id)
+			}
+		})
+	}
+}
+
+func TestServer_Staff(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	scSrv := mocks.NewMockShoppingCartServer(ctrl)
+	cSrv := mocks.NewMockCouponServer(ctrl)
+
+	testCases := map[string]struct {
+		staffToken string
+		staffID    string
+		token      string
+		status     int
+		isStaff    bool
+	}{
+		"without staff id": {
+			staffToken: testStaffToken,
+			status:     http.StatusOK,
+		},
+		"staff id with the gateway token": {
+			staffToken: testStaffToken,
+			staffID:    "alice",
+			token:      testStaffToken,
+			status:     http.StatusOK,
+			isStaff:    true,
+		},
+		"staff id without token": {
+			staffToken: testStaffToken,
+			staffID:    "alice",
+			status:     http.StatusForbidden,
+		},
+		"staff id with a wrong token": {
+			staffToken: testStaffToken,
+			staffID:    "alice",
+			token:      "guessed",
+			status:     http.StatusForbidden,
+		},
+		"staff id without trusted gateway": {
+			staffID: "alice",
+			token:   testStaffToken,
+			status:  http.StatusForbidden,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			opts := []internalHTTP.Option{}
+			if tc.staffToken != "" {
+				opts = append(opts, internalHTTP.WithStaffToken(tc.staffToken))
+			}
+			server, err := internalHTTP.NewServer("8080", scSrv, cSrv, opts...)
+			assert.Nil(t, err)
+			if tc.status == http.StatusOK {
+				scSrv.EXPECT().ListShoppingCarts(gomock.Any(), gomock.Any()).Do(func(_ http.ResponseWriter, r *http.Request) {
+					staffID, ok := auth.StaffID(r.Context())
+					assert.Equal(t, tc.isStaff, ok)
+					if ok {
+						assert.Equal(t, tc.staffID, staffID)
+					}
+				})
+			}
+			req := httptest.NewRequest(http.MethodGet, "/shopping-cart", nil)
+			if tc.staffID != "" {
+				req.Header.Set(auth.StaffIDHeader, tc.staffID)
+			}
+			if tc.token != "" {
+				req.Header.Set(auth.StaffTokenHeader, tc.token)
+			}
+			recorder := httptest.NewRecorder()
+			server.Handler.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.status, recorder.Code)
+		})
+	}
+}
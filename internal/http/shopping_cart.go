@@ -1,22 +1,17 @@
 package http
 
 import (
-	"encoding/json"
-	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
-	"strings"
-
-	// embed used for loading request cases
-	_ "embed"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"github.com/xeipuuv/gojsonschema"
 
+	"github.com/nachoconques0/schwarz-challenge/internal/auth"
 	"github.com/nachoconques0/schwarz-challenge/internal/errors"
 	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/etag"
+	"github.com/nachoconques0/schwarz-challenge/internal/logging"
 	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
 )
 
@@ -29,9 +24,6 @@ var (
 	ErrInvalidCreateShoppingCartRequest = errors.NewWrongInput("invalid create shopping cart request")
 )
 
-//go:embed schemas/shopping_cart/create.json
-var createShoppingCartRequestSchema []byte
-
 // NewShopppingCartCtrl creates a new HTTP Controller
 // with the given shoppingcart.Service
 func NewShopppingCartCtrl(svc shoppingcart.Service) shoppingcart.Server {
@@ -46,66 +38,36 @@ type shoppingCartController struct {
 
 // CreateShoppingCart receives a request in order to create a shopping cart
 func (scCtrl *shoppingCartController) CreateShoppingCart(w http.ResponseWriter, r *http.Request) {
-	createSchema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(createShoppingCartRequestSchema))
-	if err != nil {
-		slog.Error(fmt.Sprintf("creating create coupon schema : %s\n", err))
-		responseError(w, r, err)
-		return
-	}
-	requestBytes, err := io.ReadAll(r.Body)
-	if err != nil {
-		slog.Error(fmt.Sprintf("reading create coupon body : %s\n", err))
-		responseError(w, r, err)
-		return
-	}
-
-	requestJSON := gojsonschema.NewBytesLoader(requestBytes)
-	result, err := createSchema.Validate(requestJSON)
+	payload, err := decodeRequest[shoppingcart.CreateRequest](r, createShoppingCartSchema, ErrInvalidCreateShoppingCartRequest)
 	if err != nil {
-		slog.Error(fmt.Sprintf("error validating request : %s\n", err))
+		logging.FromContext(r.Context()).Error("ctrl: decoding create shopping cart request", slog.Any("error", err))
 		responseError(w, r, err)
 		return
 	}
-
-	if !result.Valid() {
-		details := make([]string, 0, len(result.Errors()))
-		for _, err := range result.Errors() {
-			details = append(details, fmt.Sprintf("Field:%s, with error:%s:", err.Field(), err.Description()))
-		}
-		slog.Error("create coupon request data is not valid:",
-			slog.String("error_details", strings.Join(details, "\n")),
-		)
-		responseError(w, r, ErrInvalidCreateShoppingCartRequest)
-		return
-	}
-
-	var payload shoppingcart.CreateRequest
-	err = json.Unmarshal(requestBytes, &payload)
-	if err != nil {
-		slog.Error(fmt.Sprintf("decoding create shopping cart request: %s\n", err))
-		responseError(w, r, err)
-		return
+	if customerID, ok := auth.CustomerID(r.Context()); ok {
+		payload.CustomerID = customerID
 	}
 
-	res, err := scCtrl.svc.CreateShoppingCart(payload)
+	res, err := scCtrl.svc.CreateShoppingCart(r.Context(), payload)
 	if err != nil {
-		slog.Error(fmt.Sprintf("creating shopping cart: %s\n", err))
+		logging.FromContext(r.Context()).Error("creating shopping cart", slog.Any("error", err))
 		responseError(w, r, err)
 		return
 	}
 
-	encodeResponse(w, res)
+	w.Header().Set(etag.Header, etag.Format(res.Version))
+	encodeResponse(w, r, res)
 }
 
 // ListShoppingCarts returns a list of shopping carts
 func (scCtrl *shoppingCartController) ListShoppingCarts(w http.ResponseWriter, r *http.Request) {
-	res, err := scCtrl.svc.ListShoppingCarts()
+	res, err := scCtrl.svc.ListShoppingCarts(r.Context())
 	if err != nil {
-		slog.Error(fmt.Sprintf("listing shopping cart: %s\n", err))
+		logging.FromContext(r.Context()).Error("listing shopping cart", slog.Any("error", err))
 		responseError(w, r, err)
 		return
 	}
-	encodeResponse(w, res)
+	encodeResponse(w, r, res)
 }
 
 // ApplyCoupon receives a request in order to apply a coupon to a shopping cart
@@ -115,35 +77,36 @@ func (scCtrl *shoppingCartController) ApplyCoupon(w http.ResponseWriter, r *http
 	couponID := vars["coupon_id"]
 
 	if shoppingCartID == "" {
-		slog.Error(fmt.Sprintf("ctrl: applying coupon: %s\n", ErrShoppingCartEmptyID))
+		logging.FromContext(r.Context()).Error("ctrl: applying coupon", slog.Any("error", ErrShoppingCartEmptyID))
 		responseError(w, r, ErrShoppingCartEmptyID)
 		return
 	}
 	if couponID == "" {
-		slog.Error(fmt.Sprintf("ctrl: applying coupon: %s\n", ErrCouponEmptyID))
+		logging.FromContext(r.Context()).Error("ctrl: applying coupon", slog.Any("error", ErrCouponEmptyID))
 		responseError(w, r, ErrCouponEmptyID)
 		return
 	}
 
 	parsedShoppingCartID, err := uuid.Parse(shoppingCartID)
 	if err != nil {
-		slog.Error(fmt.Sprintf("ctrl: applying coupon: %s\n", ErrShoppingCartEmptyID))
+		logging.FromContext(r.Context()).Error("ctrl: applying coupon", slog.Any("error", ErrShoppingCartEmptyID))
 		w.WriteHeader(http.StatusBadRequest)
-		encodeResponse(w, nil)
+		encodeResponse(w, r, nil)
 		return
 	}
 	parsedCouponID, err := uuid.Parse(couponID)
 	if err != nil {
-		slog.Error(fmt.Sprintf("ctrl: applying coupon: %s\n", ErrCouponEmptyID))
+		logging.FromContext(r.Context()).Error("ctrl: applying coupon", slog.Any("error", ErrCouponEmptyID))
 		w.WriteHeader(http.StatusBadRequest)
-		encodeResponse(w, nil)
+		encodeResponse(w, r, nil)
 		return
 	}
-	err = scCtrl.svc.ApplyCoupon(parsedShoppingCartID, parsedCouponID)
+	res, err := scCtrl.svc.ApplyCoupon(r.Context(), parsedShoppingCartID, parsedCouponID)
 	if err != nil {
-		slog.Error(fmt.Sprintf("ctrl: applying coupon: %s\n", err))
+		logging.FromContext(r.Context()).Error("ctrl: applying coupon", slog.Any("error", err))
 		responseError(w, r, err)
 		return
 	}
+	w.Header().Set(etag.Header, etag.Format(res.Version))
 	w.WriteHeader(http.StatusOK)
 }
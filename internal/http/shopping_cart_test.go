@@ -2,14 +2,19 @@ package http_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	stdErrors "errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/nachoconques0/schwarz-challenge/internal/auth"
 	"github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/etag"
 	internalHTTP "github.com/nachoconques0/schwarz-challenge/internal/http"
 	"github.com/nachoconques0/schwarz-challenge/internal/mocks"
 	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
@@ -24,25 +29,26 @@ func TestController_CreateShoppingCart(t *testing.T) {
 	svc := mocks.NewMockShoppingCartService(ctrl)
 	controller := internalHTTP.NewShopppingCartCtrl(svc)
 
+	// the items of the request carry no ID, it is generated
+	createShoppingCartBody := map[string]interface{}{
+		"items": []map[string]interface{}{
+			{"name": testName, "description": testName, "price": testAmount},
+		},
+	}
+
 	t.Run("success", func(t *testing.T) {
-		svc.EXPECT().CreateShoppingCart(gomock.Any()).Return(&shoppingcart.ShoppingCart{
+		svc.EXPECT().CreateShoppingCart(gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{
 			Items: shoppingcart.Items{
 				shoppingcart.Item{
-					Price: float32(testAmount),
-					Name:  testName,
+					Price:       float32(testAmount),
+					Name:        testName,
+					Description: testName,
 				},
 			},
 			Amount: float32(testAmount),
 		}, nil)
 
-		body, err := json.Marshal(shoppingcart.CreateRequest{
-			Items: shoppingcart.Items{
-				shoppingcart.Item{
-					Price: float32(testAmount),
-					Name:  testName,
-				},
-			},
-		})
+		body, err := json.Marshal(createShoppingCartBody)
 		assert.Nil(t, err)
 
 		req, err := http.NewRequest(http.MethodPost, "http://www.test.com", bytes.NewBuffer(body))
@@ -60,16 +66,65 @@ func TestController_CreateShoppingCart(t *testing.T) {
 		_ = resp.Body.Close()
 	})
 
-	t.Run("fail", func(t *testing.T) {
-		svc.EXPECT().CreateShoppingCart(gomock.Any()).Return(nil, errTest)
-		body, err := json.Marshal(shoppingcart.CreateRequest{
-			Items: shoppingcart.Items{
-				shoppingcart.Item{
-					Price: float32(testAmount),
-					Name:  testName,
-				},
-			},
+	t.Run("success with authenticated customer", func(t *testing.T) {
+		customerID := uuid.New()
+		svc.EXPECT().CreateShoppingCart(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, req shoppingcart.CreateRequest) (*shoppingcart.ShoppingCart, error) {
+			assert.Equal(t, customerID, req.CustomerID)
+			return shoppingcart.New(req), nil
 		})
+
+		body, err := json.Marshal(createShoppingCartBody)
+		assert.Nil(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, "http://www.test.com", bytes.NewBuffer(body))
+		assert.Nil(t, err)
+		req = req.WithContext(auth.WithCustomerID(req.Context(), customerID))
+
+		recorder := httptest.NewRecorder()
+		controller.CreateShoppingCart(recorder, req)
+		resp := recorder.Result()
+
+		response := &shoppingcart.ShoppingCart{}
+		err = json.NewDecoder(resp.Body).Decode(response)
+		assert.Nil(t, err)
+		assert.Equal(t, customerID, response.CustomerID)
+		_ = resp.Body.Close()
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		body := `{"items": [{"name": "pen", "price": 0, "id": "1"}], "coupon": "SALE"}`
+		req, err := http.NewRequest(http.MethodPost, "http://www.test.com/shopping-cart", bytes.NewBufferString(body))
+		assert.Nil(t, err)
+
+		recorder := httptest.NewRecorder()
+		controller.CreateShoppingCart(recorder, req)
+		resp := recorder.Result()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, errors.ProblemContentType, resp.Header.Get("Content-Type"))
+		problem := errors.Problem{}
+		err = json.NewDecoder(resp.Body).Decode(&problem)
+		assert.Nil(t, err)
+		assert.Equal(t, errors.Problem{
+			Type:     "/problems/wrong_input",
+			Title:    "Bad Request",
+			Status:   http.StatusBadRequest,
+			Detail:   "invalid create shopping cart request",
+			Instance: "/shopping-cart",
+			Code:     errors.KindWrongInput,
+			Errors: []errors.FieldError{
+				{Field: "coupon", Code: "additional_property_not_allowed", Message: "Additional property coupon is not allowed"},
+				{Field: "items.0.description", Code: "required", Message: "description is required"},
+				{Field: "items.0.id", Code: "additional_property_not_allowed", Message: "Additional property id is not allowed"},
+				{Field: "items.0.price", Code: "number_gt", Message: "Must be greater than 0"},
+			},
+		}, problem)
+		_ = resp.Body.Close()
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		svc.EXPECT().CreateShoppingCart(gomock.Any(), gomock.Any()).Return(nil, errTest)
+		body, err := json.Marshal(createShoppingCartBody)
 		assert.Nil(t, err)
 
 		req, err := http.NewRequest(http.MethodPost, "http://www.test.com", bytes.NewBuffer(body))
@@ -94,12 +149,13 @@ func TestController_ListShoppingCarts(t *testing.T) {
 	controller := internalHTTP.NewShopppingCartCtrl(svc)
 
 	t.Run("success", func(t *testing.T) {
-		svc.EXPECT().ListShoppingCarts().Return([]shoppingcart.ShoppingCart{
+		svc.EXPECT().ListShoppingCarts(gomock.Any()).Return([]shoppingcart.ShoppingCart{
 			{
 				Items: shoppingcart.Items{
 					shoppingcart.Item{
-						Price: float32(testAmount),
-						Name:  testName,
+						Price:       float32(testAmount),
+						Name:        testName,
+						Description: testName,
 					},
 				},
 				Amount: float32(testAmount),
@@ -122,7 +178,7 @@ func TestController_ListShoppingCarts(t *testing.T) {
 	})
 
 	t.Run("fail", func(t *testing.T) {
-		svc.EXPECT().ListShoppingCarts().Return(nil, errTest)
+		svc.EXPECT().ListShoppingCarts(gomock.Any()).Return(nil, errTest)
 
 		req, err := http.NewRequest(http.MethodPost, "http://www.test.com", nil)
 		assert.Nil(t, err)
@@ -136,6 +192,41 @@ func TestController_ListShoppingCarts(t *testing.T) {
 		assert.Equal(t, errTest, responseErr)
 		_ = resp.Body.Close()
 	})
+
+	t.Run("infrastructure errors", func(t *testing.T) {
+		testCases := map[string]struct {
+			err      error
+			expected *errors.Error
+		}{
+			"timeout": {
+				err:      fmt.Errorf("listing shopping carts: %w", context.DeadlineExceeded),
+				expected: errors.ErrRequestTimeout,
+			},
+			"unknown": {
+				err:      stdErrors.New("dial tcp 10.0.0.5:5432: connection refused"),
+				expected: errors.ErrInternal,
+			},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				svc.EXPECT().ListShoppingCarts(gomock.Any()).Return(nil, tc.err)
+
+				req, err := http.NewRequest(http.MethodGet, "http://www.test.com", nil)
+				assert.Nil(t, err)
+
+				recorder := httptest.NewRecorder()
+				controller.ListShoppingCarts(recorder, req)
+				resp := recorder.Result()
+				assert.Equal(t, tc.expected.HTTPStatus(), resp.StatusCode)
+				responseErr := &errors.Error{}
+				err = json.NewDecoder(resp.Body).Decode(responseErr)
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expected, responseErr)
+				_ = resp.Body.Close()
+			})
+		}
+	})
 }
 
 func TestController_ApplyCoupon(t *testing.T) {
@@ -147,7 +238,7 @@ func TestController_ApplyCoupon(t *testing.T) {
 	controller := internalHTTP.NewShopppingCartCtrl(svc)
 
 	t.Run("success", func(t *testing.T) {
-		svc.EXPECT().ApplyCoupon(shoppingCartID, couponID).Return(nil)
+		svc.EXPECT().ApplyCoupon(gomock.Any(), shoppingCartID, couponID).Return(&shoppingcart.ShoppingCart{ID: shoppingCartID, Version: 2}, nil)
 		urlVars := map[string]string{
 			"id":        shoppingCartID.String(),
 			"coupon_id": couponID.String(),
@@ -164,6 +255,7 @@ func TestController_ApplyCoupon(t *testing.T) {
 
 		resp := recorder.Result()
 		assert.Equal(t, recorder.Code, http.StatusOK)
+		assert.Equal(t, `"2"`, resp.Header.Get(etag.Header))
 		_ = resp.Body.Close()
 	})
 
@@ -216,7 +308,7 @@ func TestController_ApplyCoupon(t *testing.T) {
 	})
 
 	t.Run("fail svc", func(t *testing.T) {
-		svc.EXPECT().ApplyCoupon(shoppingCartID, couponID).Return(errTest)
+		svc.EXPECT().ApplyCoupon(gomock.Any(), shoppingCartID, couponID).Return(nil, errTest)
 		urlVars := map[string]string{
 			"id":        shoppingCartID.String(),
 			"coupon_id": couponID.String(),
@@ -0,0 +1,165 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/logging"
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
+)
+
+var (
+	// ErrWebhookEmptyID used when webhook subscription ID is invalid
+	ErrWebhookEmptyID = errors.NewWrongInput("webhook subscription ID is invalid")
+	// ErrWebhookDeliveryEmptyID used when webhook delivery ID is invalid
+	ErrWebhookDeliveryEmptyID = errors.NewWrongInput("webhook delivery ID is invalid")
+	// ErrInvalidCreateWebhookRequest used when create webhook request contains invalid data
+	ErrInvalidCreateWebhookRequest = errors.NewWrongInput("invalid create webhook subscription request")
+	// ErrInvalidUpdateWebhookRequest used when update webhook request contains invalid data
+	ErrInvalidUpdateWebhookRequest = errors.NewWrongInput("invalid update webhook subscription request")
+	// ErrWebhookStaffOnly used when the webhooks are managed by someone not in the support staff
+	ErrWebhookStaffOnly = errors.NewForbidden("webhooks are only available to the support staff")
+)
+
+// NewWebhookCtrl creates a new HTTP Controller
+// with the given webhook.Service
+func NewWebhookCtrl(svc webhook.Service) webhook.Server {
+	return &webhookController{svc: svc}
+}
+
+// webhookController holds the required dependencies
+// in order to implement the service Request
+type webhookController struct {
+	svc webhook.Service
+}
+
+// CreateSubscription receives a request in order to create a subscription
+func (wCtrl *webhookController) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodeRequest[webhook.CreateRequest](r, createWebhookSchema, ErrInvalidCreateWebhookRequest)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: decoding create webhook request", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+
+	res, err := wCtrl.svc.CreateSubscription(r.Context(), payload)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: creating webhook subscription", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+	encodeResponse(w, r, res)
+}
+
+// ListSubscriptions returns a list of subscriptions
+func (wCtrl *webhookController) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	res, err := wCtrl.svc.ListSubscriptions(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: listing webhook subscriptions", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+	encodeResponse(w, r, res)
+}
+
+// GetSubscription returns a subscription
+func (wCtrl *webhookController) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id", ErrWebhookEmptyID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: getting webhook subscription", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+
+	res, err := wCtrl.svc.GetSubscription(r.Context(), id)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: getting webhook subscription", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+	encodeResponse(w, r, res)
+}
+
+// UpdateSubscription receives a request in order to update a subscription
+func (wCtrl *webhookController) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id", ErrWebhookEmptyID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: updating webhook subscription", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+
+	payload, err := decodeRequest[webhook.UpdateRequest](r, updateWebhookSchema, ErrInvalidUpdateWebhookRequest)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: decoding update webhook request", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+
+	res, err := wCtrl.svc.UpdateSubscription(r.Context(), id, payload)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: updating webhook subscription", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+	encodeResponse(w, r, res)
+}
+
+// DeleteSubscription receives a request in order to delete a subscription
+func (wCtrl *webhookController) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id", ErrWebhookEmptyID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: deleting webhook subscription", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+
+	if err := wCtrl.svc.DeleteSubscription(r.Context(), id); err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: deleting webhook subscription", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeadLetters returns the deliveries that failed too many times
+func (wCtrl *webhookController) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	res, err := wCtrl.svc.ListDeadLetters(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: listing webhook dead letters", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+	encodeResponse(w, r, res)
+}
+
+// RetryDeadLetter receives a request in order to send a dead letter once more
+func (wCtrl *webhookController) RetryDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id", ErrWebhookDeliveryEmptyID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: retrying webhook dead letter", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+
+	res, err := wCtrl.svc.RetryDeadLetter(r.Context(), id)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ctrl: retrying webhook dead letter", slog.Any("error", err))
+		responseError(w, r, err)
+		return
+	}
+	encodeResponse(w, r, res)
+}
+
+// pathID returns the ID set in the given request path variable, invalidErr when it is not an UUID
+func pathID(r *http.Request, name string, invalidErr error) (uuid.UUID, error) {
+	id, err := uuid.Parse(mux.Vars(r)[name])
+	if err != nil {
+		return uuid.Nil, invalidErr
+	}
+	return id, nil
+}
@@ -0,0 +1,345 @@
+package http_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/auth"
+	"github.com/nachoconques0/schwarz-challenge/internal/errors"
+	internalHTTP "github.com/nachoconques0/schwarz-challenge/internal/http"
+	"github.com/nachoconques0/schwarz-challenge/internal/mocks"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
+)
+
+const testWebhookURL = "https://203.0.113.10/hook"
+
+func TestController_CreateSubscription(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := mocks.NewMockWebhookService(ctrl)
+	controller := internalHTTP.NewWebhookCtrl(svc)
+
+	t.Run("success", func(t *testing.T) {
+		svc.EXPECT().CreateSubscription(gomock.Any(), webhook.CreateRequest{
+			URL:        testWebhookURL,
+			EventTypes: webhook.EventTypeList{shoppingcart.EventCreated},
+		}).Return(&webhook.Subscription{ID: uuid.New(), URL: testWebhookURL, Secret: "whsec_test"}, nil)
+
+		body, _ := json.Marshal(map[string]interface{}{
+			"url":         testWebhookURL,
+			"event_types": []string{shoppingcart.EventCreated},
+		})
+		req, err := http.NewRequest(http.MethodPost, "http://www.test.com", bytes.NewBuffer(body))
+		assert.Nil(t, err)
+
+		recorder := httptest.NewRecorder()
+		controller.CreateSubscription(recorder, req)
+		resp := recorder.Result()
+
+		response := &webhook.Subscription{}
+		err = json.NewDecoder(resp.Body).Decode(response)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, testWebhookURL, response.URL)
+		assert.Equal(t, "whsec_test", response.Secret)
+		_ = resp.Body.Close()
+	})
+
+	t.Run("unknown event type", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]interface{}{
+			"url":         testWebhookURL,
+			"event_types": []string{"coupon.created"},
+		})
+		req, err := http.NewRequest(http.MethodPost, "http://www.test.com", bytes.NewBuffer(body))
+		assert.Nil(t, err)
+
+		recorder := httptest.NewRecorder()
+		controller.CreateSubscription(recorder, req)
+		resp := recorder.Result()
+
+		responseErr := &errors.Error{}
+		err = json.NewDecoder(resp.Body).Decode(responseErr)
+		assert.Nil(t, err)
+		expected := internalHTTP.ErrInvalidCreateWebhookRequest.WithFields(errors.FieldError{
+			Field:   "event_types.0",
+			Code:    "enum",
+			Message: `event_types.0 must be one of the following: "shopping_cart.created", "shopping_cart.coupon_applied", "coupon.exhausted"`,
+		})
+		assert.Equal(t, expected.Problem(), responseErr.Problem())
+		_ = resp.Body.Close()
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		svc.EXPECT().CreateSubscription(gomock.Any(), gomock.Any()).Return(nil, errTest)
+		body, _ := json.Marshal(map[string]interface{}{
+			"url":         testWebhookURL,
+			"event_types": []string{shoppingcart.EventCreated},
+		})
+		req, err := http.NewRequest(http.MethodPost, "http://www.test.com", bytes.NewBuffer(body))
+		assert.Nil(t, err)
+
+		recorder := httptest.NewRecorder()
+		controller.CreateSubscription(recorder, req)
+		resp := recorder.Result()
+
+		responseErr := &errors.Error{}
+		err = json.NewDecoder(resp.Body).Decode(responseErr)
+		assert.Nil(t, err)
+		assert.Equal(t, errTest, responseErr)
+		_ = resp.Body.Close()
+	})
+}
+
+func TestController_ListSubscriptions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := mocks.NewMockWebhookService(ctrl)
+	controller := internalHTTP.NewWebhookCtrl(svc)
+
+	svc.EXPECT().ListSubscriptions(gomock.Any()).Return([]webhook.Subscription{{ID: uuid.New(), URL: testWebhookURL}}, nil)
+	req, err := http.NewRequest(http.MethodGet, "http://www.test.com", nil)
+	assert.Nil(t, err)
+
+	recorder := httptest.NewRecorder()
+	controller.ListSubscriptions(recorder, req)
+	resp := recorder.Result()
+
+	var response []webhook.Subscription
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	assert.Nil(t, err)
+	assert.Len(t, response, 1)
+	_ = resp.Body.Close()
+}
+
+func TestController_GetSubscription(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := mocks.NewMockWebhookService(ctrl)
+	controller := internalHTTP.NewWebhookCtrl(svc)
+	subscriptionID := uuid.New()
+
+	t.Run("success", func(t *testing.T) {
+		svc.EXPECT().GetSubscription(gomock.Any(), subscriptionID).Return(&webhook.Subscription{ID: subscriptionID, URL: testWebhookURL}, nil)
+		req, err := http.NewRequest(http.MethodGet, "http://www.test.com", nil)
+		assert.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": subscriptionID.String()})
+
+		recorder := httptest.NewRecorder()
+		controller.GetSubscription(recorder, req)
+		resp := recorder.Result()
+
+		response := &webhook.Subscription{}
+		err = json.NewDecoder(resp.Body).Decode(response)
+		assert.Nil(t, err)
+		assert.Equal(t, subscriptionID, response.ID)
+		_ = resp.Body.Close()
+	})
+
+	t.Run("invalid subscription id", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://www.test.com", nil)
+		assert.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": "invalid"})
+
+		recorder := httptest.NewRecorder()
+		controller.GetSubscription(recorder, req)
+		resp := recorder.Result()
+
+		responseErr := &errors.Error{}
+		err = json.NewDecoder(resp.Body).Decode(responseErr)
+		assert.Nil(t, err)
+		assert.Equal(t, internalHTTP.ErrWebhookEmptyID, responseErr)
+		_ = resp.Body.Close()
+	})
+}
+
+func TestController_UpdateSubscription(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := mocks.NewMockWebhookService(ctrl)
+	controller := internalHTTP.NewWebhookCtrl(svc)
+	subscriptionID := uuid.New()
+
+	t.Run("success", func(t *testing.T) {
+		url := "https://other.test/hook"
+		svc.EXPECT().UpdateSubscription(gomock.Any(), subscriptionID, webhook.UpdateRequest{URL: &url}).
+			Return(&webhook.Subscription{ID: subscriptionID, URL: url}, nil)
+
+		body, _ := json.Marshal(map[string]interface{}{"url": url})
+		req, err := http.NewRequest(http.MethodPatch, "http://www.test.com", bytes.NewBuffer(body))
+		assert.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": subscriptionID.String()})
+
+		recorder := httptest.NewRecorder()
+		controller.UpdateSubscription(recorder, req)
+		resp := recorder.Result()
+
+		response := &webhook.Subscription{}
+		err = json.NewDecoder(resp.Body).Decode(response)
+		assert.Nil(t, err)
+		assert.Equal(t, url, response.URL)
+		_ = resp.Body.Close()
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]interface{}{})
+		req, err := http.NewRequest(http.MethodPatch, "http://www.test.com", bytes.NewBuffer(body))
+		assert.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": subscriptionID.String()})
+
+		recorder := httptest.NewRecorder()
+		controller.UpdateSubscription(recorder, req)
+		resp := recorder.Result()
+
+		responseErr := &errors.Error{}
+		err = json.NewDecoder(resp.Body).Decode(responseErr)
+		assert.Nil(t, err)
+		expected := internalHTTP.ErrInvalidUpdateWebhookRequest.WithFields(errors.FieldError{
+			Code:    "array_min_properties",
+			Message: "Must have at least 1 properties",
+		})
+		assert.Equal(t, expected.Problem(), responseErr.Problem())
+		_ = resp.Body.Close()
+	})
+}
+
+func TestController_DeleteSubscription(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := mocks.NewMockWebhookService(ctrl)
+	controller := internalHTTP.NewWebhookCtrl(svc)
+	subscriptionID := uuid.New()
+
+	svc.EXPECT().DeleteSubscription(gomock.Any(), subscriptionID).Return(nil)
+	req, err := http.NewRequest(http.MethodDelete, "http://www.test.com", nil)
+	assert.Nil(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": subscriptionID.String()})
+
+	recorder := httptest.NewRecorder()
+	controller.DeleteSubscription(recorder, req)
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+}
+
+func TestController_DeadLetters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := mocks.NewMockWebhookService(ctrl)
+	controller := internalHTTP.NewWebhookCtrl(svc)
+	deliveryID := uuid.New()
+
+	t.Run("list", func(t *testing.T) {
+		svc.EXPECT().ListDeadLetters(gomock.Any()).Return([]webhook.Delivery{{ID: deliveryID, Status: webhook.StatusDead}}, nil)
+		req, err := http.NewRequest(http.MethodGet, "http://www.test.com", nil)
+		assert.Nil(t, err)
+
+		recorder := httptest.NewRecorder()
+		controller.ListDeadLetters(recorder, req)
+		resp := recorder.Result()
+
+		var response []webhook.Delivery
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		assert.Nil(t, err)
+		assert.Len(t, response, 1)
+		_ = resp.Body.Close()
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		svc.EXPECT().RetryDeadLetter(gomock.Any(), deliveryID).Return(&webhook.Delivery{ID: deliveryID, Status: webhook.StatusPending}, nil)
+		req, err := http.NewRequest(http.MethodPost, "http://www.test.com", nil)
+		assert.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": deliveryID.String()})
+
+		recorder := httptest.NewRecorder()
+		controller.RetryDeadLetter(recorder, req)
+		resp := recorder.Result()
+
+		response := &webhook.Delivery{}
+		err = json.NewDecoder(resp.Body).Decode(response)
+		assert.Nil(t, err)
+		assert.Equal(t, webhook.StatusPending, response.Status)
+		_ = resp.Body.Close()
+	})
+
+	t.Run("invalid delivery id", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "http://www.test.com", nil)
+		assert.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": "invalid"})
+
+		recorder := httptest.NewRecorder()
+		controller.RetryDeadLetter(recorder, req)
+		resp := recorder.Result()
+
+		responseErr := &errors.Error{}
+		err = json.NewDecoder(resp.Body).Decode(responseErr)
+		assert.Nil(t, err)
+		assert.Equal(t, internalHTTP.ErrWebhookDeliveryEmptyID, responseErr)
+		_ = resp.Body.Close()
+	})
+}
+
+func TestServer_WebhookRoutes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	wSrv := mocks.NewMockWebhookServer(ctrl)
+	server, err := internalHTTP.NewServer("8080", mocks.NewMockShoppingCartServer(ctrl), mocks.NewMockCouponServer(ctrl), internalHTTP.WithWebhooks(wSrv), internalHTTP.WithStaffToken(testStaffToken))
+	assert.Nil(t, err)
+
+	testCases := map[string]struct {
+		method string
+		path   string
+		mocks  func()
+	}{
+		"dead letters are not a subscription": {
+			method: http.MethodGet,
+			path:   "/webhook/dead-letters",
+			mocks: func() {
+				wSrv.EXPECT().ListDeadLetters(gomock.Any(), gomock.Any())
+			},
+		},
+		"retry dead letter": {
+			method: http.MethodPost,
+			path:   "/webhook/dead-letters/" + uuid.NewString() + "/retry",
+			mocks: func() {
+				wSrv.EXPECT().RetryDeadLetter(gomock.Any(), gomock.Any())
+			},
+		},
+		"get subscription": {
+			method: http.MethodGet,
+			path:   "/webhook/" + uuid.NewString(),
+			mocks: func() {
+				wSrv.EXPECT().GetSubscription(gomock.Any(), gomock.Any())
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tc.mocks()
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set(auth.StaffIDHeader, "alice")
+			req.Header.Set(auth.StaffTokenHeader, testStaffToken)
+			server.Handler.ServeHTTP(httptest.NewRecorder(), req)
+		})
+	}
+
+	t.Run("staff only", func(t *testing.T) {
+		for name, tc := range testCases {
+			recorder := httptest.NewRecorder()
+			server.Handler.ServeHTTP(recorder, httptest.NewRequest(tc.method, tc.path, nil))
+			assert.Equal(t, http.StatusForbidden, recorder.Code, name)
+		}
+	})
+}
@@ -0,0 +1,145 @@
+// Package idempotency lets clients safely retry mutating requests. The first
+// request with a given Idempotency-Key reserves it, its response is recorded
+// and replayed to every retry carrying the same key and body
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+)
+
+// Headers used for the idempotent requests
+const (
+	KeyHeader      = "Idempotency-Key"
+	ReplayedHeader = "Idempotency-Replayed"
+)
+
+const (
+	// DefaultTTL is how long a key is remembered, after it the key can be used again
+	DefaultTTL = 24 * time.Hour
+	// DefaultLease is how long a key in progress is held for its request, after
+	// it the request is taken for crashed and a retry takes the key over
+	DefaultLease = time.Minute
+	// DefaultSweepInterval is the time between two sweeps of the expired keys
+	DefaultSweepInterval = time.Hour
+	// MaxKeyLength is the longest key accepted
+	MaxKeyLength = 255
+)
+
+var (
+	// ErrMissingStore used when the store is nil
+	ErrMissingStore = internalErrors.NewInternalError("idempotency store is missing")
+	// ErrInvalidKey used when the key is empty or too long
+	ErrInvalidKey = internalErrors.NewWrongInput("Idempotency-Key header must have between 1 and 255 characters")
+	// ErrKeyReused used when a key is sent again with a different request
+	ErrKeyReused = internalErrors.NewUnprocessableEntity("Idempotency-Key was already used with a different request")
+	// ErrRequestInProgress used when the request of the key is still being processed
+	ErrRequestInProgress = internalErrors.NewConflict("a request with this Idempotency-Key is still in progress, retry later")
+	// ErrUnavailable used when the keys can not be checked
+	ErrUnavailable = internalErrors.NewInternalError("Idempotency-Key can not be checked, retry later")
+)
+
+// Record is a reserved key with the response of its request once completed
+type Record struct {
+	// Scope isolates the keys of different clients
+	Scope string `json:"scope"`
+	// Key is the value of the Idempotency-Key header
+	Key string `json:"key"`
+	// Fingerprint identifies the request, see Fingerprint
+	Fingerprint string `json:"fingerprint"`
+	// StatusCode of the recorded response, zero while the request is in progress
+	StatusCode int `json:"status_code"`
+	// Header of the recorded response
+	Header Header `json:"header"`
+	// Body of the recorded response
+	Body []byte `json:"body"`
+	// Timestamp when the key was reserved
+	CreatedAt time.Time `json:"created_at"`
+	// Timestamp when the response was recorded
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// IsCompleted returns whether the response of the request was recorded
+func (r Record) IsCompleted() bool {
+	return r.StatusCode != 0
+}
+
+// Header contains the recorded response headers in json format
+type Header map[string][]string
+
+// Value for DB
+func (h Header) Value() (driver.Value, error) {
+	if h == nil {
+		return nil, nil
+	}
+	return json.Marshal(h)
+}
+
+// Scan will unmarshall Header data
+func (h *Header) Scan(src interface{}) error {
+	switch t := src.(type) {
+	case string:
+		return json.Unmarshal([]byte(t), h)
+	case []byte:
+		return json.Unmarshal(t, h)
+	case nil:
+		*h = nil
+		return nil
+	}
+	return errors.New("err unmarshal header")
+}
+
+// ValidateKey validates the given Idempotency-Key value
+func ValidateKey(key string) error {
+	if key == "" || len(key) > MaxKeyLength {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+// Fingerprint returns the hash identifying a request by its method, path and body
+func Fingerprint(method string, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Store keeps the reserved keys and their responses
+type Store interface {
+	// Reserve stores rec as in progress unless its key is already stored and was
+	// reserved after expiredBefore, in that case the stored record is returned.
+	// A key still in progress reserved before abandonedBefore is taken over
+	Reserve(ctx context.Context, rec Record, expiredBefore time.Time, abandonedBefore time.Time) (*Record, error)
+	// Complete records the response of a reserved key
+	Complete(ctx context.Context, rec Record) error
+	// Release drops a key still in progress, so its request can be retried
+	Release(ctx context.Context, scope string, key string) error
+	// Sweep drops the keys reserved before expiredBefore
+	Sweep(ctx context.Context, expiredBefore time.Time) error
+}
+
+// RunSweeper sweeps the keys of store older than ttl every interval until ctx is done
+func RunSweeper(ctx context.Context, store Store, ttl time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := store.Sweep(ctx, time.Now().Add(-ttl)); err != nil && ctx.Err() == nil {
+			slog.Error(fmt.Sprintf("idempotency: sweeping expired keys: %s\n", err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package idempotency_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/idempotency"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateKey(t *testing.T) {
+	tests := map[string]struct {
+		key string
+		err error
+	}{
+		"valid": {
+			key: "8e0f6f52-3c8e-4c4e-9d47-2a4f0c3d1f7b",
+		},
+		"empty": {
+			key: "",
+			err: idempotency.ErrInvalidKey,
+		},
+		"too long": {
+			key: strings.Repeat("a", idempotency.MaxKeyLength+1),
+			err: idempotency.ErrInvalidKey,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.err, idempotency.ValidateKey(tc.key))
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	fingerprint := idempotency.Fingerprint(http.MethodPost, "/shopping-cart", []byte(`{"items":[]}`))
+	assert.Equal(t, fingerprint, idempotency.Fingerprint(http.MethodPost, "/shopping-cart", []byte(`{"items":[]}`)))
+	assert.NotEqual(t, fingerprint, idempotency.Fingerprint(http.MethodPost, "/shopping-cart", []byte(`{"items":[{}]}`)))
+	assert.NotEqual(t, fingerprint, idempotency.Fingerprint(http.MethodPost, "/coupon", []byte(`{"items":[]}`)))
+}
+
+func TestHeader_Scan(t *testing.T) {
+	header := idempotency.Header{"Etag": {`"1"`}}
+	value, err := header.Value()
+	assert.Nil(t, err)
+
+	var scanned idempotency.Header
+	assert.Nil(t, scanned.Scan(value))
+	assert.Equal(t, header, scanned)
+
+	assert.Nil(t, scanned.Scan(nil))
+	assert.Nil(t, scanned)
+	assert.NotNil(t, scanned.Scan(1))
+}
@@ -0,0 +1,96 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryStore is an in memory Store, safe for concurrent use
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+// NewMemoryStore returns an in memory Store, keys are lost once the application stops
+func NewMemoryStore() Store {
+	return &memoryStore{
+		records: make(map[string]Record),
+	}
+}
+
+// Reserve stores rec as in progress unless its key is already stored and was
+// reserved after expiredBefore, in that case the stored record is returned.
+// A key still in progress reserved before abandonedBefore is taken over
+func (m *memoryStore) Reserve(ctx context.Context, rec Record, expiredBefore time.Time, abandonedBefore time.Time) (*Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := recordID(rec.Scope, rec.Key)
+	if stored, ok := m.records[id]; ok && !stored.CreatedAt.Before(expiredBefore) &&
+		(stored.IsCompleted() || !stored.CreatedAt.Before(abandonedBefore)) {
+		return &stored, nil
+	}
+	rec.StatusCode = 0
+	rec.Header = nil
+	rec.Body = nil
+	rec.CompletedAt = nil
+	m.records[id] = rec
+	return nil, nil
+}
+
+// Complete records the response of a reserved key
+func (m *memoryStore) Complete(ctx context.Context, rec Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := recordID(rec.Scope, rec.Key)
+	stored, ok := m.records[id]
+	if !ok || stored.IsCompleted() || stored.Fingerprint != rec.Fingerprint {
+		return nil
+	}
+	m.records[id] = rec
+	return nil
+}
+
+// Release drops a key still in progress, so its request can be retried
+func (m *memoryStore) Release(ctx context.Context, scope string, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := recordID(scope, key)
+	if stored, ok := m.records[id]; ok && !stored.IsCompleted() {
+		delete(m.records, id)
+	}
+	return nil
+}
+
+// Sweep drops the keys reserved before expiredBefore
+func (m *memoryStore) Sweep(ctx context.Context, expiredBefore time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, rec := range m.records {
+		if rec.CreatedAt.Before(expiredBefore) {
+			delete(m.records, id)
+		}
+	}
+	return nil
+}
+
+// recordID returns the map key of a record
+func recordID(scope string, key string) string {
+	return scope + "\x00" + key
+}
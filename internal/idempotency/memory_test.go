@@ -0,0 +1,14 @@
+package idempotency_test
+
+import (
+	"testing"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/contract"
+	"github.com/nachoconques0/schwarz-challenge/internal/idempotency"
+)
+
+func TestContract_MemoryStore(t *testing.T) {
+	contract.TestIdempotencyStore(t, func(t *testing.T) contract.Backend {
+		return contract.Backend{IdempotencyStore: idempotency.NewMemoryStore()}
+	})
+}
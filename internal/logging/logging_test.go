@@ -0,0 +1,77 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/logging"
+)
+
+func TestNewLogger(t *testing.T) {
+	testCases := map[string]struct {
+		format   string
+		level    string
+		expected string
+		err      bool
+	}{
+		"text": {
+			format:   logging.FormatText,
+			level:    "info",
+			expected: "level=WARN msg=shown\n",
+		},
+		"json": {
+			format:   logging.FormatJSON,
+			level:    "warn",
+			expected: `{"level":"WARN","msg":"shown"}` + "\n",
+		},
+		"wrong format": {
+			format: "xml",
+			level:  "info",
+			err:    true,
+		},
+		"wrong level": {
+			format: logging.FormatJSON,
+			level:  "verbose",
+			err:    true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := logging.NewLogger(&buf, tc.format, tc.level)
+			if tc.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			logger = slog.New(withoutTime{logger.Handler()})
+			logger.Debug("hidden")
+			logger.Warn("shown")
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, slog.Default(), logging.FromContext(context.Background()))
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	ctx := logging.WithLogger(context.Background(), logger)
+	assert.Equal(t, logger, logging.FromContext(ctx))
+}
+
+// withoutTime drops the time of the records so the output is predictable
+type withoutTime struct {
+	slog.Handler
+}
+
+func (h withoutTime) Handle(ctx context.Context, r slog.Record) error {
+	r.Time = time.Time{}
+	return h.Handler.Handle(ctx, r)
+}
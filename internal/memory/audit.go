@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+type auditRepository struct {
+	store *Store
+}
+
+// NewAuditRepository returns an audit.Repository keeping the entries in the given store
+func NewAuditRepository(store *Store) (audit.Repository, error) {
+	if store == nil {
+		return nil, ErrMissingStore
+	}
+	return &auditRepository{store: store}, nil
+}
+
+// Add stores the entries within the transaction of the state change producing
+// them, unlike the other changes they are hidden until it is committed
+func (ar *auditRepository) Add(_ context.Context, tx transaction.Tx, entries ...audit.Entry) error {
+	mtx, err := ar.store.txFrom(tx)
+	if err != nil {
+		return err
+	}
+	pending := append([]audit.Entry{}, entries...)
+	mtx.onCommit(func() {
+		for _, e := range pending {
+			ar.store.lastAuditSeq++
+			e.Seq = ar.store.lastAuditSeq
+			ar.store.audit = append(ar.store.audit, e)
+		}
+	})
+	return nil
+}
+
+// List returns the entries of an entity in the order they were stored
+func (ar *auditRepository) List(ctx context.Context, entityType string, entityID uuid.UUID) ([]audit.Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ar.store.mu.RLock()
+	defer ar.store.mu.RUnlock()
+
+	result := []audit.Entry{}
+	for _, e := range ar.store.audit {
+		if e.EntityType == entityType && e.EntityID == entityID {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
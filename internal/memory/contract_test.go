@@ -0,0 +1,39 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/contract"
+)
+
+func TestContract_CouponRepository(t *testing.T) {
+	contract.TestCouponRepository(t, newBackend)
+}
+
+func TestContract_ShoppingCartRepository(t *testing.T) {
+	contract.TestShoppingCartRepository(t, newBackend)
+}
+
+func TestContract_OutboxRepository(t *testing.T) {
+	contract.TestOutboxRepository(t, newBackend)
+}
+
+func TestContract_WebhookRepository(t *testing.T) {
+	contract.TestWebhookRepository(t, newBackend)
+}
+
+func TestContract_AuditRepository(t *testing.T) {
+	contract.TestAuditRepository(t, newBackend)
+}
+
+func newBackend(t *testing.T) contract.Backend {
+	ts := buildStore(t)
+	return contract.Backend{
+		CouponRepo:       ts.couponRepo,
+		ShoppingCartRepo: ts.shoppingCartRepo,
+		TxManager:        ts.txManager,
+		OutboxRepo:       ts.outboxRepo,
+		WebhookRepo:      ts.webhookRepo,
+		AuditRepo:        ts.auditRepo,
+	}
+}
@@ -0,0 +1,333 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+type couponRepository struct {
+	store *Store
+}
+
+// NewCouponRepository builds a new in memory repository
+// that satisfies the coupon repository interface
+func NewCouponRepository(store *Store) (coupon.Repository, error) {
+	if store == nil {
+		return nil, ErrMissingStore
+	}
+	return &couponRepository{store: store}, nil
+}
+
+// CreateCoupon creates a coupon within the given transaction
+func (cr *couponRepository) CreateCoupon(ctx context.Context, tx transaction.Tx, c *coupon.Coupon) (*coupon.Coupon, error) {
+	mtx, err := cr.store.txFrom(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := mtx.lock(ctx, couponKey(c.ID)); err != nil {
+		return nil, err
+	}
+
+	cr.store.mu.Lock()
+	defer cr.store.mu.Unlock()
+
+	if err := cr.insert(c); err != nil {
+		return nil, err
+	}
+	mtx.onRollback(func() {
+		delete(cr.store.coupons, c.ID)
+	})
+	return c, nil
+}
+
+// ListCoupons returns a list of coupons
+func (cr *couponRepository) ListCoupons(ctx context.Context) ([]coupon.Coupon, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result := cr.list()
+	if len(result) == 0 {
+		return nil, coupon.ErrCouponNotFound
+	}
+	return result, nil
+}
+
+// GetCouponForUpdate returns an specific  and it will lock the row in order to update it
+func (cr *couponRepository) GetCouponForUpdate(ctx context.Context, tx transaction.Tx, couponID uuid.UUID) (*coupon.Coupon, error) {
+	if couponID == uuid.Nil {
+		return nil, coupon.ErrCouponMissingID
+	}
+	mtx, err := cr.store.txFrom(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := mtx.lock(ctx, couponKey(couponID)); err != nil {
+		return nil, err
+	}
+
+	cr.store.mu.RLock()
+	defer cr.store.mu.RUnlock()
+	c, ok := cr.store.coupons[couponID]
+	if !ok || c.DeletedAt != nil {
+		return nil, coupon.ErrCouponNotFound
+	}
+	return &c, nil
+}
+
+// UpdateCoupon updates coupon entity
+func (cr *couponRepository) UpdateCoupon(ctx context.Context, tx transaction.Tx, c *coupon.Coupon) (*coupon.Coupon, error) {
+	mtx, err := cr.store.txFrom(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := mtx.lock(ctx, couponKey(c.ID)); err != nil {
+		return nil, err
+	}
+
+	cr.store.mu.Lock()
+	defer cr.store.mu.Unlock()
+
+	previous, existed := cr.store.coupons[c.ID]
+	if !existed || previous.Version != c.Version {
+		return nil, coupon.ErrCouponVersionConflict
+	}
+	mtx.onRollback(func() {
+		cr.store.coupons[c.ID] = previous
+	})
+
+	c.Version++
+	c.CreatedAt = previous.CreatedAt
+	c.UpdatedAt = cr.store.now()
+	cr.store.coupons[c.ID] = *c
+	return c, nil
+}
+
+// DeleteCoupon soft deletes a coupon, read within the same transaction
+func (cr *couponRepository) DeleteCoupon(ctx context.Context, tx transaction.Tx, c *coupon.Coupon) error {
+	if c == nil || c.ID == uuid.Nil {
+		return coupon.ErrCouponMissingID
+	}
+	mtx, err := cr.store.txFrom(tx)
+	if err != nil {
+		return err
+	}
+	if err := mtx.lock(ctx, couponKey(c.ID)); err != nil {
+		return err
+	}
+
+	cr.store.mu.Lock()
+	defer cr.store.mu.Unlock()
+
+	previous, existed := cr.store.coupons[c.ID]
+	if !existed || previous.DeletedAt != nil {
+		return coupon.ErrCouponNotFound
+	}
+	if previous.Version != c.Version {
+		return coupon.ErrCouponVersionConflict
+	}
+	mtx.onRollback(func() {
+		cr.store.coupons[c.ID] = previous
+	})
+
+	now := cr.store.now()
+	deleted := previous
+	deleted.DeletedAt = &now
+	deleted.Version++
+	cr.store.coupons[c.ID] = deleted
+	c.DeletedAt = deleted.DeletedAt
+	c.Version = deleted.Version
+	return nil
+}
+
+// CountCouponRedemptions returns how many shopping carts have the coupon applied
+func (cr *couponRepository) CountCouponRedemptions(ctx context.Context, tx transaction.Tx, couponID uuid.UUID) (int64, error) {
+	if _, err := cr.store.txFrom(tx); err != nil {
+		return 0, err
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	cr.store.mu.RLock()
+	defer cr.store.mu.RUnlock()
+	var count int64
+	for _, sc := range cr.store.carts {
+		if sc.CouponID == couponID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetCouponStats aggregates the redemptions of a coupon
+func (cr *couponRepository) GetCouponStats(ctx context.Context, couponID uuid.UUID, req coupon.StatsRequest) (*coupon.Stats, error) {
+	if couponID == uuid.Nil {
+		return nil, coupon.ErrCouponMissingID
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	cr.store.mu.RLock()
+	defer cr.store.mu.RUnlock()
+
+	if c, ok := cr.store.coupons[couponID]; !ok || c.DeletedAt != nil {
+		return nil, coupon.ErrCouponNotFound
+	}
+
+	var summary bucketSum
+	buckets := make(map[time.Time]*bucketSum)
+	for _, sc := range cr.store.carts {
+		if sc.CouponID != couponID {
+			continue
+		}
+		appliedAt := sc.CouponAppliedAt
+		if req.From != nil && (appliedAt == nil || appliedAt.Before(*req.From)) {
+			continue
+		}
+		if req.Until != nil && (appliedAt == nil || !appliedAt.Before(*req.Until)) {
+			continue
+		}
+		summary.add(sc)
+		if appliedAt == nil {
+			continue
+		}
+		start := truncate(*appliedAt, req.Interval)
+		if buckets[start] == nil {
+			buckets[start] = &bucketSum{}
+		}
+		buckets[start].add(sc)
+	}
+
+	stats := &coupon.Stats{
+		CouponID:         couponID,
+		Redemptions:      summary.redemptions,
+		TotalDiscount:    summary.discount,
+		AverageCartValue: summary.average(),
+		Interval:         req.Interval,
+		Buckets:          make([]coupon.StatsBucket, 0, len(buckets)),
+	}
+	for start, b := range buckets {
+		stats.Buckets = append(stats.Buckets, coupon.StatsBucket{
+			Start:            start,
+			Redemptions:      b.redemptions,
+			TotalDiscount:    b.discount,
+			AverageCartValue: b.average(),
+		})
+	}
+	sort.Slice(stats.Buckets, func(i, j int) bool {
+		return stats.Buckets[i].Start.Before(stats.Buckets[j].Start)
+	})
+	return stats, nil
+}
+
+// ImportCoupon creates a coupon within the given transaction, a failure only discards this coupon
+func (cr *couponRepository) ImportCoupon(ctx context.Context, tx transaction.Tx, c *coupon.Coupon) (*coupon.Coupon, error) {
+	// a failed insert leaves the store untouched, so there is nothing to discard
+	return cr.CreateCoupon(ctx, tx, c)
+}
+
+// ExportCoupons calls the given function for every coupon
+func (cr *couponRepository) ExportCoupons(ctx context.Context, fn func(coupon.Coupon) error) error {
+	for _, c := range cr.list() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insert stores a new coupon, the store lock must be held
+func (cr *couponRepository) insert(c *coupon.Coupon) error {
+	if _, ok := cr.store.coupons[c.ID]; ok {
+		return ErrAlreadyExists
+	}
+	if c.Code != "" {
+		for _, other := range cr.store.coupons {
+			if other.Code == c.Code && other.DeletedAt == nil {
+				return coupon.ErrCouponCodeAlreadyExists
+			}
+		}
+	}
+
+	now := cr.store.now()
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = now
+	}
+	if c.UpdatedAt.IsZero() {
+		c.UpdatedAt = now
+	}
+	if c.Version == 0 {
+		c.Version = 1
+	}
+	cr.store.coupons[c.ID] = *c
+	return nil
+}
+
+// list returns the coupons not deleted, oldest first
+func (cr *couponRepository) list() []coupon.Coupon {
+	cr.store.mu.RLock()
+	defer cr.store.mu.RUnlock()
+
+	result := make([]coupon.Coupon, 0, len(cr.store.coupons))
+	for _, c := range cr.store.coupons {
+		if c.DeletedAt == nil {
+			result = append(result, c)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].ID.String() < result[j].ID.String()
+		}
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// bucketSum accumulates the redemptions of a stats bucket
+type bucketSum struct {
+	redemptions int64
+	discount    float64
+	amount      float64
+}
+
+func (b *bucketSum) add(sc shoppingcart.ShoppingCart) {
+	b.redemptions++
+	b.discount += float64(sc.Amount) - float64(sc.Total)
+	b.amount += float64(sc.Amount)
+}
+
+func (b *bucketSum) average() float64 {
+	if b.redemptions == 0 {
+		return 0
+	}
+	return b.amount / float64(b.redemptions)
+}
+
+// truncate returns the start of the interval the given moment
+// belongs to, like postgres date_trunc does in UTC
+func truncate(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	switch interval {
+	case coupon.StatsIntervalHour:
+		return t.Truncate(time.Hour)
+	case coupon.StatsIntervalWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		// weeks start on monday
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case coupon.StatsIntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
@@ -0,0 +1,186 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	"github.com/nachoconques0/schwarz-challenge/internal/memory"
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
+)
+
+type testStore struct {
+	couponRepo       coupon.Repository
+	shoppingCartRepo shoppingcart.Repository
+	txManager        transaction.Manager
+	outboxRepo       outbox.Repository
+	webhookRepo      webhook.Repository
+	auditRepo        audit.Repository
+}
+
+func TestCouponRepository_CreateCoupon(t *testing.T) {
+	ts := buildStore(t)
+	ctx := context.Background()
+
+	_, err := ts.couponRepo.ListCoupons(ctx)
+	assert.Equal(t, coupon.ErrCouponNotFound, err)
+
+	c := coupon.New(coupon.CreateRequest{Name: "FREE30", Code: "FREE30", Amount: 30})
+	res, err := storeCoupon(ts, c)
+	assert.Nil(t, err)
+	assert.False(t, res.CreatedAt.IsZero())
+
+	_, err = storeCoupon(ts, c)
+	assert.Equal(t, memory.ErrAlreadyExists, err)
+
+	_, err = storeCoupon(ts, coupon.New(coupon.CreateRequest{Name: "other", Code: "FREE30", Amount: 10}))
+	assert.Equal(t, coupon.ErrCouponCodeAlreadyExists, err)
+
+	list, err := ts.couponRepo.ListCoupons(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, list, 1)
+}
+
+func TestCouponRepository_DeleteCoupon(t *testing.T) {
+	ts := buildStore(t)
+	ctx := context.Background()
+	c := createCoupon(t, ts)
+
+	err := ts.txManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		assert.Equal(t, coupon.ErrCouponMissingID, ts.couponRepo.DeleteCoupon(ctx, tx, &coupon.Coupon{}))
+		assert.Nil(t, ts.couponRepo.DeleteCoupon(ctx, tx, c))
+		assert.Equal(t, int64(2), c.Version)
+		assert.Equal(t, coupon.ErrCouponNotFound, ts.couponRepo.DeleteCoupon(ctx, tx, c))
+		return nil
+	})
+	assert.Nil(t, err)
+
+	err = ts.txManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		_, err := ts.couponRepo.GetCouponForUpdate(ctx, tx, c.ID)
+		return err
+	})
+	assert.Equal(t, coupon.ErrCouponNotFound, err)
+
+	// the code of a deleted coupon can be used again
+	_, err = storeCoupon(ts, coupon.New(coupon.CreateRequest{Name: "again", Code: c.Code, Amount: 10}))
+	assert.Nil(t, err)
+}
+
+func TestCouponRepository_ImportCoupon(t *testing.T) {
+	ts := buildStore(t)
+	ctx := context.Background()
+
+	err := ts.txManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		_, err := ts.couponRepo.ImportCoupon(ctx, tx, coupon.New(coupon.CreateRequest{Name: "FREE30", Code: "FREE30", Amount: 30}))
+		assert.Nil(t, err)
+		_, err = ts.couponRepo.ImportCoupon(ctx, tx, coupon.New(coupon.CreateRequest{Name: "FREE30", Code: "FREE30", Amount: 30}))
+		assert.Equal(t, coupon.ErrCouponCodeAlreadyExists, err)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	list, err := ts.couponRepo.ListCoupons(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, list, 1)
+}
+
+func TestCouponRepository_GetCouponStats(t *testing.T) {
+	ts := buildStore(t)
+	ctx := context.Background()
+	c := createCoupon(t, ts)
+
+	monday := time.Date(2024, time.June, 10, 10, 30, 0, 0, time.UTC)
+	for _, appliedAt := range []time.Time{monday, monday.Add(time.Hour), monday.AddDate(0, 0, 8)} {
+		appliedAt := appliedAt
+		storeShoppingCart(t, ts, &shoppingcart.ShoppingCart{
+			ID:              uuid.New(),
+			Amount:          100,
+			Total:           70,
+			CouponID:        c.ID,
+			CouponAppliedAt: &appliedAt,
+		})
+	}
+
+	_, err := ts.couponRepo.GetCouponStats(ctx, uuid.New(), coupon.StatsRequest{Interval: coupon.StatsIntervalDay})
+	assert.Equal(t, coupon.ErrCouponNotFound, err)
+
+	stats, err := ts.couponRepo.GetCouponStats(ctx, c.ID, coupon.StatsRequest{Interval: coupon.StatsIntervalWeek})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), stats.Redemptions)
+	assert.Equal(t, float64(90), stats.TotalDiscount)
+	assert.Equal(t, float64(100), stats.AverageCartValue)
+	assert.Len(t, stats.Buckets, 2)
+	assert.Equal(t, time.Date(2024, time.June, 10, 0, 0, 0, 0, time.UTC), stats.Buckets[0].Start)
+	assert.Equal(t, int64(2), stats.Buckets[0].Redemptions)
+	assert.Equal(t, time.Date(2024, time.June, 17, 0, 0, 0, 0, time.UTC), stats.Buckets[1].Start)
+
+	until := monday.AddDate(0, 0, 1)
+	stats, err = ts.couponRepo.GetCouponStats(ctx, c.ID, coupon.StatsRequest{Interval: coupon.StatsIntervalHour, Until: &until})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), stats.Redemptions)
+	assert.Len(t, stats.Buckets, 2)
+}
+
+func TestCouponRepository_ExportCoupons(t *testing.T) {
+	ts := buildStore(t)
+	ctx := context.Background()
+	createCoupon(t, ts)
+
+	var exported []coupon.Coupon
+	err := ts.couponRepo.ExportCoupons(ctx, func(c coupon.Coupon) error {
+		exported = append(exported, c)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, exported, 1)
+}
+
+func buildStore(t *testing.T) testStore {
+	store := memory.NewStore()
+	couponRepo, err := memory.NewCouponRepository(store)
+	assert.Nil(t, err)
+	shoppingCartRepo, err := memory.NewShoppingCartRepository(store)
+	assert.Nil(t, err)
+	txManager, err := memory.NewTransactionManager(store)
+	assert.Nil(t, err)
+	outboxRepo, err := memory.NewOutboxRepository(store)
+	assert.Nil(t, err)
+	webhookRepo, err := memory.NewWebhookRepository(store)
+	assert.Nil(t, err)
+	auditRepo, err := memory.NewAuditRepository(store)
+	assert.Nil(t, err)
+	return testStore{
+		couponRepo:       couponRepo,
+		shoppingCartRepo: shoppingCartRepo,
+		txManager:        txManager,
+		outboxRepo:       outboxRepo,
+		webhookRepo:      webhookRepo,
+		auditRepo:        auditRepo,
+	}
+}
+
+// storeCoupon creates the given coupon within its own transaction
+func storeCoupon(ts testStore, c *coupon.Coupon) (*coupon.Coupon, error) {
+	ctx := context.Background()
+	var res *coupon.Coupon
+	err := ts.txManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		var err error
+		res, err = ts.couponRepo.CreateCoupon(ctx, tx, c)
+		return err
+	})
+	return res, err
+}
+
+func createCoupon(t *testing.T, ts testStore) *coupon.Coupon {
+	c, err := storeCoupon(ts, coupon.New(coupon.CreateRequest{Name: "FREE30", Code: "FREE30", Amount: 30}))
+	assert.Nil(t, err)
+	return c
+}
@@ -0,0 +1,146 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+type outboxRepository struct {
+	store *Store
+}
+
+// NewOutboxRepository returns an outbox.Repository keeping the events in the given store
+func NewOutboxRepository(store *Store) (outbox.Repository, error) {
+	if store == nil {
+		return nil, ErrMissingStore
+	}
+	return &outboxRepository{store: store}, nil
+}
+
+// Add stores the events within the transaction of the state change producing
+// them, unlike the other changes they are hidden until it is committed
+func (or *outboxRepository) Add(_ context.Context, tx transaction.Tx, events ...outbox.Event) error {
+	mtx, err := or.store.txFrom(tx)
+	if err != nil {
+		return err
+	}
+	pending := append([]outbox.Event{}, events...)
+	mtx.onCommit(func() {
+		for _, e := range pending {
+			or.store.lastSeq++
+			e.Seq = or.store.lastSeq
+			or.store.outbox = append(or.store.outbox, e)
+		}
+	})
+	return nil
+}
+
+// Pending returns up to limit events not published yet and due at the given
+// time, in the order they were stored. The events of an aggregate are held
+// back while an earlier event of it is not due
+func (or *outboxRepository) Pending(ctx context.Context, due time.Time, limit int) ([]outbox.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	or.store.mu.RLock()
+	defer or.store.mu.RUnlock()
+
+	var result []outbox.Event
+	for _, i := range or.pending(due, limit) {
+		result = append(result, or.store.outbox[i])
+	}
+	return result, nil
+}
+
+// Claim returns the events Pending would return and keeps them from the
+// other relays until the given time, reading and delaying them at once
+func (or *outboxRepository) Claim(ctx context.Context, due, until time.Time, limit int) ([]outbox.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	or.store.mu.Lock()
+	defer or.store.mu.Unlock()
+
+	var result []outbox.Event
+	for _, i := range or.pending(due, limit) {
+		or.store.outbox[i].NextAttemptAt = until
+		result = append(result, or.store.outbox[i])
+	}
+	return result, nil
+}
+
+// Release makes a claimed event due again at the given time
+func (or *outboxRepository) Release(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return or.update(ctx, id, func(e *outbox.Event) {
+		e.NextAttemptAt = at
+	})
+}
+
+// pending returns the positions of the events Pending returns, the store
+// lock must be held
+func (or *outboxRepository) pending(due time.Time, limit int) []int {
+	var result []int
+	held := map[uuid.UUID]bool{}
+	for i, e := range or.store.outbox {
+		if len(result) == limit {
+			break
+		}
+		if e.PublishedAt != nil || e.DeadAt != nil || held[e.AggregateID] {
+			continue
+		}
+		if e.NextAttemptAt.After(due) {
+			held[e.AggregateID] = true
+			continue
+		}
+		result = append(result, i)
+	}
+	return result
+}
+
+// MarkPublished records the delivery of an event
+func (or *outboxRepository) MarkPublished(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return or.update(ctx, id, func(e *outbox.Event) {
+		e.PublishedAt = &at
+	})
+}
+
+// MarkFailed records a failed delivery of an event, delivered again once next is reached
+func (or *outboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, next time.Time, reason string) error {
+	return or.update(ctx, id, func(e *outbox.Event) {
+		e.Attempts++
+		e.NextAttemptAt = next
+		e.LastError = reason
+	})
+}
+
+// MarkDead records the last failed delivery of an event that ran out of
+// attempts, it is kept for inspection but never delivered again
+func (or *outboxRepository) MarkDead(ctx context.Context, id uuid.UUID, at time.Time, reason string) error {
+	return or.update(ctx, id, func(e *outbox.Event) {
+		e.Attempts++
+		e.DeadAt = &at
+		e.LastError = reason
+	})
+}
+
+// update applies fn to the stored event with the given ID
+func (or *outboxRepository) update(ctx context.Context, id uuid.UUID, fn func(*outbox.Event)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	or.store.mu.Lock()
+	defer or.store.mu.Unlock()
+
+	for i := range or.store.outbox {
+		if or.store.outbox[i].ID == id {
+			fn(&or.store.outbox[i])
+			return nil
+		}
+	}
+	return outbox.ErrEventNotFound
+}
@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+type shoppingCartRepository struct {
+	store *Store
+}
+
+// NewShoppingCartRepository builds a new in memory repository
+// that satisfies the shopping cart repository interface
+func NewShoppingCartRepository(store *Store) (shoppingcart.Repository, error) {
+	if store == nil {
+		return nil, ErrMissingStore
+	}
+	return &shoppingCartRepository{store: store}, nil
+}
+
+// CreateShoppingCart will create a new shopping cart within the given transaction
+func (sr *shoppingCartRepository) CreateShoppingCart(ctx context.Context, tx transaction.Tx, sc *shoppingcart.ShoppingCart) (*shoppingcart.ShoppingCart, error) {
+	mtx, err := sr.store.txFrom(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := mtx.lock(ctx, cartKey(sc.ID)); err != nil {
+		return nil, err
+	}
+
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	if _, ok := sr.store.carts[sc.ID]; ok {
+		return nil, ErrAlreadyExists
+	}
+	now := sr.store.now()
+	if sc.CreatedAt.IsZero() {
+		sc.CreatedAt = now
+	}
+	if sc.UpdatedAt.IsZero() {
+		sc.UpdatedAt = now
+	}
+	if sc.Version == 0 {
+		sc.Version = 1
+	}
+	sr.store.carts[sc.ID] = copyCart(*sc)
+	mtx.onRollback(func() {
+		delete(sr.store.carts, sc.ID)
+	})
+	return sc, nil
+}
+
+// ListShoppingCarts returns a list of shopping carts
+func (sr *shoppingCartRepository) ListShoppingCarts(ctx context.Context) ([]shoppingcart.ShoppingCart, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	if len(sr.store.carts) == 0 {
+		return nil, shoppingcart.ErrShoppingCartsNotFound
+	}
+	result := make([]shoppingcart.ShoppingCart, 0, len(sr.store.carts))
+	for _, sc := range sr.store.carts {
+		result = append(result, copyCart(sc))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].ID.String() < result[j].ID.String()
+		}
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+// GetShoppingCartForUpdate returns a shopping cart and it will lock the row in order to update it
+func (sr *shoppingCartRepository) GetShoppingCartForUpdate(ctx context.Context, tx transaction.Tx, scID uuid.UUID) (*shoppingcart.ShoppingCart, error) {
+	mtx, err := sr.store.txFrom(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := mtx.lock(ctx, cartKey(scID)); err != nil {
+		return nil, err
+	}
+
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+	sc, ok := sr.store.carts[scID]
+	if !ok {
+		return nil, shoppingcart.ErrShoppingCartNotFound
+	}
+	sc = copyCart(sc)
+	return &sc, nil
+}
+
+// UpdateShoppingCart updates shopping cart entity increasing its version, it fails
+// with ErrShoppingCartVersionConflict if the stored version is not the given one
+func (sr *shoppingCartRepository) UpdateShoppingCart(ctx context.Context, tx transaction.Tx, sc *shoppingcart.ShoppingCart) (*shoppingcart.ShoppingCart, error) {
+	mtx, err := sr.store.txFrom(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := mtx.lock(ctx, cartKey(sc.ID)); err != nil {
+		return nil, err
+	}
+
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	previous, existed := sr.store.carts[sc.ID]
+	if !existed || previous.Version != sc.Version {
+		return nil, shoppingcart.ErrShoppingCartVersionConflict
+	}
+	mtx.onRollback(func() {
+		sr.store.carts[sc.ID] = previous
+	})
+
+	sc.Version++
+	sc.CreatedAt = previous.CreatedAt
+	sc.UpdatedAt = sr.store.now()
+	sr.store.carts[sc.ID] = copyCart(*sc)
+	return sc, nil
+}
+
+// CountCustomerCouponUses returns how many shopping carts of a customer have the given coupon applied
+func (sr *shoppingCartRepository) CountCustomerCouponUses(ctx context.Context, tx transaction.Tx, customerID uuid.UUID, couponID uuid.UUID) (int64, error) {
+	if _, err := sr.store.txFrom(tx); err != nil {
+		return 0, err
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+	var count int64
+	for _, sc := range sr.store.carts {
+		if sc.CustomerID == customerID && sc.CouponID == couponID {
+			count++
+		}
+	}
+	return count, nil
+}
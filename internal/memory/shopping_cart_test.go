@@ -0,0 +1,76 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+func TestShoppingCartRepository_CreateShoppingCart(t *testing.T) {
+	ts := buildStore(t)
+	ctx := context.Background()
+
+	_, err := ts.shoppingCartRepo.ListShoppingCarts(ctx)
+	assert.Equal(t, shoppingcart.ErrShoppingCartsNotFound, err)
+
+	sc := createShoppingCart(t, ts)
+	// changes done by the caller do not leak into the store
+	sc.Items[0].Name = "changed"
+
+	list, err := ts.shoppingCartRepo.ListShoppingCarts(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "item", list[0].Items[0].Name)
+}
+
+func TestShoppingCartRepository_UpdateShoppingCart(t *testing.T) {
+	ts := buildStore(t)
+	ctx := context.Background()
+	sc := createShoppingCart(t, ts)
+	customerID := uuid.New()
+	couponID := uuid.New()
+
+	err := ts.txManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		_, err := ts.shoppingCartRepo.GetShoppingCartForUpdate(ctx, tx, uuid.New())
+		assert.Equal(t, shoppingcart.ErrShoppingCartNotFound, err)
+
+		toUpdate, err := ts.shoppingCartRepo.GetShoppingCartForUpdate(ctx, tx, sc.ID)
+		assert.Nil(t, err)
+		toUpdate.CustomerID = customerID
+		assert.Nil(t, toUpdate.ApplyCoupon(couponID, 10))
+		_, err = ts.shoppingCartRepo.UpdateShoppingCart(ctx, tx, toUpdate)
+		assert.Nil(t, err)
+
+		uses, err := ts.shoppingCartRepo.CountCustomerCouponUses(ctx, tx, customerID, couponID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), uses)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	list, err := ts.shoppingCartRepo.ListShoppingCarts(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, couponID, list[0].CouponID)
+	assert.Equal(t, float32(90), list[0].Total)
+}
+
+func createShoppingCart(t *testing.T, ts testStore) *shoppingcart.ShoppingCart {
+	return storeShoppingCart(t, ts, shoppingcart.New(shoppingcart.CreateRequest{
+		Items: shoppingcart.Items{{Name: "item", Description: "description", Price: 100}},
+	}))
+}
+
+func storeShoppingCart(t *testing.T, ts testStore, sc *shoppingcart.ShoppingCart) *shoppingcart.ShoppingCart {
+	ctx := context.Background()
+	err := ts.txManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		_, err := ts.shoppingCartRepo.CreateShoppingCart(ctx, tx, sc)
+		return err
+	})
+	assert.Nil(t, err)
+	return sc
+}
@@ -0,0 +1,111 @@
+// Package memory contains thread safe in memory implementations of the
+// repositories and the transaction manager. They need no database, so
+// they are meant for fast tests and local demos.
+//
+// Rows locked for update stay locked until the transaction finishes, like
+// SELECT ... FOR UPDATE does, and the changes done within a transaction
+// are undone when it is rolled back. Changes not committed yet are
+// visible to readers outside of the transaction.
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
+)
+
+var (
+	// ErrMissingStore used when the store is nil
+	ErrMissingStore = internalErrors.NewInternalError("in memory store is missing")
+	// ErrAlreadyExists used when an entity with the same ID is already stored
+	ErrAlreadyExists = internalErrors.NewConflict("entity already exists")
+)
+
+// Store holds the data shared by the in memory repositories
+type Store struct {
+	mu      sync.RWMutex
+	coupons map[uuid.UUID]coupon.Coupon
+	carts   map[uuid.UUID]shoppingcart.ShoppingCart
+	// subscriptions holds the webhook subscriptions, deliveries
+	// their deliveries in the order they were stored
+	subscriptions map[uuid.UUID]webhook.Subscription
+	deliveries    []webhook.Delivery
+	// outbox holds the committed events in the order they were stored
+	outbox  []outbox.Event
+	lastSeq int64
+	// audit holds the committed audit entries in the order they were stored
+	audit        []audit.Entry
+	lastAuditSeq int64
+	locks        *rowLocks
+	now          func() time.Time
+}
+
+// NewStore returns a new empty Store
+func NewStore() *Store {
+	return &Store{
+		coupons:       make(map[uuid.UUID]coupon.Coupon),
+		carts:         make(map[uuid.UUID]shoppingcart.ShoppingCart),
+		subscriptions: make(map[uuid.UUID]webhook.Subscription),
+		locks:         &rowLocks{locks: make(map[string]chan struct{})},
+		now:           time.Now,
+	}
+}
+
+// rowLocks hands out exclusive locks on rows
+type rowLocks struct {
+	mu    sync.Mutex
+	locks map[string]chan struct{}
+}
+
+// acquire blocks until the row is locked or the context is done
+func (l *rowLocks) acquire(ctx context.Context, key string) error {
+	l.mu.Lock()
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		l.locks[key] = lock
+	}
+	l.mu.Unlock()
+
+	select {
+	case lock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release unlocks a row locked with acquire
+func (l *rowLocks) release(key string) {
+	l.mu.Lock()
+	lock := l.locks[key]
+	l.mu.Unlock()
+	<-lock
+}
+
+// couponKey returns the lock key of a coupon row
+func couponKey(id uuid.UUID) string {
+	return "coupon:" + id.String()
+}
+
+// cartKey returns the lock key of a shopping cart row
+func cartKey(id uuid.UUID) string {
+	return "shopping_cart:" + id.String()
+}
+
+// copyCart returns a copy of the cart not sharing its items
+func copyCart(sc shoppingcart.ShoppingCart) shoppingcart.ShoppingCart {
+	if sc.Items != nil {
+		sc.Items = append(shoppingcart.Items{}, sc.Items...)
+	}
+	return sc
+}
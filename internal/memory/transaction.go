@@ -0,0 +1,138 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+// memoryTx is the transaction.Tx handed to the in memory repositories
+type memoryTx struct {
+	store *Store
+
+	mu   sync.Mutex
+	done bool
+	held map[string]bool
+	// undo holds the functions reverting the changes done so far
+	undo []func()
+	// commit holds the changes only applied once committed
+	commit []func()
+}
+
+// lock locks the given row until the transaction finishes
+func (tx *memoryTx) lock(ctx context.Context, key string) error {
+	tx.mu.Lock()
+	held := tx.held[key]
+	tx.mu.Unlock()
+	if held {
+		return nil
+	}
+
+	if err := tx.store.locks.acquire(ctx, key); err != nil {
+		return err
+	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		tx.store.locks.release(key)
+		return transaction.ErrInvalidTx
+	}
+	tx.held[key] = true
+	return nil
+}
+
+// onRollback registers a function reverting a change, the store
+// lock must be held while calling it
+func (tx *memoryTx) onRollback(fn func()) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.undo = append(tx.undo, fn)
+}
+
+// onCommit registers a change hidden from everyone until the transaction
+// is committed, the store lock is held while running it
+func (tx *memoryTx) onCommit(fn func()) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.commit = append(tx.commit, fn)
+}
+
+// finish releases the locks held by the transaction, undoing its
+// changes first unless it is committed
+func (tx *memoryTx) finish(commit bool) {
+	tx.mu.Lock()
+	undo, onCommit, held := tx.undo, tx.commit, tx.held
+	tx.done, tx.undo, tx.commit, tx.held = true, nil, nil, nil
+	tx.mu.Unlock()
+
+	tx.store.mu.Lock()
+	if commit {
+		for _, fn := range onCommit {
+			fn()
+		}
+	} else {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}
+	tx.store.mu.Unlock()
+	for key := range held {
+		tx.store.locks.release(key)
+	}
+}
+
+type transactionManager struct {
+	store *Store
+}
+
+// NewTransactionManager builds a new transaction
+// manager for the in memory repositories
+func NewTransactionManager(store *Store) (transaction.Manager, error) {
+	if store == nil {
+		return nil, ErrMissingStore
+	}
+	return &transactionManager{store: store}, nil
+}
+
+// WithinTx starts a transaction and runs fn with it. The transaction is
+// committed when fn succeeds and rolled back when it returns an error or
+// panics. Errors returned by fn are given back unchanged
+func (tm *transactionManager) WithinTx(ctx context.Context, fn func(tx transaction.Tx) error) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	tx := &memoryTx{store: tm.store, held: make(map[string]bool)}
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error(fmt.Sprintf("transaction panicked: %v\n%s", r, debug.Stack()))
+			tx.finish(false)
+			err = transaction.ErrTxPanicked
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.finish(false)
+		return err
+	}
+	tx.finish(true)
+	return nil
+}
+
+// txFrom returns the in memory transaction behind the given transaction.Tx
+func (s *Store) txFrom(tx transaction.Tx) (*memoryTx, error) {
+	mtx, ok := tx.(*memoryTx)
+	if !ok || mtx == nil || mtx.store != s {
+		return nil, transaction.ErrInvalidTx
+	}
+	mtx.mu.Lock()
+	defer mtx.mu.Unlock()
+	if mtx.done {
+		return nil, transaction.ErrInvalidTx
+	}
+	return mtx, nil
+}
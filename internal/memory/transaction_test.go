@@ -0,0 +1,251 @@
+package memory_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	"github.com/nachoconques0/schwarz-challenge/internal/memory"
+	"github.com/nachoconques0/schwarz-challenge/internal/service"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+func TestTransactionManager_WithinTx(t *testing.T) {
+	errUnitOfWork := errors.New("unit of work failed")
+
+	testCases := map[string]struct {
+		fn            func(ts testStore, c *coupon.Coupon) func(transaction.Tx) error
+		expectedError error
+		expectedName  string
+	}{
+		"commits when the unit of work succeeds": {
+			fn: func(ts testStore, c *coupon.Coupon) func(transaction.Tx) error {
+				return func(tx transaction.Tx) error {
+					c.Name = "committed"
+					_, err := ts.couponRepo.UpdateCoupon(context.Background(), tx, c)
+					return err
+				}
+			},
+			expectedName: "committed",
+		},
+		"rolls back when the unit of work fails": {
+			fn: func(ts testStore, c *coupon.Coupon) func(transaction.Tx) error {
+				return func(tx transaction.Tx) error {
+					c.Name = "rolled back"
+					if _, err := ts.couponRepo.UpdateCoupon(context.Background(), tx, c); err != nil {
+						return err
+					}
+					return errUnitOfWork
+				}
+			},
+			expectedError: errUnitOfWork,
+			expectedName:  "FREE30",
+		},
+		"rolls back when the unit of work panics": {
+			fn: func(ts testStore, c *coupon.Coupon) func(transaction.Tx) error {
+				return func(tx transaction.Tx) error {
+					c.Name = "rolled back"
+					if _, err := ts.couponRepo.UpdateCoupon(context.Background(), tx, c); err != nil {
+						return err
+					}
+					panic("unexpected")
+				}
+			},
+			expectedError: transaction.ErrTxPanicked,
+			expectedName:  "FREE30",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ts := buildStore(t)
+			c := createCoupon(t, ts)
+
+			toUpdate := *c
+			err := ts.txManager.WithinTx(context.Background(), tc.fn(ts, &toUpdate))
+			assert.Equal(t, tc.expectedError, err)
+
+			list, err := ts.couponRepo.ListCoupons(context.Background())
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedName, list[0].Name)
+		})
+	}
+}
+
+func TestTransactionManager_InvalidTx(t *testing.T) {
+	ts := buildStore(t)
+	ctx := context.Background()
+	c := createCoupon(t, ts)
+
+	_, err := ts.couponRepo.GetCouponForUpdate(ctx, nil, c.ID)
+	assert.Equal(t, transaction.ErrInvalidTx, err)
+
+	var finished transaction.Tx
+	err = ts.txManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		finished = tx
+		return nil
+	})
+	assert.Nil(t, err)
+	_, err = ts.couponRepo.GetCouponForUpdate(ctx, finished, c.ID)
+	assert.Equal(t, transaction.ErrInvalidTx, err)
+
+	other := buildStore(t)
+	err = other.txManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		_, err := ts.couponRepo.GetCouponForUpdate(ctx, tx, c.ID)
+		return err
+	})
+	assert.Equal(t, transaction.ErrInvalidTx, err)
+}
+
+func TestTransactionManager_RowLocks(t *testing.T) {
+	t.Run("waits for the transaction holding the row", func(t *testing.T) {
+		ts := buildStore(t)
+		ctx := context.Background()
+		c := createCoupon(t, ts)
+
+		locked := make(chan struct{})
+		release := make(chan struct{})
+		var events []string
+		var mu sync.Mutex
+		record := func(e string) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, e)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = ts.txManager.WithinTx(ctx, func(tx transaction.Tx) error {
+				_, err := ts.couponRepo.GetCouponForUpdate(ctx, tx, c.ID)
+				close(locked)
+				<-release
+				record("first")
+				return err
+			})
+		}()
+
+		<-locked
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = ts.txManager.WithinTx(ctx, func(tx transaction.Tx) error {
+				_, err := ts.couponRepo.GetCouponForUpdate(ctx, tx, c.ID)
+				record("second")
+				return err
+			})
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		assert.Equal(t, []string{"first", "second"}, events)
+	})
+
+	t.Run("stops waiting once the context is done", func(t *testing.T) {
+		ts := buildStore(t)
+		c := createCoupon(t, ts)
+
+		err := ts.txManager.WithinTx(context.Background(), func(tx transaction.Tx) error {
+			_, err := ts.couponRepo.GetCouponForUpdate(context.Background(), tx, c.ID)
+			assert.Nil(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			return ts.txManager.WithinTx(ctx, func(other transaction.Tx) error {
+				_, err := ts.couponRepo.GetCouponForUpdate(ctx, other, c.ID)
+				return err
+			})
+		})
+		assert.Equal(t, context.DeadlineExceeded, err)
+	})
+}
+
+func TestShoppingCartService_ApplyCouponConcurrently(t *testing.T) {
+	ts := buildStore(t)
+	ctx := context.Background()
+	c := createCoupon(t, ts)
+
+	svc, err := service.NewShoppingCartService(ts.shoppingCartRepo, ts.couponRepo, ts.txManager, ts.outboxRepo, ts.auditRepo)
+	assert.Nil(t, err)
+
+	const carts = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, carts)
+	for i := 0; i < carts; i++ {
+		sc, err := svc.CreateShoppingCart(ctx, shoppingcart.CreateRequest{
+			Items: shoppingcart.Items{{Name: "item", Description: "description", Price: 100}},
+		})
+		assert.Nil(t, err)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.ApplyCoupon(ctx, sc.ID, c.ID)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var applied int
+	for err := range errs {
+		if err == nil {
+			applied++
+			continue
+		}
+		assert.Equal(t, coupon.ErrCouponAlreadyUsed, err)
+	}
+	assert.Equal(t, 1, applied, "a single use coupon is only applied once")
+
+	list, err := svc.ListShoppingCarts(ctx)
+	assert.Nil(t, err)
+	var withCoupon int
+	for _, sc := range list {
+		if sc.CouponID == c.ID {
+			withCoupon++
+		}
+	}
+	assert.Equal(t, 1, withCoupon)
+
+	events, err := ts.outboxRepo.Pending(ctx, time.Now(), 100)
+	assert.Nil(t, err)
+	count := map[string]int{}
+	for _, e := range events {
+		count[e.Type]++
+	}
+	assert.Equal(t, map[string]int{
+		shoppingcart.EventCreated:       carts,
+		shoppingcart.EventCouponApplied: 1,
+		coupon.EventExhausted:           1,
+	}, count, "the failed applies store no events")
+
+	entries, err := ts.auditRepo.List(ctx, audit.EntityCoupon, c.ID)
+	assert.Nil(t, err)
+	if assert.Len(t, entries, 1, "the failed applies are not audited") {
+		assert.Equal(t, audit.ActionRedeem, entries[0].Action)
+	}
+}
+
+func TestNewRepositories_MissingStore(t *testing.T) {
+	_, err := memory.NewCouponRepository(nil)
+	assert.Equal(t, memory.ErrMissingStore, err)
+	_, err = memory.NewShoppingCartRepository(nil)
+	assert.Equal(t, memory.ErrMissingStore, err)
+	_, err = memory.NewTransactionManager(nil)
+	assert.Equal(t, memory.ErrMissingStore, err)
+	_, err = memory.NewOutboxRepository(nil)
+	assert.Equal(t, memory.ErrMissingStore, err)
+	_, err = memory.NewWebhookRepository(nil)
+	assert.Equal(t, memory.ErrMissingStore, err)
+	_, err = memory.NewAuditRepository(nil)
+	assert.Equal(t, memory.ErrMissingStore, err)
+}
@@ -0,0 +1,306 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
+)
+
+type webhookRepository struct {
+	store *Store
+}
+
+// NewWebhookRepository returns a webhook.Repository keeping the subscriptions and deliveries in the given store
+func NewWebhookRepository(store *Store) (webhook.Repository, error) {
+	if store == nil {
+		return nil, ErrMissingStore
+	}
+	return &webhookRepository{store: store}, nil
+}
+
+// CreateSubscription stores a new subscription
+func (wr *webhookRepository) CreateSubscription(ctx context.Context, s *webhook.Subscription) (*webhook.Subscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	wr.store.mu.Lock()
+	defer wr.store.mu.Unlock()
+
+	if _, ok := wr.store.subscriptions[s.ID]; ok {
+		return nil, ErrAlreadyExists
+	}
+	now := wr.store.now()
+	s.CreatedAt = now
+	s.UpdatedAt = now
+	wr.store.subscriptions[s.ID] = copySubscription(*s)
+	return s, nil
+}
+
+// GetSubscription returns a subscription
+func (wr *webhookRepository) GetSubscription(ctx context.Context, id uuid.UUID) (*webhook.Subscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	wr.store.mu.RLock()
+	defer wr.store.mu.RUnlock()
+
+	s, ok := wr.store.subscriptions[id]
+	if !ok {
+		return nil, webhook.ErrSubscriptionNotFound
+	}
+	s = copySubscription(s)
+	return &s, nil
+}
+
+// ListSubscriptions returns every subscription
+func (wr *webhookRepository) ListSubscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	return wr.list(ctx, func(webhook.Subscription) bool { return true })
+}
+
+// ListSubscriptionsForEvent returns the subscriptions receiving the given event type
+func (wr *webhookRepository) ListSubscriptionsForEvent(ctx context.Context, eventType string) ([]webhook.Subscription, error) {
+	return wr.list(ctx, func(s webhook.Subscription) bool { return s.Subscribed(eventType) })
+}
+
+// UpdateSubscription updates a subscription
+func (wr *webhookRepository) UpdateSubscription(ctx context.Context, s *webhook.Subscription) (*webhook.Subscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	wr.store.mu.Lock()
+	defer wr.store.mu.Unlock()
+
+	previous, ok := wr.store.subscriptions[s.ID]
+	if !ok {
+		return nil, webhook.ErrSubscriptionNotFound
+	}
+	s.CreatedAt = previous.CreatedAt
+	s.UpdatedAt = wr.store.now()
+	wr.store.subscriptions[s.ID] = copySubscription(*s)
+	return s, nil
+}
+
+// DeleteSubscription deletes a subscription and its deliveries
+func (wr *webhookRepository) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	wr.store.mu.Lock()
+	defer wr.store.mu.Unlock()
+
+	if _, ok := wr.store.subscriptions[id]; !ok {
+		return webhook.ErrSubscriptionNotFound
+	}
+	delete(wr.store.subscriptions, id)
+	kept := wr.store.deliveries[:0]
+	for _, d := range wr.store.deliveries {
+		if d.SubscriptionID != id {
+			kept = append(kept, d)
+		}
+	}
+	wr.store.deliveries = kept
+	return nil
+}
+
+// AddDeliveries stores the given deliveries, skipping the events
+// already delivered to the same subscription
+func (wr *webhookRepository) AddDeliveries(ctx context.Context, deliveries ...webhook.Delivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	wr.store.mu.Lock()
+	defer wr.store.mu.Unlock()
+
+	for _, d := range deliveries {
+		if wr.hasDelivery(d.SubscriptionID, d.EventID) {
+			continue
+		}
+		wr.store.deliveries = append(wr.store.deliveries, d)
+	}
+	return nil
+}
+
+// PendingDeliveries returns up to limit pending deliveries due at the given moment
+func (wr *webhookRepository) PendingDeliveries(ctx context.Context, now time.Time, limit int) ([]webhook.Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	wr.store.mu.RLock()
+	defer wr.store.mu.RUnlock()
+
+	result := []webhook.Delivery{}
+	for _, i := range wr.pendingDeliveries(now, limit) {
+		result = append(result, wr.store.deliveries[i])
+	}
+	return result, nil
+}
+
+// ClaimDeliveries returns up to limit pending deliveries due at the given
+// moment, due again at until unless marked before
+func (wr *webhookRepository) ClaimDeliveries(ctx context.Context, now, until time.Time, limit int) ([]webhook.Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	wr.store.mu.Lock()
+	defer wr.store.mu.Unlock()
+
+	result := []webhook.Delivery{}
+	for _, i := range wr.pendingDeliveries(now, limit) {
+		wr.store.deliveries[i].NextAttemptAt = until
+		result = append(result, wr.store.deliveries[i])
+	}
+	return result, nil
+}
+
+// MarkDelivered records the delivery was accepted
+func (wr *webhookRepository) MarkDelivered(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return wr.updateDelivery(ctx, id, func(d *webhook.Delivery) bool {
+		d.Status = webhook.StatusDelivered
+		d.DeliveredAt = &at
+		return true
+	})
+}
+
+// MarkFailed records a failed delivery, sent again once next is reached
+func (wr *webhookRepository) MarkFailed(ctx context.Context, id uuid.UUID, next time.Time, reason string) error {
+	return wr.updateDelivery(ctx, id, func(d *webhook.Delivery) bool {
+		d.Attempts++
+		d.NextAttemptAt = next
+		d.LastError = reason
+		return true
+	})
+}
+
+// MarkDead records a failed delivery that is not sent anymore
+func (wr *webhookRepository) MarkDead(ctx context.Context, id uuid.UUID, reason string) error {
+	return wr.updateDelivery(ctx, id, func(d *webhook.Delivery) bool {
+		d.Status = webhook.StatusDead
+		d.Attempts++
+		d.LastError = reason
+		return true
+	})
+}
+
+// ListDeadLetters returns the dead deliveries
+func (wr *webhookRepository) ListDeadLetters(ctx context.Context) ([]webhook.Delivery, error) {
+	return wr.listDeliveries(ctx, func(d webhook.Delivery) bool {
+		return d.Status == webhook.StatusDead
+	})
+}
+
+// RetryDeadLetter makes a dead delivery pending again, due at the given moment
+func (wr *webhookRepository) RetryDeadLetter(ctx context.Context, id uuid.UUID, at time.Time) (*webhook.Delivery, error) {
+	var result webhook.Delivery
+	err := wr.updateDelivery(ctx, id, func(d *webhook.Delivery) bool {
+		if d.Status != webhook.StatusDead {
+			return false
+		}
+		d.Status = webhook.StatusPending
+		d.Attempts = 0
+		d.NextAttemptAt = at
+		result = *d
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// list returns the subscriptions matching the given filter, oldest first
+func (wr *webhookRepository) list(ctx context.Context, match func(webhook.Subscription) bool) ([]webhook.Subscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	wr.store.mu.RLock()
+	defer wr.store.mu.RUnlock()
+
+	result := []webhook.Subscription{}
+	for _, s := range wr.store.subscriptions {
+		if match(s) {
+			result = append(result, copySubscription(s))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+// listDeliveries returns the deliveries matching the given filter in the order they were stored
+func (wr *webhookRepository) listDeliveries(ctx context.Context, match func(webhook.Delivery) bool) ([]webhook.Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	wr.store.mu.RLock()
+	defer wr.store.mu.RUnlock()
+
+	result := []webhook.Delivery{}
+	for _, d := range wr.store.deliveries {
+		if match(d) {
+			result = append(result, d)
+		}
+	}
+	return result, nil
+}
+
+// updateDelivery applies fn to the stored delivery with the given ID,
+// the delivery is not found when fn returns false
+func (wr *webhookRepository) updateDelivery(ctx context.Context, id uuid.UUID, fn func(*webhook.Delivery) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	wr.store.mu.Lock()
+	defer wr.store.mu.Unlock()
+
+	for i := range wr.store.deliveries {
+		if wr.store.deliveries[i].ID == id {
+			if !fn(&wr.store.deliveries[i]) {
+				break
+			}
+			return nil
+		}
+	}
+	return webhook.ErrDeliveryNotFound
+}
+
+// pendingDeliveries returns the indexes of up to limit pending deliveries due
+// at the given moment, the store lock must be held while calling it
+func (wr *webhookRepository) pendingDeliveries(now time.Time, limit int) []int {
+	result := []int{}
+	for i, d := range wr.store.deliveries {
+		if d.Status == webhook.StatusPending && !d.NextAttemptAt.After(now) {
+			result = append(result, i)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return wr.store.deliveries[result[i]].NextAttemptAt.Before(wr.store.deliveries[result[j]].NextAttemptAt)
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// hasDelivery checks if the event is already delivered to the subscription,
+// the store lock must be held while calling it
+func (wr *webhookRepository) hasDelivery(subscriptionID, eventID uuid.UUID) bool {
+	for _, d := range wr.store.deliveries {
+		if d.SubscriptionID == subscriptionID && d.EventID == eventID {
+			return true
+		}
+	}
+	return false
+}
+
+// copySubscription returns a copy of the subscription not sharing its event types
+func copySubscription(s webhook.Subscription) webhook.Subscription {
+	if s.EventTypes != nil {
+		s.EventTypes = append(webhook.EventTypeList{}, s.EventTypes...)
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+)
+
+// startedAtKey stores the start of a query in the gorm statement
+const startedAtKey = "metrics:started_at"
+
+// callbackName names the gorm callbacks timing the queries
+const callbackName = "metrics"
+
+// InstrumentDB times every query made through db and exposes the stats
+// of its connection pool
+func (m *Metrics) InstrumentDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err := m.registry.Register(collectors.NewDBStatsCollector(sqlDB, "postgres")); err != nil {
+		return err
+	}
+
+	cb := db.Callback()
+	registrations := []struct {
+		operation string
+		before    func(string, func(*gorm.DB)) error
+		after     func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("*").Register, cb.Create().After("*").Register},
+		{"query", cb.Query().Before("*").Register, cb.Query().After("*").Register},
+		{"update", cb.Update().Before("*").Register, cb.Update().After("*").Register},
+		{"delete", cb.Delete().Before("*").Register, cb.Delete().After("*").Register},
+		{"row", cb.Row().Before("*").Register, cb.Row().After("*").Register},
+		{"raw", cb.Raw().Before("*").Register, cb.Raw().After("*").Register},
+	}
+	for _, r := range registrations {
+		if err := r.before(fmt.Sprintf("%s:before_%s", callbackName, r.operation), startQuery); err != nil {
+			return err
+		}
+		if err := r.after(fmt.Sprintf("%s:after_%s", callbackName, r.operation), m.endQuery(r.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startQuery stores the start of the query in its statement
+func startQuery(db *gorm.DB) {
+	db.InstanceSet(startedAtKey, time.Now())
+}
+
+// endQuery records the query of the given operation once done
+func (m *Metrics) endQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(startedAtKey)
+		if !ok {
+			return
+		}
+		startedAt, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		err := db.Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+		m.ObserveQuery(operation, table, time.Since(startedAt), err)
+	}
+}
@@ -0,0 +1,177 @@
+// Package metrics exposes the Prometheus metrics of the service: the http
+// requests served, the DB queries and connection pool, and the business
+// KPIs of the shopping carts and coupons. Every metric is registered in
+// the registry of its Metrics, so tests and several servers never share
+// the global one.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+)
+
+// namespace prefixes every metric of the service
+const namespace = "schwarz"
+
+// failureReasons names the known reasons a coupon can not be applied,
+// the other errors are named by their kind
+var failureReasons = []struct {
+	err    error
+	reason string
+}{
+	{coupon.ErrCouponExpired, "expired"},
+	{coupon.ErrCouponNotYetValid, "not_yet_valid"},
+	{coupon.ErrCouponInactive, "inactive"},
+	{coupon.ErrCouponAlreadyUsed, "already_used"},
+	{coupon.ErrCouponCustomerLimitReached, "customer_limit_reached"},
+	{coupon.ErrCouponNotAssignedToCustomer, "not_assigned_to_customer"},
+	{coupon.ErrCouponRequiresCustomer, "requires_customer"},
+	{coupon.ErrCouponVersionConflict, "version_conflict"},
+	{shoppingcart.ErrShoppinCartCouponAlreadyApplied, "cart_already_has_coupon"},
+	{shoppingcart.ErrShoppingCartWithCouponAlreadyApplied, "cart_already_has_coupon"},
+	{shoppingcart.ErrShoppointCartCouponAmountExceeded, "amount_exceeds_total"},
+	{shoppingcart.ErrShoppingCartVersionConflict, "version_conflict"},
+}
+
+// Metrics holds the collectors of the service and the registry serving them
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	dbDuration   *prometheus.HistogramVec
+	dbErrors     *prometheus.CounterVec
+
+	cartsCreated        prometheus.Counter
+	couponsApplied      prometheus.Counter
+	couponApplyFailures *prometheus.CounterVec
+}
+
+// New returns the Metrics of the service, along with the go runtime
+// and process metrics
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests served by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Time taken to serve the HTTP requests by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      "query_duration_seconds",
+			Help:      "Time taken by the DB queries by operation and table.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "table"}),
+		dbErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      "query_errors_total",
+			Help:      "DB queries failed by operation and table, not found records excluded.",
+		}, []string{"operation", "table"}),
+		cartsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "shopping_carts_created_total",
+			Help:      "Shopping carts created.",
+		}),
+		couponsApplied: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "coupons_applied_total",
+			Help:      "Coupons applied to a shopping cart.",
+		}),
+		couponApplyFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "coupon_apply_failures_total",
+			Help:      "Coupons failed to be applied to a shopping cart by reason.",
+		}, []string{"reason"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.dbDuration,
+		m.dbErrors,
+		m.cartsCreated,
+		m.couponsApplied,
+		m.couponApplyFailures,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Registry returns the registry holding the metrics
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// ObserveRequest records a served http request, route being the template
+// of the matched route so the ids never end up in the labels
+func (m *Metrics) ObserveRequest(route, method string, status int, duration time.Duration) {
+	m.httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+// ObserveQuery records a DB query, failed when err is not nil
+func (m *Metrics) ObserveQuery(operation, table string, duration time.Duration, err error) {
+	m.dbDuration.WithLabelValues(operation, table).Observe(duration.Seconds())
+	if err != nil {
+		m.dbErrors.WithLabelValues(operation, table).Inc()
+	}
+}
+
+// CartCreated records a created shopping cart
+func (m *Metrics) CartCreated() {
+	m.cartsCreated.Inc()
+}
+
+// CouponApplied records a coupon applied to a shopping cart
+func (m *Metrics) CouponApplied() {
+	m.couponsApplied.Inc()
+}
+
+// CouponApplyFailed records a coupon failed to be applied with the given error
+func (m *Metrics) CouponApplyFailed(err error) {
+	m.couponApplyFailures.WithLabelValues(FailureReason(err)).Inc()
+}
+
+// FailureReason names the reason of the given apply coupon error
+func FailureReason(err error) string {
+	for _, known := range failureReasons {
+		if errors.Is(err, known.err) {
+			return known.reason
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+	var internalErr *internalErrors.Error
+	if !errors.As(err, &internalErr) {
+		return "internal"
+	}
+	return internalErr.Kind()
+}
@@ -0,0 +1,91 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/metrics"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+)
+
+func TestFailureReason(t *testing.T) {
+	testCases := map[string]struct {
+		err      error
+		expected string
+	}{
+		"expired":           {err: coupon.ErrCouponExpired, expected: "expired"},
+		"wrapped":           {err: fmt.Errorf("applying: %w", coupon.ErrCouponInactive), expected: "inactive"},
+		"amount exceeded":   {err: shoppingcart.ErrShoppointCartCouponAmountExceeded, expected: "amount_exceeds_total"},
+		"not found":         {err: internalErrors.NewNotFound("coupon not found"), expected: "not_found"},
+		"other wrong input": {err: internalErrors.NewWrongInput("bad"), expected: "wrong_input"},
+		"timeout":           {err: context.DeadlineExceeded, expected: "canceled"},
+		"unknown":           {err: errors.New("boom"), expected: "internal"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, metrics.FailureReason(tc.err))
+		})
+	}
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	m := metrics.New()
+	m.ObserveRequest("/coupon/{id}", http.MethodPatch, http.StatusOK, 20*time.Millisecond)
+	m.CartCreated()
+	m.CouponApplied()
+	m.CouponApplyFailed(coupon.ErrCouponExpired)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, body, `schwarz_http_requests_total{method="PATCH",route="/coupon/{id}",status="200"} 1`)
+	assert.Contains(t, body, `schwarz_http_request_duration_seconds_count{method="PATCH",route="/coupon/{id}"} 1`)
+	assert.Contains(t, body, "schwarz_shopping_carts_created_total 1")
+	assert.Contains(t, body, "schwarz_coupons_applied_total 1")
+	assert.Contains(t, body, `schwarz_coupon_apply_failures_total{reason="expired"} 1`)
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestMetrics_InstrumentDB(t *testing.T) {
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=u password=p dbname=d"), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+
+	m := metrics.New()
+	require.NoError(t, m.InstrumentDB(db))
+
+	var rows []map[string]interface{}
+	db.Table("coupons").Find(&rows)
+	db.Table("coupons").Where("id = ?", 1).Delete(&struct{}{})
+
+	count, err := testutil.GatherAndCount(m.Registry(), "schwarz_db_query_duration_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	expected := `
+# HELP go_sql_max_open_connections Maximum number of open connections to the database.
+# TYPE go_sql_max_open_connections gauge
+go_sql_max_open_connections{db_name="postgres"} 0
+`
+	assert.NoError(t, testutil.GatherAndCompare(m.Registry(), strings.NewReader(expected), "go_sql_max_open_connections"))
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+)
+
+// shoppingCartService records the business KPIs of the shopping carts
+type shoppingCartService struct {
+	shoppingcart.Service
+	metrics *Metrics
+}
+
+// NewShoppingCartService returns the given service recording the created
+// shopping carts and the coupons applied or failed to be applied
+func NewShoppingCartService(next shoppingcart.Service, m *Metrics) shoppingcart.Service {
+	return &shoppingCartService{
+		Service: next,
+		metrics: m,
+	}
+}
+
+// CreateShoppingCart creates a shopping cart and records it once created
+func (s *shoppingCartService) CreateShoppingCart(ctx context.Context, req shoppingcart.CreateRequest) (*shoppingcart.ShoppingCart, error) {
+	sc, err := s.Service.CreateShoppingCart(ctx, req)
+	if err == nil {
+		s.metrics.CartCreated()
+	}
+	return sc, err
+}
+
+// ApplyCoupon applies a coupon and records whether it succeeded, with the
+// reason when it failed
+func (s *shoppingCartService) ApplyCoupon(ctx context.Context, scID, couponID uuid.UUID) (*shoppingcart.ShoppingCart, error) {
+	sc, err := s.Service.ApplyCoupon(ctx, scID, couponID)
+	if err != nil {
+		s.metrics.CouponApplyFailed(err)
+		return sc, err
+	}
+	s.metrics.CouponApplied()
+	return sc, nil
+}
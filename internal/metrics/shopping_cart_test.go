@@ -0,0 +1,59 @@
+package metrics_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	"github.com/nachoconques0/schwarz-challenge/internal/metrics"
+	"github.com/nachoconques0/schwarz-challenge/internal/mocks"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+)
+
+func TestShoppingCartService(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	next := mocks.NewMockShoppingCartService(ctrl)
+	m := metrics.New()
+	svc := metrics.NewShoppingCartService(next, m)
+	ctx := context.Background()
+
+	next.EXPECT().CreateShoppingCart(ctx, gomock.Any()).Return(&shoppingcart.ShoppingCart{}, nil)
+	_, err := svc.CreateShoppingCart(ctx, shoppingcart.CreateRequest{})
+	require.NoError(t, err)
+
+	scID, couponID := uuid.New(), uuid.New()
+	next.EXPECT().ApplyCoupon(ctx, scID, couponID).Return(&shoppingcart.ShoppingCart{}, nil)
+	_, err = svc.ApplyCoupon(ctx, scID, couponID)
+	require.NoError(t, err)
+
+	next.EXPECT().ApplyCoupon(ctx, scID, couponID).Return(nil, coupon.ErrCouponExpired).Times(2)
+	for i := 0; i < 2; i++ {
+		_, err = svc.ApplyCoupon(ctx, scID, couponID)
+		assert.Equal(t, coupon.ErrCouponExpired, err)
+	}
+
+	next.EXPECT().ListShoppingCarts(ctx).Return([]shoppingcart.ShoppingCart{}, nil)
+	_, err = svc.ListShoppingCarts(ctx)
+	require.NoError(t, err)
+
+	expected := `
+# HELP schwarz_coupon_apply_failures_total Coupons failed to be applied to a shopping cart by reason.
+# TYPE schwarz_coupon_apply_failures_total counter
+schwarz_coupon_apply_failures_total{reason="expired"} 2
+# HELP schwarz_coupons_applied_total Coupons applied to a shopping cart.
+# TYPE schwarz_coupons_applied_total counter
+schwarz_coupons_applied_total 1
+# HELP schwarz_shopping_carts_created_total Shopping carts created.
+# TYPE schwarz_shopping_carts_created_total counter
+schwarz_shopping_carts_created_total 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(m.Registry(), strings.NewReader(expected),
+		"schwarz_coupon_apply_failures_total", "schwarz_coupons_applied_total", "schwarz_shopping_carts_created_total"))
+}
@@ -0,0 +1,321 @@
+// Package migrate applies and reverts the SQL migrations of the service
+// schema. The applied version is kept in the schema_migrations table, the
+// same way the migrate CLI does, so databases migrated with it are
+// recognized. A migration failing half way leaves the schema dirty, and it
+// has to be fixed by hand before running any other migration.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"gorm.io/gorm"
+
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+)
+
+var (
+	// ErrMissingDB used when the DB is nil
+	ErrMissingDB = internalErrors.NewInternalError("migration DB is missing")
+	// ErrNoMigrations used when there is no migration to run
+	ErrNoMigrations = internalErrors.NewInternalError("there are no migrations")
+	// ErrInvalidSteps used when the number of migrations to revert is not positive
+	ErrInvalidSteps = internalErrors.NewWrongInput("number of migrations to revert must be positive")
+	// ErrDirtySchema used when a previous migration failed half way
+	ErrDirtySchema = internalErrors.NewInternalError("database schema is dirty, a migration failed half way and has to be fixed by hand")
+	// ErrOutdatedSchema used when the database misses some migrations
+	ErrOutdatedSchema = internalErrors.NewInternalError("database schema is outdated, run the pending migrations")
+	// ErrUnknownSchemaVersion used when the database version is not one of the known migrations
+	ErrUnknownSchemaVersion = internalErrors.NewInternalError("database schema version is unknown, it is newer than this binary")
+)
+
+// versionTable keeps the applied version, with the columns used by the migrate CLI
+const versionTable = "schema_migrations"
+
+// lockID identifies the advisory lock taken while migrating, so two runners never migrate at once
+const lockID = 7245301923
+
+// fileName matches the <version>_<name>.<up|down>.sql migration files
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a versioned change of the schema
+type Migration struct {
+	// Version orders the migrations, the newest one has the highest version
+	Version int64 `json:"version"`
+	// Name describes the migration
+	Name string `json:"name"`
+	// Up applies the migration
+	Up string `json:"-"`
+	// Down reverts the migration
+	Down string `json:"-"`
+}
+
+// Load returns the migrations of the given directory ordered by version,
+// every migration must have both its up and down files
+func Load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, f := range files {
+		if f.IsDir() || path.Ext(f.Name()) != ".sql" {
+			continue
+		}
+		match := fileName.FindStringSubmatch(f.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s must be named <version>_<name>.<up|down>.sql", f.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration file %s has an invalid version: %w", f.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, f.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	result := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both its up and down files", m.Version, m.Name)
+		}
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+	return result, nil
+}
+
+// Status describes the schema of a database against the known migrations
+type Status struct {
+	// Version is the applied version, zero when nothing is applied
+	Version int64 `json:"version"`
+	// Dirty is set when the last migration failed half way
+	Dirty bool `json:"dirty"`
+	// Latest is the version of the newest known migration
+	Latest int64 `json:"latest"`
+	// Pending lists the migrations not applied yet
+	Pending []Migration `json:"pending"`
+}
+
+// NewStatus returns the status of a database at the given version
+func NewStatus(migrations []Migration, version int64, dirty bool) (*Status, error) {
+	if len(migrations) == 0 {
+		return nil, ErrNoMigrations
+	}
+	known := version == 0
+	pending := []Migration{}
+	for _, m := range migrations {
+		if m.Version == version {
+			known = true
+		}
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	if !known {
+		return nil, ErrUnknownSchemaVersion
+	}
+	return &Status{
+		Version: version,
+		Dirty:   dirty,
+		Latest:  migrations[len(migrations)-1].Version,
+		Pending: pending,
+	}, nil
+}
+
+// Check returns an error unless every known migration is applied cleanly
+func (s *Status) Check() error {
+	if s.Dirty {
+		return ErrDirtySchema
+	}
+	if len(s.Pending) > 0 {
+		return ErrOutdatedSchema
+	}
+	return nil
+}
+
+// Runner applies and reverts the migrations of a database
+type Runner struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewRunner returns a Runner migrating the given DB with the given migrations
+func NewRunner(db *gorm.DB, migrations []Migration) (*Runner, error) {
+	if db == nil {
+		return nil, ErrMissingDB
+	}
+	if len(migrations) == 0 {
+		return nil, ErrNoMigrations
+	}
+	return &Runner{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// Status returns the status of the database, it only reads it so it is
+// safe to call from the readiness checks
+func (r *Runner) Status(ctx context.Context) (*Status, error) {
+	var status *Status
+	err := r.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		var err error
+		status, err = r.status(conn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Check returns an error unless every known migration is applied cleanly
+func (r *Runner) Check(ctx context.Context) error {
+	status, err := r.Status(ctx)
+	if err != nil {
+		return err
+	}
+	return status.Check()
+}
+
+// Up applies every pending migration in order and returns the applied ones
+func (r *Runner) Up(ctx context.Context) ([]Migration, error) {
+	applied := []Migration{}
+	err := r.locked(ctx, func(conn *gorm.DB, status *Status) error {
+		for _, m := range status.Pending {
+			if err := r.run(conn, m.Version, m.Up); err != nil {
+				return fmt.Errorf("applying migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			applied = append(applied, m)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the given number of applied migrations, newest first,
+// and returns the reverted ones
+func (r *Runner) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, ErrInvalidSteps
+	}
+	reverted := []Migration{}
+	err := r.locked(ctx, func(conn *gorm.DB, status *Status) error {
+		for i := len(r.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := r.migrations[i]
+			if m.Version > status.Version {
+				continue
+			}
+			var previous int64
+			if i > 0 {
+				previous = r.migrations[i-1].Version
+			}
+			if err := r.run(conn, previous, m.Down); err != nil {
+				return fmt.Errorf("reverting migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// locked runs fn on a single connection holding the migration lock,
+// once the schema is known to be clean
+func (r *Runner) locked(ctx context.Context, fn func(*gorm.DB, *Status) error) error {
+	return r.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockID).Error; err != nil {
+			return err
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", lockID)
+
+		if err := createVersionTable(conn); err != nil {
+			return err
+		}
+		status, err := r.status(conn)
+		if err != nil {
+			return err
+		}
+		if status.Dirty {
+			return ErrDirtySchema
+		}
+		return fn(conn, status)
+	})
+}
+
+// createVersionTable creates the version table when missing
+func createVersionTable(conn *gorm.DB) error {
+	return conn.Exec("CREATE TABLE IF NOT EXISTS " + versionTable + " (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)").Error
+}
+
+// status reads the applied version without changing the database,
+// a missing version table means that nothing is applied
+func (r *Runner) status(conn *gorm.DB) (*Status, error) {
+	var exists bool
+	if err := conn.Raw("SELECT to_regclass(?) IS NOT NULL", versionTable).Scan(&exists).Error; err != nil {
+		return nil, err
+	}
+	if !exists {
+		return NewStatus(r.migrations, 0, false)
+	}
+	var rows []struct {
+		Version int64
+		Dirty   bool
+	}
+	if err := conn.Raw("SELECT version, dirty FROM " + versionTable + " LIMIT 1").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return NewStatus(r.migrations, 0, false)
+	}
+	return NewStatus(r.migrations, rows[0].Version, rows[0].Dirty)
+}
+
+// run executes the SQL of a migration leaving the schema at the given
+// version, it is kept dirty until the SQL succeeds
+func (r *Runner) run(conn *gorm.DB, version int64, sql string) error {
+	if err := setVersion(conn, version, true); err != nil {
+		return err
+	}
+	if err := conn.Exec(sql).Error; err != nil {
+		return err
+	}
+	return setVersion(conn, version, false)
+}
+
+// setVersion stores the given version, zero meaning that nothing is applied
+func setVersion(conn *gorm.DB, version int64, dirty bool) error {
+	return conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("TRUNCATE " + versionTable).Error; err != nil {
+			return err
+		}
+		if version == 0 && !dirty {
+			return nil
+		}
+		return tx.Exec("INSERT INTO "+versionTable+" (version, dirty) VALUES (?, ?)", version, dirty).Error
+	})
+}
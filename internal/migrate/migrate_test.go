@@ -0,0 +1,143 @@
+package migrate_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/migrate"
+	"github.com/nachoconques0/schwarz-challenge/migrations"
+)
+
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+func TestLoad(t *testing.T) {
+	testCases := map[string]struct {
+		fsys     fstest.MapFS
+		expected []migrate.Migration
+		err      bool
+	}{
+		"ordered by version": {
+			fsys: fstest.MapFS{
+				"2_add-table.up.sql":   file("CREATE TABLE b;"),
+				"2_add-table.down.sql": file("DROP TABLE b;"),
+				"1_init.up.sql":        file("CREATE SCHEMA a;"),
+				"1_init.down.sql":      file("DROP SCHEMA a;"),
+				"migrations.go":        file("package migrations"),
+			},
+			expected: []migrate.Migration{
+				{Version: 1, Name: "init", Up: "CREATE SCHEMA a;", Down: "DROP SCHEMA a;"},
+				{Version: 2, Name: "add-table", Up: "CREATE TABLE b;", Down: "DROP TABLE b;"},
+			},
+		},
+		"missing down file": {
+			fsys: fstest.MapFS{
+				"1_init.up.sql": file("CREATE SCHEMA a;"),
+			},
+			err: true,
+		},
+		"invalid file name": {
+			fsys: fstest.MapFS{
+				"init.up.sql":   file("CREATE SCHEMA a;"),
+				"init.down.sql": file("DROP SCHEMA a;"),
+			},
+			err: true,
+		},
+		"same version with different names": {
+			fsys: fstest.MapFS{
+				"1_init.up.sql":    file("CREATE SCHEMA a;"),
+				"1_other.down.sql": file("DROP SCHEMA a;"),
+			},
+			err: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			result, err := migrate.Load(tc.fsys)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestLoad_Embedded(t *testing.T) {
+	result, err := migrate.Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, result)
+	assert.Equal(t, int64(20240608141014), result[0].Version)
+}
+
+func TestNewStatus(t *testing.T) {
+	all := []migrate.Migration{
+		{Version: 1, Name: "init"},
+		{Version: 2, Name: "add-table"},
+		{Version: 3, Name: "add-column"},
+	}
+
+	testCases := map[string]struct {
+		version    int64
+		dirty      bool
+		pending    []migrate.Migration
+		err        error
+		checkError error
+	}{
+		"nothing applied": {
+			version:    0,
+			pending:    all,
+			checkError: migrate.ErrOutdatedSchema,
+		},
+		"outdated": {
+			version:    2,
+			pending:    all[2:],
+			checkError: migrate.ErrOutdatedSchema,
+		},
+		"up to date": {
+			version: 3,
+			pending: []migrate.Migration{},
+		},
+		"dirty": {
+			version:    3,
+			dirty:      true,
+			pending:    []migrate.Migration{},
+			checkError: migrate.ErrDirtySchema,
+		},
+		"unknown version": {
+			version: 4,
+			err:     migrate.ErrUnknownSchemaVersion,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			status, err := migrate.NewStatus(all, tc.version, tc.dirty)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.version, status.Version)
+			assert.Equal(t, tc.dirty, status.Dirty)
+			assert.Equal(t, int64(3), status.Latest)
+			assert.Equal(t, tc.pending, status.Pending)
+			if tc.checkError != nil {
+				assert.ErrorIs(t, status.Check(), tc.checkError)
+			} else {
+				assert.NoError(t, status.Check())
+			}
+		})
+	}
+}
+
+func TestNewRunner_MissingDB(t *testing.T) {
+	_, err := migrate.NewRunner(nil, []migrate.Migration{{Version: 1}})
+	assert.ErrorIs(t, err, migrate.ErrMissingDB)
+}
@@ -0,0 +1,151 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/audit/audit.go
+//
+// Generated by this command:
+//
+//	mockgen --source=internal/audit/audit.go --destination=internal/mocks/mock_audit.go --package=mocks --mock_names=Repository=MockAuditRepository,Service=MockAuditService,Server=MockAuditServer
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	http "net/http"
+	reflect "reflect"
+
+	uuid "github.com/google/uuid"
+	audit "github.com/nachoconques0/schwarz-challenge/internal/audit"
+	transaction "github.com/nachoconques0/schwarz-challenge/internal/transaction"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockAuditService is a mock of Service interface.
+type MockAuditService struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditServiceMockRecorder
+}
+
+// MockAuditServiceMockRecorder is the mock recorder for MockAuditService.
+type MockAuditServiceMockRecorder struct {
+	mock *MockAuditService
+}
+
+// NewMockAuditService creates a new mock instance.
+func NewMockAuditService(ctrl *gomock.Controller) *MockAuditService {
+	mock := &MockAuditService{ctrl: ctrl}
+	mock.recorder = &MockAuditServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuditService) EXPECT() *MockAuditServiceMockRecorder {
+	return m.recorder
+}
+
+// ListEntries mocks base method.
+func (m *MockAuditService) ListEntries(arg0 context.Context, arg1 audit.ListRequest) ([]audit.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListEntries", arg0, arg1)
+	ret0, _ := ret[0].([]audit.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListEntries indicates an expected call of ListEntries.
+func (mr *MockAuditServiceMockRecorder) ListEntries(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntries", reflect.TypeOf((*MockAuditService)(nil).ListEntries), arg0, arg1)
+}
+
+// MockAuditRepository is a mock of Repository interface.
+type MockAuditRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditRepositoryMockRecorder
+}
+
+// MockAuditRepositoryMockRecorder is the mock recorder for MockAuditRepository.
+type MockAuditRepositoryMockRecorder struct {
+	mock *MockAuditRepository
+}
+
+// NewMockAuditRepository creates a new mock instance.
+func NewMockAuditRepository(ctrl *gomock.Controller) *MockAuditRepository {
+	mock := &MockAuditRepository{ctrl: ctrl}
+	mock.recorder = &MockAuditRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuditRepository) EXPECT() *MockAuditRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Add mocks base method.
+func (m *MockAuditRepository) Add(arg0 context.Context, arg1 transaction.Tx, arg2 ...audit.Entry) error {
+	m.ctrl.T.Helper()
+	varargs := []any{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Add", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Add indicates an expected call of Add.
+func (mr *MockAuditRepositoryMockRecorder) Add(arg0, arg1 any, arg2 ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockAuditRepository)(nil).Add), varargs...)
+}
+
+// List mocks base method.
+func (m *MockAuditRepository) List(ctx context.Context, entityType string, entityID uuid.UUID) ([]audit.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, entityType, entityID)
+	ret0, _ := ret[0].([]audit.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockAuditRepositoryMockRecorder) List(ctx, entityType, entityID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockAuditRepository)(nil).List), ctx, entityType, entityID)
+}
+
+// MockAuditServer is a mock of Server interface.
+type MockAuditServer struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditServerMockRecorder
+}
+
+// MockAuditServerMockRecorder is the mock recorder for MockAuditServer.
+type MockAuditServerMockRecorder struct {
+	mock *MockAuditServer
+}
+
+// NewMockAuditServer creates a new mock instance.
+func NewMockAuditServer(ctrl *gomock.Controller) *MockAuditServer {
+	mock := &MockAuditServer{ctrl: ctrl}
+	mock.recorder = &MockAuditServerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuditServer) EXPECT() *MockAuditServerMockRecorder {
+	return m.recorder
+}
+
+// ListEntries mocks base method.
+func (m *MockAuditServer) ListEntries(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ListEntries", w, r)
+}
+
+// ListEntries indicates an expected call of ListEntries.
+func (mr *MockAuditServerMockRecorder) ListEntries(w, r any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntries", reflect.TypeOf((*MockAuditServer)(nil).ListEntries), w, r)
+}
@@ -10,13 +10,14 @@
 package mocks
 
 import (
+	context "context"
 	http "net/http"
 	reflect "reflect"
 
 	uuid "github.com/google/uuid"
 	coupon "github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	transaction "github.com/nachoconques0/schwarz-challenge/internal/transaction"
 	gomock "go.uber.org/mock/gomock"
-	gorm "gorm.io/gorm"
 )
 
 // MockCouponService is a mock of Service interface.
@@ -43,33 +44,136 @@ func (m *MockCouponService) EXPECT() *MockCouponServiceMockRecorder {
 }
 
 // CreateCoupon mocks base method.
-func (m *MockCouponService) CreateCoupon(arg0 coupon.CreateRequest) (*coupon.Coupon, error) {
+func (m *MockCouponService) CreateCoupon(arg0 context.Context, arg1 coupon.CreateRequest) (*coupon.Coupon, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateCoupon", arg0)
+	ret := m.ctrl.Call(m, "CreateCoupon", arg0, arg1)
 	ret0, _ := ret[0].(*coupon.Coupon)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateCoupon indicates an expected call of CreateCoupon.
-func (mr *MockCouponServiceMockRecorder) CreateCoupon(arg0 any) *gomock.Call {
+func (mr *MockCouponServiceMockRecorder) CreateCoupon(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCoupon", reflect.TypeOf((*MockCouponService)(nil).CreateCoupon), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCoupon", reflect.TypeOf((*MockCouponService)(nil).CreateCoupon), arg0, arg1)
+}
+
+// DeactivateCoupon mocks base method.
+func (m *MockCouponService) DeactivateCoupon(arg0 context.Context, arg1 uuid.UUID) (*coupon.Coupon, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeactivateCoupon", arg0, arg1)
+	ret0, _ := ret[0].(*coupon.Coupon)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeactivateCoupon indicates an expected call of DeactivateCoupon.
+func (mr *MockCouponServiceMockRecorder) DeactivateCoupon(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeactivateCoupon", reflect.TypeOf((*MockCouponService)(nil).DeactivateCoupon), arg0, arg1)
+}
+
+// DeleteCoupon mocks base method.
+func (m *MockCouponService) DeleteCoupon(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCoupon", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteCoupon indicates an expected call of DeleteCoupon.
+func (mr *MockCouponServiceMockRecorder) DeleteCoupon(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCoupon", reflect.TypeOf((*MockCouponService)(nil).DeleteCoupon), arg0, arg1)
+}
+
+// ExportCoupons mocks base method.
+func (m *MockCouponService) ExportCoupons(arg0 context.Context, arg1 func(coupon.Coupon) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportCoupons", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportCoupons indicates an expected call of ExportCoupons.
+func (mr *MockCouponServiceMockRecorder) ExportCoupons(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportCoupons", reflect.TypeOf((*MockCouponService)(nil).ExportCoupons), arg0, arg1)
+}
+
+// GetCouponStats mocks base method.
+func (m *MockCouponService) GetCouponStats(arg0 context.Context, arg1 uuid.UUID, arg2 coupon.StatsRequest) (*coupon.Stats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCouponStats", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*coupon.Stats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCouponStats indicates an expected call of GetCouponStats.
+func (mr *MockCouponServiceMockRecorder) GetCouponStats(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCouponStats", reflect.TypeOf((*MockCouponService)(nil).GetCouponStats), arg0, arg1, arg2)
+}
+
+// ImportCoupons mocks base method.
+func (m *MockCouponService) ImportCoupons(arg0 context.Context, arg1 []coupon.ImportRow, arg2 string) (*coupon.ImportResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportCoupons", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*coupon.ImportResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportCoupons indicates an expected call of ImportCoupons.
+func (mr *MockCouponServiceMockRecorder) ImportCoupons(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportCoupons", reflect.TypeOf((*MockCouponService)(nil).ImportCoupons), arg0, arg1, arg2)
 }
 
 // ListCoupons mocks base method.
-func (m *MockCouponService) ListCoupons() ([]coupon.Coupon, error) {
+func (m *MockCouponService) ListCoupons(arg0 context.Context) ([]coupon.Coupon, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListCoupons")
+	ret := m.ctrl.Call(m, "ListCoupons", arg0)
 	ret0, _ := ret[0].([]coupon.Coupon)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListCoupons indicates an expected call of ListCoupons.
-func (mr *MockCouponServiceMockRecorder) ListCoupons() *gomock.Call {
+func (mr *MockCouponServiceMockRecorder) ListCoupons(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCoupons", reflect.TypeOf((*MockCouponService)(nil).ListCoupons), arg0)
+}
+
+// ReactivateCoupon mocks base method.
+func (m *MockCouponService) ReactivateCoupon(arg0 context.Context, arg1 uuid.UUID) (*coupon.Coupon, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReactivateCoupon", arg0, arg1)
+	ret0, _ := ret[0].(*coupon.Coupon)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReactivateCoupon indicates an expected call of ReactivateCoupon.
+func (mr *MockCouponServiceMockRecorder) ReactivateCoupon(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReactivateCoupon", reflect.TypeOf((*MockCouponService)(nil).ReactivateCoupon), arg0, arg1)
+}
+
+// UpdateCoupon mocks base method.
+func (m *MockCouponService) UpdateCoupon(arg0 context.Context, arg1 uuid.UUID, arg2 coupon.UpdateRequest) (*coupon.Coupon, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCoupon", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*coupon.Coupon)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateCoupon indicates an expected call of UpdateCoupon.
+func (mr *MockCouponServiceMockRecorder) UpdateCoupon(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCoupons", reflect.TypeOf((*MockCouponService)(nil).ListCoupons))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCoupon", reflect.TypeOf((*MockCouponService)(nil).UpdateCoupon), arg0, arg1, arg2)
 }
 
 // MockCouponRepository is a mock of Repository interface.
@@ -95,64 +199,137 @@ func (m *MockCouponRepository) EXPECT() *MockCouponRepositoryMockRecorder {
 	return m.recorder
 }
 
+// CountCouponRedemptions mocks base method.
+func (m *MockCouponRepository) CountCouponRedemptions(arg0 context.Context, arg1 transaction.Tx, arg2 uuid.UUID) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountCouponRedemptions", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountCouponRedemptions indicates an expected call of CountCouponRedemptions.
+func (mr *MockCouponRepositoryMockRecorder) CountCouponRedemptions(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountCouponRedemptions", reflect.TypeOf((*MockCouponRepository)(nil).CountCouponRedemptions), arg0, arg1, arg2)
+}
+
 // CreateCoupon mocks base method.
-func (m *MockCouponRepository) CreateCoupon(arg0 *coupon.Coupon) (*coupon.Coupon, error) {
+func (m *MockCouponRepository) CreateCoupon(arg0 context.Context, arg1 transaction.Tx, arg2 *coupon.Coupon) (*coupon.Coupon, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateCoupon", arg0)
+	ret := m.ctrl.Call(m, "CreateCoupon", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*coupon.Coupon)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateCoupon indicates an expected call of CreateCoupon.
-func (mr *MockCouponRepositoryMockRecorder) CreateCoupon(arg0 any) *gomock.Call {
+func (mr *MockCouponRepositoryMockRecorder) CreateCoupon(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCoupon", reflect.TypeOf((*MockCouponRepository)(nil).CreateCoupon), arg0, arg1, arg2)
+}
+
+// DeleteCoupon mocks base method.
+func (m *MockCouponRepository) DeleteCoupon(arg0 context.Context, arg1 transaction.Tx, arg2 *coupon.Coupon) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCoupon", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteCoupon indicates an expected call of DeleteCoupon.
+func (mr *MockCouponRepositoryMockRecorder) DeleteCoupon(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCoupon", reflect.TypeOf((*MockCouponRepository)(nil).CreateCoupon), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCoupon", reflect.TypeOf((*MockCouponRepository)(nil).DeleteCoupon), arg0, arg1, arg2)
+}
+
+// ExportCoupons mocks base method.
+func (m *MockCouponRepository) ExportCoupons(arg0 context.Context, arg1 func(coupon.Coupon) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportCoupons", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportCoupons indicates an expected call of ExportCoupons.
+func (mr *MockCouponRepositoryMockRecorder) ExportCoupons(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportCoupons", reflect.TypeOf((*MockCouponRepository)(nil).ExportCoupons), arg0, arg1)
 }
 
 // GetCouponForUpdate mocks base method.
-func (m *MockCouponRepository) GetCouponForUpdate(arg0 *gorm.DB, arg1 uuid.UUID) (*coupon.Coupon, error) {
+func (m *MockCouponRepository) GetCouponForUpdate(arg0 context.Context, arg1 transaction.Tx, arg2 uuid.UUID) (*coupon.Coupon, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetCouponForUpdate", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetCouponForUpdate", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*coupon.Coupon)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetCouponForUpdate indicates an expected call of GetCouponForUpdate.
-func (mr *MockCouponRepositoryMockRecorder) GetCouponForUpdate(arg0, arg1 any) *gomock.Call {
+func (mr *MockCouponRepositoryMockRecorder) GetCouponForUpdate(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCouponForUpdate", reflect.TypeOf((*MockCouponRepository)(nil).GetCouponForUpdate), arg0, arg1, arg2)
+}
+
+// GetCouponStats mocks base method.
+func (m *MockCouponRepository) GetCouponStats(arg0 context.Context, arg1 uuid.UUID, arg2 coupon.StatsRequest) (*coupon.Stats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCouponStats", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*coupon.Stats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCouponStats indicates an expected call of GetCouponStats.
+func (mr *MockCouponRepositoryMockRecorder) GetCouponStats(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCouponForUpdate", reflect.TypeOf((*MockCouponRepository)(nil).GetCouponForUpdate), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCouponStats", reflect.TypeOf((*MockCouponRepository)(nil).GetCouponStats), arg0, arg1, arg2)
+}
+
+// ImportCoupon mocks base method.
+func (m *MockCouponRepository) ImportCoupon(arg0 context.Context, arg1 transaction.Tx, arg2 *coupon.Coupon) (*coupon.Coupon, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportCoupon", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*coupon.Coupon)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportCoupon indicates an expected call of ImportCoupon.
+func (mr *MockCouponRepositoryMockRecorder) ImportCoupon(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportCoupon", reflect.TypeOf((*MockCouponRepository)(nil).ImportCoupon), arg0, arg1, arg2)
 }
 
 // ListCoupons mocks base method.
-func (m *MockCouponRepository) ListCoupons() ([]coupon.Coupon, error) {
+func (m *MockCouponRepository) ListCoupons(arg0 context.Context) ([]coupon.Coupon, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListCoupons")
+	ret := m.ctrl.Call(m, "ListCoupons", arg0)
 	ret0, _ := ret[0].([]coupon.Coupon)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListCoupons indicates an expected call of ListCoupons.
-func (mr *MockCouponRepositoryMockRecorder) ListCoupons() *gomock.Call {
+func (mr *MockCouponRepositoryMockRecorder) ListCoupons(arg0 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCoupons", reflect.TypeOf((*MockCouponRepository)(nil).ListCoupons))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCoupons", reflect.TypeOf((*MockCouponRepository)(nil).ListCoupons), arg0)
 }
 
 // UpdateCoupon mocks base method.
-func (m *MockCouponRepository) UpdateCoupon(arg0 *gorm.DB, arg1 *coupon.Coupon) (*coupon.Coupon, error) {
+func (m *MockCouponRepository) UpdateCoupon(arg0 context.Context, arg1 transaction.Tx, arg2 *coupon.Coupon) (*coupon.Coupon, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateCoupon", arg0, arg1)
+	ret := m.ctrl.Call(m, "UpdateCoupon", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*coupon.Coupon)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // UpdateCoupon indicates an expected call of UpdateCoupon.
-func (mr *MockCouponRepositoryMockRecorder) UpdateCoupon(arg0, arg1 any) *gomock.Call {
+func (mr *MockCouponRepositoryMockRecorder) UpdateCoupon(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCoupon", reflect.TypeOf((*MockCouponRepository)(nil).UpdateCoupon), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCoupon", reflect.TypeOf((*MockCouponRepository)(nil).UpdateCoupon), arg0, arg1, arg2)
 }
 
 // MockCouponServer is a mock of Server interface.
@@ -190,6 +367,66 @@ func (mr *MockCouponServerMockRecorder) CreateCoupon(w, r any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCoupon", reflect.TypeOf((*MockCouponServer)(nil).CreateCoupon), w, r)
 }
 
+// DeactivateCoupon mocks base method.
+func (m *MockCouponServer) DeactivateCoupon(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "DeactivateCoupon", w, r)
+}
+
+// DeactivateCoupon indicates an expected call of DeactivateCoupon.
+func (mr *MockCouponServerMockRecorder) DeactivateCoupon(w, r any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeactivateCoupon", reflect.TypeOf((*MockCouponServer)(nil).DeactivateCoupon), w, r)
+}
+
+// DeleteCoupon mocks base method.
+func (m *MockCouponServer) DeleteCoupon(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "DeleteCoupon", w, r)
+}
+
+// DeleteCoupon indicates an expected call of DeleteCoupon.
+func (mr *MockCouponServerMockRecorder) DeleteCoupon(w, r any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCoupon", reflect.TypeOf((*MockCouponServer)(nil).DeleteCoupon), w, r)
+}
+
+// ExportCoupons mocks base method.
+func (m *MockCouponServer) ExportCoupons(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ExportCoupons", w, r)
+}
+
+// ExportCoupons indicates an expected call of ExportCoupons.
+func (mr *MockCouponServerMockRecorder) ExportCoupons(w, r any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportCoupons", reflect.TypeOf((*MockCouponServer)(nil).ExportCoupons), w, r)
+}
+
+// GetCouponStats mocks base method.
+func (m *MockCouponServer) GetCouponStats(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "GetCouponStats", w, r)
+}
+
+// GetCouponStats indicates an expected call of GetCouponStats.
+func (mr *MockCouponServerMockRecorder) GetCouponStats(w, r any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCouponStats", reflect.TypeOf((*MockCouponServer)(nil).GetCouponStats), w, r)
+}
+
+// ImportCoupons mocks base method.
+func (m *MockCouponServer) ImportCoupons(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ImportCoupons", w, r)
+}
+
+// ImportCoupons indicates an expected call of ImportCoupons.
+func (mr *MockCouponServerMockRecorder) ImportCoupons(w, r any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportCoupons", reflect.TypeOf((*MockCouponServer)(nil).ImportCoupons), w, r)
+}
+
 // LisCoupons mocks base method.
 func (m *MockCouponServer) LisCoupons(w http.ResponseWriter, r *http.Request) {
 	m.ctrl.T.Helper()
@@ -201,3 +438,27 @@ func (mr *MockCouponServerMockRecorder) LisCoupons(w, r any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LisCoupons", reflect.TypeOf((*MockCouponServer)(nil).LisCoupons), w, r)
 }
+
+// ReactivateCoupon mocks base method.
+func (m *MockCouponServer) ReactivateCoupon(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ReactivateCoupon", w, r)
+}
+
+// ReactivateCoupon indicates an expected call of ReactivateCoupon.
+func (mr *MockCouponServerMockRecorder) ReactivateCoupon(w, r any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReactivateCoupon", reflect.TypeOf((*MockCouponServer)(nil).ReactivateCoupon), w, r)
+}
+
+// UpdateCoupon mocks base method.
+func (m *MockCouponServer) UpdateCoupon(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UpdateCoupon", w, r)
+}
+
+// UpdateCoupon indicates an expected call of UpdateCoupon.
+func (mr *MockCouponServerMockRecorder) UpdateCoupon(w, r any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCoupon", reflect.TypeOf((*MockCouponServer)(nil).UpdateCoupon), w, r)
+}
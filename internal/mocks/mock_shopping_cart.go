@@ -10,13 +10,14 @@
 package mocks
 
 import (
+	context "context"
 	http "net/http"
 	reflect "reflect"
 
 	uuid "github.com/google/uuid"
 	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	transaction "github.com/nachoconques0/schwarz-challenge/internal/transaction"
 	gomock "go.uber.org/mock/gomock"
-	gorm "gorm.io/gorm"
 )
 
 // MockShoppingCartService is a mock of Service interface.
@@ -43,47 +44,48 @@ func (m *MockShoppingCartService) EXPECT() *MockShoppingCartServiceMockRecorder
 }
 
 // ApplyCoupon mocks base method.
-func (m *MockShoppingCartService) ApplyCoupon(arg0, arg1 uuid.UUID) error {
+func (m *MockShoppingCartService) ApplyCoupon(arg0 context.Context, arg1, arg2 uuid.UUID) (*shoppingcart.ShoppingCart, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ApplyCoupon", arg0, arg1)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "ApplyCoupon", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*shoppingcart.ShoppingCart)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // ApplyCoupon indicates an expected call of ApplyCoupon.
-func (mr *MockShoppingCartServiceMockRecorder) ApplyCoupon(arg0, arg1 any) *gomock.Call {
+func (mr *MockShoppingCartServiceMockRecorder) ApplyCoupon(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyCoupon", reflect.TypeOf((*MockShoppingCartService)(nil).ApplyCoupon), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyCoupon", reflect.TypeOf((*MockShoppingCartService)(nil).ApplyCoupon), arg0, arg1, arg2)
 }
 
 // CreateShoppingCart mocks base method.
-func (m *MockShoppingCartService) CreateShoppingCart(arg0 shoppingcart.CreateRequest) (*shoppingcart.ShoppingCart, error) {
+func (m *MockShoppingCartService) CreateShoppingCart(arg0 context.Context, arg1 shoppingcart.CreateRequest) (*shoppingcart.ShoppingCart, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateShoppingCart", arg0)
+	ret := m.ctrl.Call(m, "CreateShoppingCart", arg0, arg1)
 	ret0, _ := ret[0].(*shoppingcart.ShoppingCart)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateShoppingCart indicates an expected call of CreateShoppingCart.
-func (mr *MockShoppingCartServiceMockRecorder) CreateShoppingCart(arg0 any) *gomock.Call {
+func (mr *MockShoppingCartServiceMockRecorder) CreateShoppingCart(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateShoppingCart", reflect.TypeOf((*MockShoppingCartService)(nil).CreateShoppingCart), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateShoppingCart", reflect.TypeOf((*MockShoppingCartService)(nil).CreateShoppingCart), arg0, arg1)
 }
 
 // ListShoppingCarts mocks base method.
-func (m *MockShoppingCartService) ListShoppingCarts() ([]shoppingcart.ShoppingCart, error) {
+func (m *MockShoppingCartService) ListShoppingCarts(arg0 context.Context) ([]shoppingcart.ShoppingCart, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListShoppingCarts")
+	ret := m.ctrl.Call(m, "ListShoppingCarts", arg0)
 	ret0, _ := ret[0].([]shoppingcart.ShoppingCart)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListShoppingCarts indicates an expected call of ListShoppingCarts.
-func (mr *MockShoppingCartServiceMockRecorder) ListShoppingCarts() *gomock.Call {
+func (mr *MockShoppingCartServiceMockRecorder) ListShoppingCarts(arg0 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListShoppingCarts", reflect.TypeOf((*MockShoppingCartService)(nil).ListShoppingCarts))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListShoppingCarts", reflect.TypeOf((*MockShoppingCartService)(nil).ListShoppingCarts), arg0)
 }
 
 // MockShoppingCartRepository is a mock of Repository interface.
@@ -109,106 +111,79 @@ func (m *MockShoppingCartRepository) EXPECT() *MockShoppingCartRepositoryMockRec
 	return m.recorder
 }
 
-// BeginTransaction mocks base method.
-func (m *MockShoppingCartRepository) BeginTransaction() *gorm.DB {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BeginTransaction")
-	ret0, _ := ret[0].(*gorm.DB)
-	return ret0
-}
-
-// BeginTransaction indicates an expected call of BeginTransaction.
-func (mr *MockShoppingCartRepositoryMockRecorder) BeginTransaction() *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginTransaction", reflect.TypeOf((*MockShoppingCartRepository)(nil).BeginTransaction))
-}
-
-// CommitTransaction mocks base method.
-func (m *MockShoppingCartRepository) CommitTransaction(tx *gorm.DB) error {
+// CountCustomerCouponUses mocks base method.
+func (m *MockShoppingCartRepository) CountCustomerCouponUses(ctx context.Context, tx transaction.Tx, customerID, couponID uuid.UUID) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CommitTransaction", tx)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "CountCustomerCouponUses", ctx, tx, customerID, couponID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// CommitTransaction indicates an expected call of CommitTransaction.
-func (mr *MockShoppingCartRepositoryMockRecorder) CommitTransaction(tx any) *gomock.Call {
+// CountCustomerCouponUses indicates an expected call of CountCustomerCouponUses.
+func (mr *MockShoppingCartRepositoryMockRecorder) CountCustomerCouponUses(ctx, tx, customerID, couponID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CommitTransaction", reflect.TypeOf((*MockShoppingCartRepository)(nil).CommitTransaction), tx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountCustomerCouponUses", reflect.TypeOf((*MockShoppingCartRepository)(nil).CountCustomerCouponUses), ctx, tx, customerID, couponID)
 }
 
 // CreateShoppingCart mocks base method.
-func (m *MockShoppingCartRepository) CreateShoppingCart(arg0 *shoppingcart.ShoppingCart) (*shoppingcart.ShoppingCart, error) {
+func (m *MockShoppingCartRepository) CreateShoppingCart(arg0 context.Context, arg1 transaction.Tx, arg2 *shoppingcart.ShoppingCart) (*shoppingcart.ShoppingCart, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateShoppingCart", arg0)
+	ret := m.ctrl.Call(m, "CreateShoppingCart", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*shoppingcart.ShoppingCart)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateShoppingCart indicates an expected call of CreateShoppingCart.
-func (mr *MockShoppingCartRepositoryMockRecorder) CreateShoppingCart(arg0 any) *gomock.Call {
+func (mr *MockShoppingCartRepositoryMockRecorder) CreateShoppingCart(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateShoppingCart", reflect.TypeOf((*MockShoppingCartRepository)(nil).CreateShoppingCart), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateShoppingCart", reflect.TypeOf((*MockShoppingCartRepository)(nil).CreateShoppingCart), arg0, arg1, arg2)
 }
 
 // GetShoppingCartForUpdate mocks base method.
-func (m *MockShoppingCartRepository) GetShoppingCartForUpdate(arg0 *gorm.DB, arg1 uuid.UUID) (*shoppingcart.ShoppingCart, error) {
+func (m *MockShoppingCartRepository) GetShoppingCartForUpdate(arg0 context.Context, arg1 transaction.Tx, arg2 uuid.UUID) (*shoppingcart.ShoppingCart, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetShoppingCartForUpdate", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetShoppingCartForUpdate", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*shoppingcart.ShoppingCart)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetShoppingCartForUpdate indicates an expected call of GetShoppingCartForUpdate.
-func (mr *MockShoppingCartRepositoryMockRecorder) GetShoppingCartForUpdate(arg0, arg1 any) *gomock.Call {
+func (mr *MockShoppingCartRepositoryMockRecorder) GetShoppingCartForUpdate(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShoppingCartForUpdate", reflect.TypeOf((*MockShoppingCartRepository)(nil).GetShoppingCartForUpdate), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShoppingCartForUpdate", reflect.TypeOf((*MockShoppingCartRepository)(nil).GetShoppingCartForUpdate), arg0, arg1, arg2)
 }
 
 // ListShoppingCarts mocks base method.
-func (m *MockShoppingCartRepository) ListShoppingCarts() ([]shoppingcart.ShoppingCart, error) {
+func (m *MockShoppingCartRepository) ListShoppingCarts(arg0 context.Context) ([]shoppingcart.ShoppingCart, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListShoppingCarts")
+	ret := m.ctrl.Call(m, "ListShoppingCarts", arg0)
 	ret0, _ := ret[0].([]shoppingcart.ShoppingCart)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListShoppingCarts indicates an expected call of ListShoppingCarts.
-func (mr *MockShoppingCartRepositoryMockRecorder) ListShoppingCarts() *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListShoppingCarts", reflect.TypeOf((*MockShoppingCartRepository)(nil).ListShoppingCarts))
-}
-
-// RollbackTransaction mocks base method.
-func (m *MockShoppingCartRepository) RollbackTransaction(tx *gorm.DB) error {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "RollbackTransaction", tx)
-	ret0, _ := ret[0].(error)
-	return ret0
-}
-
-// RollbackTransaction indicates an expected call of RollbackTransaction.
-func (mr *MockShoppingCartRepositoryMockRecorder) RollbackTransaction(tx any) *gomock.Call {
+func (mr *MockShoppingCartRepositoryMockRecorder) ListShoppingCarts(arg0 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RollbackTransaction", reflect.TypeOf((*MockShoppingCartRepository)(nil).RollbackTransaction), tx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListShoppingCarts", reflect.TypeOf((*MockShoppingCartRepository)(nil).ListShoppingCarts), arg0)
 }
 
 // UpdateShoppingCart mocks base method.
-func (m *MockShoppingCartRepository) UpdateShoppingCart(arg0 *gorm.DB, arg1 *shoppingcart.ShoppingCart) (*shoppingcart.ShoppingCart, error) {
+func (m *MockShoppingCartRepository) UpdateShoppingCart(arg0 context.Context, arg1 transaction.Tx, arg2 *shoppingcart.ShoppingCart) (*shoppingcart.ShoppingCart, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateShoppingCart", arg0, arg1)
+	ret := m.ctrl.Call(m, "UpdateShoppingCart", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*shoppingcart.ShoppingCart)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // UpdateShoppingCart indicates an expected call of UpdateShoppingCart.
-func (mr *MockShoppingCartRepositoryMockRecorder) UpdateShoppingCart(arg0, arg1 any) *gomock.Call {
+func (mr *MockShoppingCartRepositoryMockRecorder) UpdateShoppingCart(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateShoppingCart", reflect.TypeOf((*MockShoppingCartRepository)(nil).UpdateShoppingCart), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateShoppingCart", reflect.TypeOf((*MockShoppingCartRepository)(nil).UpdateShoppingCart), arg0, arg1, arg2)
 }
 
 // MockShoppingCartServer is a mock of Server interface.
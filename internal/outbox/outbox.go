@@ -0,0 +1,109 @@
+// Package outbox delivers the domain events to the other teams. The services
+// store the events in the outbox within the same transaction as the state
+// change producing them, so an event is stored if and only if its change is
+// committed. A Relay then publishes the stored events at least once, in the
+// order they were stored for every aggregate, retrying the failed ones.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+var (
+	// ErrMissingRepository used when the outbox repository is nil
+	ErrMissingRepository = internalErrors.NewInternalError("outbox repository is missing")
+	// ErrMissingPublisher used when the publisher is nil
+	ErrMissingPublisher = internalErrors.NewInternalError("outbox publisher is missing")
+	// ErrInvalidRelayConfig used when the relay config has non positive values
+	ErrInvalidRelayConfig = internalErrors.NewInternalError("outbox relay config must be positive")
+	// ErrInvalidEvent used when the event misses its aggregate or type
+	ErrInvalidEvent = internalErrors.NewInternalError("outbox event must have an aggregate and a type")
+	// ErrEventNotFound used when the outbox event is not found
+	ErrEventNotFound = internalErrors.NewNotFound("outbox event not found")
+)
+
+// Event is a domain event stored in the outbox
+type Event struct {
+	// ID Unique Identifier of the event, consumers use it to drop duplicates
+	ID uuid.UUID `json:"id"`
+	// Seq orders the events as they were stored
+	Seq int64 `json:"-" gorm:"->"`
+	// AggregateType names the kind of entity the event is about
+	AggregateType string `json:"aggregate_type"`
+	// AggregateID is the ID of the entity the event is about
+	AggregateID uuid.UUID `json:"aggregate_id"`
+	// Type of the event
+	Type string `json:"type"`
+	// Payload is the event encoded as json
+	Payload json.RawMessage `json:"payload"`
+	// OccurredAt is the moment the state changed
+	OccurredAt time.Time `json:"occurred_at"`
+	// Attempts is the number of failed deliveries
+	Attempts int `json:"-"`
+	// NextAttemptAt is the moment the event can be delivered again
+	NextAttemptAt time.Time `json:"-"`
+	// PublishedAt is set once the event is delivered
+	PublishedAt *time.Time `json:"-"`
+	// DeadAt is set once the event ran out of attempts, it is not delivered anymore
+	DeadAt *time.Time `json:"-"`
+	// LastError is the reason of the last failed delivery
+	LastError string `json:"-"`
+}
+
+// NewEvent returns a new event of the given aggregate encoding its payload as json
+func NewEvent(aggregateType string, aggregateID uuid.UUID, eventType string, payload interface{}) (Event, error) {
+	if aggregateType == "" || aggregateID == uuid.Nil || eventType == "" {
+		return Event{}, ErrInvalidEvent
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, err
+	}
+	now := time.Now()
+	return Event{
+		ID:            uuid.New(),
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Type:          eventType,
+		Payload:       encoded,
+		OccurredAt:    now,
+		NextAttemptAt: now,
+	}, nil
+}
+
+// Repository defines the available functions for the outbox repository
+type Repository interface {
+	// Add stores the events within the transaction of the state change producing them
+	Add(context.Context, transaction.Tx, ...Event) error
+	// Pending returns up to limit events not published yet and due at the given
+	// time, in the order they were stored. The events of an aggregate are held
+	// back while an earlier event of it is not due
+	Pending(ctx context.Context, due time.Time, limit int) ([]Event, error)
+	// MarkPublished records the delivery of an event
+	MarkPublished(ctx context.Context, id uuid.UUID, at time.Time) error
+	// MarkFailed records a failed delivery of an event, delivered again once next is reached
+	MarkFailed(ctx context.Context, id uuid.UUID, next time.Time, reason string) error
+	// MarkDead records the last failed delivery of an event that ran out of
+	// attempts, it is kept for inspection but never delivered again
+	MarkDead(ctx context.Context, id uuid.UUID, at time.Time, reason string) error
+	// Claim returns the events Pending would return and keeps them from the
+	// other relays until the given time, by when they are due again unless
+	// marked. Claims never overlap, so the relays of several instances never
+	// publish the same events at once
+	Claim(ctx context.Context, due, until time.Time, limit int) ([]Event, error)
+	// Release makes a claimed event due again at the given time
+	Release(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+// Publisher delivers the events to their consumers
+type Publisher interface {
+	// Publish delivers the event, an error means it has to be delivered again
+	Publish(context.Context, Event) error
+}
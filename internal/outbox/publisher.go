@@ -0,0 +1,139 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+)
+
+var (
+	// ErrMissingWebhookURL used when the webhook publisher has no URL
+	ErrMissingWebhookURL = internalErrors.NewInternalError("outbox webhook url is missing")
+	// ErrMissingFilePath used when the file publisher has no path
+	ErrMissingFilePath = internalErrors.NewInternalError("outbox file path is missing")
+)
+
+// EventTypeHeader is the header of the webhook requests carrying the event type
+const EventTypeHeader = "X-Event-Type"
+
+type logPublisher struct{}
+
+// NewLogPublisher returns a Publisher logging the events, meant for local runs
+func NewLogPublisher() Publisher {
+	return logPublisher{}
+}
+
+// Publish logs the event
+func (logPublisher) Publish(_ context.Context, e Event) error {
+	slog.Info("outbox: event published",
+		slog.String("id", e.ID.String()),
+		slog.String("type", e.Type),
+		slog.String("aggregate_type", e.AggregateType),
+		slog.String("aggregate_id", e.AggregateID.String()),
+		slog.String("payload", string(e.Payload)),
+	)
+	return nil
+}
+
+type webhookPublisher struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookPublisher returns a Publisher posting every event as json to the
+// given URL, any response other than 2xx is a failed delivery
+func NewWebhookPublisher(url string, client *http.Client) (Publisher, error) {
+	if url == "" {
+		return nil, ErrMissingWebhookURL
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &webhookPublisher{url: url, client: client}, nil
+}
+
+// Publish posts the event to the webhook URL
+func (p *webhookPublisher) Publish(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventTypeHeader, e.Type)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type filePublisher struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFilePublisher returns a Publisher appending every event
+// as a json line to the file at the given path
+func NewFilePublisher(path string) (Publisher, error) {
+	if path == "" {
+		return nil, ErrMissingFilePath
+	}
+	return &filePublisher{path: path}, nil
+}
+
+// Publish appends the event to the file
+func (p *filePublisher) Publish(_ context.Context, e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, err := os.OpenFile(p.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type multiPublisher struct {
+	publishers []Publisher
+}
+
+// NewMultiPublisher returns a Publisher handing every event to each of the
+// given publishers in order, a failure stops it and the event is published
+// again later to all of them, so they must tolerate duplicates
+func NewMultiPublisher(publishers ...Publisher) Publisher {
+	return &multiPublisher{publishers: publishers}
+}
+
+// Publish hands the event to every publisher
+func (p *multiPublisher) Publish(ctx context.Context, e Event) error {
+	for _, publisher := range p.publishers {
+		if err := publisher.Publish(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
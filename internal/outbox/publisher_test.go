@@ -0,0 +1,110 @@
+package outbox_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
+)
+
+func TestWebhookPublisher_Publish(t *testing.T) {
+	e, err := outbox.NewEvent("test", uuid.New(), "test.happened", map[string]string{"name": "test"})
+	require.Nil(t, err)
+
+	t.Run("posts the event", func(t *testing.T) {
+		var received outbox.Event
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, e.Type, r.Header.Get(outbox.EventTypeHeader))
+			body, err := io.ReadAll(r.Body)
+			assert.Nil(t, err)
+			assert.Nil(t, json.Unmarshal(body, &received))
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		defer srv.Close()
+
+		publisher, err := outbox.NewWebhookPublisher(srv.URL, srv.Client())
+		require.Nil(t, err)
+		assert.Nil(t, publisher.Publish(context.Background(), e))
+		assert.Equal(t, e.ID, received.ID)
+		assert.JSONEq(t, string(e.Payload), string(received.Payload))
+	})
+
+	t.Run("fails when the webhook does not answer 2xx", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer srv.Close()
+
+		publisher, err := outbox.NewWebhookPublisher(srv.URL, srv.Client())
+		require.Nil(t, err)
+		assert.NotNil(t, publisher.Publish(context.Background(), e))
+	})
+
+	t.Run("missing url", func(t *testing.T) {
+		_, err := outbox.NewWebhookPublisher("", nil)
+		assert.Equal(t, outbox.ErrMissingWebhookURL, err)
+	})
+}
+
+func TestFilePublisher_Publish(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	publisher, err := outbox.NewFilePublisher(path)
+	require.Nil(t, err)
+
+	var events []outbox.Event
+	for i := 0; i < 2; i++ {
+		e, err := outbox.NewEvent("test", uuid.New(), "test.happened", map[string]int{"i": i})
+		require.Nil(t, err)
+		require.Nil(t, publisher.Publish(context.Background(), e))
+		events = append(events, e)
+	}
+
+	f, err := os.Open(path)
+	require.Nil(t, err)
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	var lines []outbox.Event
+	for scanner.Scan() {
+		var e outbox.Event
+		require.Nil(t, json.Unmarshal(scanner.Bytes(), &e))
+		lines = append(lines, e)
+	}
+	require.Len(t, lines, 2)
+	assert.Equal(t, events[0].ID, lines[0].ID)
+	assert.Equal(t, events[1].ID, lines[1].ID)
+
+	_, err = outbox.NewFilePublisher("")
+	assert.Equal(t, outbox.ErrMissingFilePath, err)
+}
+
+func TestMultiPublisher_Publish(t *testing.T) {
+	e, err := outbox.NewEvent("test", uuid.New(), "test.happened", map[string]string{})
+	require.Nil(t, err)
+
+	t.Run("publishes to every publisher", func(t *testing.T) {
+		first := &recordingPublisher{failing: map[uuid.UUID]bool{}}
+		second := &recordingPublisher{failing: map[uuid.UUID]bool{}}
+		assert.Nil(t, outbox.NewMultiPublisher(first, second).Publish(context.Background(), e))
+		assert.Equal(t, []uuid.UUID{e.ID}, first.publishedIDs())
+		assert.Equal(t, []uuid.UUID{e.ID}, second.publishedIDs())
+	})
+
+	t.Run("stops at the first failure", func(t *testing.T) {
+		first := &recordingPublisher{failing: map[uuid.UUID]bool{e.AggregateID: true}}
+		second := &recordingPublisher{failing: map[uuid.UUID]bool{}}
+		assert.Equal(t, errPublish, outbox.NewMultiPublisher(first, second).Publish(context.Background(), e))
+		assert.Empty(t, second.publishedIDs())
+	})
+}
@@ -0,0 +1,163 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/logging"
+)
+
+// RelayConfig defines how often the relay looks for events and how it retries them
+type RelayConfig struct {
+	// PollInterval is the time between two looks for pending events
+	PollInterval time.Duration
+	// BatchSize is the number of pending events read at once
+	BatchSize int
+	// MinBackoff is the wait before delivering a failed event again,
+	// doubled after every failed delivery
+	MinBackoff time.Duration
+	// MaxBackoff bounds the wait between two deliveries of an event
+	MaxBackoff time.Duration
+	// MaxAttempts is the number of failed deliveries after which
+	// an event is dead and not delivered anymore
+	MaxAttempts int
+	// Lease is how long a batch of events is claimed for, the relay stops
+	// publishing the batch once it is over since another relay may claim
+	// the rest
+	Lease time.Duration
+}
+
+// DefaultRelayConfig polls every second and retries from one second up to
+// five minutes, an event is given up after 20 failed deliveries. A batch is
+// claimed for five minutes
+func DefaultRelayConfig() RelayConfig {
+	return RelayConfig{
+		PollInterval: time.Second,
+		BatchSize:    100,
+		MinBackoff:   time.Second,
+		MaxBackoff:   5 * time.Minute,
+		MaxAttempts:  20,
+		Lease:        5 * time.Minute,
+	}
+}
+
+// Relay publishes the events stored in the outbox. Every instance of the
+// service runs one, every relay claims the batch it publishes so the events
+// are not delivered twice
+type Relay struct {
+	repo      Repository
+	publisher Publisher
+	config    RelayConfig
+	now       func() time.Time
+}
+
+// NewRelay returns a Relay publishing the events of repo with the given publisher
+func NewRelay(repo Repository, publisher Publisher, config RelayConfig) (*Relay, error) {
+	if repo == nil {
+		return nil, ErrMissingRepository
+	}
+	if publisher == nil {
+		return nil, ErrMissingPublisher
+	}
+	if config.PollInterval <= 0 || config.BatchSize <= 0 || config.MinBackoff <= 0 || config.MaxBackoff < config.MinBackoff || config.MaxAttempts <= 0 || config.Lease <= 0 {
+		return nil, ErrInvalidRelayConfig
+	}
+	return &Relay{
+		repo:      repo,
+		publisher: publisher,
+		config:    config,
+		now:       time.Now,
+	}, nil
+}
+
+// Run publishes the pending events every poll interval until ctx is done
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.RelayOnce(ctx); err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("outbox: relaying events", slog.Any("error", err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce claims a batch of pending events, publishes them and returns how
+// many were published. The events claimed by the relay of another instance
+// are left to it. Once an event of an aggregate can not be published, the
+// later events of that aggregate wait for it so they are delivered in order
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	claimedAt := r.now()
+	leaseEnd := claimedAt.Add(r.config.Lease)
+	events, err := r.repo.Claim(ctx, claimedAt, leaseEnd, r.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	blocked := map[uuid.UUID]bool{}
+	for _, e := range events {
+		if err := ctx.Err(); err != nil {
+			return published, err
+		}
+		now := r.now()
+		if !now.Before(leaseEnd) {
+			return published, nil
+		}
+		if blocked[e.AggregateID] {
+			if err := r.repo.Release(ctx, e.ID, now); err != nil {
+				return published, err
+			}
+			continue
+		}
+
+		if err := r.publisher.Publish(ctx, e); err != nil {
+			blocked[e.AggregateID] = true
+			if err := r.markFailed(ctx, e, now, err); err != nil {
+				return published, err
+			}
+			continue
+		}
+		if err := r.repo.MarkPublished(ctx, e.ID, now); err != nil {
+			return published, err
+		}
+		published++
+	}
+	return published, nil
+}
+
+// markFailed records the failed delivery of e, once it ran out of attempts
+// the event is dead and the later events of its aggregate are delivered
+func (r *Relay) markFailed(ctx context.Context, e Event, now time.Time, reason error) error {
+	if e.Attempts+1 >= r.config.MaxAttempts {
+		logging.FromContext(ctx).Error("outbox: giving up event",
+			slog.String("event_id", e.ID.String()),
+			slog.Int("attempts", e.Attempts+1),
+			slog.Any("error", reason))
+		return r.repo.MarkDead(ctx, e.ID, now, reason.Error())
+	}
+	logging.FromContext(ctx).Error("outbox: publishing event",
+		slog.String("event_id", e.ID.String()),
+		slog.Int("attempts", e.Attempts+1),
+		slog.Any("error", reason))
+	return r.repo.MarkFailed(ctx, e.ID, now.Add(r.backoff(e.Attempts)), reason.Error())
+}
+
+// backoff returns the wait before delivering again an event after the given failed attempts
+func (r *Relay) backoff(attempts int) time.Duration {
+	wait := r.config.MinBackoff
+	for i := 0; i < attempts && wait < r.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > r.config.MaxBackoff {
+		return r.config.MaxBackoff
+	}
+	return wait
+}
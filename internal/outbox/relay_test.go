@@ -0,0 +1,330 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/memory"
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+var errPublish = errors.New("publish failed")
+
+// testMaxAttempts is the number of failed deliveries after which the test relays give up an event
+const testMaxAttempts = 3
+
+type testOutbox struct {
+	repo      outbox.Repository
+	txManager transaction.Manager
+}
+
+// recordingPublisher records the published events and fails the ones of the given aggregates
+type recordingPublisher struct {
+	mu        sync.Mutex
+	failing   map[uuid.UUID]bool
+	published []outbox.Event
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, e outbox.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failing[e.AggregateID] {
+		return errPublish
+	}
+	p.published = append(p.published, e)
+	return nil
+}
+
+func (p *recordingPublisher) setFailing(aggregateID uuid.UUID, failing bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failing[aggregateID] = failing
+}
+
+func (p *recordingPublisher) publishedIDs() []uuid.UUID {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ids := make([]uuid.UUID, 0, len(p.published))
+	for _, e := range p.published {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+// slowPublisher takes delay to publish every event
+type slowPublisher struct {
+	delay time.Duration
+}
+
+func (p *slowPublisher) Publish(context.Context, outbox.Event) error {
+	time.Sleep(p.delay)
+	return nil
+}
+
+func TestNewRelay(t *testing.T) {
+	to := newTestOutbox(t)
+	publisher := outbox.NewLogPublisher()
+
+	testCases := map[string]struct {
+		repo          outbox.Repository
+		publisher     outbox.Publisher
+		config        func(*outbox.RelayConfig)
+		expectedError error
+	}{
+		"missing repository": {
+			publisher:     publisher,
+			expectedError: outbox.ErrMissingRepository,
+		},
+		"missing publisher": {
+			repo:          to.repo,
+			expectedError: outbox.ErrMissingPublisher,
+		},
+		"invalid poll interval": {
+			repo:          to.repo,
+			publisher:     publisher,
+			config:        func(c *outbox.RelayConfig) { c.PollInterval = 0 },
+			expectedError: outbox.ErrInvalidRelayConfig,
+		},
+		"invalid batch size": {
+			repo:          to.repo,
+			publisher:     publisher,
+			config:        func(c *outbox.RelayConfig) { c.BatchSize = 0 },
+			expectedError: outbox.ErrInvalidRelayConfig,
+		},
+		"invalid lease": {
+			repo:          to.repo,
+			publisher:     publisher,
+			config:        func(c *outbox.RelayConfig) { c.Lease = 0 },
+			expectedError: outbox.ErrInvalidRelayConfig,
+		},
+		"invalid max attempts": {
+			repo:          to.repo,
+			publisher:     publisher,
+			config:        func(c *outbox.RelayConfig) { c.MaxAttempts = 0 },
+			expectedError: outbox.ErrInvalidRelayConfig,
+		},
+		"max backoff below min backoff": {
+			repo:          to.repo,
+			publisher:     publisher,
+			config:        func(c *outbox.RelayConfig) { c.MaxBackoff = c.MinBackoff / 2 },
+			expectedError: outbox.ErrInvalidRelayConfig,
+		},
+		"success": {
+			repo:      to.repo,
+			publisher: publisher,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			config := outbox.DefaultRelayConfig()
+			if tc.config != nil {
+				tc.config(&config)
+			}
+			relay, err := outbox.NewRelay(tc.repo, tc.publisher, config)
+			assert.Equal(t, tc.expectedError, err)
+			if tc.expectedError == nil {
+				assert.NotNil(t, relay)
+			}
+		})
+	}
+}
+
+func TestRelay_RelayOnce(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("publishes the pending events in order", func(t *testing.T) {
+		to := newTestOutbox(t)
+		publisher := &recordingPublisher{failing: map[uuid.UUID]bool{}}
+		relay := newRelay(t, to, publisher, time.Hour)
+		aggregateID := uuid.New()
+		first := to.add(t, aggregateID)
+		second := to.add(t, aggregateID)
+
+		published, err := relay.RelayOnce(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 2, published)
+		assert.Equal(t, []uuid.UUID{first.ID, second.ID}, publisher.publishedIDs())
+
+		pending, err := to.repo.Pending(ctx, time.Now(), 10)
+		require.Nil(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("holds back the aggregate of a failed event", func(t *testing.T) {
+		to := newTestOutbox(t)
+		failingID := uuid.New()
+		publisher := &recordingPublisher{failing: map[uuid.UUID]bool{failingID: true}}
+		relay := newRelay(t, to, publisher, time.Hour)
+		to.add(t, failingID)
+		to.add(t, failingID)
+		other := to.add(t, uuid.New())
+
+		published, err := relay.RelayOnce(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 1, published)
+		assert.Equal(t, []uuid.UUID{other.ID}, publisher.publishedIDs())
+
+		pending, err := to.repo.Pending(ctx, time.Now().Add(2*time.Hour), 10)
+		require.Nil(t, err)
+		require.Len(t, pending, 2)
+		assert.Equal(t, 1, pending[0].Attempts)
+		assert.Equal(t, errPublish.Error(), pending[0].LastError)
+		assert.Equal(t, 0, pending[1].Attempts)
+
+		publisher.setFailing(failingID, false)
+		published, err = relay.RelayOnce(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 0, published, "the failed event is not due yet")
+	})
+
+	t.Run("retries the failed event once due", func(t *testing.T) {
+		to := newTestOutbox(t)
+		aggregateID := uuid.New()
+		publisher := &recordingPublisher{failing: map[uuid.UUID]bool{aggregateID: true}}
+		relay := newRelay(t, to, publisher, 10*time.Millisecond)
+		first := to.add(t, aggregateID)
+		second := to.add(t, aggregateID)
+
+		_, err := relay.RelayOnce(ctx)
+		require.Nil(t, err)
+
+		publisher.setFailing(aggregateID, false)
+		time.Sleep(20 * time.Millisecond)
+		published, err := relay.RelayOnce(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 2, published)
+		assert.Equal(t, []uuid.UUID{first.ID, second.ID}, publisher.publishedIDs())
+	})
+
+	t.Run("gives up the events running out of attempts", func(t *testing.T) {
+		to := newTestOutbox(t)
+		aggregateID := uuid.New()
+		publisher := &recordingPublisher{failing: map[uuid.UUID]bool{aggregateID: true}}
+		relay := newRelay(t, to, publisher, time.Millisecond)
+		to.add(t, aggregateID)
+		later := to.add(t, aggregateID)
+
+		for i := 0; i < testMaxAttempts; i++ {
+			time.Sleep(2 * time.Millisecond)
+			_, err := relay.RelayOnce(ctx)
+			require.Nil(t, err)
+		}
+		publisher.setFailing(aggregateID, false)
+		time.Sleep(2 * time.Millisecond)
+		published, err := relay.RelayOnce(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 1, published)
+		assert.Equal(t, []uuid.UUID{later.ID}, publisher.publishedIDs(), "the dead event is not delivered")
+	})
+
+	t.Run("leaves the events claimed by another relay", func(t *testing.T) {
+		to := newTestOutbox(t)
+		publisher := &recordingPublisher{failing: map[uuid.UUID]bool{}}
+		relay := newRelay(t, to, publisher, time.Hour)
+		to.add(t, uuid.New())
+
+		now := time.Now()
+		claimed, err := to.repo.Claim(ctx, now, now.Add(time.Hour), 10)
+		require.Nil(t, err)
+		require.Len(t, claimed, 1)
+
+		published, err := relay.RelayOnce(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 0, published)
+		assert.Empty(t, publisher.publishedIDs())
+	})
+
+	t.Run("stops publishing once its lease is over", func(t *testing.T) {
+		to := newTestOutbox(t)
+		publisher := &slowPublisher{delay: 20 * time.Millisecond}
+		config := testRelayConfig(time.Hour)
+		config.Lease = 10 * time.Millisecond
+		relay, err := outbox.NewRelay(to.repo, publisher, config)
+		require.Nil(t, err)
+		to.add(t, uuid.New())
+		to.add(t, uuid.New())
+
+		published, err := relay.RelayOnce(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 1, published, "the second event may be claimed by another relay")
+
+		pending, err := to.repo.Pending(ctx, time.Now(), 10)
+		require.Nil(t, err)
+		assert.Len(t, pending, 1, "the second event is due again once the lease is over")
+	})
+}
+
+func TestRelay_Run(t *testing.T) {
+	to := newTestOutbox(t)
+	publisher := &recordingPublisher{failing: map[uuid.UUID]bool{}}
+	relay := newRelay(t, to, publisher, time.Hour)
+	e := to.add(t, uuid.New())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return len(publisher.publishedIDs()) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []uuid.UUID{e.ID}, publisher.publishedIDs())
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("relay did not stop once its context was canceled")
+	}
+}
+
+func newTestOutbox(t *testing.T) testOutbox {
+	store := memory.NewStore()
+	repo, err := memory.NewOutboxRepository(store)
+	require.Nil(t, err)
+	txManager, err := memory.NewTransactionManager(store)
+	require.Nil(t, err)
+	return testOutbox{repo: repo, txManager: txManager}
+}
+
+// add stores a new event of the given aggregate
+func (to testOutbox) add(t *testing.T, aggregateID uuid.UUID) outbox.Event {
+	t.Helper()
+	e, err := outbox.NewEvent("test", aggregateID, "test.happened", map[string]string{})
+	require.Nil(t, err)
+	ctx := context.Background()
+	err = to.txManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		return to.repo.Add(ctx, tx, e)
+	})
+	require.Nil(t, err)
+	return e
+}
+
+func newRelay(t *testing.T, to testOutbox, publisher outbox.Publisher, backoff time.Duration) *outbox.Relay {
+	relay, err := outbox.NewRelay(to.repo, publisher, testRelayConfig(backoff))
+	require.Nil(t, err)
+	return relay
+}
+
+// testRelayConfig polls every millisecond and retries the failed events after backoff
+func testRelayConfig(backoff time.Duration) outbox.RelayConfig {
+	return outbox.RelayConfig{
+		PollInterval: time.Millisecond,
+		BatchSize:    10,
+		MinBackoff:   backoff,
+		MaxBackoff:   backoff,
+		MaxAttempts:  testMaxAttempts,
+		Lease:        time.Minute,
+	}
+}
@@ -6,6 +6,7 @@ package postgres
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -15,11 +16,37 @@ import (
 )
 
 const (
-	maxConnections = 1
+	defaultMaxOpenConns    = 10
+	defaultMaxIdleConns    = 5
+	defaultConnMaxLifetime = 30 * time.Minute
+	defaultLogLevel        = "warn"
+	defaultSSLMode         = "prefer"
+	// minOpenConns leaves a connection to the requests while the
+	// background loops, such as the outbox relay, hold another
+	minOpenConns = 2
 )
 
+// logLevels maps the names of the gorm log levels
+var logLevels = map[string]logger.LogLevel{
+	"silent": logger.Silent,
+	"error":  logger.Error,
+	"warn":   logger.Warn,
+	"info":   logger.Info,
+}
+
+// sslModes lists the SSL modes supported by postgres
+var sslModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
 // DBOptions defines the data needed to create a db connection
-// SSLMode is a optional option that will take disable as default
+// SSLMode is a optional option that will take prefer as default,
+// the pool and logging options take a sensible default when zero
 type DBOptions struct {
 	Host     string
 	Port     string
@@ -27,10 +54,23 @@ type DBOptions struct {
 	Password string
 	Database string
 	SSLMode  string
+
+	// MaxOpenConns bounds the connections open at once, 10 by default and
+	// at least 2
+	MaxOpenConns int
+	// MaxIdleConns bounds the connections kept idle in the pool, 5 by
+	// default and never more than MaxOpenConns
+	MaxIdleConns int
+	// ConnMaxLifetime is how long a connection is reused, 30 minutes by default
+	ConnMaxLifetime time.Duration
+	// StatementTimeout aborts the statements running longer, zero means no timeout
+	StatementTimeout time.Duration
+	// LogLevel is the gorm log level: silent, error, warn or info, warn by default
+	LogLevel string
 }
 
 func (o *DBOptions) connection() string {
-	return fmt.Sprintf(
+	conn := fmt.Sprintf(
 		"host=%s port=%s dbname=%s user=%s password=%s sslmode=%s",
 		o.Host,
 		o.Port,
@@ -39,6 +79,52 @@ func (o *DBOptions) connection() string {
 		o.Password,
 		o.SSLMode,
 	)
+	if o.StatementTimeout > 0 {
+		conn += fmt.Sprintf(" statement_timeout=%d", o.StatementTimeout.Milliseconds())
+	}
+	return conn
+}
+
+// validatePool checks the pool and logging options, setting the default
+// of the ones not given
+func (o *DBOptions) validatePool() error {
+	if o.MaxOpenConns < 0 {
+		return internalErrors.NewWrongInput("max open connections must not be negative")
+	}
+	if o.MaxOpenConns > 0 && o.MaxOpenConns < minOpenConns {
+		return internalErrors.NewWrongInput(fmt.Sprintf("max open connections must be at least %d", minOpenConns))
+	}
+	if o.MaxIdleConns < 0 {
+		return internalErrors.NewWrongInput("max idle connections must not be negative")
+	}
+	if o.ConnMaxLifetime < 0 {
+		return internalErrors.NewWrongInput("connection max lifetime must not be negative")
+	}
+	if o.StatementTimeout < 0 {
+		return internalErrors.NewWrongInput("statement timeout must not be negative")
+	}
+	if o.StatementTimeout > 0 && o.StatementTimeout < time.Millisecond {
+		return internalErrors.NewWrongInput("statement timeout must be at least 1ms")
+	}
+	if o.MaxOpenConns == 0 {
+		o.MaxOpenConns = defaultMaxOpenConns
+	}
+	if o.MaxIdleConns == 0 {
+		o.MaxIdleConns = min(defaultMaxIdleConns, o.MaxOpenConns)
+	}
+	if o.MaxIdleConns > o.MaxOpenConns {
+		return internalErrors.NewWrongInput("max idle connections must not exceed max open connections")
+	}
+	if o.ConnMaxLifetime == 0 {
+		o.ConnMaxLifetime = defaultConnMaxLifetime
+	}
+	if o.LogLevel == "" {
+		o.LogLevel = defaultLogLevel
+	}
+	if _, ok := logLevels[o.LogLevel]; !ok {
+		return internalErrors.NewWrongInput("log level must be silent, error, warn or info")
+	}
+	return nil
 }
 
 // NewDB initialized a new GORM DB with the provided database
@@ -62,14 +148,20 @@ func NewDB(opts *DBOptions) (*gorm.DB, error) {
 	if opts.SSLMode == "" {
 		// we should use prefer as the default SSL Mode
 		// to enforce a secure connection
-		opts.SSLMode = "prefer"
+		opts.SSLMode = defaultSSLMode
+	}
+	if !sslModes[opts.SSLMode] {
+		return nil, internalErrors.NewWrongInput("ssl mode must be disable, allow, prefer, require, verify-ca or verify-full")
+	}
+	if err := opts.validatePool(); err != nil {
+		return nil, err
 	}
 
 	dbLogger := logger.New(
 		log.Default(),
 		logger.Config{
 			IgnoreRecordNotFoundError: true,
-			LogLevel:                  logger.Warn,
+			LogLevel:                  logLevels[opts.LogLevel],
 		},
 	)
 	db, err := gorm.Open(postgres.Open(opts.connection()), &gorm.Config{
@@ -83,6 +175,8 @@ func NewDB(opts *DBOptions) (*gorm.DB, error) {
 	if err != nil {
 		return nil, internalErrors.NewWrongInput(fmt.Sprintf("error getting db connection: %s", err))
 	}
-	con.SetMaxOpenConns(maxConnections)
+	con.SetMaxOpenConns(opts.MaxOpenConns)
+	con.SetMaxIdleConns(opts.MaxIdleConns)
+	con.SetConnMaxLifetime(opts.ConnMaxLifetime)
 	return db, nil
 }
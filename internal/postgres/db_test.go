@@ -2,6 +2,7 @@ package postgres_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -95,6 +96,82 @@ func TestNewDB(t *testing.T) {
 			},
 			err: errors.NewWrongInput("database is required"),
 		},
+		{
+			name: "when max open connections is negative",
+			opts: func() *postgres.DBOptions {
+				o := *opts
+				o.MaxOpenConns = -1
+				return &o
+			},
+			err: errors.NewWrongInput("max open connections must not be negative"),
+		},
+		{
+			name: "when a single open connection is allowed",
+			opts: func() *postgres.DBOptions {
+				o := *opts
+				o.MaxOpenConns = 1
+				return &o
+			},
+			err: errors.NewWrongInput("max open connections must be at least 2"),
+		},
+		{
+			name: "when the SSL mode is unknown",
+			opts: func() *postgres.DBOptions {
+				o := *opts
+				o.SSLMode = "sometimes"
+				return &o
+			},
+			err: errors.NewWrongInput("ssl mode must be disable, allow, prefer, require, verify-ca or verify-full"),
+		},
+		{
+			name: "when the log level is unknown",
+			opts: func() *postgres.DBOptions {
+				o := *opts
+				o.LogLevel = "debug"
+				return &o
+			},
+			err: errors.NewWrongInput("log level must be silent, error, warn or info"),
+		},
+		{
+			name: "when max idle connections exceeds max open connections",
+			opts: func() *postgres.DBOptions {
+				o := *opts
+				o.MaxOpenConns = 2
+				o.MaxIdleConns = 3
+				return &o
+			},
+			err: errors.NewWrongInput("max idle connections must not exceed max open connections"),
+		},
+		{
+			name: "when connection max lifetime is negative",
+			opts: func() *postgres.DBOptions {
+				o := *opts
+				o.ConnMaxLifetime = -time.Second
+				return &o
+			},
+			err: errors.NewWrongInput("connection max lifetime must not be negative"),
+		},
+		{
+			name: "when statement timeout is below a millisecond",
+			opts: func() *postgres.DBOptions {
+				o := *opts
+				o.StatementTimeout = time.Microsecond
+				return &o
+			},
+			err: errors.NewWrongInput("statement timeout must be at least 1ms"),
+		},
+		{
+			name: "when the pool is tuned",
+			opts: func() *postgres.DBOptions {
+				o := *opts
+				o.MaxOpenConns = 4
+				o.MaxIdleConns = 2
+				o.ConnMaxLifetime = time.Minute
+				o.StatementTimeout = 5 * time.Second
+				o.LogLevel = "silent"
+				return &o
+			},
+		},
 		{
 			name: "when SSL Mode is not provided",
 			opts: func() *postgres.DBOptions {
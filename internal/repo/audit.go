@@ -0,0 +1,52 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+// auditLogTable is the table name for the audit entries
+const auditLogTable = "schwarz.audit_log"
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditRepository returns an audit.Repository keeping the entries in postgres
+func NewAuditRepository(db *gorm.DB) (audit.Repository, error) {
+	if db == nil {
+		return nil, ErrMissingDB
+	}
+	return &auditRepository{
+		db: db,
+	}, nil
+}
+
+// Add stores the entries within the transaction of the state change producing them
+func (ar auditRepository) Add(ctx context.Context, tx transaction.Tx, entries ...audit.Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	db, err := txDB(ctx, tx)
+	if err != nil {
+		return err
+	}
+	return db.Table(auditLogTable).Create(&entries).Error
+}
+
+// List returns the entries of an entity in the order they were stored
+func (ar auditRepository) List(ctx context.Context, entityType string, entityID uuid.UUID) ([]audit.Entry, error) {
+	result := []audit.Entry{}
+	if err := ar.db.WithContext(ctx).Table(auditLogTable).
+		Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+		Order("seq").
+		Find(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,68 @@
+package repo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/contract"
+	"github.com/nachoconques0/schwarz-challenge/internal/helpers"
+	"github.com/nachoconques0/schwarz-challenge/internal/repo"
+)
+
+func TestContract_CouponRepository(t *testing.T) {
+	contract.TestCouponRepository(t, newBackend)
+}
+
+func TestContract_ShoppingCartRepository(t *testing.T) {
+	contract.TestShoppingCartRepository(t, newBackend)
+}
+
+func TestContract_IdempotencyStore(t *testing.T) {
+	contract.TestIdempotencyStore(t, newBackend)
+}
+
+func TestContract_OutboxRepository(t *testing.T) {
+	contract.TestOutboxRepository(t, newBackend)
+}
+
+func TestContract_WebhookRepository(t *testing.T) {
+	contract.TestWebhookRepository(t, newBackend)
+}
+
+func TestContract_AuditRepository(t *testing.T) {
+	contract.TestAuditRepository(t, newBackend)
+}
+
+// newBackend runs every check on a pool of real connections, so the
+// concurrency checks race like they do in production. The tables are
+// emptied around every check
+func newBackend(t *testing.T) contract.Backend {
+	db, teardown, err := helpers.NewTestPool()
+	require.Nil(t, err)
+	t.Cleanup(teardown)
+
+	couponRepo, err := repo.NewCouponRepository(db)
+	require.Nil(t, err)
+	shoppingCartRepo, err := repo.NewShoppingCarRepository(db)
+	require.Nil(t, err)
+	txManager, err := repo.NewTransactionManager(db)
+	require.Nil(t, err)
+	idempotencyStore, err := repo.NewIdempotencyStore(db)
+	require.Nil(t, err)
+	outboxRepo, err := repo.NewOutboxRepository(db)
+	require.Nil(t, err)
+	webhookRepo, err := repo.NewWebhookRepository(db)
+	require.Nil(t, err)
+	auditRepo, err := repo.NewAuditRepository(db)
+	require.Nil(t, err)
+	return contract.Backend{
+		CouponRepo:       couponRepo,
+		ShoppingCartRepo: shoppingCartRepo,
+		TxManager:        txManager,
+		IdempotencyStore: idempotencyStore,
+		OutboxRepo:       outboxRepo,
+		WebhookRepo:      webhookRepo,
+		AuditRepo:        auditRepo,
+	}
+}
@@ -1,25 +1,29 @@
 package repo
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
-	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-var (
-	// ErrCouponNotFound used when coupon is not found
-	ErrCouponNotFound = internalErrors.NewNotFound("coupon not found")
-	// ErrCouponMissingID used when coupon id is missing
-	ErrCouponMissingID = internalErrors.NewWrongInput("coupon id is missing")
+const (
+	// couponTable is the table name for the coupon model
+	couponTable = "schwarz.coupon"
+	// couponCodeIndex is the unique index of the coupon codes
+	couponCodeIndex = "coupon_code_idx"
+	// exportBatchSize is the number of coupons read at once while exporting
+	exportBatchSize = 500
+	// uniqueViolationCode is the postgres error code of a unique constraint violation
+	uniqueViolationCode = "23505"
 )
 
-// couponTable is the table name for the coupon model
-const couponTable = "schwarz.coupon"
-
 type couponRepository struct {
 	db *gorm.DB
 }
@@ -33,49 +37,235 @@ func NewCouponRepository(db *gorm.DB) (coupon.Repository, error) {
 	}, nil
 }
 
-// CreateCoupon returns a new coupon
-func (cs couponRepository) CreateCoupon(coupon *coupon.Coupon) (*coupon.Coupon, error) {
-	if err := cs.db.Table(couponTable).Create(&coupon).Error; err != nil {
+// CreateCoupon creates a coupon within the given transaction
+func (cs couponRepository) CreateCoupon(ctx context.Context, tx transaction.Tx, c *coupon.Coupon) (*coupon.Coupon, error) {
+	db, err := txDB(ctx, tx)
+	if err != nil {
 		return nil, err
 	}
-	return coupon, nil
+	if err := db.Table(couponTable).Create(&c).Error; err != nil {
+		return nil, mapCouponError(err)
+	}
+	return c, nil
 }
 
 // ListCoupons returns a list of coupons
-func (cs couponRepository) ListCoupons() ([]coupon.Coupon, error) {
+func (cs couponRepository) ListCoupons(ctx context.Context) ([]coupon.Coupon, error) {
 	var result []coupon.Coupon
-	if err := cs.db.Table(couponTable).Find(&result).Error; err != nil {
+	if err := cs.db.WithContext(ctx).Table(couponTable).Where("deleted_at IS NULL").Find(&result).Error; err != nil {
 		return nil, err
 	}
 	if len(result) == 0 {
-		return nil, ErrCouponNotFound
+		return nil, coupon.ErrCouponNotFound
 	}
 	return result, nil
 }
 
 // GetCouponForUpdate returns an specific  and it will lock the row in order to update it
-func (cs couponRepository) GetCouponForUpdate(tx *gorm.DB, couponID uuid.UUID) (*coupon.Coupon, error) {
+func (cs couponRepository) GetCouponForUpdate(ctx context.Context, tx transaction.Tx, couponID uuid.UUID) (*coupon.Coupon, error) {
 	if couponID == uuid.Nil {
-		return nil, ErrCouponMissingID
+		return nil, coupon.ErrCouponMissingID
+	}
+	db, err := txDB(ctx, tx)
+	if err != nil {
+		return nil, err
 	}
 
 	var result *coupon.Coupon
-	if err := tx.Table(couponTable).
+	if err := db.Table(couponTable).
 		Where(coupon.Coupon{ID: couponID}).
+		Where("deleted_at IS NULL").
 		Clauses(clause.Locking{Strength: "UPDATE"}).
 		First(&result).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrCouponNotFound
+			return nil, coupon.ErrCouponNotFound
 		}
 		return nil, err
 	}
 	return result, nil
 }
 
-// UpdateCoupon updates coupon entity
-func (cs couponRepository) UpdateCoupon(tx *gorm.DB, coupon *coupon.Coupon) (*coupon.Coupon, error) {
-	if err := tx.Table(couponTable).Save(&coupon).Error; err != nil {
+// UpdateCoupon updates coupon entity increasing its version, it fails
+// with ErrCouponVersionConflict if the stored version is not the given one
+func (cs couponRepository) UpdateCoupon(ctx context.Context, tx transaction.Tx, c *coupon.Coupon) (*coupon.Coupon, error) {
+	db, err := txDB(ctx, tx)
+	if err != nil {
 		return nil, err
 	}
-	return coupon, nil
+
+	current := c.Version
+	c.Version++
+	res := db.Table(couponTable).
+		Where("version = ?", current).
+		Select("*").
+		Omit("created_at").
+		Updates(c)
+	if res.Error != nil {
+		c.Version = current
+		return nil, mapCouponError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		c.Version = current
+		return nil, coupon.ErrCouponVersionConflict
+	}
+	return c, nil
+}
+
+// DeleteCoupon soft deletes a coupon, read within the same transaction
+func (cs couponRepository) DeleteCoupon(ctx context.Context, tx transaction.Tx, c *coupon.Coupon) error {
+	if c == nil || c.ID == uuid.Nil {
+		return coupon.ErrCouponMissingID
+	}
+	db, err := txDB(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	res := db.Table(couponTable).
+		Where("id = ? AND version = ? AND deleted_at IS NULL", c.ID, c.Version).
+		Updates(map[string]interface{}{
+			"deleted_at": now,
+			"version":    c.Version + 1,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return cs.deleteMissError(db, c.ID)
+	}
+	c.DeletedAt = &now
+	c.Version++
+	return nil
+}
+
+// deleteMissError tells apart why a deletion matched no row: the coupon
+// is gone or deleted already, or it was updated since it was read
+func (cs couponRepository) deleteMissError(db *gorm.DB, couponID uuid.UUID) error {
+	var count int64
+	err := db.Table(couponTable).
+		Where("id = ? AND deleted_at IS NULL", couponID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return coupon.ErrCouponNotFound
+	}
+	return coupon.ErrCouponVersionConflict
+}
+
+// CountCouponRedemptions returns how many shopping carts have the coupon applied
+func (cs couponRepository) CountCouponRedemptions(ctx context.Context, tx transaction.Tx, couponID uuid.UUID) (int64, error) {
+	db, err := txDB(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	if err := db.Table(shoppingCartTable).
+		Where("coupon_id = ?", couponID).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetCouponStats aggregates the redemptions of a coupon
+func (cs couponRepository) GetCouponStats(ctx context.Context, couponID uuid.UUID, req coupon.StatsRequest) (*coupon.Stats, error) {
+	if couponID == uuid.Nil {
+		return nil, coupon.ErrCouponMissingID
+	}
+
+	var count int64
+	if err := cs.db.WithContext(ctx).Table(couponTable).
+		Where("id = ? AND deleted_at IS NULL", couponID).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, coupon.ErrCouponNotFound
+	}
+
+	redemptions := cs.db.WithContext(ctx).Table(shoppingCartTable).Where("coupon_id = ?", couponID)
+	if req.From != nil {
+		redemptions = redemptions.Where("coupon_applied_at >= ?", *req.From)
+	}
+	if req.Until != nil {
+		redemptions = redemptions.Where("coupon_applied_at < ?", *req.Until)
+	}
+
+	var summary coupon.StatsBucket
+	if err := redemptions.Session(&gorm.Session{}).
+		Select("COUNT(*) AS redemptions, " +
+			"COALESCE(SUM(amount - total), 0) AS total_discount, " +
+			"COALESCE(AVG(amount), 0) AS average_cart_value").
+		Scan(&summary).Error; err != nil {
+		return nil, err
+	}
+
+	buckets := []coupon.StatsBucket{}
+	if err := redemptions.Session(&gorm.Session{}).
+		Select("date_trunc(?, coupon_applied_at) AS start, "+
+			"COUNT(*) AS redemptions, "+
+			"SUM(amount - total) AS total_discount, "+
+			"AVG(amount) AS average_cart_value", req.Interval).
+		Where("coupon_applied_at IS NOT NULL").
+		Group("1").
+		Order("1").
+		Scan(&buckets).Error; err != nil {
+		return nil, err
+	}
+
+	return &coupon.Stats{
+		CouponID:         couponID,
+		Redemptions:      summary.Redemptions,
+		TotalDiscount:    summary.TotalDiscount,
+		AverageCartValue: summary.AverageCartValue,
+		Interval:         req.Interval,
+		Buckets:          buckets,
+	}, nil
+}
+
+// ImportCoupon creates a coupon within the given transaction, a failure only discards this coupon
+func (cs couponRepository) ImportCoupon(ctx context.Context, tx transaction.Tx, c *coupon.Coupon) (*coupon.Coupon, error) {
+	const savePoint = "import_coupon"
+	db, err := txDB(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.SavePoint(savePoint).Error; err != nil {
+		return nil, err
+	}
+	created, err := cs.CreateCoupon(ctx, tx, c)
+	if err != nil {
+		if rbErr := db.RollbackTo(savePoint).Error; rbErr != nil {
+			return nil, rbErr
+		}
+		return nil, err
+	}
+	return created, nil
+}
+
+// ExportCoupons calls the given function for every coupon, reading them in batches
+func (cs couponRepository) ExportCoupons(ctx context.Context, fn func(coupon.Coupon) error) error {
+	var batch []coupon.Coupon
+	return cs.db.WithContext(ctx).Table(couponTable).
+		Where("deleted_at IS NULL").
+		FindInBatches(&batch, exportBatchSize, func(_ *gorm.DB, _ int) error {
+			for _, c := range batch {
+				if err := fn(c); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// mapCouponError translates the known database errors into domain errors
+func mapCouponError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode && pgErr.ConstraintName == couponCodeIndex {
+		return coupon.ErrCouponCodeAlreadyExists
+	}
+	return err
 }
@@ -1,6 +1,7 @@
 package repo_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
 	"github.com/nachoconques0/schwarz-challenge/internal/helpers"
 	"github.com/nachoconques0/schwarz-challenge/internal/repo"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
 )
@@ -39,7 +41,7 @@ func TestRepository_CreateCoupon(t *testing.T) {
 	r := createCouponRepo(t, db)
 
 	t.Run("it should create the coupon", func(t *testing.T) {
-		res, err := r.CreateCoupon(c)
+		res, err := r.CreateCoupon(context.Background(), repo.NewTx(db), c)
 		assert.Nil(t, err)
 		assert.Equal(t, c.ID, res.ID)
 		assert.Equal(t, c.Name, res.Name)
@@ -52,7 +54,7 @@ func TestRepository_CreateCoupon(t *testing.T) {
 	t.Run("it should fail if the coupon already exists", func(t *testing.T) {
 		updatedCoupon := c
 		updatedCoupon.Name = "new name"
-		_, err := r.CreateCoupon(updatedCoupon)
+		_, err := r.CreateCoupon(context.Background(), repo.NewTx(db), updatedCoupon)
 		assert.NotNil(t, err)
 	})
 }
@@ -63,7 +65,7 @@ func TestRepository_ListCoupon(t *testing.T) {
 		expectedLen   int
 	}{
 		"when the are no coupons": {
-			expectedError: repo.ErrCouponNotFound,
+			expectedError: coupon.ErrCouponNotFound,
 			expectedLen:   0,
 		},
 		"when coupon exists": {
@@ -81,11 +83,11 @@ func TestRepository_ListCoupon(t *testing.T) {
 
 		r := createCouponRepo(t, db)
 		if tc.expectedLen != 0 {
-			createCoupon(t, r)
+			createCoupon(t, db, r)
 		}
 
 		t.Run(name, func(t *testing.T) {
-			res, err := r.ListCoupons()
+			res, err := r.ListCoupons(context.Background())
 			assert.Equal(t, tc.expectedError, err)
 			if res != nil {
 				assert.Len(t, res, tc.expectedLen)
@@ -105,7 +107,7 @@ func TestRepository_GetCouponForUpdate(t *testing.T) {
 
 	r := createCouponRepo(t, db)
 
-	createdCoupon := createCoupon(t, r)
+	createdCoupon := createCoupon(t, db, r)
 
 	testCases := map[string]struct {
 		expectedError  error
@@ -114,7 +116,7 @@ func TestRepository_GetCouponForUpdate(t *testing.T) {
 	}{
 		"when there is no coupon": {
 			id:             uuid.New(),
-			expectedError:  repo.ErrCouponNotFound,
+			expectedError:  coupon.ErrCouponNotFound,
 			expectedCoupon: nil,
 		},
 		"when coupon exists": {
@@ -126,7 +128,7 @@ func TestRepository_GetCouponForUpdate(t *testing.T) {
 
 	for name, tc := range testCases {
 		t.Run(name, func(t *testing.T) {
-			res, err := r.GetCouponForUpdate(db, tc.id)
+			res, err := r.GetCouponForUpdate(context.Background(), repo.NewTx(db), tc.id)
 			assert.Equal(t, tc.expectedError, err)
 			if res != nil {
 				assert.Equal(t, tc.expectedCoupon.ID, res.ID)
@@ -155,11 +157,11 @@ func TestRepository_UpdateCoupon(t *testing.T) {
 		defer teardown()
 
 		r := createCouponRepo(t, db)
-		createdCoupon := createCoupon(t, r)
+		createdCoupon := createCoupon(t, db, r)
 
 		t.Run(name, func(t *testing.T) {
 			createdCoupon.Used = true
-			res, err := r.UpdateCoupon(db, createdCoupon)
+			res, err := r.UpdateCoupon(context.Background(), repo.NewTx(db), createdCoupon)
 			assert.Equal(t, tc.expectedError, err)
 			if res != nil {
 				assert.True(t, res.Used)
@@ -170,6 +172,160 @@ func TestRepository_UpdateCoupon(t *testing.T) {
 	}
 }
 
+func TestRepository_DeleteCoupon(t *testing.T) {
+	db, teardown, err := helpers.NewTestDB()
+	if err != nil {
+		assert.Nil(t, err)
+	}
+	defer teardown()
+
+	r := createCouponRepo(t, db)
+	createdCoupon := createCoupon(t, db, r)
+
+	t.Run("when coupon exists", func(t *testing.T) {
+		err := r.DeleteCoupon(context.Background(), repo.NewTx(db), createdCoupon)
+		assert.Nil(t, err)
+		assert.NotNil(t, createdCoupon.DeletedAt)
+		assert.Equal(t, int64(2), createdCoupon.Version)
+
+		_, err = r.GetCouponForUpdate(context.Background(), repo.NewTx(db), createdCoupon.ID)
+		assert.Equal(t, coupon.ErrCouponNotFound, err)
+		_, err = r.ListCoupons(context.Background())
+		assert.Equal(t, coupon.ErrCouponNotFound, err)
+	})
+
+	t.Run("when coupon is already deleted", func(t *testing.T) {
+		err := r.DeleteCoupon(context.Background(), repo.NewTx(db), createdCoupon)
+		assert.Equal(t, coupon.ErrCouponNotFound, err)
+	})
+
+	t.Run("when coupon id is missing", func(t *testing.T) {
+		err := r.DeleteCoupon(context.Background(), repo.NewTx(db), &coupon.Coupon{})
+		assert.Equal(t, coupon.ErrCouponMissingID, err)
+	})
+}
+
+func TestRepository_CountCouponRedemptions(t *testing.T) {
+	db, teardown, err := helpers.NewTestDB()
+	if err != nil {
+		assert.Nil(t, err)
+	}
+	defer teardown()
+
+	r := createCouponRepo(t, db)
+	createdCoupon := createCoupon(t, db, r)
+
+	res, err := r.CountCouponRedemptions(context.Background(), repo.NewTx(db), createdCoupon.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), res)
+
+	scRepo := createShoppingCartRepo(t, db)
+	_, err = scRepo.CreateShoppingCart(context.Background(), repo.NewTx(db), &shoppingcart.ShoppingCart{
+		ID:       uuid.New(),
+		CouponID: createdCoupon.ID,
+		Amount:   10,
+	})
+	assert.Nil(t, err)
+
+	res, err = r.CountCouponRedemptions(context.Background(), repo.NewTx(db), createdCoupon.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), res)
+}
+
+func TestRepository_GetCouponStats(t *testing.T) {
+	db, teardown, err := helpers.NewTestDB()
+	if err != nil {
+		assert.Nil(t, err)
+	}
+	defer teardown()
+
+	r := createCouponRepo(t, db)
+	createdCoupon := createCoupon(t, db, r)
+	scRepo := createShoppingCartRepo(t, db)
+
+	appliedAt := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	for _, amount := range []float32{100, 50} {
+		_, err = scRepo.CreateShoppingCart(context.Background(), repo.NewTx(db), &shoppingcart.ShoppingCart{
+			ID:              uuid.New(),
+			CouponID:        createdCoupon.ID,
+			CouponAppliedAt: &appliedAt,
+			Amount:          amount,
+			Total:           amount - createdCoupon.Amount,
+		})
+		assert.Nil(t, err)
+	}
+
+	t.Run("when coupon has redemptions", func(t *testing.T) {
+		res, err := r.GetCouponStats(context.Background(), createdCoupon.ID, coupon.StatsRequest{Interval: coupon.StatsIntervalDay})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), res.Redemptions)
+		assert.Equal(t, float64(2*createdCoupon.Amount), res.TotalDiscount)
+		assert.Equal(t, float64(75), res.AverageCartValue)
+		assert.Len(t, res.Buckets, 1)
+		assert.Equal(t, int64(2), res.Buckets[0].Redemptions)
+	})
+
+	t.Run("when redemptions are out of range", func(t *testing.T) {
+		from := appliedAt.Add(time.Hour)
+		res, err := r.GetCouponStats(context.Background(), createdCoupon.ID, coupon.StatsRequest{Interval: coupon.StatsIntervalDay, From: &from})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), res.Redemptions)
+		assert.Len(t, res.Buckets, 0)
+	})
+
+	t.Run("when coupon does not exist", func(t *testing.T) {
+		_, err := r.GetCouponStats(context.Background(), uuid.New(), coupon.StatsRequest{Interval: coupon.StatsIntervalDay})
+		assert.Equal(t, coupon.ErrCouponNotFound, err)
+	})
+}
+
+func TestRepository_ImportCoupon(t *testing.T) {
+	db, teardown, err := helpers.NewTestDB()
+	if err != nil {
+		assert.Nil(t, err)
+	}
+	defer teardown()
+
+	r := createCouponRepo(t, db)
+
+	t.Run("it should import the coupon", func(t *testing.T) {
+		res, err := r.ImportCoupon(context.Background(), repo.NewTx(db), coupon.New(coupon.CreateRequest{Name: "FREE30", Code: "FREE30", Amount: 30}))
+		assert.Nil(t, err)
+		assert.Equal(t, "FREE30", res.Code)
+	})
+
+	t.Run("it should keep the transaction usable when the code already exists", func(t *testing.T) {
+		_, err := r.ImportCoupon(context.Background(), repo.NewTx(db), coupon.New(coupon.CreateRequest{Name: "FREE30", Code: "FREE30", Amount: 30}))
+		assert.Equal(t, coupon.ErrCouponCodeAlreadyExists, err)
+
+		_, err = r.ImportCoupon(context.Background(), repo.NewTx(db), coupon.New(coupon.CreateRequest{Name: "FREE40", Code: "FREE40", Amount: 40}))
+		assert.Nil(t, err)
+	})
+}
+
+func TestRepository_ExportCoupons(t *testing.T) {
+	db, teardown, err := helpers.NewTestDB()
+	if err != nil {
+		assert.Nil(t, err)
+	}
+	defer teardown()
+
+	r := createCouponRepo(t, db)
+	createdCoupon := createCoupon(t, db, r)
+	deletedCoupon, err := r.CreateCoupon(context.Background(), repo.NewTx(db), coupon.New(coupon.CreateRequest{Name: "deleted", Amount: 10}))
+	assert.Nil(t, err)
+	assert.Nil(t, r.DeleteCoupon(context.Background(), repo.NewTx(db), deletedCoupon))
+
+	var exported []coupon.Coupon
+	err = r.ExportCoupons(context.Background(), func(c coupon.Coupon) error {
+		exported = append(exported, c)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, exported, 1)
+	assert.Equal(t, createdCoupon.ID, exported[0].ID)
+}
+
 func createCouponRepo(t *testing.T, db *gorm.DB) coupon.Repository {
 	r, err := repo.NewCouponRepository(db)
 	if err != nil {
@@ -178,8 +334,8 @@ func createCouponRepo(t *testing.T, db *gorm.DB) coupon.Repository {
 	return r
 }
 
-func createCoupon(t *testing.T, r coupon.Repository) *coupon.Coupon {
-	c, err := r.CreateCoupon(testCoupon)
+func createCoupon(t *testing.T, db *gorm.DB, r coupon.Repository) *coupon.Coupon {
+	c, err := r.CreateCoupon(context.Background(), repo.NewTx(db), testCoupon)
 	if err != nil {
 		assert.Nil(t, err)
 	}
@@ -0,0 +1,13 @@
+package repo
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+// NewTx wraps the test db, already within a transaction,
+// so it can be given to the repository methods
+func NewTx(db *gorm.DB) transaction.Tx {
+	return &gormTx{db: db}
+}
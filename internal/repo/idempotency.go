@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/idempotency"
+)
+
+// idempotencyKeyTable is the table name for the idempotency keys
+const idempotencyKeyTable = "schwarz.idempotency_key"
+
+type idempotencyStore struct {
+	db *gorm.DB
+}
+
+// NewIdempotencyStore returns an idempotency.Store keeping the keys in postgres
+func NewIdempotencyStore(db *gorm.DB) (idempotency.Store, error) {
+	if db == nil {
+		return nil, ErrMissingDB
+	}
+	return &idempotencyStore{
+		db: db,
+	}, nil
+}
+
+// Reserve stores rec as in progress unless its key is already stored and was
+// reserved after expiredBefore, in that case the stored record is returned.
+// A key still in progress reserved before abandonedBefore is taken over
+func (s idempotencyStore) Reserve(ctx context.Context, rec idempotency.Record, expiredBefore time.Time, abandonedBefore time.Time) (*idempotency.Record, error) {
+	// a single statement, so concurrent requests with the same key can not both reserve it
+	res := s.db.WithContext(ctx).Exec(
+		"INSERT INTO "+idempotencyKeyTable+" (scope, key, fingerprint, created_at) VALUES (?, ?, ?, ?) "+
+			"ON CONFLICT (scope, key) DO UPDATE SET fingerprint = EXCLUDED.fingerprint, status_code = 0, "+
+			"header = NULL, body = NULL, created_at = EXCLUDED.created_at, completed_at = NULL "+
+			"WHERE "+idempotencyKeyTable+".created_at < ? "+
+			"OR ("+idempotencyKeyTable+".status_code = 0 AND "+idempotencyKeyTable+".created_at < ?)",
+		rec.Scope, rec.Key, rec.Fingerprint, rec.CreatedAt, expiredBefore, abandonedBefore,
+	)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	var stored idempotency.Record
+	if err := s.db.WithContext(ctx).Table(idempotencyKeyTable).
+		Where("scope = ? AND key = ?", rec.Scope, rec.Key).
+		First(&stored).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// released meanwhile, the client can retry it
+			return nil, idempotency.ErrRequestInProgress
+		}
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// Complete records the response of a reserved key
+func (s idempotencyStore) Complete(ctx context.Context, rec idempotency.Record) error {
+	return s.db.WithContext(ctx).Table(idempotencyKeyTable).
+		Where("scope = ? AND key = ? AND fingerprint = ? AND status_code = 0", rec.Scope, rec.Key, rec.Fingerprint).
+		Updates(map[string]interface{}{
+			"status_code":  rec.StatusCode,
+			"header":       rec.Header,
+			"body":         rec.Body,
+			"completed_at": rec.CompletedAt,
+		}).Error
+}
+
+// Release drops a key still in progress, so its request can be retried
+func (s idempotencyStore) Release(ctx context.Context, scope string, key string) error {
+	return s.db.WithContext(ctx).
+		Exec("DELETE FROM "+idempotencyKeyTable+" WHERE scope = ? AND key = ? AND status_code = 0", scope, key).
+		Error
+}
+
+// Sweep drops the keys reserved before expiredBefore
+func (s idempotencyStore) Sweep(ctx context.Context, expiredBefore time.Time) error {
+	return s.db.WithContext(ctx).
+		Exec("DELETE FROM "+idempotencyKeyTable+" WHERE created_at < ?", expiredBefore).
+		Error
+}
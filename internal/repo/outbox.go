@@ -0,0 +1,145 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+const (
+	// outboxTable is the table name for the outbox events
+	outboxTable = "schwarz.outbox"
+	// outboxClaimLock is the key of the advisory lock serializing the claims of the relays
+	outboxClaimLock = 0x0b0c5
+)
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+// NewOutboxRepository returns an outbox.Repository keeping the events in postgres
+func NewOutboxRepository(db *gorm.DB) (outbox.Repository, error) {
+	if db == nil {
+		return nil, ErrMissingDB
+	}
+	return &outboxRepository{
+		db: db,
+	}, nil
+}
+
+// Add stores the events within the transaction of the state change producing them
+func (or outboxRepository) Add(ctx context.Context, tx transaction.Tx, events ...outbox.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	db, err := txDB(ctx, tx)
+	if err != nil {
+		return err
+	}
+	return db.Table(outboxTable).Create(&events).Error
+}
+
+// Pending returns up to limit events not published yet and due at the given
+// time, in the order they were stored. The events of an aggregate are held
+// back while an earlier event of it is not due
+func (or outboxRepository) Pending(ctx context.Context, due time.Time, limit int) ([]outbox.Event, error) {
+	var result []outbox.Event
+	if err := pending(or.db.WithContext(ctx), due, limit).Find(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Claim returns the events Pending would return and keeps them from the
+// other relays until the given time. The claims take a transaction level
+// advisory lock, so a claim sees the events delayed by the previous ones,
+// and it is only held while the events are read and delayed
+func (or outboxRepository) Claim(ctx context.Context, due, until time.Time, limit int) ([]outbox.Event, error) {
+	var result []outbox.Event
+	err := or.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", outboxClaimLock).Error; err != nil {
+			return err
+		}
+		if err := pending(tx, due, limit).Find(&result).Error; err != nil {
+			return err
+		}
+		if len(result) == 0 {
+			return nil
+		}
+		ids := make([]uuid.UUID, 0, len(result))
+		for _, e := range result {
+			ids = append(ids, e.ID)
+		}
+		return tx.Table(outboxTable).Where("id IN ?", ids).Update("next_attempt_at", until).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range result {
+		result[i].NextAttemptAt = until
+	}
+	return result, nil
+}
+
+// Release makes a claimed event due again at the given time
+func (or outboxRepository) Release(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return or.update(ctx, id, map[string]interface{}{
+		"next_attempt_at": at,
+	})
+}
+
+// MarkPublished records the delivery of an event
+func (or outboxRepository) MarkPublished(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return or.update(ctx, id, map[string]interface{}{
+		"published_at": at,
+	})
+}
+
+// MarkFailed records a failed delivery of an event, delivered again once next is reached
+func (or outboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, next time.Time, reason string) error {
+	return or.update(ctx, id, map[string]interface{}{
+		"attempts":        gorm.Expr("attempts + 1"),
+		"next_attempt_at": next,
+		"last_error":      reason,
+	})
+}
+
+// MarkDead records the last failed delivery of an event that ran out of
+// attempts, it is kept for inspection but never delivered again
+func (or outboxRepository) MarkDead(ctx context.Context, id uuid.UUID, at time.Time, reason string) error {
+	return or.update(ctx, id, map[string]interface{}{
+		"attempts":   gorm.Expr("attempts + 1"),
+		"dead_at":    at,
+		"last_error": reason,
+	})
+}
+
+// update sets the given columns of the event with the given ID
+func (or outboxRepository) update(ctx context.Context, id uuid.UUID, columns map[string]interface{}) error {
+	res := or.db.WithContext(ctx).Table(outboxTable).Where("id = ?", id).Updates(columns)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return outbox.ErrEventNotFound
+	}
+	return nil
+}
+
+// pending selects up to limit events not published yet and due at the given
+// time in the order they were stored, holding back the aggregates of the
+// events not due
+func pending(db *gorm.DB, due time.Time, limit int) *gorm.DB {
+	return db.Table(outboxTable+" AS pending").
+		Where("pending.published_at IS NULL AND pending.dead_at IS NULL AND pending.next_attempt_at <= ?", due).
+		Where("NOT EXISTS (SELECT 1 FROM "+outboxTable+" AS earlier "+
+			"WHERE earlier.aggregate_id = pending.aggregate_id AND earlier.seq < pending.seq "+
+			"AND earlier.published_at IS NULL AND earlier.dead_at IS NULL AND earlier.next_attempt_at > ?)", due).
+		Order("pending.seq").
+		Limit(limit)
+}
@@ -1,6 +1,7 @@
 package repo
 
 import (
+	"context"
 	"errors"
 
 	"github.com/google/uuid"
@@ -9,16 +10,11 @@ import (
 
 	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
 	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
 )
 
-var (
-	// ErrMissingDB used when DB is nil
-	ErrMissingDB = internalErrors.NewNotFound("DB connection is missing")
-	// ErrShoppingCartsNotFound used when there are no shopping carts
-	ErrShoppingCartsNotFound = internalErrors.NewNotFound("shopping carts not found")
-	// ErrShoppingCartNotFound used when there is no shopping cart
-	ErrShoppingCartNotFound = internalErrors.NewNotFound("shopping cart not found")
-)
+// ErrMissingDB used when DB is nil
+var ErrMissingDB = internalErrors.NewNotFound("DB connection is missing")
 
 // shoppingCartTable is the table name for the shopping cart model
 const shoppingCartTable = "schwarz.shopping_cart"
@@ -36,36 +32,44 @@ func NewShoppingCarRepository(db *gorm.DB) (shoppingcart.Repository, error) {
 	}, nil
 }
 
-// CreateShoppingCart will create a new shopping cart
-func (sc shoppingRepository) CreateShoppingCart(shoppingCart *shoppingcart.ShoppingCart) (*shoppingcart.ShoppingCart, error) {
-	if err := sc.db.Table(shoppingCartTable).Create(&shoppingCart).Error; err != nil {
+// CreateShoppingCart will create a new shopping cart within the given transaction
+func (sc shoppingRepository) CreateShoppingCart(ctx context.Context, tx transaction.Tx, shoppingCart *shoppingcart.ShoppingCart) (*shoppingcart.ShoppingCart, error) {
+	db, err := txDB(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Table(shoppingCartTable).Create(&shoppingCart).Error; err != nil {
 		return nil, err
 	}
 	return shoppingCart, nil
 }
 
 // ListShoppingCarts returns a list of shopping carts
-func (sc shoppingRepository) ListShoppingCarts() ([]shoppingcart.ShoppingCart, error) {
+func (sc shoppingRepository) ListShoppingCarts(ctx context.Context) ([]shoppingcart.ShoppingCart, error) {
 	var result []shoppingcart.ShoppingCart
-	if err := sc.db.Table(shoppingCartTable).Find(&result).Error; err != nil {
+	if err := sc.db.WithContext(ctx).Table(shoppingCartTable).Find(&result).Error; err != nil {
 		return nil, err
 	}
 	if len(result) == 0 {
-		return nil, ErrShoppingCartsNotFound
+		return nil, shoppingcart.ErrShoppingCartsNotFound
 	}
 	return result, nil
 }
 
 // GetShoppingCartForUpdate returns a shopping cart and it will lock the row in order to update it
-func (sc shoppingRepository) GetShoppingCartForUpdate(tx *gorm.DB, scID uuid.UUID) (*shoppingcart.ShoppingCart, error) {
-	var result shoppingcart.ShoppingCart
+func (sc shoppingRepository) GetShoppingCartForUpdate(ctx context.Context, tx transaction.Tx, scID uuid.UUID) (*shoppingcart.ShoppingCart, error) {
+	db, err := txDB(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := tx.Table(shoppingCartTable).
+	var result shoppingcart.ShoppingCart
+	if err := db.Table(shoppingCartTable).
 		Where(shoppingcart.ShoppingCart{ID: scID}).
 		Clauses(clause.Locking{Strength: "UPDATE"}).
 		First(&result).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrShoppingCartNotFound
+			return nil, shoppingcart.ErrShoppingCartNotFound
 		}
 		return nil, err
 	}
@@ -73,22 +77,43 @@ func (sc shoppingRepository) GetShoppingCartForUpdate(tx *gorm.DB, scID uuid.UUI
 	return &result, nil
 }
 
-// UpdateShoppingCart updates shopping cart entity
-func (sc shoppingRepository) UpdateShoppingCart(tx *gorm.DB, shoppingCart *shoppingcart.ShoppingCart) (*shoppingcart.ShoppingCart, error) {
-	if err := tx.Table(shoppingCartTable).Save(&shoppingCart).Error; err != nil {
+// UpdateShoppingCart updates shopping cart entity increasing its version, it fails
+// with ErrShoppingCartVersionConflict if the stored version is not the given one
+func (sc shoppingRepository) UpdateShoppingCart(ctx context.Context, tx transaction.Tx, shoppingCart *shoppingcart.ShoppingCart) (*shoppingcart.ShoppingCart, error) {
+	db, err := txDB(ctx, tx)
+	if err != nil {
 		return nil, err
 	}
-	return shoppingCart, nil
-}
-
-func (sc *shoppingRepository) BeginTransaction() *gorm.DB {
-	return sc.db.Begin()
-}
 
-func (sc *shoppingRepository) CommitTransaction(tx *gorm.DB) error {
-	return tx.Commit().Error
+	current := shoppingCart.Version
+	shoppingCart.Version++
+	res := db.Table(shoppingCartTable).
+		Where("version = ?", current).
+		Select("*").
+		Omit("created_at").
+		Updates(shoppingCart)
+	if res.Error != nil {
+		shoppingCart.Version = current
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		shoppingCart.Version = current
+		return nil, shoppingcart.ErrShoppingCartVersionConflict
+	}
+	return shoppingCart, nil
 }
 
-func (sc *shoppingRepository) RollbackTransaction(tx *gorm.DB) error {
-	return tx.Rollback().Error
+// CountCustomerCouponUses returns how many shopping carts of a customer have the given coupon applied
+func (sc shoppingRepository) CountCustomerCouponUses(ctx context.Context, tx transaction.Tx, customerID uuid.UUID, couponID uuid.UUID) (int64, error) {
+	db, err := txDB(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	if err := db.Table(shoppingCartTable).
+		Where("customer_id = ? AND coupon_id = ?", customerID, couponID).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
 }
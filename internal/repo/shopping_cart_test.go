@@ -1,6 +1,7 @@
 package repo_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -33,7 +34,7 @@ func TestRepository_CreateShoppingCart(t *testing.T) {
 	r := createShoppingCartRepo(t, db)
 
 	t.Run("it should create the shopping cart", func(t *testing.T) {
-		res, err := r.CreateShoppingCart(testShoppingCart)
+		res, err := r.CreateShoppingCart(context.Background(), repo.NewTx(db), testShoppingCart)
 		assert.Nil(t, err)
 		assert.Equal(t, testShoppingCart.ID, res.ID)
 		assert.Equal(t, testShoppingCart.Amount, res.Amount)
@@ -45,7 +46,7 @@ func TestRepository_CreateShoppingCart(t *testing.T) {
 	t.Run("it should fail if the shopping cart already exists", func(t *testing.T) {
 		updatedShoppingCart := testShoppingCart
 		updatedShoppingCart.Amount = 30
-		_, err := r.CreateShoppingCart(updatedShoppingCart)
+		_, err := r.CreateShoppingCart(context.Background(), repo.NewTx(db), updatedShoppingCart)
 		assert.NotNil(t, err)
 	})
 }
@@ -56,7 +57,7 @@ func TestRepository_ListShoppingCarts(t *testing.T) {
 		expectedLen   int
 	}{
 		"when the are no shopping carts": {
-			expectedError: repo.ErrShoppingCartsNotFound,
+			expectedError: shoppingcart.ErrShoppingCartsNotFound,
 			expectedLen:   0,
 		},
 		"when shopping cart exists": {
@@ -76,9 +77,9 @@ func TestRepository_ListShoppingCarts(t *testing.T) {
 			r := createShoppingCartRepo(t, db)
 
 			if tc.expectedLen != 0 {
-				createShoppingCart(t, r)
+				createShoppingCart(t, db, r)
 			}
-			res, err := r.ListShoppingCarts()
+			res, err := r.ListShoppingCarts(context.Background())
 			assert.Equal(t, tc.expectedError, err)
 			if res != nil {
 				assert.Len(t, res, tc.expectedLen)
@@ -97,7 +98,7 @@ func TestRepository_GetShoppingCartForUpdate(t *testing.T) {
 	defer teardown()
 
 	r := createShoppingCartRepo(t, db)
-	createdShoppingCart := createShoppingCart(t, r)
+	createdShoppingCart := createShoppingCart(t, db, r)
 
 	testCases := map[string]struct {
 		expectedError        error
@@ -105,7 +106,7 @@ func TestRepository_GetShoppingCartForUpdate(t *testing.T) {
 		id                   uuid.UUID
 	}{
 		"when there is no shopping cart": {
-			expectedError:        repo.ErrShoppingCartNotFound,
+			expectedError:        shoppingcart.ErrShoppingCartNotFound,
 			expectedShoppingCart: nil,
 			id:                   uuid.New(),
 		},
@@ -118,7 +119,7 @@ func TestRepository_GetShoppingCartForUpdate(t *testing.T) {
 
 	for name, tc := range testCases {
 		t.Run(name, func(t *testing.T) {
-			res, err := r.GetShoppingCartForUpdate(db, tc.id)
+			res, err := r.GetShoppingCartForUpdate(context.Background(), repo.NewTx(db), tc.id)
 			assert.Equal(t, tc.expectedError, err)
 			if res != nil {
 				assert.Equal(t, tc.expectedShoppingCart.ID, res.ID)
@@ -138,7 +139,7 @@ func TestRepository_UpdateShoppingCart(t *testing.T) {
 
 	r := createShoppingCartRepo(t, db)
 
-	createdShoppingCart := createShoppingCart(t, r)
+	createdShoppingCart := createShoppingCart(t, db, r)
 	testCases := map[string]struct {
 		expectedError  error
 		expectedAmount float32
@@ -151,7 +152,7 @@ func TestRepository_UpdateShoppingCart(t *testing.T) {
 	for name, tc := range testCases {
 		t.Run(name, func(t *testing.T) {
 			createdShoppingCart.Amount = 100
-			res, err := r.UpdateShoppingCart(db, createdShoppingCart)
+			res, err := r.UpdateShoppingCart(context.Background(), repo.NewTx(db), createdShoppingCart)
 			assert.Equal(t, tc.expectedError, err)
 			if res != nil {
 				assert.Equal(t, tc.expectedAmount, res.Amount)
@@ -162,6 +163,57 @@ func TestRepository_UpdateShoppingCart(t *testing.T) {
 	}
 }
 
+func TestRepository_CountCustomerCouponUses(t *testing.T) {
+	db, teardown, err := helpers.NewTestDB()
+	if err != nil {
+		assert.Nil(t, err)
+	}
+	defer teardown()
+
+	r := createShoppingCartRepo(t, db)
+
+	customerID := uuid.New()
+	usedCouponID := uuid.New()
+	_, err = r.CreateShoppingCart(context.Background(), repo.NewTx(db), &shoppingcart.ShoppingCart{
+		ID:         uuid.New(),
+		CustomerID: customerID,
+		CouponID:   usedCouponID,
+		Amount:     10,
+		Items:      shoppingcart.Items{shoppingcart.Item{Price: 10}},
+	})
+	assert.Nil(t, err)
+
+	testCases := map[string]struct {
+		customerID    uuid.UUID
+		couponID      uuid.UUID
+		expectedCount int64
+	}{
+		"when the customer used the coupon": {
+			customerID:    customerID,
+			couponID:      usedCouponID,
+			expectedCount: 1,
+		},
+		"when the customer did not use the coupon": {
+			customerID:    customerID,
+			couponID:      uuid.New(),
+			expectedCount: 0,
+		},
+		"when another customer used the coupon": {
+			customerID:    uuid.New(),
+			couponID:      usedCouponID,
+			expectedCount: 0,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			res, err := r.CountCustomerCouponUses(context.Background(), repo.NewTx(db), tc.customerID, tc.couponID)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedCount, res)
+		})
+	}
+}
+
 func createShoppingCartRepo(t *testing.T, db *gorm.DB) shoppingcart.Repository {
 	r, err := repo.NewShoppingCarRepository(db)
 	if err != nil {
@@ -170,8 +222,8 @@ func createShoppingCartRepo(t *testing.T, db *gorm.DB) shoppingcart.Repository {
 	return r
 }
 
-func createShoppingCart(t *testing.T, r shoppingcart.Repository) *shoppingcart.ShoppingCart {
-	sc, err := r.CreateShoppingCart(testShoppingCart)
+func createShoppingCart(t *testing.T, db *gorm.DB, r shoppingcart.Repository) *shoppingcart.ShoppingCart {
+	sc, err := r.CreateShoppingCart(context.Background(), repo.NewTx(db), testShoppingCart)
 	if err != nil {
 		assert.Nil(t, err)
 	}
@@ -0,0 +1,72 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"gorm.io/gorm"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+)
+
+// gormTx is the transaction.Tx handed to the postgres repositories
+type gormTx struct {
+	db *gorm.DB
+}
+
+type transactionManager struct {
+	db *gorm.DB
+}
+
+// NewTransactionManager builds a new transaction manager
+// for the postgres repositories
+func NewTransactionManager(db *gorm.DB) (transaction.Manager, error) {
+	if db == nil {
+		return nil, ErrMissingDB
+	}
+	return &transactionManager{db: db}, nil
+}
+
+// WithinTx starts a transaction and runs fn with it. The transaction is
+// committed when fn succeeds and rolled back when it returns an error or
+// panics. Errors returned by fn are given back unchanged. Every call starts
+// a transaction of its own, a call made within fn does not join the outer one
+func (tm *transactionManager) WithinTx(ctx context.Context, fn func(tx transaction.Tx) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			// gorm already rolled back the transaction before the panic reached us
+			slog.Error(fmt.Sprintf("transaction panicked: %v\n%s", r, debug.Stack()))
+			err = transaction.ErrTxPanicked
+		}
+	}()
+
+	var started bool
+	var fnErr error
+	err = tm.db.WithContext(ctx).Transaction(func(db *gorm.DB) error {
+		started = true
+		fnErr = fn(&gormTx{db: db})
+		return fnErr
+	})
+	switch {
+	case err == nil || fnErr != nil:
+		return err
+	case !started:
+		slog.Error(fmt.Sprintf("beginning transaction: %s", err))
+		return transaction.ErrBeginTx
+	default:
+		slog.Error(fmt.Sprintf("committing transaction: %s", err))
+		return transaction.ErrCommitTx
+	}
+}
+
+// txDB returns the gorm transaction behind the given transaction.Tx
+// bound to the given context
+func txDB(ctx context.Context, tx transaction.Tx) (*gorm.DB, error) {
+	gtx, ok := tx.(*gormTx)
+	if !ok || gtx == nil {
+		return nil, transaction.ErrInvalidTx
+	}
+	return gtx.db.WithContext(ctx), nil
+}
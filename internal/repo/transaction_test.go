@@ -0,0 +1,97 @@
+package repo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	"github.com/nachoconques0/schwarz-challenge/internal/helpers"
+	"github.com/nachoconques0/schwarz-challenge/internal/repo"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionManager_WithinTx(t *testing.T) {
+	errUnitOfWork := errors.New("unit of work failed")
+
+	testCases := map[string]struct {
+		fn            func(r coupon.Repository, c *coupon.Coupon) func(transaction.Tx) error
+		expectedError error
+		expectedName  string
+	}{
+		"commits when the unit of work succeeds": {
+			fn: func(r coupon.Repository, c *coupon.Coupon) func(transaction.Tx) error {
+				return func(tx transaction.Tx) error {
+					c.Name = "committed"
+					_, err := r.UpdateCoupon(context.Background(), tx, c)
+					return err
+				}
+			},
+			expectedError: nil,
+			expectedName:  "committed",
+		},
+		"rolls back when the unit of work fails": {
+			fn: func(r coupon.Repository, c *coupon.Coupon) func(transaction.Tx) error {
+				return func(tx transaction.Tx) error {
+					c.Name = "rolled back"
+					if _, err := r.UpdateCoupon(context.Background(), tx, c); err != nil {
+						return err
+					}
+					return errUnitOfWork
+				}
+			},
+			expectedError: errUnitOfWork,
+			expectedName:  testCoupon.Name,
+		},
+		"rolls back when the unit of work panics": {
+			fn: func(r coupon.Repository, c *coupon.Coupon) func(transaction.Tx) error {
+				return func(tx transaction.Tx) error {
+					c.Name = "rolled back"
+					if _, err := r.UpdateCoupon(context.Background(), tx, c); err != nil {
+						return err
+					}
+					panic("unexpected")
+				}
+			},
+			expectedError: transaction.ErrTxPanicked,
+			expectedName:  testCoupon.Name,
+		},
+	}
+
+	for name, tc := range testCases {
+		db, teardown, err := helpers.NewTestDB()
+		if err != nil {
+			assert.Nil(t, err)
+		}
+		defer teardown()
+
+		r := createCouponRepo(t, db)
+		createdCoupon := createCoupon(t, db, r)
+		tm, err := repo.NewTransactionManager(db)
+		assert.Nil(t, err)
+
+		t.Run(name, func(t *testing.T) {
+			toUpdate := *createdCoupon
+			err := tm.WithinTx(context.Background(), tc.fn(r, &toUpdate))
+			assert.Equal(t, tc.expectedError, err)
+
+			res, err := r.GetCouponForUpdate(context.Background(), repo.NewTx(db), createdCoupon.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedName, res.Name)
+		})
+	}
+}
+
+func TestTransactionManager_InvalidTx(t *testing.T) {
+	db, teardown, err := helpers.NewTestDB()
+	if err != nil {
+		assert.Nil(t, err)
+	}
+	defer teardown()
+
+	r := createCouponRepo(t, db)
+	_, err = r.GetCouponForUpdate(context.Background(), nil, uuid.New())
+	assert.Equal(t, transaction.ErrInvalidTx, err)
+}
@@ -0,0 +1,239 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
+)
+
+const (
+	// webhookSubscriptionTable is the table name for the webhook subscriptions
+	webhookSubscriptionTable = "schwarz.webhook_subscription"
+	// webhookDeliveryTable is the table name for the webhook deliveries
+	webhookDeliveryTable = "schwarz.webhook_delivery"
+)
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookRepository returns a webhook.Repository keeping the subscriptions and deliveries in postgres
+func NewWebhookRepository(db *gorm.DB) (webhook.Repository, error) {
+	if db == nil {
+		return nil, ErrMissingDB
+	}
+	return &webhookRepository{
+		db: db,
+	}, nil
+}
+
+// CreateSubscription stores a new subscription
+func (wr webhookRepository) CreateSubscription(ctx context.Context, s *webhook.Subscription) (*webhook.Subscription, error) {
+	if err := wr.db.WithContext(ctx).Table(webhookSubscriptionTable).Create(s).Error; err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetSubscription returns a subscription
+func (wr webhookRepository) GetSubscription(ctx context.Context, id uuid.UUID) (*webhook.Subscription, error) {
+	var result webhook.Subscription
+	if err := wr.db.WithContext(ctx).Table(webhookSubscriptionTable).
+		Where("id = ?", id).
+		First(&result).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, webhook.ErrSubscriptionNotFound
+		}
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListSubscriptions returns every subscription
+func (wr webhookRepository) ListSubscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	result := []webhook.Subscription{}
+	if err := wr.db.WithContext(ctx).Table(webhookSubscriptionTable).
+		Order("created_at").
+		Find(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListSubscriptionsForEvent returns the subscriptions receiving the given event type
+func (wr webhookRepository) ListSubscriptionsForEvent(ctx context.Context, eventType string) ([]webhook.Subscription, error) {
+	filter, err := json.Marshal([]string{eventType})
+	if err != nil {
+		return nil, err
+	}
+	var result []webhook.Subscription
+	if err := wr.db.WithContext(ctx).Table(webhookSubscriptionTable).
+		Where("event_types @> ?::jsonb", string(filter)).
+		Find(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// UpdateSubscription updates a subscription
+func (wr webhookRepository) UpdateSubscription(ctx context.Context, s *webhook.Subscription) (*webhook.Subscription, error) {
+	res := wr.db.WithContext(ctx).Table(webhookSubscriptionTable).
+		Where("id = ?", s.ID).
+		Select("url", "event_types", "secret").
+		Updates(s)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, webhook.ErrSubscriptionNotFound
+	}
+	return wr.GetSubscription(ctx, s.ID)
+}
+
+// DeleteSubscription deletes a subscription, its deliveries are deleted by the foreign key
+func (wr webhookRepository) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	res := wr.db.WithContext(ctx).Table(webhookSubscriptionTable).
+		Where("id = ?", id).
+		Delete(&webhook.Subscription{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return webhook.ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// AddDeliveries stores the given deliveries, skipping the events
+// already delivered to the same subscription
+func (wr webhookRepository) AddDeliveries(ctx context.Context, deliveries ...webhook.Delivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return wr.db.WithContext(ctx).Table(webhookDeliveryTable).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&deliveries).Error
+}
+
+// PendingDeliveries returns up to limit pending deliveries due at the given moment
+func (wr webhookRepository) PendingDeliveries(ctx context.Context, now time.Time, limit int) ([]webhook.Delivery, error) {
+	var result []webhook.Delivery
+	if err := wr.db.WithContext(ctx).Table(webhookDeliveryTable).
+		Where("status = ? AND next_attempt_at <= ?", webhook.StatusPending, now).
+		Order("next_attempt_at, created_at").
+		Limit(limit).
+		Find(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ClaimDeliveries returns up to limit pending deliveries due at the given
+// moment, due again at until unless marked before. The rows claimed by a
+// concurrent call are skipped instead of waited for
+func (wr webhookRepository) ClaimDeliveries(ctx context.Context, now, until time.Time, limit int) ([]webhook.Delivery, error) {
+	var result []webhook.Delivery
+	err := wr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table(webhookDeliveryTable).
+			Where("status = ? AND next_attempt_at <= ?", webhook.StatusPending, now).
+			Order("next_attempt_at, created_at").
+			Limit(limit).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Find(&result).Error; err != nil {
+			return err
+		}
+		if len(result) == 0 {
+			return nil
+		}
+		ids := make([]uuid.UUID, len(result))
+		for i := range result {
+			ids[i] = result[i].ID
+			result[i].NextAttemptAt = until
+		}
+		return tx.Table(webhookDeliveryTable).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", until).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// MarkDelivered records the delivery was accepted
+func (wr webhookRepository) MarkDelivered(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return wr.updateDelivery(ctx, id, map[string]interface{}{
+		"status":       webhook.StatusDelivered,
+		"delivered_at": at,
+	})
+}
+
+// MarkFailed records a failed delivery, sent again once next is reached
+func (wr webhookRepository) MarkFailed(ctx context.Context, id uuid.UUID, next time.Time, reason string) error {
+	return wr.updateDelivery(ctx, id, map[string]interface{}{
+		"attempts":        gorm.Expr("attempts + 1"),
+		"next_attempt_at": next,
+		"last_error":      reason,
+	})
+}
+
+// MarkDead records a failed delivery that is not sent anymore
+func (wr webhookRepository) MarkDead(ctx context.Context, id uuid.UUID, reason string) error {
+	return wr.updateDelivery(ctx, id, map[string]interface{}{
+		"status":     webhook.StatusDead,
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": reason,
+	})
+}
+
+// ListDeadLetters returns the dead deliveries
+func (wr webhookRepository) ListDeadLetters(ctx context.Context) ([]webhook.Delivery, error) {
+	result := []webhook.Delivery{}
+	if err := wr.db.WithContext(ctx).Table(webhookDeliveryTable).
+		Where("status = ?", webhook.StatusDead).
+		Order("created_at").
+		Find(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RetryDeadLetter makes a dead delivery pending again, due at the given moment
+func (wr webhookRepository) RetryDeadLetter(ctx context.Context, id uuid.UUID, at time.Time) (*webhook.Delivery, error) {
+	var result webhook.Delivery
+	res := wr.db.WithContext(ctx).Table(webhookDeliveryTable).
+		Model(&result).
+		Clauses(clause.Returning{}).
+		Where("id = ? AND status = ?", id, webhook.StatusDead).
+		Updates(map[string]interface{}{
+			"status":          webhook.StatusPending,
+			"attempts":        0,
+			"next_attempt_at": at,
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, webhook.ErrDeliveryNotFound
+	}
+	return &result, nil
+}
+
+// updateDelivery sets the given columns of the delivery with the given ID
+func (wr webhookRepository) updateDelivery(ctx context.Context, id uuid.UUID, columns map[string]interface{}) error {
+	res := wr.db.WithContext(ctx).Table(webhookDeliveryTable).Where("id = ?", id).Updates(columns)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return webhook.ErrDeliveryNotFound
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// Package requestid carries the ID of the request being served through
+// its context, so the changes it makes can be traced back to it
+package requestid
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Header is the header carrying the request ID, forwarded by the
+// gateway or generated by the server when missing
+const Header = "X-Request-ID"
+
+// maxLength bounds the request IDs accepted from the callers
+const maxLength = 128
+
+type requestIDKey struct{}
+
+// New returns a new request ID
+func New() string {
+	return uuid.NewString()
+}
+
+// Valid checks if the given request ID, set by the caller, can be kept
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// WithID returns a copy of ctx holding the given request ID
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// FromContext returns the request ID stored in ctx, if any
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	if !ok || id == "" {
+		return "", false
+	}
+	return id, true
+}
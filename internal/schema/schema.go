@@ -0,0 +1,224 @@
+// Package schema generates the JSON schema of the request types from their
+// json and jsonschema struct tags, so the schemas validating the requests
+// at the edge never drift from the rules of the types they are decoded into.
+//
+// The jsonschema tag holds comma separated rules:
+//
+//	Name  string   `json:"name" jsonschema:"required,minLength=1"`
+//	Price float32  `json:"price" jsonschema:"required,exclusiveMinimum=0"`
+//	Types []string `json:"types" jsonschema:"minItems=1,uniqueItems,enum=a|b"`
+//
+// String rules (minLength, pattern, format and enum) given on a slice apply
+// to its items. Fields tagged jsonschema:"-" are left out, and every object
+// rejects the properties it does not define.
+package schema
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Draft is the JSON schema draft the generated schemas follow
+const Draft = "http://json-schema.org/draft-07/schema#"
+
+// ErrUnsupportedType used when a type has no JSON schema counterpart
+var ErrUnsupportedType = errors.New("unsupported type")
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+// Schema is a JSON schema, limited to the keywords the requests use
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	UniqueItems          bool               `json:"uniqueItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+}
+
+// For returns the schema of the type of v, which must be a struct
+func For(v any) (*Schema, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %v, a struct is expected", ErrUnsupportedType, t)
+	}
+	s, err := forType(t)
+	if err != nil {
+		return nil, err
+	}
+	s.Schema = Draft
+	return s, nil
+}
+
+// Marshal encodes the schema indented, the way the schema files are written
+func (s *Schema) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func forType(t reflect.Type) (*Schema, error) {
+	if t.Kind() == reflect.Pointer {
+		return forType(t.Elem())
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := forType(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Struct:
+		return forStruct(t)
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, t)
+}
+
+func forStruct(t reflect.Type) (*Schema, error) {
+	closed := false
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: &closed}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		rules := field.Tag.Get("jsonschema")
+		if name == "-" || rules == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property, err := forType(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		required, err := applyRules(property, rules)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		if required {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = property
+	}
+	return s, nil
+}
+
+// applyRules sets the rules of the tag on s, it returns whether the field is required
+func applyRules(s *Schema, tag string) (bool, error) {
+	if tag == "" {
+		return false, nil
+	}
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		// string rules on a slice apply to its items
+		target := s
+		if s.Type == "array" && s.Items != nil {
+			switch key {
+			case "minLength", "maxLength", "pattern", "format", "enum":
+				target = s.Items
+			}
+		}
+
+		var err error
+		switch key {
+		case "required":
+			required = true
+		case "uniqueItems":
+			s.UniqueItems = true
+		case "format":
+			target.Format = value
+		case "pattern":
+			target.Pattern = value
+		case "enum":
+			target.Enum = strings.Split(value, "|")
+		case "minLength":
+			target.MinLength, err = intRule(value)
+		case "maxLength":
+			target.MaxLength, err = intRule(value)
+		case "minItems":
+			s.MinItems, err = intRule(value)
+		case "maxItems":
+			s.MaxItems, err = intRule(value)
+		case "minimum":
+			s.Minimum, err = numberRule(value)
+		case "exclusiveMinimum":
+			s.ExclusiveMinimum, err = numberRule(value)
+		case "maximum":
+			s.Maximum, err = numberRule(value)
+		default:
+			return false, fmt.Errorf("unknown jsonschema rule %q", key)
+		}
+		if err != nil {
+			return false, fmt.Errorf("jsonschema rule %s: %w", key, err)
+		}
+	}
+	return required, nil
+}
+
+func intRule(value string) (*int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func numberRule(value string) (*float64, error) {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
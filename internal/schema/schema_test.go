@@ -0,0 +1,98 @@
+package schema_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/schema"
+)
+
+type line struct {
+	ID    uuid.UUID `json:"id" jsonschema:"-"`
+	Name  string    `json:"name" jsonschema:"required,minLength=1"`
+	Price float32   `json:"price,omitempty" jsonschema:"required,exclusiveMinimum=0"`
+}
+
+type order struct {
+	Lines      []line     `json:"lines" jsonschema:"required,minItems=1,maxItems=3"`
+	CustomerID uuid.UUID  `json:"customer_id,omitempty" jsonschema:"format=uuid"`
+	Tags       []string   `json:"tags,omitempty" jsonschema:"uniqueItems,enum=gift|express"`
+	Uses       *int       `json:"uses,omitempty" jsonschema:"minimum=0"`
+	PaidAt     *time.Time `json:"paid_at,omitempty"`
+	Internal   string     `json:"-"`
+	note       string
+}
+
+func TestFor(t *testing.T) {
+	one, zero, three := 1, 0.0, 3
+	closed := false
+
+	s, err := schema.For(&order{})
+	require.NoError(t, err)
+	assert.Equal(t, &schema.Schema{
+		Schema: schema.Draft,
+		Type:   "object",
+		Properties: map[string]*schema.Schema{
+			"lines": {
+				Type:     "array",
+				MinItems: &one,
+				MaxItems: &three,
+				Items: &schema.Schema{
+					Type: "object",
+					Properties: map[string]*schema.Schema{
+						"name":  {Type: "string", MinLength: &one},
+						"price": {Type: "number", ExclusiveMinimum: &zero},
+					},
+					Required:             []string{"name", "price"},
+					AdditionalProperties: &closed,
+				},
+			},
+			"customer_id": {Type: "string", Format: "uuid"},
+			"tags": {
+				Type:        "array",
+				UniqueItems: true,
+				Items:       &schema.Schema{Type: "string", Enum: []string{"gift", "express"}},
+			},
+			"uses":    {Type: "integer", Minimum: &zero},
+			"paid_at": {Type: "string", Format: "date-time"},
+		},
+		Required:             []string{"lines"},
+		AdditionalProperties: &closed,
+	}, s)
+}
+
+func TestFor_Errors(t *testing.T) {
+	testCases := map[string]struct {
+		v any
+	}{
+		"not a struct": {
+			v: "order",
+		},
+		"unsupported field": {
+			v: struct {
+				Callback func() `json:"callback"`
+			}{},
+		},
+		"unknown rule": {
+			v: struct {
+				Name string `json:"name" jsonschema:"minSize=1"`
+			}{},
+		},
+		"invalid rule value": {
+			v: struct {
+				Name string `json:"name" jsonschema:"minLength=one"`
+			}{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := schema.For(tc.v)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
+)
+
+type auditService struct {
+	repo audit.Repository
+}
+
+// NewAuditService builds a new service that
+// satisfies the audit interface
+func NewAuditService(repo audit.Repository) (audit.Service, error) {
+	if repo == nil {
+		return nil, ErrMissingAuditRepo
+	}
+	return &auditService{repo: repo}, nil
+}
+
+// ListEntries returns the audit log of an entity, oldest change first
+func (as *auditService) ListEntries(ctx context.Context, req audit.ListRequest) ([]audit.Entry, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return as.repo.List(ctx, req.Entity, req.ID)
+}
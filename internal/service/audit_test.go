@@ -0,0 +1,63 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
+	"github.com/nachoconques0/schwarz-challenge/internal/mocks"
+	"github.com/nachoconques0/schwarz-challenge/internal/service"
+)
+
+func TestAuditService_ListEntries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	auditRepo := mocks.NewMockAuditRepository(ctrl)
+	svc, err := service.NewAuditService(auditRepo)
+	assert.Nil(t, err)
+	entityID := uuid.New()
+
+	testCases := map[string]struct {
+		req           audit.ListRequest
+		mocks         func()
+		expectedError error
+	}{
+		"invalid request": {
+			req:           audit.ListRequest{Entity: "webhook", ID: entityID},
+			mocks:         func() {},
+			expectedError: audit.ErrUnknownEntity,
+		},
+		"repo fail": {
+			req: audit.ListRequest{Entity: audit.EntityCoupon, ID: entityID},
+			mocks: func() {
+				auditRepo.EXPECT().List(gomock.Any(), audit.EntityCoupon, entityID).Return(nil, errGeneric)
+			},
+			expectedError: errGeneric,
+		},
+		"success": {
+			req: audit.ListRequest{Entity: audit.EntityCoupon, ID: entityID},
+			mocks: func() {
+				auditRepo.EXPECT().List(gomock.Any(), audit.EntityCoupon, entityID).Return([]audit.Entry{{EntityID: entityID}}, nil)
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc.mocks()
+		t.Run(name, func(t *testing.T) {
+			res, err := svc.ListEntries(context.Background(), tc.req)
+			assert.Equal(t, tc.expectedError, err)
+			if err == nil {
+				assert.Len(t, res, 1)
+			}
+		})
+	}
+}
+
+func TestNewAuditService_MissingRepo(t *testing.T) {
+	_, err := service.NewAuditService(nil)
+	assert.Equal(t, service.ErrMissingAuditRepo, err)
+}
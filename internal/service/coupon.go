@@ -1,35 +1,75 @@
 package service
 
 import (
+	"context"
+	stdErrors "errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
 	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
 	"github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/etag"
+	"github.com/nachoconques0/schwarz-challenge/internal/logging"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
 )
 
 var (
 	// ErrMissingDB used when DB is nil
 	ErrMissingDB = errors.NewNotFound("DB connection is missing")
+	// ErrMissingTxManager used when the transaction manager is nil
+	ErrMissingTxManager = errors.NewInternalError("transaction manager is missing")
+	// ErrMissingOutbox used when the outbox repository is nil
+	ErrMissingOutbox = errors.NewInternalError("outbox repository is missing")
+	// ErrMissingAuditRepo used when the audit repository is nil
+	ErrMissingAuditRepo = errors.NewInternalError("audit repository is missing")
 )
 
+// errImportAborted rolls back an all or nothing import once a row fails
+var errImportAborted = stdErrors.New("import aborted")
+
 type couponService struct {
-	repo coupon.Repository
+	repo      coupon.Repository
+	txManager transaction.Manager
+	auditRepo audit.Repository
 }
 
 // NewCouponService builds a new repository that
 // satisfies the coupon interface
-func NewCouponService(repo coupon.Repository) (coupon.Service, error) {
+func NewCouponService(repo coupon.Repository, txm transaction.Manager, ar audit.Repository) (coupon.Service, error) {
+	if txm == nil {
+		return nil, ErrMissingTxManager
+	}
+	if ar == nil {
+		return nil, ErrMissingAuditRepo
+	}
 	return &couponService{
-		repo: repo,
+		repo:      repo,
+		txManager: txm,
+		auditRepo: ar,
 	}, nil
 }
 
 // CreateCoupon creates a new coupon
-func (cs *couponService) CreateCoupon(req coupon.CreateRequest) (*coupon.Coupon, error) {
+func (cs *couponService) CreateCoupon(ctx context.Context, req coupon.CreateRequest) (*coupon.Coupon, error) {
 	err := req.Validate()
 	if err != nil {
 		return nil, err
 	}
 	payload := coupon.New(req)
-	res, err := cs.repo.CreateCoupon(payload)
+	var res *coupon.Coupon
+	err = cs.txManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		created, err := cs.repo.CreateCoupon(ctx, tx, payload)
+		if err != nil {
+			return err
+		}
+		res = created
+		return cs.audit(ctx, tx, created.ID, audit.ActionCreate, nil, created)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -37,10 +77,216 @@ func (cs *couponService) CreateCoupon(req coupon.CreateRequest) (*coupon.Coupon,
 }
 
 // ListCoupons returns a list of coupons
-func (cs *couponService) ListCoupons() ([]coupon.Coupon, error) {
-	res, err := cs.repo.ListCoupons()
+func (cs *couponService) ListCoupons(ctx context.Context) ([]coupon.Coupon, error) {
+	res, err := cs.repo.ListCoupons(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// UpdateCoupon updates the name, amount or validity of a coupon
+func (cs *couponService) UpdateCoupon(ctx context.Context, couponID uuid.UUID, req coupon.UpdateRequest) (*coupon.Coupon, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return cs.modify(ctx, couponID, audit.ActionUpdate, func(tx transaction.Tx, c *coupon.Coupon) error {
+		var redemptions int64
+		if req.Amount != nil {
+			var err error
+			redemptions, err = cs.repo.CountCouponRedemptions(ctx, tx, couponID)
+			if err != nil {
+				return err
+			}
+		}
+		return c.Update(req, redemptions)
+	})
+}
+
+// DeactivateCoupon stops a coupon from being redeemed
+func (cs *couponService) DeactivateCoupon(ctx context.Context, couponID uuid.UUID) (*coupon.Coupon, error) {
+	return cs.modify(ctx, couponID, audit.ActionDeactivate, func(_ transaction.Tx, c *coupon.Coupon) error {
+		c.Deactivate(time.Now())
+		return nil
+	})
+}
+
+// ReactivateCoupon allows a deactivated coupon to be redeemed again
+func (cs *couponService) ReactivateCoupon(ctx context.Context, couponID uuid.UUID) (*coupon.Coupon, error) {
+	return cs.modify(ctx, couponID, audit.ActionReactivate, func(_ transaction.Tx, c *coupon.Coupon) error {
+		c.Reactivate()
+		return nil
+	})
+}
+
+// DeleteCoupon soft deletes a coupon
+func (cs *couponService) DeleteCoupon(ctx context.Context, couponID uuid.UUID) error {
+	return cs.txManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		c, err := cs.repo.GetCouponForUpdate(ctx, tx, couponID)
+		if err != nil {
+			return err
+		}
+		if err := etag.Check(ctx, c.Version); err != nil {
+			return err
+		}
+		before := *c
+		if err := cs.repo.DeleteCoupon(ctx, tx, c); err != nil {
+			return err
+		}
+		return cs.audit(ctx, tx, couponID, audit.ActionDelete, &before, nil)
+	})
+}
+
+// GetCouponStats returns the redemption stats of a coupon
+func (cs *couponService) GetCouponStats(ctx context.Context, couponID uuid.UUID, req coupon.StatsRequest) (*coupon.Stats, error) {
+	if req.Interval == "" {
+		req.Interval = coupon.StatsIntervalDay
+	}
+	err := req.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := cs.repo.GetCouponStats(ctx, couponID, req)
+	if err != nil {
+		return nil, err
+	}
+	res.TotalDiscount = roundAmount(res.TotalDiscount)
+	res.AverageCartValue = roundAmount(res.AverageCartValue)
+	for i := range res.Buckets {
+		res.Buckets[i].TotalDiscount = roundAmount(res.Buckets[i].TotalDiscount)
+		res.Buckets[i].AverageCartValue = roundAmount(res.Buckets[i].AverageCartValue)
+	}
+	return res, nil
+}
+
+// ImportCoupons validates and creates the given coupons. In partial mode every
+// valid row is created, in all or nothing mode nothing is created if any row fails
+func (cs *couponService) ImportCoupons(ctx context.Context, rows []coupon.ImportRow, mode string) (*coupon.ImportResult, error) {
+	if mode == "" {
+		mode = coupon.ImportModePartial
+	}
+	if mode != coupon.ImportModePartial && mode != coupon.ImportModeAllOrNothing {
+		return nil, coupon.ErrCouponInvalidImportMode
+	}
+
+	result := &coupon.ImportResult{
+		Mode:   mode,
+		Total:  len(rows),
+		Errors: []coupon.ImportError{},
+	}
+	type importable struct {
+		line   int
+		coupon *coupon.Coupon
+	}
+	toImport := make([]importable, 0, len(rows))
+	codes := make(map[string]int, len(rows))
+	for _, row := range rows {
+		err := row.Err
+		if err == nil {
+			err = row.Request.Validate()
+		}
+		if err == nil && row.Request.Code != "" {
+			if _, ok := codes[row.Request.Code]; ok {
+				err = coupon.ErrCouponCodeAlreadyExists
+			}
+			codes[row.Request.Code] = row.Line
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, importError(ctx, row.Line, err))
+			continue
+		}
+		toImport = append(toImport, importable{line: row.Line, coupon: coupon.New(row.Request)})
+	}
+	if mode == coupon.ImportModeAllOrNothing && len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	err := cs.txManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		for _, i := range toImport {
+			created, err := cs.repo.ImportCoupon(ctx, tx, i.coupon)
+			if err != nil {
+				result.Errors = append(result.Errors, importError(ctx, i.line, err))
+				if mode == coupon.ImportModeAllOrNothing {
+					return errImportAborted
+				}
+				continue
+			}
+			if err := cs.audit(ctx, tx, created.ID, audit.ActionImport, nil, created); err != nil {
+				return err
+			}
+			result.Imported++
+		}
+		return nil
+	})
+	if stdErrors.Is(err, errImportAborted) {
+		result.Imported = 0
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ExportCoupons calls the given function for every coupon
+func (cs *couponService) ExportCoupons(ctx context.Context, fn func(coupon.Coupon) error) error {
+	return cs.repo.ExportCoupons(ctx, fn)
+}
+
+// importError describes why the given line was not imported without
+// exposing unexpected internal errors
+func importError(ctx context.Context, line int, err error) coupon.ImportError {
+	var internalErr *errors.Error
+	if !stdErrors.As(err, &internalErr) || internalErr.Code >= http.StatusInternalServerError {
+		logging.FromContext(ctx).Error("importing coupon", slog.Int("line", line), slog.Any("error", err))
+		return coupon.ImportError{Line: line, Error: "coupon could not be imported"}
+	}
+	return coupon.ImportError{Line: line, Error: internalErr.Message}
+}
+
+// modify locks the given coupon, checks the If-Match version of the request,
+// applies fn to it, stores the result and audits the change as the given
+// action, all of it within the same transaction
+func (cs *couponService) modify(ctx context.Context, couponID uuid.UUID, action string, fn func(transaction.Tx, *coupon.Coupon) error) (*coupon.Coupon, error) {
+	var res *coupon.Coupon
+	err := cs.txManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		c, err := cs.repo.GetCouponForUpdate(ctx, tx, couponID)
+		if err != nil {
+			return err
+		}
+		if err := etag.Check(ctx, c.Version); err != nil {
+			return err
+		}
+		before := *c
+		if err := fn(tx, c); err != nil {
+			return err
+		}
+		res, err = cs.repo.UpdateCoupon(ctx, tx, c)
+		if err != nil {
+			return err
+		}
+		return cs.audit(ctx, tx, couponID, action, &before, res)
+	})
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
+
+// audit stores the change of a coupon in the audit log within the given transaction,
+// before or after are nil when the coupon is created or deleted
+func (cs *couponService) audit(ctx context.Context, tx transaction.Tx, couponID uuid.UUID, action string, before, after *coupon.Coupon) error {
+	entry, err := audit.NewEntry(ctx, audit.EntityCoupon, couponID, action, before, after)
+	if err != nil {
+		return err
+	}
+	return cs.auditRepo.Add(ctx, tx, entry)
+}
+
+// roundAmount rounds the given amount to cents
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
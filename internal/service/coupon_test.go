@@ -1,23 +1,31 @@
 package service_test
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
 	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
 	"github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/etag"
 	"github.com/nachoconques0/schwarz-challenge/internal/mocks"
 	"github.com/nachoconques0/schwarz-challenge/internal/service"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 )
 
 var (
 	errGeneric = errors.NewInternalError("some-error")
+	testName   = "test"
 )
 
 type testCouponService struct {
 	svc            coupon.Service
 	couponMockRepo *mocks.MockCouponRepository
+	auditMockRepo  *mocks.MockAuditRepository
 }
 
 func TestCouponService_CreateCoupon(t *testing.T) {
@@ -36,7 +44,7 @@ func TestCouponService_CreateCoupon(t *testing.T) {
 		"repo fail": {
 			req: couponReq,
 			mocks: func() {
-				ts.couponMockRepo.EXPECT().CreateCoupon(gomock.Any()).Return(nil, errGeneric)
+				ts.couponMockRepo.EXPECT().CreateCoupon(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errGeneric)
 			},
 			expectedCoupon: nil,
 			expectedError:  errGeneric,
@@ -50,10 +58,25 @@ func TestCouponService_CreateCoupon(t *testing.T) {
 			expectedCoupon: nil,
 			expectedError:  coupon.ErrCouponInvalidAmount,
 		},
+		"audit fail": {
+			req: couponReq,
+			mocks: func() {
+				ts.couponMockRepo.EXPECT().CreateCoupon(gomock.Any(), gomock.Any(), gomock.Any()).Return(expectedCoupon, nil)
+				ts.auditMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).Return(errGeneric)
+			},
+			expectedCoupon: nil,
+			expectedError:  errGeneric,
+		},
 		"success": {
 			req: couponReq,
 			mocks: func() {
-				ts.couponMockRepo.EXPECT().CreateCoupon(gomock.Any()).Return(expectedCoupon, nil)
+				ts.couponMockRepo.EXPECT().CreateCoupon(gomock.Any(), gomock.Any(), gomock.Any()).Return(expectedCoupon, nil)
+				ts.auditMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ transaction.Tx, entries ...audit.Entry) error {
+					assert.Equal(t, audit.ActionCreate, entries[0].Action)
+					assert.Equal(t, expectedCoupon.ID, entries[0].EntityID)
+					assert.Nil(t, entries[0].Before)
+					return nil
+				})
 			},
 			expectedCoupon: expectedCoupon,
 			expectedError:  nil,
@@ -63,7 +86,7 @@ func TestCouponService_CreateCoupon(t *testing.T) {
 	for name, tc := range testCases {
 		tc.mocks()
 		t.Run(name, func(t *testing.T) {
-			c, err := ts.svc.CreateCoupon(*tc.req)
+			c, err := ts.svc.CreateCoupon(context.Background(), *tc.req)
 			assert.Equal(t, tc.expectedError, err)
 			if err == nil {
 				assert.Equal(t, tc.expectedCoupon.Name, c.Name)
@@ -88,14 +111,14 @@ func TestCouponService_ListCoupons(t *testing.T) {
 	}{
 		"repo fail": {
 			mocks: func() {
-				ts.couponMockRepo.EXPECT().ListCoupons().Return(nil, errGeneric)
+				ts.couponMockRepo.EXPECT().ListCoupons(gomock.Any()).Return(nil, errGeneric)
 			},
 			expectedCoupons: []coupon.Coupon{},
 			expectedError:   errGeneric,
 		},
 		"success": {
 			mocks: func() {
-				ts.couponMockRepo.EXPECT().ListCoupons().Return([]coupon.Coupon{*c}, nil)
+				ts.couponMockRepo.EXPECT().ListCoupons(gomock.Any()).Return([]coupon.Coupon{*c}, nil)
 			},
 			expectedCoupons: []coupon.Coupon{
 				*c,
@@ -107,7 +130,7 @@ func TestCouponService_ListCoupons(t *testing.T) {
 	for name, tc := range testCases {
 		tc.mocks()
 		t.Run(name, func(t *testing.T) {
-			res, err := ts.svc.ListCoupons()
+			res, err := ts.svc.ListCoupons(context.Background())
 			assert.Equal(t, tc.expectedError, err)
 			if err == nil {
 				assert.Len(t, res, 1)
@@ -116,14 +139,296 @@ func TestCouponService_ListCoupons(t *testing.T) {
 	}
 }
 
+func TestCouponService_UpdateCoupon(t *testing.T) {
+	ts := buildCouponService(t)
+	couponID := uuid.New()
+	newName := "new name"
+	newAmount := float32(50)
+
+	testCases := map[string]struct {
+		req           coupon.UpdateRequest
+		mocks         func()
+		expectedError error
+	}{
+		"invalid data": {
+			req:           coupon.UpdateRequest{},
+			mocks:         func() {},
+			expectedError: coupon.ErrCouponEmptyUpdate,
+		},
+		"GetCouponForUpdate fails": {
+			req: coupon.UpdateRequest{Name: &newName},
+			mocks: func() {
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), couponID).Return(nil, errGeneric)
+			},
+			expectedError: errGeneric,
+		},
+		"amount of a redeemed coupon": {
+			req: coupon.UpdateRequest{Amount: &newAmount},
+			mocks: func() {
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), couponID).Return(&coupon.Coupon{ID: couponID, Amount: 10}, nil)
+				ts.couponMockRepo.EXPECT().CountCouponRedemptions(gomock.Any(), gomock.Any(), couponID).Return(int64(1), nil)
+			},
+			expectedError: coupon.ErrCouponDiscountLocked,
+		},
+		"repo update fails": {
+			req: coupon.UpdateRequest{Name: &newName},
+			mocks: func() {
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), couponID).Return(&coupon.Coupon{ID: couponID}, nil)
+				ts.couponMockRepo.EXPECT().UpdateCoupon(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errGeneric)
+			},
+			expectedError: errGeneric,
+		},
+		"success": {
+			req: coupon.UpdateRequest{Name: &newName, Amount: &newAmount},
+			mocks: func() {
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), couponID).Return(&coupon.Coupon{ID: couponID, Amount: 10}, nil)
+				ts.couponMockRepo.EXPECT().CountCouponRedemptions(gomock.Any(), gomock.Any(), couponID).Return(int64(0), nil)
+				ts.couponMockRepo.EXPECT().UpdateCoupon(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ any, c *coupon.Coupon) (*coupon.Coupon, error) {
+					return c, nil
+				})
+				ts.auditMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ transaction.Tx, entries ...audit.Entry) error {
+					assert.Equal(t, audit.ActionUpdate, entries[0].Action)
+					assert.Equal(t, couponID, entries[0].EntityID)
+					assert.Contains(t, entries[0].Diff, "name")
+					assert.Contains(t, entries[0].Diff, "amount")
+					return nil
+				})
+			},
+			expectedError: nil,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc.mocks()
+		t.Run(name, func(t *testing.T) {
+			res, err := ts.svc.UpdateCoupon(context.Background(), couponID, tc.req)
+			assert.Equal(t, tc.expectedError, err)
+			if err == nil {
+				assert.Equal(t, newName, res.Name)
+				assert.Equal(t, newAmount, res.Amount)
+			}
+		})
+	}
+}
+
+func TestCouponService_DeactivateCoupon(t *testing.T) {
+	ts := buildCouponService(t)
+	couponID := uuid.New()
+
+	t.Run("GetCouponForUpdate fails", func(t *testing.T) {
+		ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), couponID).Return(nil, errGeneric)
+
+		_, err := ts.svc.DeactivateCoupon(context.Background(), couponID)
+		assert.Equal(t, errGeneric, err)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), couponID).Return(&coupon.Coupon{ID: couponID}, nil)
+		ts.couponMockRepo.EXPECT().UpdateCoupon(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ any, c *coupon.Coupon) (*coupon.Coupon, error) {
+			return c, nil
+		})
+		ts.auditMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+		res, err := ts.svc.DeactivateCoupon(context.Background(), couponID)
+		assert.Nil(t, err)
+		assert.False(t, res.IsActive())
+	})
+}
+
+func TestCouponService_ReactivateCoupon(t *testing.T) {
+	ts := buildCouponService(t)
+	couponID := uuid.New()
+	deactivatedAt := time.Now()
+
+	ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), couponID).Return(&coupon.Coupon{
+		ID:            couponID,
+		DeactivatedAt: &deactivatedAt,
+	}, nil)
+	ts.couponMockRepo.EXPECT().UpdateCoupon(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ any, c *coupon.Coupon) (*coupon.Coupon, error) {
+		return c, nil
+	})
+	ts.auditMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+	res, err := ts.svc.ReactivateCoupon(context.Background(), couponID)
+	assert.Nil(t, err)
+	assert.True(t, res.IsActive())
+}
+
+func TestCouponService_DeleteCoupon(t *testing.T) {
+	ts := buildCouponService(t)
+	couponID := uuid.New()
+
+	t.Run("lock fail", func(t *testing.T) {
+		ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), couponID).Return(nil, errGeneric)
+		assert.Equal(t, errGeneric, ts.svc.DeleteCoupon(context.Background(), couponID))
+	})
+
+	t.Run("stale if-match version", func(t *testing.T) {
+		ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), couponID).Return(&coupon.Coupon{ID: couponID, Version: 2}, nil)
+		ctx := etag.WithIfMatch(context.Background(), 1)
+		assert.Equal(t, etag.ErrPreconditionFailed, ts.svc.DeleteCoupon(ctx, couponID))
+	})
+
+	t.Run("repo fail", func(t *testing.T) {
+		c := &coupon.Coupon{ID: couponID, Version: 1}
+		ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), couponID).Return(c, nil)
+		ts.couponMockRepo.EXPECT().DeleteCoupon(gomock.Any(), gomock.Any(), c).Return(errGeneric)
+		assert.Equal(t, errGeneric, ts.svc.DeleteCoupon(context.Background(), couponID))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		c := &coupon.Coupon{ID: couponID, Version: 1}
+		ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), couponID).Return(c, nil)
+		ts.couponMockRepo.EXPECT().DeleteCoupon(gomock.Any(), gomock.Any(), c).Return(nil)
+		ts.auditMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ transaction.Tx, entries ...audit.Entry) error {
+			assert.Equal(t, audit.ActionDelete, entries[0].Action)
+			assert.Nil(t, entries[0].After)
+			return nil
+		})
+		ctx := etag.WithIfMatch(context.Background(), 1)
+		assert.Nil(t, ts.svc.DeleteCoupon(ctx, couponID))
+	})
+}
+
+func TestCouponService_GetCouponStats(t *testing.T) {
+	ts := buildCouponService(t)
+	couponID := uuid.New()
+
+	t.Run("invalid interval", func(t *testing.T) {
+		_, err := ts.svc.GetCouponStats(context.Background(), couponID, coupon.StatsRequest{Interval: "year"})
+		assert.Equal(t, coupon.ErrCouponInvalidStatsInterval, err)
+	})
+
+	t.Run("repo fail", func(t *testing.T) {
+		ts.couponMockRepo.EXPECT().GetCouponStats(gomock.Any(), couponID, gomock.Any()).Return(nil, errGeneric)
+		_, err := ts.svc.GetCouponStats(context.Background(), couponID, coupon.StatsRequest{})
+		assert.Equal(t, errGeneric, err)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		ts.couponMockRepo.EXPECT().GetCouponStats(gomock.Any(), couponID, coupon.StatsRequest{Interval: coupon.StatsIntervalDay}).Return(&coupon.Stats{
+			CouponID:         couponID,
+			Redemptions:      3,
+			TotalDiscount:    30.004,
+			AverageCartValue: 33.3333,
+			Interval:         coupon.StatsIntervalDay,
+			Buckets: []coupon.StatsBucket{
+				{Redemptions: 3, TotalDiscount: 30.004, AverageCartValue: 33.3333},
+			},
+		}, nil)
+		res, err := ts.svc.GetCouponStats(context.Background(), couponID, coupon.StatsRequest{})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(3), res.Redemptions)
+		assert.Equal(t, 30.0, res.TotalDiscount)
+		assert.Equal(t, 33.33, res.AverageCartValue)
+		assert.Equal(t, 33.33, res.Buckets[0].AverageCartValue)
+	})
+}
+
+func TestCouponService_ImportCoupons(t *testing.T) {
+	ts := buildCouponService(t)
+	validRow := coupon.ImportRow{Line: 2, Request: coupon.CreateRequest{Name: "FREE30", Code: "FREE30", Amount: 30}}
+	otherValidRow := coupon.ImportRow{Line: 3, Request: coupon.CreateRequest{Name: "FREE40", Code: "FREE40", Amount: 40}}
+	invalidRow := coupon.ImportRow{Line: 4, Request: coupon.CreateRequest{Name: "FREE0", Amount: 0}}
+	duplicatedRow := coupon.ImportRow{Line: 5, Request: coupon.CreateRequest{Name: "FREE30", Code: "FREE30", Amount: 30}}
+	unparsedRow := coupon.ImportRow{Line: 6, Err: coupon.ErrCouponInvalidAmount}
+
+	t.Run("invalid mode", func(t *testing.T) {
+		_, err := ts.svc.ImportCoupons(context.Background(), nil, "some")
+		assert.Equal(t, coupon.ErrCouponInvalidImportMode, err)
+	})
+
+	t.Run("partial import", func(t *testing.T) {
+		ts.couponMockRepo.EXPECT().ImportCoupon(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ any, c *coupon.Coupon) (*coupon.Coupon, error) {
+			return c, nil
+		})
+		ts.auditMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+		ts.couponMockRepo.EXPECT().ImportCoupon(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, coupon.ErrCouponCodeAlreadyExists)
+
+		res, err := ts.svc.ImportCoupons(context.Background(), []coupon.ImportRow{validRow, otherValidRow, invalidRow, duplicatedRow, unparsedRow}, "")
+		assert.Nil(t, err)
+		assert.Equal(t, coupon.ImportModePartial, res.Mode)
+		assert.Equal(t, 5, res.Total)
+		assert.Equal(t, 1, res.Imported)
+		assert.Equal(t, []coupon.ImportError{
+			{Line: 4, Error: coupon.ErrCouponInvalidAmount.Message},
+			{Line: 5, Error: coupon.ErrCouponCodeAlreadyExists.Message},
+			{Line: 6, Error: coupon.ErrCouponInvalidAmount.Message},
+			{Line: 3, Error: coupon.ErrCouponCodeAlreadyExists.Message},
+		}, res.Errors)
+	})
+
+	t.Run("all or nothing import with invalid rows", func(t *testing.T) {
+		res, err := ts.svc.ImportCoupons(context.Background(), []coupon.ImportRow{validRow, invalidRow}, coupon.ImportModeAllOrNothing)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, res.Imported)
+		assert.Len(t, res.Errors, 1)
+	})
+
+	t.Run("all or nothing import failing to insert", func(t *testing.T) {
+		ts.couponMockRepo.EXPECT().ImportCoupon(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ any, c *coupon.Coupon) (*coupon.Coupon, error) {
+			return c, nil
+		})
+		ts.auditMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+		ts.couponMockRepo.EXPECT().ImportCoupon(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errGeneric)
+
+		res, err := ts.svc.ImportCoupons(context.Background(), []coupon.ImportRow{validRow, otherValidRow}, coupon.ImportModeAllOrNothing)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, res.Imported)
+		assert.Equal(t, []coupon.ImportError{{Line: 3, Error: "coupon could not be imported"}}, res.Errors)
+	})
+
+	t.Run("all or nothing import", func(t *testing.T) {
+		ts.couponMockRepo.EXPECT().ImportCoupon(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ any, c *coupon.Coupon) (*coupon.Coupon, error) {
+			return c, nil
+		}).Times(2)
+		ts.auditMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+		res, err := ts.svc.ImportCoupons(context.Background(), []coupon.ImportRow{validRow, otherValidRow}, coupon.ImportModeAllOrNothing)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, res.Imported)
+		assert.Empty(t, res.Errors)
+	})
+}
+
+func TestCouponService_ExportCoupons(t *testing.T) {
+	ts := buildCouponService(t)
+
+	ts.couponMockRepo.EXPECT().ExportCoupons(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, fn func(coupon.Coupon) error) error {
+		return fn(coupon.Coupon{Name: testName})
+	})
+
+	var exported []coupon.Coupon
+	err := ts.svc.ExportCoupons(context.Background(), func(c coupon.Coupon) error {
+		exported = append(exported, c)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, exported, 1)
+}
+
+func TestNewCouponService_MissingTxManager(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	_, err := service.NewCouponService(mocks.NewMockCouponRepository(ctrl), nil, mocks.NewMockAuditRepository(ctrl))
+	assert.Equal(t, service.ErrMissingTxManager, err)
+}
+
+func TestNewCouponService_MissingAudit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	_, err := service.NewCouponService(mocks.NewMockCouponRepository(ctrl), newTxManager(ctrl), nil)
+	assert.Equal(t, service.ErrMissingAuditRepo, err)
+}
+
 func buildCouponService(t *testing.T) testCouponService {
 	ctrl := gomock.NewController(t)
 	couponRepo := mocks.NewMockCouponRepository(ctrl)
-	svc, err := service.NewCouponService(couponRepo)
+	auditRepo := mocks.NewMockAuditRepository(ctrl)
+	svc, err := service.NewCouponService(couponRepo, newTxManager(ctrl), auditRepo)
 	assert.Nil(t, err)
 
 	return testCouponService{
 		svc:            svc,
 		couponMockRepo: couponRepo,
+		auditMockRepo:  auditRepo,
 	}
 }
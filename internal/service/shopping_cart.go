@@ -1,35 +1,76 @@
 package service
 
 import (
+	"context"
+	"time"
+
 	"github.com/google/uuid"
 
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
 	couponDomain "github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	"github.com/nachoconques0/schwarz-challenge/internal/etag"
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
 	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
 )
 
 type shoppingCartService struct {
 	shoppingCartRepo shoppingcart.Repository
 	couponRepo       couponDomain.Repository
+	txManager        transaction.Manager
+	outboxRepo       outbox.Repository
+	auditRepo        audit.Repository
 }
 
 // NewShoppingCartRepository builds a new repository that
 // satisfies the shopping cart interface
-func NewShoppingCartService(scr shoppingcart.Repository, cr couponDomain.Repository) (shoppingcart.Service, error) {
+func NewShoppingCartService(scr shoppingcart.Repository, cr couponDomain.Repository, txm transaction.Manager, obr outbox.Repository, ar audit.Repository) (shoppingcart.Service, error) {
+	if txm == nil {
+		return nil, ErrMissingTxManager
+	}
+	if obr == nil {
+		return nil, ErrMissingOutbox
+	}
+	if ar == nil {
+		return nil, ErrMissingAuditRepo
+	}
 	return &shoppingCartService{
 		shoppingCartRepo: scr,
 		couponRepo:       cr,
+		txManager:        txm,
+		outboxRepo:       obr,
+		auditRepo:        ar,
 	}, nil
 }
 
 // CreateShoppingCart will create a new shopping cart
-func (sc *shoppingCartService) CreateShoppingCart(req shoppingcart.CreateRequest) (*shoppingcart.ShoppingCart, error) {
+func (sc *shoppingCartService) CreateShoppingCart(ctx context.Context, req shoppingcart.CreateRequest) (*shoppingcart.ShoppingCart, error) {
 	err := req.Validate()
 	if err != nil {
 		return nil, err
 	}
 
 	payload := shoppingcart.New(req)
-	res, err := sc.shoppingCartRepo.CreateShoppingCart(payload)
+	var res *shoppingcart.ShoppingCart
+	err = sc.txManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		created, err := sc.shoppingCartRepo.CreateShoppingCart(ctx, tx, payload)
+		if err != nil {
+			return err
+		}
+		event, err := created.CreatedEvent()
+		if err != nil {
+			return err
+		}
+		res = created
+		if err := sc.outboxRepo.Add(ctx, tx, event); err != nil {
+			return err
+		}
+		entry, err := audit.NewEntry(ctx, audit.EntityShoppingCart, created.ID, audit.ActionCreate, nil, created)
+		if err != nil {
+			return err
+		}
+		return sc.auditRepo.Add(ctx, tx, entry)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -38,50 +79,114 @@ func (sc *shoppingCartService) CreateShoppingCart(req shoppingcart.CreateRequest
 }
 
 // ListShoppingCarts returns a shopping cart list
-func (sc *shoppingCartService) ListShoppingCarts() ([]shoppingcart.ShoppingCart, error) {
-	res, err := sc.shoppingCartRepo.ListShoppingCarts()
+func (sc *shoppingCartService) ListShoppingCarts(ctx context.Context) ([]shoppingcart.ShoppingCart, error) {
+	res, err := sc.shoppingCartRepo.ListShoppingCarts(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
-// ApplyCoupon applies a coupon code
-func (sc *shoppingCartService) ApplyCoupon(scID uuid.UUID, couponID uuid.UUID) error {
-	tx := sc.shoppingCartRepo.BeginTransaction()
+// ApplyCoupon applies a coupon code and returns the updated shopping cart
+func (sc *shoppingCartService) ApplyCoupon(ctx context.Context, scID uuid.UUID, couponID uuid.UUID) (*shoppingcart.ShoppingCart, error) {
+	var res *shoppingcart.ShoppingCart
+	err := sc.txManager.WithinTx(ctx, func(tx transaction.Tx) error {
+		coupon, err := sc.couponRepo.GetCouponForUpdate(ctx, tx, couponID)
+		if err != nil {
+			return err
+		}
 
-	coupon, err := sc.couponRepo.GetCouponForUpdate(tx, couponID)
-	if err != nil {
-		return err
-	}
+		if coupon.IsUsed() {
+			return couponDomain.ErrCouponAlreadyUsed
+		}
 
-	if coupon.IsUsed() {
-		return couponDomain.ErrCouponAlreadyUsed
-	}
+		err = coupon.CheckRedeemable(time.Now())
+		if err != nil {
+			return err
+		}
 
-	toUpdateShoppingCart, err := sc.shoppingCartRepo.GetShoppingCartForUpdate(tx, scID)
-	if err != nil {
-		return err
-	}
+		toUpdateShoppingCart, err := sc.shoppingCartRepo.GetShoppingCartForUpdate(ctx, tx, scID)
+		if err != nil {
+			return err
+		}
+		if err := etag.Check(ctx, toUpdateShoppingCart.Version); err != nil {
+			return err
+		}
 
-	err = toUpdateShoppingCart.ApplyCoupon(couponID, coupon.Amount)
-	if err != nil {
-		return err
-	}
+		err = coupon.CheckCustomer(toUpdateShoppingCart.CustomerID)
+		if err != nil {
+			return err
+		}
 
-	_, err = sc.shoppingCartRepo.UpdateShoppingCart(tx, toUpdateShoppingCart)
-	if err != nil {
-		return err
-	}
+		// the coupon row is locked, so concurrent redemptions of the
+		// same coupon wait here until this transaction finishes
+		if coupon.HasCustomerLimit() {
+			uses, err := sc.shoppingCartRepo.CountCustomerCouponUses(ctx, tx, toUpdateShoppingCart.CustomerID, couponID)
+			if err != nil {
+				return err
+			}
+			err = coupon.CheckCustomerUses(uses)
+			if err != nil {
+				return err
+			}
+		}
 
-	coupon.Used = true
-	_, err = sc.couponRepo.UpdateCoupon(tx, coupon)
-	if err != nil {
-		return err
-	}
-	err = sc.shoppingCartRepo.CommitTransaction(tx)
+		// a single use coupon is used by this redemption, the
+		// others are used once their max uses are reached
+		var redemptions int64
+		if coupon.IsMultiUse() {
+			redemptions, err = sc.couponRepo.CountCouponRedemptions(ctx, tx, couponID)
+			if err != nil {
+				return err
+			}
+		}
+
+		cartBefore, couponBefore := *toUpdateShoppingCart, *coupon
+		err = toUpdateShoppingCart.ApplyCoupon(couponID, coupon.Discount(toUpdateShoppingCart.Total))
+		if err != nil {
+			return err
+		}
+
+		res, err = sc.shoppingCartRepo.UpdateShoppingCart(ctx, tx, toUpdateShoppingCart)
+		if err != nil {
+			return err
+		}
+
+		coupon.MarkAsRedeemed(redemptions)
+		redeemed, err := sc.couponRepo.UpdateCoupon(ctx, tx, coupon)
+		if err != nil {
+			return err
+		}
+
+		events := make([]outbox.Event, 0, 2)
+		applied, err := res.CouponAppliedEvent()
+		if err != nil {
+			return err
+		}
+		events = append(events, applied)
+		if coupon.IsUsed() {
+			exhausted, err := coupon.ExhaustedEvent(applied.OccurredAt)
+			if err != nil {
+				return err
+			}
+			events = append(events, exhausted)
+		}
+		if err := sc.outboxRepo.Add(ctx, tx, events...); err != nil {
+			return err
+		}
+
+		applyEntry, err := audit.NewEntry(ctx, audit.EntityShoppingCart, scID, audit.ActionApplyCoupon, &cartBefore, res)
+		if err != nil {
+			return err
+		}
+		redeemEntry, err := audit.NewEntry(ctx, audit.EntityCoupon, coupon.ID, audit.ActionRedeem, &couponBefore, redeemed)
+		if err != nil {
+			return err
+		}
+		return sc.auditRepo.Add(ctx, tx, applyEntry, redeemEntry)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return res, nil
 }
@@ -1,22 +1,30 @@
 package service_test
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 
+	"github.com/nachoconques0/schwarz-challenge/internal/audit"
 	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	"github.com/nachoconques0/schwarz-challenge/internal/etag"
 	"github.com/nachoconques0/schwarz-challenge/internal/mocks"
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
 	"github.com/nachoconques0/schwarz-challenge/internal/service"
 	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
 )
 
 type testShoppingCartService struct {
 	svc                  shoppingcart.Service
 	couponMockRepo       *mocks.MockCouponRepository
 	shoppingCartMockRepo *mocks.MockShoppingCartRepository
+	outboxMockRepo       *mocks.MockOutboxRepository
+	auditMockRepo        *mocks.MockAuditRepository
 }
 
 func TestShoppingCartService_CreateCoupon(t *testing.T) {
@@ -40,7 +48,16 @@ func TestShoppingCartService_CreateCoupon(t *testing.T) {
 		"repo fail": {
 			req: shoppingCartReq,
 			mocks: func() {
-				ts.shoppingCartMockRepo.EXPECT().CreateShoppingCart(gomock.Any()).Return(nil, errGeneric)
+				ts.shoppingCartMockRepo.EXPECT().CreateShoppingCart(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errGeneric)
+			},
+			expectedShoppingCart: nil,
+			expectedError:        errGeneric,
+		},
+		"outbox fail": {
+			req: shoppingCartReq,
+			mocks: func() {
+				ts.shoppingCartMockRepo.EXPECT().CreateShoppingCart(gomock.Any(), gomock.Any(), gomock.Any()).Return(expectedShoppingCart, nil)
+				ts.outboxMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).Return(errGeneric)
 			},
 			expectedShoppingCart: nil,
 			expectedError:        errGeneric,
@@ -53,10 +70,33 @@ func TestShoppingCartService_CreateCoupon(t *testing.T) {
 			expectedShoppingCart: nil,
 			expectedError:        shoppingcart.ErrShoppingCartEmptyItems,
 		},
+		"audit fail": {
+			req: shoppingCartReq,
+			mocks: func() {
+				ts.shoppingCartMockRepo.EXPECT().CreateShoppingCart(gomock.Any(), gomock.Any(), gomock.Any()).Return(expectedShoppingCart, nil)
+				ts.outboxMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				ts.auditMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).Return(errGeneric)
+			},
+			expectedShoppingCart: nil,
+			expectedError:        errGeneric,
+		},
 		"success": {
 			req: shoppingCartReq,
 			mocks: func() {
-				ts.shoppingCartMockRepo.EXPECT().CreateShoppingCart(gomock.Any()).Return(expectedShoppingCart, nil)
+				ts.shoppingCartMockRepo.EXPECT().CreateShoppingCart(gomock.Any(), gomock.Any(), gomock.Any()).Return(expectedShoppingCart, nil)
+				ts.outboxMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ transaction.Tx, events ...outbox.Event) error {
+					assert.Len(t, events, 1)
+					assert.Equal(t, shoppingcart.EventCreated, events[0].Type)
+					assert.Equal(t, expectedShoppingCart.ID, events[0].AggregateID)
+					return nil
+				})
+				ts.auditMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ transaction.Tx, entries ...audit.Entry) error {
+					assert.Len(t, entries, 1)
+					assert.Equal(t, audit.EntityShoppingCart, entries[0].EntityType)
+					assert.Equal(t, audit.ActionCreate, entries[0].Action)
+					assert.Equal(t, expectedShoppingCart.ID, entries[0].EntityID)
+					return nil
+				})
 			},
 			expectedShoppingCart: expectedShoppingCart,
 			expectedError:        nil,
@@ -66,7 +106,7 @@ func TestShoppingCartService_CreateCoupon(t *testing.T) {
 	for name, tc := range testCases {
 		tc.mocks()
 		t.Run(name, func(t *testing.T) {
-			c, err := ts.svc.CreateShoppingCart(*tc.req)
+			c, err := ts.svc.CreateShoppingCart(context.Background(), *tc.req)
 			assert.Equal(t, tc.expectedError, err)
 			if err == nil {
 				assert.Equal(t, tc.expectedShoppingCart.Amount, c.Amount)
@@ -94,14 +134,14 @@ func TestShoppingCartService_ListShoppingCarts(t *testing.T) {
 	}{
 		"repo fail": {
 			mocks: func() {
-				ts.shoppingCartMockRepo.EXPECT().ListShoppingCarts().Return(nil, errGeneric)
+				ts.shoppingCartMockRepo.EXPECT().ListShoppingCarts(gomock.Any()).Return(nil, errGeneric)
 			},
 			expectedShoppingCarts: []shoppingcart.ShoppingCart{},
 			expectedError:         errGeneric,
 		},
 		"success": {
 			mocks: func() {
-				ts.shoppingCartMockRepo.EXPECT().ListShoppingCarts().Return([]shoppingcart.ShoppingCart{*createdSc}, nil)
+				ts.shoppingCartMockRepo.EXPECT().ListShoppingCarts(gomock.Any()).Return([]shoppingcart.ShoppingCart{*createdSc}, nil)
 			},
 			expectedShoppingCarts: []shoppingcart.ShoppingCart{
 				*createdSc,
@@ -113,7 +153,7 @@ func TestShoppingCartService_ListShoppingCarts(t *testing.T) {
 	for name, tc := range testCases {
 		tc.mocks()
 		t.Run(name, func(t *testing.T) {
-			res, err := ts.svc.ListShoppingCarts()
+			res, err := ts.svc.ListShoppingCarts(context.Background())
 			assert.Equal(t, tc.expectedError, err)
 			if err == nil {
 				assert.Len(t, res, 1)
@@ -126,6 +166,7 @@ func TestShoppingCartService_ApplyCoupon(t *testing.T) {
 	ts := buildShoppingCartService(t)
 	couponID := uuid.MustParse(uuid.NewString())
 	scID := uuid.MustParse(uuid.NewString())
+	customerID := uuid.MustParse(uuid.NewString())
 
 	invalidCoupon := &coupon.Coupon{
 		Amount: 50,
@@ -133,6 +174,7 @@ func TestShoppingCartService_ApplyCoupon(t *testing.T) {
 	}
 
 	toUpdateShoppingCart := &shoppingcart.ShoppingCart{
+		ID:       scID,
 		CouponID: uuid.MustParse(uuid.Nil.String()),
 		Items: shoppingcart.Items{
 			shoppingcart.Item{
@@ -147,42 +189,63 @@ func TestShoppingCartService_ApplyCoupon(t *testing.T) {
 
 	testCases := map[string]struct {
 		req           *shoppingcart.CreateRequest
+		ifMatch       int64
 		mocks         func()
 		expectedError error
 	}{
 		"GetCouponForUpdate fails": {
 			mocks: func() {
-				ts.shoppingCartMockRepo.EXPECT().BeginTransaction().Return(nil)
-				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any()).Return(nil, errGeneric)
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errGeneric)
 			},
 			expectedError: errGeneric,
 		},
 		"coupon is used": {
 			mocks: func() {
-				ts.shoppingCartMockRepo.EXPECT().BeginTransaction().Return(nil)
-				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any()).Return(invalidCoupon, nil)
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(invalidCoupon, nil)
 			},
 			expectedError: coupon.ErrCouponAlreadyUsed,
 		},
+		"coupon is not active": {
+			mocks: func() {
+				deactivatedAt := time.Now()
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
+					Amount:        50,
+					DeactivatedAt: &deactivatedAt,
+				}, nil)
+			},
+			expectedError: coupon.ErrCouponInactive,
+		},
 		"GetShoppingCartForUpdate fails": {
 			mocks: func() {
-				ts.shoppingCartMockRepo.EXPECT().BeginTransaction().Return(nil)
-				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
 					Amount: 50,
 					Used:   false,
 				}, nil)
-				ts.shoppingCartMockRepo.EXPECT().GetShoppingCartForUpdate(gomock.Any(), gomock.Any()).Return(nil, errGeneric)
+				ts.shoppingCartMockRepo.EXPECT().GetShoppingCartForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errGeneric)
 			},
 			expectedError: errGeneric,
 		},
+		"shopping cart version is stale": {
+			ifMatch: 1,
+			mocks: func() {
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
+					Amount: 50,
+				}, nil)
+				ts.shoppingCartMockRepo.EXPECT().GetShoppingCartForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{
+					Amount:  100,
+					Total:   100,
+					Version: 2,
+				}, nil)
+			},
+			expectedError: etag.ErrPreconditionFailed,
+		},
 		"shopping cart coupon has been applied": {
 			mocks: func() {
-				ts.shoppingCartMockRepo.EXPECT().BeginTransaction().Return(nil)
-				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
 					Amount: 50,
 					Used:   false,
 				}, nil)
-				ts.shoppingCartMockRepo.EXPECT().GetShoppingCartForUpdate(gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{
+				ts.shoppingCartMockRepo.EXPECT().GetShoppingCartForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{
 					CouponID: uuid.MustParse(uuid.NewString()),
 					Items: shoppingcart.Items{
 						shoppingcart.Item{
@@ -197,26 +260,146 @@ func TestShoppingCartService_ApplyCoupon(t *testing.T) {
 			},
 			expectedError: shoppingcart.ErrShoppinCartCouponAlreadyApplied,
 		},
+		"coupon assigned to another customer": {
+			mocks: func() {
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
+					Amount:     50,
+					CustomerID: uuid.New(),
+				}, nil)
+				ts.shoppingCartMockRepo.EXPECT().GetShoppingCartForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{
+					CustomerID: customerID,
+					Amount:     100,
+					Total:      100,
+				}, nil)
+			},
+			expectedError: coupon.ErrCouponNotAssignedToCustomer,
+		},
+		"customer reached coupon max uses": {
+			mocks: func() {
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
+					Amount:             50,
+					MaxUsesPerCustomer: 1,
+				}, nil)
+				ts.shoppingCartMockRepo.EXPECT().GetShoppingCartForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{
+					CustomerID: customerID,
+					Amount:     100,
+					Total:      100,
+				}, nil)
+				ts.shoppingCartMockRepo.EXPECT().CountCustomerCouponUses(gomock.Any(), gomock.Any(), customerID, gomock.Any()).Return(int64(1), nil)
+			},
+			expectedError: coupon.ErrCouponCustomerLimitReached,
+		},
+		"count customer coupon uses fails": {
+			mocks: func() {
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
+					Amount:             50,
+					MaxUsesPerCustomer: 1,
+				}, nil)
+				ts.shoppingCartMockRepo.EXPECT().GetShoppingCartForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{
+					CustomerID: customerID,
+					Amount:     100,
+					Total:      100,
+				}, nil)
+				ts.shoppingCartMockRepo.EXPECT().CountCustomerCouponUses(gomock.Any(), gomock.Any(), customerID, gomock.Any()).Return(int64(0), errGeneric)
+			},
+			expectedError: errGeneric,
+		},
+		"count coupon redemptions fails": {
+			mocks: func() {
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
+					Amount:             50,
+					MaxUsesPerCustomer: 2,
+					MaxUses:            10,
+				}, nil)
+				ts.shoppingCartMockRepo.EXPECT().GetShoppingCartForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{
+					CustomerID: customerID,
+					Amount:     100,
+					Total:      100,
+				}, nil)
+				ts.shoppingCartMockRepo.EXPECT().CountCustomerCouponUses(gomock.Any(), gomock.Any(), customerID, gomock.Any()).Return(int64(0), nil)
+				ts.couponMockRepo.EXPECT().CountCouponRedemptions(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(0), errGeneric)
+			},
+			expectedError: errGeneric,
+		},
+		"success with coupon limited per customer reaching its max uses": {
+			mocks: func() {
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
+					ID:                 couponID,
+					Amount:             50,
+					MaxUsesPerCustomer: 2,
+					MaxUses:            10,
+				}, nil)
+				ts.shoppingCartMockRepo.EXPECT().GetShoppingCartForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{
+					CustomerID: customerID,
+					Amount:     100,
+					Total:      100,
+				}, nil)
+				ts.shoppingCartMockRepo.EXPECT().CountCustomerCouponUses(gomock.Any(), gomock.Any(), customerID, gomock.Any()).Return(int64(1), nil)
+				ts.couponMockRepo.EXPECT().CountCouponRedemptions(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(9), nil)
+				ts.shoppingCartMockRepo.EXPECT().UpdateShoppingCart(gomock.Any(), gomock.Any(), gomock.Any()).Return(toUpdateShoppingCart, nil)
+				ts.couponMockRepo.EXPECT().UpdateCoupon(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ any, c *coupon.Coupon) (*coupon.Coupon, error) {
+					assert.True(t, c.Used)
+					return c, nil
+				})
+				ts.outboxMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ transaction.Tx, events ...outbox.Event) error {
+					assert.Len(t, events, 2)
+					assert.Equal(t, shoppingcart.EventCouponApplied, events[0].Type)
+					assert.Equal(t, coupon.EventExhausted, events[1].Type)
+					return nil
+				})
+				ts.auditMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expectedError: nil,
+		},
+		"success with coupon limited per customer": {
+			mocks: func() {
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
+					ID:                 couponID,
+					Amount:             50,
+					MaxUsesPerCustomer: 2,
+					MaxUses:            10,
+				}, nil)
+				ts.shoppingCartMockRepo.EXPECT().GetShoppingCartForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{
+					CustomerID: customerID,
+					Amount:     100,
+					Total:      100,
+				}, nil)
+				ts.shoppingCartMockRepo.EXPECT().CountCustomerCouponUses(gomock.Any(), gomock.Any(), customerID, gomock.Any()).Return(int64(1), nil)
+				ts.couponMockRepo.EXPECT().CountCouponRedemptions(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(3), nil)
+				ts.shoppingCartMockRepo.EXPECT().UpdateShoppingCart(gomock.Any(), gomock.Any(), gomock.Any()).Return(toUpdateShoppingCart, nil)
+				ts.couponMockRepo.EXPECT().UpdateCoupon(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ any, c *coupon.Coupon) (*coupon.Coupon, error) {
+					assert.False(t, c.Used)
+					return c, nil
+				})
+				ts.outboxMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ transaction.Tx, events ...outbox.Event) error {
+					assert.Len(t, events, 1)
+					assert.Equal(t, shoppingcart.EventCouponApplied, events[0].Type)
+					return nil
+				})
+				ts.auditMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expectedError: nil,
+		},
 		"shopping cart update fails": {
 			mocks: func() {
-				ts.shoppingCartMockRepo.EXPECT().BeginTransaction().Return(nil)
-				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
 					Amount: 50,
 					Used:   false,
 				}, nil)
-				ts.shoppingCartMockRepo.EXPECT().GetShoppingCartForUpdate(gomock.Any(), gomock.Any()).Return(toUpdateShoppingCart, nil)
-				ts.shoppingCartMockRepo.EXPECT().UpdateShoppingCart(gomock.Any(), gomock.Any()).Return(nil, errGeneric)
+				ts.shoppingCartMockRepo.EXPECT().GetShoppingCartForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(toUpdateShoppingCart, nil)
+				ts.shoppingCartMockRepo.EXPECT().UpdateShoppingCart(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errGeneric)
 			},
 			expectedError: errGeneric,
 		},
 		"success": {
 			mocks: func() {
-				ts.shoppingCartMockRepo.EXPECT().BeginTransaction().Return(nil)
-				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
+					ID:     couponID,
 					Amount: 50,
 					Used:   false,
 				}, nil)
-				ts.shoppingCartMockRepo.EXPECT().GetShoppingCartForUpdate(gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{
+				ts.shoppingCartMockRepo.EXPECT().GetShoppingCartForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{
+					ID:       scID,
 					CouponID: uuid.MustParse(uuid.Nil.String()),
 					Items: shoppingcart.Items{
 						shoppingcart.Item{
@@ -228,32 +411,112 @@ func TestShoppingCartService_ApplyCoupon(t *testing.T) {
 					Amount: 100,
 					Total:  100,
 				}, nil)
-				ts.shoppingCartMockRepo.EXPECT().UpdateShoppingCart(gomock.Any(), gomock.Any()).Return(toUpdateShoppingCart, nil)
-				ts.couponMockRepo.EXPECT().UpdateCoupon(gomock.Any(), gomock.Any()).Return(nil, nil)
-				ts.shoppingCartMockRepo.EXPECT().CommitTransaction(gomock.Any()).Return(nil)
+				ts.shoppingCartMockRepo.EXPECT().UpdateShoppingCart(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ transaction.Tx, sc *shoppingcart.ShoppingCart) (*shoppingcart.ShoppingCart, error) {
+					return sc, nil
+				})
+				ts.couponMockRepo.EXPECT().UpdateCoupon(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ any, c *coupon.Coupon) (*coupon.Coupon, error) {
+					return c, nil
+				})
+				ts.outboxMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ transaction.Tx, events ...outbox.Event) error {
+					assert.Len(t, events, 2)
+					assert.Equal(t, shoppingcart.EventCouponApplied, events[0].Type)
+					assert.Equal(t, coupon.EventExhausted, events[1].Type)
+					return nil
+				})
+				ts.auditMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ transaction.Tx, entries ...audit.Entry) error {
+					assert.Len(t, entries, 2)
+					assert.Equal(t, audit.ActionApplyCoupon, entries[0].Action)
+					assert.Contains(t, entries[0].Diff, "coupon_id")
+					assert.Contains(t, entries[0].Diff, "total")
+					assert.Equal(t, audit.ActionRedeem, entries[1].Action)
+					assert.Equal(t, couponID, entries[1].EntityID)
+					assert.Contains(t, entries[1].Diff, "used")
+					return nil
+				})
 			},
 			expectedError: nil,
 		},
+		"outbox fails": {
+			mocks: func() {
+				ts.couponMockRepo.EXPECT().GetCouponForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&coupon.Coupon{
+					ID:     couponID,
+					Amount: 50,
+				}, nil)
+				ts.shoppingCartMockRepo.EXPECT().GetShoppingCartForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{
+					Amount: 100,
+					Total:  100,
+				}, nil)
+				ts.shoppingCartMockRepo.EXPECT().UpdateShoppingCart(gomock.Any(), gomock.Any(), gomock.Any()).Return(toUpdateShoppingCart, nil)
+				ts.couponMockRepo.EXPECT().UpdateCoupon(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+				ts.outboxMockRepo.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(errGeneric)
+			},
+			expectedError: errGeneric,
+		},
 	}
 
 	for name, tc := range testCases {
 		tc.mocks()
 		t.Run(name, func(t *testing.T) {
-			err := ts.svc.ApplyCoupon(couponID, scID)
+			ctx := context.Background()
+			if tc.ifMatch != 0 {
+				ctx = etag.WithIfMatch(ctx, tc.ifMatch)
+			}
+			res, err := ts.svc.ApplyCoupon(ctx, couponID, scID)
 			assert.Equal(t, tc.expectedError, err)
+			if tc.expectedError == nil {
+				assert.Equal(t, scID, res.ID)
+			}
 		})
 	}
 }
 
+func TestNewShoppingCartService_MissingOutbox(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	_, err := service.NewShoppingCartService(
+		mocks.NewMockShoppingCartRepository(ctrl),
+		mocks.NewMockCouponRepository(ctrl),
+		newTxManager(ctrl),
+		nil,
+		mocks.NewMockAuditRepository(ctrl),
+	)
+	assert.Equal(t, service.ErrMissingOutbox, err)
+}
+
+func TestNewShoppingCartService_MissingAudit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	_, err := service.NewShoppingCartService(
+		mocks.NewMockShoppingCartRepository(ctrl),
+		mocks.NewMockCouponRepository(ctrl),
+		newTxManager(ctrl),
+		mocks.NewMockOutboxRepository(ctrl),
+		nil,
+	)
+	assert.Equal(t, service.ErrMissingAuditRepo, err)
+}
+
 func buildShoppingCartService(t *testing.T) testShoppingCartService {
 	ctrl := gomock.NewController(t)
 	couponRepo := mocks.NewMockCouponRepository(ctrl)
 	shoppingCartRepo := mocks.NewMockShoppingCartRepository(ctrl)
-	svc, err := service.NewShoppingCartService(shoppingCartRepo, couponRepo)
+	outboxRepo := mocks.NewMockOutboxRepository(ctrl)
+	auditRepo := mocks.NewMockAuditRepository(ctrl)
+	svc, err := service.NewShoppingCartService(shoppingCartRepo, couponRepo, newTxManager(ctrl), outboxRepo, auditRepo)
 	assert.Nil(t, err)
 	return testShoppingCartService{
 		svc:                  svc,
 		couponMockRepo:       couponRepo,
 		shoppingCartMockRepo: shoppingCartRepo,
+		outboxMockRepo:       outboxRepo,
+		auditMockRepo:        auditRepo,
 	}
 }
+
+// newTxManager returns a transaction manager running every unit of work
+// straight away, the transaction handling itself is covered by the repo tests
+func newTxManager(ctrl *gomock.Controller) *mocks.MockTxManager {
+	txManager := mocks.NewMockTxManager(ctrl)
+	txManager.EXPECT().WithinTx(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, fn func(transaction.Tx) error) error {
+		return fn(nil)
+	}).AnyTimes()
+	return txManager
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
+)
+
+// ErrMissingWebhookRepo used when the webhook repository is nil
+var ErrMissingWebhookRepo = errors.NewInternalError("webhook repository is missing")
+
+type webhookService struct {
+	repo webhook.Repository
+}
+
+// NewWebhookService builds a new service that
+// satisfies the webhook interface
+func NewWebhookService(repo webhook.Repository) (webhook.Service, error) {
+	if repo == nil {
+		return nil, ErrMissingWebhookRepo
+	}
+	return &webhookService{repo: repo}, nil
+}
+
+// CreateSubscription creates a new subscription, the only response carrying
+// its secret. The host of its URL must resolve to public addresses
+func (ws *webhookService) CreateSubscription(ctx context.Context, req webhook.CreateRequest) (*webhook.Subscription, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if err := webhook.ResolveURL(ctx, req.URL); err != nil {
+		return nil, err
+	}
+	payload, err := webhook.New(req)
+	if err != nil {
+		return nil, err
+	}
+	return ws.repo.CreateSubscription(ctx, payload)
+}
+
+// ListSubscriptions returns every subscription without their secrets
+func (ws *webhookService) ListSubscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	res, err := ws.repo.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range res {
+		res[i] = res[i].Redacted()
+	}
+	return res, nil
+}
+
+// GetSubscription returns a subscription without its secret
+func (ws *webhookService) GetSubscription(ctx context.Context, id uuid.UUID) (*webhook.Subscription, error) {
+	res, err := ws.repo.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	redacted := res.Redacted()
+	return &redacted, nil
+}
+
+// UpdateSubscription updates the url, event types or secret of a subscription,
+// a new URL must resolve to public addresses
+func (ws *webhookService) UpdateSubscription(ctx context.Context, id uuid.UUID, req webhook.UpdateRequest) (*webhook.Subscription, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if req.URL != nil {
+		if err := webhook.ResolveURL(ctx, *req.URL); err != nil {
+			return nil, err
+		}
+	}
+	s, err := ws.repo.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.Update(req)
+	res, err := ws.repo.UpdateSubscription(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	redacted := res.Redacted()
+	return &redacted, nil
+}
+
+// DeleteSubscription deletes a subscription and its pending deliveries
+func (ws *webhookService) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	return ws.repo.DeleteSubscription(ctx, id)
+}
+
+// ListDeadLetters returns the deliveries that failed too many times
+func (ws *webhookService) ListDeadLetters(ctx context.Context) ([]webhook.Delivery, error) {
+	return ws.repo.ListDeadLetters(ctx)
+}
+
+// RetryDeadLetter sends a dead letter once more, as soon as possible
+func (ws *webhookService) RetryDeadLetter(ctx context.Context, id uuid.UUID) (*webhook.Delivery, error) {
+	return ws.repo.RetryDeadLetter(ctx, id, time.Now())
+}
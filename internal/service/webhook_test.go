@@ -0,0 +1,198 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	"github.com/nachoconques0/schwarz-challenge/internal/mocks"
+	"github.com/nachoconques0/schwarz-challenge/internal/service"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
+)
+
+const testWebhookSecret = "0123456789abcdef"
+
+type testWebhookService struct {
+	svc             webhook.Service
+	webhookMockRepo *mocks.MockWebhookRepository
+}
+
+func TestWebhookService_CreateSubscription(t *testing.T) {
+	ts := buildWebhookService(t)
+	req := webhook.CreateRequest{
+		URL:        "https://203.0.113.10/hook",
+		EventTypes: webhook.EventTypeList{shoppingcart.EventCreated},
+	}
+	testCases := map[string]struct {
+		req           webhook.CreateRequest
+		mocks         func()
+		expectedError error
+	}{
+		"invalid data": {
+			req:           webhook.CreateRequest{URL: "partner"},
+			mocks:         func() {},
+			expectedError: webhook.ErrSubscriptionInvalidURL,
+		},
+		"unresolvable host": {
+			req: webhook.CreateRequest{
+				URL:        "https://partner.invalid/hook",
+				EventTypes: webhook.EventTypeList{shoppingcart.EventCreated},
+			},
+			mocks:         func() {},
+			expectedError: webhook.ErrSubscriptionUnresolvableURL,
+		},
+		"repo fail": {
+			req: req,
+			mocks: func() {
+				ts.webhookMockRepo.EXPECT().CreateSubscription(gomock.Any(), gomock.Any()).Return(nil, errGeneric)
+			},
+			expectedError: errGeneric,
+		},
+		"success": {
+			req: req,
+			mocks: func() {
+				ts.webhookMockRepo.EXPECT().CreateSubscription(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, s *webhook.Subscription) (*webhook.Subscription, error) {
+					return s, nil
+				})
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc.mocks()
+		t.Run(name, func(t *testing.T) {
+			res, err := ts.svc.CreateSubscription(context.Background(), tc.req)
+			assert.Equal(t, tc.expectedError, err)
+			if err == nil {
+				assert.Equal(t, tc.req.URL, res.URL)
+				assert.NotEmpty(t, res.Secret, "the secret is returned once created")
+			}
+		})
+	}
+}
+
+func TestWebhookService_ListSubscriptions(t *testing.T) {
+	ts := buildWebhookService(t)
+	ts.webhookMockRepo.EXPECT().ListSubscriptions(gomock.Any()).Return([]webhook.Subscription{
+		{ID: uuid.New(), Secret: testWebhookSecret},
+	}, nil)
+
+	res, err := ts.svc.ListSubscriptions(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, res, 1)
+	assert.Empty(t, res[0].Secret)
+}
+
+func TestWebhookService_GetSubscription(t *testing.T) {
+	ts := buildWebhookService(t)
+	id := uuid.New()
+
+	t.Run("repo fail", func(t *testing.T) {
+		ts.webhookMockRepo.EXPECT().GetSubscription(gomock.Any(), id).Return(nil, errGeneric)
+		_, err := ts.svc.GetSubscription(context.Background(), id)
+		assert.Equal(t, errGeneric, err)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		ts.webhookMockRepo.EXPECT().GetSubscription(gomock.Any(), id).Return(&webhook.Subscription{ID: id, Secret: testWebhookSecret}, nil)
+		res, err := ts.svc.GetSubscription(context.Background(), id)
+		assert.Nil(t, err)
+		assert.Equal(t, id, res.ID)
+		assert.Empty(t, res.Secret)
+	})
+}
+
+func TestWebhookService_UpdateSubscription(t *testing.T) {
+	ts := buildWebhookService(t)
+	id := uuid.New()
+	eventTypes := webhook.EventTypeList{coupon.EventExhausted}
+	loopbackURL := "http://localhost:8080/hook"
+
+	testCases := map[string]struct {
+		req           webhook.UpdateRequest
+		mocks         func()
+		expectedError error
+	}{
+		"empty update": {
+			mocks:         func() {},
+			expectedError: webhook.ErrSubscriptionEmptyUpdate,
+		},
+		"url resolving to loopback": {
+			req:           webhook.UpdateRequest{URL: &loopbackURL},
+			mocks:         func() {},
+			expectedError: webhook.ErrSubscriptionPrivateURL,
+		},
+		"subscription not found": {
+			req: webhook.UpdateRequest{EventTypes: &eventTypes},
+			mocks: func() {
+				ts.webhookMockRepo.EXPECT().GetSubscription(gomock.Any(), id).Return(nil, errGeneric)
+			},
+			expectedError: errGeneric,
+		},
+		"success": {
+			req: webhook.UpdateRequest{EventTypes: &eventTypes},
+			mocks: func() {
+				ts.webhookMockRepo.EXPECT().GetSubscription(gomock.Any(), id).Return(&webhook.Subscription{
+					ID:         id,
+					URL:        "https://203.0.113.10/hook",
+					EventTypes: webhook.EventTypeList{shoppingcart.EventCreated},
+					Secret:     testWebhookSecret,
+				}, nil)
+				ts.webhookMockRepo.EXPECT().UpdateSubscription(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, s *webhook.Subscription) (*webhook.Subscription, error) {
+					assert.Equal(t, eventTypes, s.EventTypes)
+					assert.Equal(t, "https://203.0.113.10/hook", s.URL)
+					assert.Equal(t, testWebhookSecret, s.Secret)
+					return s, nil
+				})
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc.mocks()
+		t.Run(name, func(t *testing.T) {
+			res, err := ts.svc.UpdateSubscription(context.Background(), id, tc.req)
+			assert.Equal(t, tc.expectedError, err)
+			if err == nil {
+				assert.Equal(t, eventTypes, res.EventTypes)
+				assert.Empty(t, res.Secret)
+			}
+		})
+	}
+}
+
+func TestWebhookService_DeadLetters(t *testing.T) {
+	ts := buildWebhookService(t)
+	id := uuid.New()
+
+	ts.webhookMockRepo.EXPECT().ListDeadLetters(gomock.Any()).Return([]webhook.Delivery{{ID: id, Status: webhook.StatusDead}}, nil)
+	dead, err := ts.svc.ListDeadLetters(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, dead, 1)
+
+	ts.webhookMockRepo.EXPECT().RetryDeadLetter(gomock.Any(), id, gomock.Any()).Return(&webhook.Delivery{ID: id, Status: webhook.StatusPending}, nil)
+	retried, err := ts.svc.RetryDeadLetter(context.Background(), id)
+	assert.Nil(t, err)
+	assert.Equal(t, webhook.StatusPending, retried.Status)
+}
+
+func TestNewWebhookService_MissingRepo(t *testing.T) {
+	_, err := service.NewWebhookService(nil)
+	assert.Equal(t, service.ErrMissingWebhookRepo, err)
+}
+
+func buildWebhookService(t *testing.T) testWebhookService {
+	ctrl := gomock.NewController(t)
+	webhookRepo := mocks.NewMockWebhookRepository(ctrl)
+	svc, err := service.NewWebhookService(webhookRepo)
+	assert.Nil(t, err)
+	return testWebhookService{
+		svc:             svc,
+		webhookMockRepo: webhookRepo,
+	}
+}
@@ -0,0 +1,66 @@
+package shoppingcart
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
+)
+
+// AggregateType names the shopping carts in the domain events
+const AggregateType = "shopping_cart"
+
+// Domain events of the shopping carts
+const (
+	// EventCreated is emitted once a shopping cart is created
+	EventCreated = "shopping_cart.created"
+	// EventCouponApplied is emitted once a coupon is applied to a shopping cart
+	EventCouponApplied = "shopping_cart.coupon_applied"
+)
+
+// CreatedEvent is the payload of EventCreated
+type CreatedEvent struct {
+	ShoppingCartID uuid.UUID `json:"shopping_cart_id"`
+	CustomerID     uuid.UUID `json:"customer_id,omitempty"`
+	Items          Items     `json:"items"`
+	Amount         float32   `json:"amount"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// CouponAppliedEvent is the payload of EventCouponApplied
+type CouponAppliedEvent struct {
+	ShoppingCartID uuid.UUID `json:"shopping_cart_id"`
+	CouponID       uuid.UUID `json:"coupon_id"`
+	CustomerID     uuid.UUID `json:"customer_id,omitempty"`
+	Amount         float32   `json:"amount"`
+	Total          float32   `json:"total"`
+	AppliedAt      time.Time `json:"applied_at"`
+}
+
+// CreatedEvent returns the event emitted once the shopping cart is created
+func (sc *ShoppingCart) CreatedEvent() (outbox.Event, error) {
+	return outbox.NewEvent(AggregateType, sc.ID, EventCreated, CreatedEvent{
+		ShoppingCartID: sc.ID,
+		CustomerID:     sc.CustomerID,
+		Items:          sc.Items,
+		Amount:         sc.Amount,
+		CreatedAt:      sc.CreatedAt,
+	})
+}
+
+// CouponAppliedEvent returns the event emitted once a coupon is applied to the shopping cart
+func (sc *ShoppingCart) CouponAppliedEvent() (outbox.Event, error) {
+	var appliedAt time.Time
+	if sc.CouponAppliedAt != nil {
+		appliedAt = *sc.CouponAppliedAt
+	}
+	return outbox.NewEvent(AggregateType, sc.ID, EventCouponApplied, CouponAppliedEvent{
+		ShoppingCartID: sc.ID,
+		CouponID:       sc.CouponID,
+		CustomerID:     sc.CustomerID,
+		Amount:         sc.Amount,
+		Total:          sc.Total,
+		AppliedAt:      appliedAt,
+	})
+}
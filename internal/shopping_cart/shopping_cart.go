@@ -2,6 +2,7 @@
 package shoppingcart
 
 import (
+	"context"
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
@@ -9,9 +10,9 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 
 	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/transaction"
 )
 
 var (
@@ -19,6 +20,8 @@ var (
 	ErrShoppingCartWithCouponAlreadyApplied = internalErrors.NewConflict("shopping cart coupon already used")
 	// ErrShoppingCartEmptyItems used when items are empty
 	ErrShoppingCartEmptyItems = internalErrors.NewWrongInput("shopping cart empty items")
+	// ErrShoppingCartTooManyItems used when there are more than maxItems items
+	ErrShoppingCartTooManyItems = internalErrors.NewWrongInput("shopping cart must not have more than 5 items")
 	// ErrItemEmptyName used when item has empty name
 	ErrItemEmptyName = internalErrors.NewWrongInput("item empty name")
 	// ErrItemEmptyDescription used when item has empty description
@@ -29,6 +32,12 @@ var (
 	ErrShoppinCartCouponAlreadyApplied = internalErrors.NewConflict("shopping cart already with coupon applied")
 	// ErrShoppointCartCouponAmountExceeded used when a coupon amount was
 	ErrShoppointCartCouponAmountExceeded = internalErrors.NewWrongInput("coupon amount exceeds shopping cart total")
+	// ErrShoppingCartVersionConflict used when the shopping cart was updated since it was read
+	ErrShoppingCartVersionConflict = internalErrors.NewConflict("shopping cart was modified concurrently, retry")
+	// ErrShoppingCartNotFound used when there is no shopping cart
+	ErrShoppingCartNotFound = internalErrors.NewNotFound("shopping cart not found")
+	// ErrShoppingCartsNotFound used when there are no shopping carts
+	ErrShoppingCartsNotFound = internalErrors.NewNotFound("shopping carts not found")
 )
 
 // ShoppingCart defines the asset of a Shopping Cart in our service
@@ -43,27 +52,39 @@ type ShoppingCart struct {
 	Total float32 `json:"total,omitempty"`
 	// CouponID will be the ID of the applied coupon
 	CouponID uuid.UUID `json:"coupon_id,omitempty"`
+	// CustomerID is the ID of the customer owning the shopping cart
+	CustomerID uuid.UUID `json:"customer_id,omitempty"`
+	// CouponAppliedAt is the moment the coupon was applied
+	CouponAppliedAt *time.Time `json:"coupon_applied_at,omitempty"`
+	// Version is increased on every update, it is exposed as the ETag of the shopping cart
+	Version int64 `json:"version,omitempty" gorm:"default:1"`
 	// Timestamp when it was created
 	CreatedAt time.Time `json:"created_at,omitempty"`
 	// Timestamp of the last update
 	UpdatedAt time.Time `json:"updated_at,omitempty"`
 }
 
+// maxItems bounds the items of a shopping cart, the same as the maxItems
+// of the CreateRequest schema
+const maxItems = 5
+
 // CreateRequest defines needed field to create a shopping cart
 type CreateRequest struct {
-	Items Items `json:"items,omitempty"`
+	Items Items `json:"items,omitempty" jsonschema:"required,minItems=1,maxItems=5"`
+	// CustomerID is taken from the authenticated request, never from the payload
+	CustomerID uuid.UUID `json:"-"`
 }
 
 // Item defines the asset of a Item in our service
 type Item struct {
 	// ID Unique Identifier of an Item
-	ID uuid.UUID `json:"id,omitempty"`
+	ID uuid.UUID `json:"id,omitempty" jsonschema:"-"`
 	// Name will be the name of the item
-	Name string `json:"name,omitempty"`
+	Name string `json:"name,omitempty" jsonschema:"required,minLength=1"`
 	// Description will be the description of the item
-	Description string `json:"description,omitempty"`
+	Description string `json:"description,omitempty" jsonschema:"required,minLength=1"`
 	// Price
-	Price float32 `json:"price,omitempty"`
+	Price float32 `json:"price,omitempty" jsonschema:"required,exclusiveMinimum=0"`
 }
 
 // Validate validates the create request
@@ -71,6 +92,9 @@ func (r CreateRequest) Validate() error {
 	if len(r.Items) == 0 {
 		return ErrShoppingCartEmptyItems
 	}
+	if len(r.Items) > maxItems {
+		return ErrShoppingCartTooManyItems
+	}
 	for _, i := range r.Items {
 		err := i.Validate()
 		if err != nil {
@@ -108,10 +132,12 @@ func New(req CreateRequest) *ShoppingCart {
 		})
 	}
 	return &ShoppingCart{
-		ID:     uuid.MustParse(uuid.NewString()),
-		Items:  parsedItems,
-		Amount: float32(int(totalAmount*100)) / 100,
-		Total:  float32(int(totalAmount*100)) / 100,
+		ID:         uuid.MustParse(uuid.NewString()),
+		Items:      parsedItems,
+		Amount:     float32(int(totalAmount*100)) / 100,
+		Total:      float32(int(totalAmount*100)) / 100,
+		CustomerID: req.CustomerID,
+		Version:    1,
 	}
 }
 
@@ -124,7 +150,9 @@ func (sc *ShoppingCart) ApplyCoupon(couponID uuid.UUID, couponAmount float32) er
 		return ErrShoppointCartCouponAmountExceeded
 	}
 
+	now := time.Now()
 	sc.CouponID = couponID
+	sc.CouponAppliedAt = &now
 	res := sc.Total - couponAmount
 	sc.Total = float32(int(res*100)) / 100
 
@@ -168,26 +196,26 @@ func (i *Items) Scan(src interface{}) error {
 // Service defines the available functions for the Shopping Cart Service
 type Service interface {
 	// CreateShoppingCart will create a new shopping cart
-	CreateShoppingCart(CreateRequest) (*ShoppingCart, error)
+	CreateShoppingCart(context.Context, CreateRequest) (*ShoppingCart, error)
 	// ListShoppingCarts returns a shopping cart list
-	ListShoppingCarts() ([]ShoppingCart, error)
-	// ApplyCoupon applies a coupon code
-	ApplyCoupon(uuid.UUID, uuid.UUID) error
+	ListShoppingCarts(context.Context) ([]ShoppingCart, error)
+	// ApplyCoupon applies a coupon code and returns the updated shopping cart
+	ApplyCoupon(context.Context, uuid.UUID, uuid.UUID) (*ShoppingCart, error)
 }
 
 // Repository defines the available functions for the Shopping Cart repository
 type Repository interface {
-	// CreateShoppingCart will create a new shopping cart
-	CreateShoppingCart(*ShoppingCart) (*ShoppingCart, error)
+	// CreateShoppingCart will create a new shopping cart within the given transaction
+	CreateShoppingCart(context.Context, transaction.Tx, *ShoppingCart) (*ShoppingCart, error)
 	// GetShoppingCartForUpdate returns a shopping cart and it will lock the row in order to update it
-	GetShoppingCartForUpdate(*gorm.DB, uuid.UUID) (*ShoppingCart, error)
+	GetShoppingCartForUpdate(context.Context, transaction.Tx, uuid.UUID) (*ShoppingCart, error)
 	// ListShoppingCarts returns a list of shopping carts
-	ListShoppingCarts() ([]ShoppingCart, error)
-	// UpdateShoppingCart updates shopping cart entity
-	UpdateShoppingCart(*gorm.DB, *ShoppingCart) (*ShoppingCart, error)
-	BeginTransaction() *gorm.DB
-	CommitTransaction(tx *gorm.DB) error
-	RollbackTransaction(tx *gorm.DB) error
+	ListShoppingCarts(context.Context) ([]ShoppingCart, error)
+	// UpdateShoppingCart updates shopping cart entity increasing its version, it fails
+	// with ErrShoppingCartVersionConflict if the stored version is not the given one
+	UpdateShoppingCart(context.Context, transaction.Tx, *ShoppingCart) (*ShoppingCart, error)
+	// CountCustomerCouponUses returns how many shopping carts of a customer have the given coupon applied
+	CountCustomerCouponUses(ctx context.Context, tx transaction.Tx, customerID uuid.UUID, couponID uuid.UUID) (int64, error)
 }
 
 // Server defines what are the different allowed http
@@ -38,6 +38,19 @@ func TestShoppingCartCreateValidate(t *testing.T) {
 		assert.Equal(t, shoppingcart.ErrShoppingCartEmptyItems, err)
 	})
 
+	t.Run("too many items", func(t *testing.T) {
+		item := shoppingcart.Item{
+			Name:        testName,
+			Price:       float32(testAmount),
+			Description: testDescription,
+		}
+		req := shoppingcart.CreateRequest{
+			Items: shoppingcart.Items{item, item, item, item, item, item},
+		}
+		err := req.Validate()
+		assert.Equal(t, shoppingcart.ErrShoppingCartTooManyItems, err)
+	})
+
 	t.Run("invalid item name", func(t *testing.T) {
 		req := shoppingcart.CreateRequest{
 			Items: shoppingcart.Items{
@@ -108,4 +121,12 @@ func TestShoppingCartApplyCoupon(t *testing.T) {
 		err := createdShoppingCart.ApplyCoupon(c.ID, c.Amount)
 		assert.Equal(t, shoppingcart.ErrShoppointCartCouponAmountExceeded, err)
 	})
+
+	t.Run("coupon applied", func(t *testing.T) {
+		err := createdShoppingCart.ApplyCoupon(c.ID, 30)
+		assert.Nil(t, err)
+		assert.Equal(t, c.ID, createdShoppingCart.CouponID)
+		assert.Equal(t, float32(70), createdShoppingCart.Total)
+		assert.NotNil(t, createdShoppingCart.CouponAppliedAt)
+	})
 }
@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+)
+
+// couponService traces the calls to the coupon service
+type couponService struct {
+	next coupon.Service
+}
+
+// NewCouponService returns the given service tracing every call
+func NewCouponService(next coupon.Service) coupon.Service {
+	return &couponService{next: next}
+}
+
+// couponAttribute is the span attribute of the coupon being handled
+func couponAttribute(id uuid.UUID) trace.SpanStartOption {
+	return trace.WithAttributes(attribute.String("coupon.id", id.String()))
+}
+
+// CreateCoupon traces the creation of a coupon
+func (s *couponService) CreateCoupon(ctx context.Context, req coupon.CreateRequest) (c *coupon.Coupon, err error) {
+	ctx, span := Start(ctx, "CouponService.CreateCoupon")
+	defer func() { End(span, err) }()
+	return s.next.CreateCoupon(ctx, req)
+}
+
+// ListCoupons traces the listing of the coupons
+func (s *couponService) ListCoupons(ctx context.Context) (list []coupon.Coupon, err error) {
+	ctx, span := Start(ctx, "CouponService.ListCoupons")
+	defer func() { End(span, err) }()
+	return s.next.ListCoupons(ctx)
+}
+
+// UpdateCoupon traces the update of a coupon
+func (s *couponService) UpdateCoupon(ctx context.Context, id uuid.UUID, req coupon.UpdateRequest) (c *coupon.Coupon, err error) {
+	ctx, span := Start(ctx, "CouponService.UpdateCoupon", couponAttribute(id))
+	defer func() { End(span, err) }()
+	return s.next.UpdateCoupon(ctx, id, req)
+}
+
+// DeactivateCoupon traces the deactivation of a coupon
+func (s *couponService) DeactivateCoupon(ctx context.Context, id uuid.UUID) (c *coupon.Coupon, err error) {
+	ctx, span := Start(ctx, "CouponService.DeactivateCoupon", couponAttribute(id))
+	defer func() { End(span, err) }()
+	return s.next.DeactivateCoupon(ctx, id)
+}
+
+// ReactivateCoupon traces the reactivation of a coupon
+func (s *couponService) ReactivateCoupon(ctx context.Context, id uuid.UUID) (c *coupon.Coupon, err error) {
+	ctx, span := Start(ctx, "CouponService.ReactivateCoupon", couponAttribute(id))
+	defer func() { End(span, err) }()
+	return s.next.ReactivateCoupon(ctx, id)
+}
+
+// DeleteCoupon traces the deletion of a coupon
+func (s *couponService) DeleteCoupon(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := Start(ctx, "CouponService.DeleteCoupon", couponAttribute(id))
+	defer func() { End(span, err) }()
+	return s.next.DeleteCoupon(ctx, id)
+}
+
+// GetCouponStats traces the computation of the stats of a coupon
+func (s *couponService) GetCouponStats(ctx context.Context, id uuid.UUID, req coupon.StatsRequest) (stats *coupon.Stats, err error) {
+	ctx, span := Start(ctx, "CouponService.GetCouponStats", couponAttribute(id))
+	defer func() { End(span, err) }()
+	return s.next.GetCouponStats(ctx, id, req)
+}
+
+// ImportCoupons traces the import of the coupons
+func (s *couponService) ImportCoupons(ctx context.Context, rows []coupon.ImportRow, mode string) (result *coupon.ImportResult, err error) {
+	ctx, span := Start(ctx, "CouponService.ImportCoupons", trace.WithAttributes(
+		attribute.Int("coupon.import.rows", len(rows)),
+		attribute.String("coupon.import.mode", mode),
+	))
+	defer func() { End(span, err) }()
+	return s.next.ImportCoupons(ctx, rows, mode)
+}
+
+// ExportCoupons traces the export of the coupons
+func (s *couponService) ExportCoupons(ctx context.Context, fn func(coupon.Coupon) error) (err error) {
+	ctx, span := Start(ctx, "CouponService.ExportCoupons")
+	defer func() { End(span, err) }()
+	return s.next.ExportCoupons(ctx, fn)
+}
@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// spanKey stores the span of a query in the gorm statement
+const spanKey = "tracing:span"
+
+// callbackName names the gorm callbacks tracing the queries
+const callbackName = "tracing"
+
+// InstrumentDB traces every query made through db as a child of the span
+// in the context of the query, the lock waits of SELECT ... FOR UPDATE included
+func InstrumentDB(db *gorm.DB) error {
+	cb := db.Callback()
+	registrations := []struct {
+		operation string
+		before    func(string, func(*gorm.DB)) error
+		after     func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("*").Register, cb.Create().After("*").Register},
+		{"query", cb.Query().Before("*").Register, cb.Query().After("*").Register},
+		{"update", cb.Update().Before("*").Register, cb.Update().After("*").Register},
+		{"delete", cb.Delete().Before("*").Register, cb.Delete().After("*").Register},
+		{"row", cb.Row().Before("*").Register, cb.Row().After("*").Register},
+		{"raw", cb.Raw().Before("*").Register, cb.Raw().After("*").Register},
+	}
+	for _, r := range registrations {
+		if err := r.before(fmt.Sprintf("%s:before_%s", callbackName, r.operation), startQuery(r.operation)); err != nil {
+			return err
+		}
+		if err := r.after(fmt.Sprintf("%s:after_%s", callbackName, r.operation), endQuery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startQuery starts the span of a query of the given operation
+func startQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement == nil || db.Statement.Context == nil {
+			return
+		}
+		_, span := Start(db.Statement.Context, "db."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationName(operation)),
+		)
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+// endQuery ends the span of a query with its table, statement and error
+func endQuery(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	span.SetAttributes(
+		semconv.DBCollectionName(db.Statement.Table),
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+	err := db.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	End(span, err)
+}
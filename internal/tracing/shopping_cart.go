@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+)
+
+// shoppingCartService traces the calls to the shopping cart service
+type shoppingCartService struct {
+	next shoppingcart.Service
+}
+
+// NewShoppingCartService returns the given service tracing every call
+func NewShoppingCartService(next shoppingcart.Service) shoppingcart.Service {
+	return &shoppingCartService{next: next}
+}
+
+// CreateShoppingCart traces the creation of a shopping cart
+func (s *shoppingCartService) CreateShoppingCart(ctx context.Context, req shoppingcart.CreateRequest) (sc *shoppingcart.ShoppingCart, err error) {
+	ctx, span := Start(ctx, "ShoppingCartService.CreateShoppingCart",
+		trace.WithAttributes(attribute.Int("shopping_cart.items", len(req.Items))))
+	defer func() { End(span, err) }()
+	return s.next.CreateShoppingCart(ctx, req)
+}
+
+// ListShoppingCarts traces the listing of the shopping carts
+func (s *shoppingCartService) ListShoppingCarts(ctx context.Context) (list []shoppingcart.ShoppingCart, err error) {
+	ctx, span := Start(ctx, "ShoppingCartService.ListShoppingCarts")
+	defer func() { End(span, err) }()
+	return s.next.ListShoppingCarts(ctx)
+}
+
+// ApplyCoupon traces the application of a coupon to a shopping cart
+func (s *shoppingCartService) ApplyCoupon(ctx context.Context, scID, couponID uuid.UUID) (sc *shoppingcart.ShoppingCart, err error) {
+	ctx, span := Start(ctx, "ShoppingCartService.ApplyCoupon", trace.WithAttributes(
+		attribute.String("shopping_cart.id", scID.String()),
+		attribute.String("coupon.id", couponID.String()),
+	))
+	defer func() { End(span, err) }()
+	return s.next.ApplyCoupon(ctx, scID, couponID)
+}
@@ -0,0 +1,126 @@
+// Package tracing traces the requests across the http router, the services
+// and the DB queries with OpenTelemetry. The W3C trace context given by the
+// callers is continued, and the spans are exported with OTLP or printed to
+// stdout for local debugging.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+)
+
+// instrumentationName names the tracer of the service
+const instrumentationName = "github.com/nachoconques0/schwarz-challenge"
+
+// DefaultServiceName is the service name of the spans when none is given
+const DefaultServiceName = "schwarz-challenge"
+
+// Exporters
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// ErrUnknownExporter used when the exporter is not one of the supported ones
+var ErrUnknownExporter = internalErrors.NewWrongInput("tracing exporter must be none, stdout or otlp")
+
+// Config configures where the spans are exported
+type Config struct {
+	// Exporter is none, stdout or otlp, none only propagates the trace context
+	Exporter string
+	// OTLPEndpoint is the url the otlp exporter sends the spans to, the
+	// OTEL_EXPORTER_OTLP_* environment variables are used when empty
+	OTLPEndpoint string
+	// SampleRatio is the ratio of the new traces sampled, the traces
+	// started by the callers keep their sampling decision
+	SampleRatio float64
+	// ServiceName names the service in the spans
+	ServiceName string
+}
+
+// DefaultConfig returns the config used when tracing is not configured,
+// propagating the trace context without exporting any span
+func DefaultConfig() Config {
+	return Config{
+		Exporter:    ExporterNone,
+		SampleRatio: 1,
+		ServiceName: DefaultServiceName,
+	}
+}
+
+// Setup installs the global tracer provider and W3C propagators of the
+// given config, the returned function flushes and stops the exporter
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{}
+		if cfg.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, ErrUnknownExporter
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating the %s span exporter - %w", cfg.Exporter, err)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span named after the given operation, child of the span
+// in ctx if any
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End records the given error, if any, on the span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,151 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/mock/gomock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	"github.com/nachoconques0/schwarz-challenge/internal/mocks"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/tracing"
+)
+
+// recordSpans installs a tracer provider recording the ended spans
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestSetup(t *testing.T) {
+	testCases := map[string]struct {
+		exporter string
+		err      error
+	}{
+		"none":    {exporter: tracing.ExporterNone},
+		"stdout":  {exporter: tracing.ExporterStdout},
+		"otlp":    {exporter: tracing.ExporterOTLP},
+		"unknown": {exporter: "jaeger", err: tracing.ErrUnknownExporter},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			previous := otel.GetTracerProvider()
+			t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+			cfg := tracing.DefaultConfig()
+			cfg.Exporter = tc.exporter
+			cfg.OTLPEndpoint = "http://127.0.0.1:4318"
+			shutdown, err := tracing.Setup(context.Background(), cfg)
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NoError(t, shutdown(context.Background()))
+		})
+	}
+}
+
+func TestShoppingCartService(t *testing.T) {
+	recorder := recordSpans(t)
+	ctrl := gomock.NewController(t)
+	next := mocks.NewMockShoppingCartService(ctrl)
+	svc := tracing.NewShoppingCartService(next)
+
+	scID, couponID := uuid.New(), uuid.New()
+	next.EXPECT().ApplyCoupon(gomock.Any(), scID, couponID).Return(nil, coupon.ErrCouponExpired)
+	_, err := svc.ApplyCoupon(context.Background(), scID, couponID)
+	assert.Equal(t, coupon.ErrCouponExpired, err)
+
+	next.EXPECT().CreateShoppingCart(gomock.Any(), gomock.Any()).Return(&shoppingcart.ShoppingCart{}, nil)
+	_, err = svc.CreateShoppingCart(context.Background(), shoppingcart.CreateRequest{})
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "ShoppingCartService.ApplyCoupon", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "ShoppingCartService.CreateShoppingCart", spans[1].Name())
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+}
+
+func TestCouponService_PropagatesSpan(t *testing.T) {
+	recorder := recordSpans(t)
+	ctrl := gomock.NewController(t)
+	next := mocks.NewMockCouponService(ctrl)
+	svc := tracing.NewCouponService(next)
+
+	ctx, parent := tracing.Start(context.Background(), "parent")
+	id := uuid.New()
+	next.EXPECT().DeleteCoupon(gomock.Any(), id).DoAndReturn(func(ctx context.Context, _ uuid.UUID) error {
+		_, child := tracing.Start(ctx, "repository")
+		child.End()
+		return nil
+	})
+	require.NoError(t, svc.DeleteCoupon(ctx, id))
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	assert.Equal(t, "repository", spans[0].Name())
+	assert.Equal(t, "CouponService.DeleteCoupon", spans[1].Name())
+	assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, spans[2].SpanContext().SpanID(), spans[1].Parent().SpanID())
+}
+
+func TestInstrumentDB(t *testing.T) {
+	recorder := recordSpans(t)
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=u password=p dbname=d"), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, tracing.InstrumentDB(db))
+
+	ctx, parent := tracing.Start(context.Background(), "parent")
+	var rows []map[string]interface{}
+	db.WithContext(ctx).Table("coupons").Where("id = ?", 1).Find(&rows)
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	query := spans[0]
+	assert.Equal(t, "db.query", query.Name())
+	assert.Equal(t, spans[1].SpanContext().SpanID(), query.Parent().SpanID())
+	attrs := map[string]string{}
+	for _, a := range query.Attributes() {
+		attrs[string(a.Key)] = a.Value.Emit()
+	}
+	assert.Equal(t, "coupons", attrs["db.collection.name"])
+	assert.Equal(t, `SELECT * FROM "coupons" WHERE id = $1`, attrs["db.query.text"])
+	assert.Equal(t, "postgresql", attrs["db.system"])
+}
+
+func TestEnd(t *testing.T) {
+	recorder := recordSpans(t)
+	_, span := tracing.Start(context.Background(), "failing")
+	tracing.End(span, errors.New("boom"))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "boom", spans[0].Status().Description)
+	assert.Len(t, spans[0].Events(), 1)
+}
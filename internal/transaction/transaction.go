@@ -0,0 +1,34 @@
+// Package transaction defines the unit of work used by the services
+// to run several repository operations atomically, without knowing
+// anything about the storage backing them
+package transaction
+
+import (
+	"context"
+
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+)
+
+var (
+	// ErrBeginTx used when a transaction can not be started
+	ErrBeginTx = internalErrors.NewInternalError("transaction could not be started")
+	// ErrCommitTx used when a transaction can not be committed
+	ErrCommitTx = internalErrors.NewInternalError("transaction could not be committed")
+	// ErrTxPanicked used when the unit of work panics
+	ErrTxPanicked = internalErrors.NewInternalError("transaction aborted unexpectedly")
+	// ErrInvalidTx used when a repository receives a transaction
+	// that was not started by its own backend
+	ErrInvalidTx = internalErrors.NewInternalError("transaction does not belong to this storage")
+)
+
+// Tx is an open transaction. It is opaque to the services, only the
+// repositories of the backend that started it know how to use it
+type Tx interface{}
+
+// Manager runs units of work inside a transaction
+type Manager interface {
+	// WithinTx starts a transaction and runs fn with it. The transaction is
+	// committed when fn succeeds and rolled back when it returns an error or
+	// panics. Errors returned by fn are given back unchanged
+	WithinTx(ctx context.Context, fn func(tx Tx) error) error
+}
@@ -0,0 +1,275 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/logging"
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
+)
+
+var (
+	// ErrMissingRepository used when the webhook repository is nil
+	ErrMissingRepository = internalErrors.NewInternalError("webhook repository is missing")
+	// ErrInvalidDispatcherConfig used when the dispatcher config is not valid
+	ErrInvalidDispatcherConfig = internalErrors.NewInternalError("webhook dispatcher config is invalid")
+)
+
+type publisher struct {
+	repo Repository
+}
+
+// NewPublisher returns an outbox.Publisher queueing a delivery of every
+// event for each subscription receiving its type
+func NewPublisher(repo Repository) (outbox.Publisher, error) {
+	if repo == nil {
+		return nil, ErrMissingRepository
+	}
+	return &publisher{repo: repo}, nil
+}
+
+// Publish queues the deliveries of the event, publishing it again queues nothing new
+func (p *publisher) Publish(ctx context.Context, e outbox.Event) error {
+	subscriptions, err := p.repo.ListSubscriptionsForEvent(ctx, e.Type)
+	if err != nil {
+		return err
+	}
+	deliveries := make([]Delivery, 0, len(subscriptions))
+	for _, s := range subscriptions {
+		d, err := NewDelivery(s, e)
+		if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return p.repo.AddDeliveries(ctx, deliveries...)
+}
+
+// DispatcherConfig defines how often the dispatcher looks for deliveries and how it retries them
+type DispatcherConfig struct {
+	// PollInterval is the time between two looks for pending deliveries
+	PollInterval time.Duration
+	// BatchSize is the number of pending deliveries read at once
+	BatchSize int
+	// MinBackoff is the wait before sending a failed delivery again,
+	// doubled after every failed attempt
+	MinBackoff time.Duration
+	// MaxBackoff bounds the wait between two attempts of a delivery
+	MaxBackoff time.Duration
+	// MaxAttempts is the number of failed attempts before a delivery is a dead letter
+	MaxAttempts int
+	// Timeout bounds every request posted to a subscription
+	Timeout time.Duration
+	// Lease is how long a batch of deliveries is claimed for, the dispatcher
+	// stops sending the batch once it is over since another one may claim
+	// the rest
+	Lease time.Duration
+}
+
+// DefaultDispatcherConfig polls every second and retries from 5 seconds up to
+// an hour, giving up after 10 attempts. A batch is claimed for 10 minutes
+func DefaultDispatcherConfig() DispatcherConfig {
+	return DispatcherConfig{
+		PollInterval: time.Second,
+		BatchSize:    50,
+		MinBackoff:   5 * time.Second,
+		MaxBackoff:   time.Hour,
+		MaxAttempts:  10,
+		Timeout:      10 * time.Second,
+		Lease:        10 * time.Minute,
+	}
+}
+
+// Dispatcher posts the pending deliveries to their subscriptions. Every
+// instance of the service runs one, every dispatcher claims the batch it
+// sends so the deliveries are not sent twice
+type Dispatcher struct {
+	repo   Repository
+	client *http.Client
+	config DispatcherConfig
+	now    func() time.Time
+}
+
+// NewDispatcher returns a Dispatcher posting the deliveries of repo with the
+// given client. Without client, the deliveries are only posted to public
+// addresses, even when the host of a subscription resolves elsewhere since
+// it was created
+func NewDispatcher(repo Repository, client *http.Client, config DispatcherConfig) (*Dispatcher, error) {
+	if repo == nil {
+		return nil, ErrMissingRepository
+	}
+	if config.PollInterval <= 0 || config.BatchSize <= 0 || config.MinBackoff <= 0 ||
+		config.MaxBackoff < config.MinBackoff || config.MaxAttempts <= 0 || config.Timeout <= 0 || config.Lease <= 0 {
+		return nil, ErrInvalidDispatcherConfig
+	}
+	if client == nil {
+		client = &http.Client{Transport: publicTransport()}
+	}
+	return &Dispatcher{
+		repo:   repo,
+		client: client,
+		config: config,
+		now:    time.Now,
+	}, nil
+}
+
+// Run posts the pending deliveries every poll interval until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.DispatchOnce(ctx); err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("webhook: dispatching deliveries", slog.Any("error", err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce claims a batch of due deliveries, posts them and returns how
+// many were accepted. The deliveries claimed by the dispatcher of another
+// instance are left to it
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	claimedAt := d.now()
+	leaseEnd := claimedAt.Add(d.config.Lease)
+	deliveries, err := d.repo.ClaimDeliveries(ctx, claimedAt, leaseEnd, d.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	subscriptions := map[uuid.UUID]*Subscription{}
+	for _, delivery := range deliveries {
+		if err := ctx.Err(); err != nil {
+			return delivered, err
+		}
+		if !d.now().Before(leaseEnd) {
+			return delivered, nil
+		}
+		s, ok := subscriptions[delivery.SubscriptionID]
+		if !ok {
+			s, err = d.repo.GetSubscription(ctx, delivery.SubscriptionID)
+			if err != nil {
+				logging.FromContext(ctx).Error("webhook: getting the subscription of a delivery",
+					slog.String("delivery_id", delivery.ID.String()),
+					slog.String("subscription_id", delivery.SubscriptionID.String()),
+					slog.Any("error", err))
+				if err := d.failSubscription(ctx, delivery, err); err != nil {
+					return delivered, err
+				}
+				continue
+			}
+			subscriptions[delivery.SubscriptionID] = s
+		}
+
+		if err := d.send(ctx, s, delivery); err != nil {
+			logging.FromContext(ctx).Error("webhook: sending delivery",
+				slog.String("delivery_id", delivery.ID.String()),
+				slog.Int("attempts", delivery.Attempts+1),
+				slog.Any("error", err))
+			if err := d.fail(ctx, delivery, err); err != nil {
+				return delivered, err
+			}
+			continue
+		}
+		if err := d.repo.MarkDelivered(ctx, delivery.ID, d.now()); err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+	return delivered, nil
+}
+
+// send posts the signed delivery payload, any response other than 2xx is a failure
+func (d *Dispatcher) send(ctx context.Context, s *Subscription, delivery Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, d.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventTypeHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	req.Header.Set(SignatureHeader, Sign(s.Secret, d.now(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// fail schedules the next attempt of the delivery, or keeps it
+// as a dead letter once it failed too many times
+func (d *Dispatcher) fail(ctx context.Context, delivery Delivery, reason error) error {
+	attempts := delivery.Attempts + 1
+	if attempts >= d.config.MaxAttempts {
+		return d.repo.MarkDead(ctx, delivery.ID, reason.Error())
+	}
+	return d.repo.MarkFailed(ctx, delivery.ID, d.now().Add(d.backoff(delivery.Attempts)), reason.Error())
+}
+
+// failSubscription records the delivery failed since its subscription could
+// not be read, it is a dead letter when the subscription does not exist
+func (d *Dispatcher) failSubscription(ctx context.Context, delivery Delivery, reason error) error {
+	if errors.Is(reason, ErrSubscriptionNotFound) {
+		return d.repo.MarkDead(ctx, delivery.ID, reason.Error())
+	}
+	return d.fail(ctx, delivery, reason)
+}
+
+// backoff returns the wait before sending again a delivery after the given failed attempts
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.config.MinBackoff
+	for i := 0; i < attempts && wait < d.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.config.MaxBackoff {
+		return d.config.MaxBackoff
+	}
+	return wait
+}
+
+// publicTransport returns a transport refusing to connect to the addresses
+// that are not public, checked once the host is resolved. It never goes
+// through a proxy, so the checked address is the one of the subscription
+func publicTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be the address dialed, hiding the one of the subscription
+	transport.Proxy = nil
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(_ string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return ErrSubscriptionPrivateURL
+			}
+			return nil
+		},
+	}
+	transport.DialContext = dialer.DialContext
+	return transport
+}
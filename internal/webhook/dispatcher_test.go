@@ -0,0 +1,286 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	"github.com/nachoconques0/schwarz-challenge/internal/memory"
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
+)
+
+// receiver is a partner endpoint verifying the signature of the received payloads
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	status   int
+	received []webhook.Payload
+	headers  []http.Header
+}
+
+func newReceiver(t *testing.T) *receiver {
+	rcv := &receiver{status: http.StatusOK}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.Nil(t, err)
+		assert.Nil(t, webhook.Verify(testSecret, r.Header.Get(webhook.SignatureHeader), body, time.Now(), time.Minute))
+
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		if rcv.status == http.StatusOK {
+			var p webhook.Payload
+			assert.Nil(t, json.Unmarshal(body, &p))
+			rcv.received = append(rcv.received, p)
+			rcv.headers = append(rcv.headers, r.Header.Clone())
+		}
+		w.WriteHeader(rcv.status)
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (rcv *receiver) setStatus(status int) {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	rcv.status = status
+}
+
+func (rcv *receiver) payloads() []webhook.Payload {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]webhook.Payload{}, rcv.received...)
+}
+
+func TestNewDispatcher(t *testing.T) {
+	repo := newWebhookRepo(t)
+	_, err := webhook.NewDispatcher(nil, nil, webhook.DefaultDispatcherConfig())
+	assert.Equal(t, webhook.ErrMissingRepository, err)
+
+	config := webhook.DefaultDispatcherConfig()
+	config.MaxAttempts = 0
+	_, err = webhook.NewDispatcher(repo, nil, config)
+	assert.Equal(t, webhook.ErrInvalidDispatcherConfig, err)
+
+	config = webhook.DefaultDispatcherConfig()
+	config.Lease = 0
+	_, err = webhook.NewDispatcher(repo, nil, config)
+	assert.Equal(t, webhook.ErrInvalidDispatcherConfig, err)
+
+	d, err := webhook.NewDispatcher(repo, nil, webhook.DefaultDispatcherConfig())
+	assert.Nil(t, err)
+	assert.NotNil(t, d)
+}
+
+func TestPublisher_Publish(t *testing.T) {
+	ctx := context.Background()
+	repo := newWebhookRepo(t)
+	subscribed := createSubscription(t, repo, "https://203.0.113.10/hook", shoppingcart.EventCreated)
+	createSubscription(t, repo, "https://other.test/hook", coupon.EventExhausted)
+	publisher, err := webhook.NewPublisher(repo)
+	require.Nil(t, err)
+
+	e := newEvent(t, shoppingcart.EventCreated)
+	require.Nil(t, publisher.Publish(ctx, e))
+	require.Nil(t, publisher.Publish(ctx, e), "publishing again queues nothing new")
+
+	pending, err := repo.PendingDeliveries(ctx, time.Now(), 10)
+	require.Nil(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, subscribed.ID, pending[0].SubscriptionID)
+	assert.Equal(t, e.ID, pending[0].EventID)
+}
+
+func TestDispatcher_DispatchOnce(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("posts signed payloads", func(t *testing.T) {
+		rcv := newReceiver(t)
+		repo := newWebhookRepo(t)
+		createSubscription(t, repo, rcv.URL, shoppingcart.EventCreated)
+		e := publish(t, repo, shoppingcart.EventCreated)
+		dispatcher := newDispatcher(t, repo, rcv, time.Hour, 3)
+
+		delivered, err := dispatcher.DispatchOnce(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 1, delivered)
+
+		payloads := rcv.payloads()
+		require.Len(t, payloads, 1)
+		assert.Equal(t, e.ID, payloads[0].ID)
+		assert.Equal(t, e.Type, payloads[0].Type)
+		assert.JSONEq(t, string(e.Payload), string(payloads[0].Data))
+		assert.Equal(t, e.Type, rcv.headers[0].Get(webhook.EventTypeHeader))
+		assert.NotEmpty(t, rcv.headers[0].Get(webhook.DeliveryHeader))
+
+		pending, err := repo.PendingDeliveries(ctx, time.Now(), 10)
+		require.Nil(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("leaves the deliveries claimed by another dispatcher", func(t *testing.T) {
+		rcv := newReceiver(t)
+		repo := newWebhookRepo(t)
+		createSubscription(t, repo, rcv.URL, shoppingcart.EventCreated)
+		publish(t, repo, shoppingcart.EventCreated)
+		claimed, err := repo.ClaimDeliveries(ctx, time.Now(), time.Now().Add(time.Hour), 10)
+		require.Nil(t, err)
+		require.Len(t, claimed, 1)
+		dispatcher := newDispatcher(t, repo, rcv, time.Hour, 3)
+
+		delivered, err := dispatcher.DispatchOnce(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 0, delivered)
+		assert.Empty(t, rcv.payloads())
+	})
+
+	t.Run("keeps sending the batch when a subscription is gone", func(t *testing.T) {
+		rcv := newReceiver(t)
+		repo := newWebhookRepo(t)
+		s := createSubscription(t, repo, rcv.URL, shoppingcart.EventCreated)
+		gone, err := webhook.New(webhook.CreateRequest{URL: rcv.URL, EventTypes: []string{shoppingcart.EventCreated}})
+		require.Nil(t, err)
+		orphan, err := webhook.NewDelivery(*gone, newEvent(t, shoppingcart.EventCreated))
+		require.Nil(t, err)
+		d, err := webhook.NewDelivery(*s, newEvent(t, shoppingcart.EventCreated))
+		require.Nil(t, err)
+		require.Nil(t, repo.AddDeliveries(ctx, orphan, d))
+		dispatcher := newDispatcher(t, repo, rcv, time.Hour, 3)
+
+		delivered, err := dispatcher.DispatchOnce(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 1, delivered)
+
+		dead, err := repo.ListDeadLetters(ctx)
+		require.Nil(t, err)
+		require.Len(t, dead, 1)
+		assert.Equal(t, orphan.ID, dead[0].ID)
+		assert.Equal(t, webhook.ErrSubscriptionNotFound.Error(), dead[0].LastError)
+	})
+
+	t.Run("refuses to post to private addresses by default", func(t *testing.T) {
+		rcv := newReceiver(t)
+		repo := newWebhookRepo(t)
+		createSubscription(t, repo, rcv.URL, shoppingcart.EventCreated)
+		publish(t, repo, shoppingcart.EventCreated)
+		dispatcher, err := webhook.NewDispatcher(repo, nil, webhook.DefaultDispatcherConfig())
+		require.Nil(t, err)
+
+		delivered, err := dispatcher.DispatchOnce(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 0, delivered)
+		assert.Empty(t, rcv.payloads())
+	})
+
+	t.Run("retries failed deliveries with backoff", func(t *testing.T) {
+		rcv := newReceiver(t)
+		rcv.setStatus(http.StatusServiceUnavailable)
+		repo := newWebhookRepo(t)
+		createSubscription(t, repo, rcv.URL, shoppingcart.EventCreated)
+		publish(t, repo, shoppingcart.EventCreated)
+		dispatcher := newDispatcher(t, repo, rcv, 20*time.Millisecond, 5)
+
+		delivered, err := dispatcher.DispatchOnce(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 0, delivered)
+
+		rcv.setStatus(http.StatusOK)
+		delivered, err = dispatcher.DispatchOnce(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 0, delivered, "the failed delivery is not due yet")
+
+		time.Sleep(30 * time.Millisecond)
+		delivered, err = dispatcher.DispatchOnce(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 1, delivered)
+		assert.Len(t, rcv.payloads(), 1)
+	})
+
+	t.Run("keeps dead letters once failed too many times", func(t *testing.T) {
+		rcv := newReceiver(t)
+		rcv.setStatus(http.StatusInternalServerError)
+		repo := newWebhookRepo(t)
+		createSubscription(t, repo, rcv.URL, shoppingcart.EventCreated)
+		publish(t, repo, shoppingcart.EventCreated)
+		dispatcher := newDispatcher(t, repo, rcv, time.Millisecond, 2)
+
+		for i := 0; i < 2; i++ {
+			time.Sleep(5 * time.Millisecond)
+			_, err := dispatcher.DispatchOnce(ctx)
+			require.Nil(t, err)
+		}
+
+		dead, err := repo.ListDeadLetters(ctx)
+		require.Nil(t, err)
+		require.Len(t, dead, 1)
+		assert.Equal(t, 2, dead[0].Attempts)
+		assert.Equal(t, "webhook answered 500", dead[0].LastError)
+
+		time.Sleep(5 * time.Millisecond)
+		delivered, err := dispatcher.DispatchOnce(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 0, delivered, "dead letters are not sent anymore")
+
+		rcv.setStatus(http.StatusOK)
+		_, err = repo.RetryDeadLetter(ctx, dead[0].ID, time.Now())
+		require.Nil(t, err)
+		delivered, err = dispatcher.DispatchOnce(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 1, delivered)
+	})
+}
+
+func newWebhookRepo(t *testing.T) webhook.Repository {
+	repo, err := memory.NewWebhookRepository(memory.NewStore())
+	require.Nil(t, err)
+	return repo
+}
+
+func createSubscription(t *testing.T, repo webhook.Repository, url string, eventTypes ...string) *webhook.Subscription {
+	s, err := webhook.New(webhook.CreateRequest{URL: url, EventTypes: eventTypes, Secret: testSecret})
+	require.Nil(t, err)
+	s, err = repo.CreateSubscription(context.Background(), s)
+	require.Nil(t, err)
+	return s
+}
+
+func newEvent(t *testing.T, eventType string) outbox.Event {
+	e, err := outbox.NewEvent("test", uuid.New(), eventType, map[string]string{"name": "test"})
+	require.Nil(t, err)
+	return e
+}
+
+// publish queues the deliveries of a new event of the given type
+func publish(t *testing.T, repo webhook.Repository, eventType string) outbox.Event {
+	publisher, err := webhook.NewPublisher(repo)
+	require.Nil(t, err)
+	e := newEvent(t, eventType)
+	require.Nil(t, publisher.Publish(context.Background(), e))
+	return e
+}
+
+func newDispatcher(t *testing.T, repo webhook.Repository, rcv *receiver, backoff time.Duration, maxAttempts int) *webhook.Dispatcher {
+	dispatcher, err := webhook.NewDispatcher(repo, rcv.Client(), webhook.DispatcherConfig{
+		PollInterval: time.Millisecond,
+		BatchSize:    10,
+		MinBackoff:   backoff,
+		MaxBackoff:   backoff,
+		MaxAttempts:  maxAttempts,
+		Timeout:      time.Second,
+		Lease:        time.Minute,
+	})
+	require.Nil(t, err)
+	return dispatcher
+}
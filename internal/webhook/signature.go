@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+)
+
+// ErrInvalidSignature used when a payload signature does not match
+var ErrInvalidSignature = internalErrors.NewWrongInput("webhook signature is invalid")
+
+// Headers of the requests posted to the subscriptions
+const (
+	// SignatureHeader carries the timestamp and the HMAC of the payload
+	SignatureHeader = "X-Webhook-Signature"
+	// EventTypeHeader carries the type of the event posted
+	EventTypeHeader = "X-Webhook-Event"
+	// DeliveryHeader carries the ID of the delivery, the same on every retry
+	DeliveryHeader = "X-Webhook-Delivery"
+)
+
+// Sign returns the signature header of the given payload sent at the given moment.
+// It has the form t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<payload>">
+func Sign(secret string, at time.Time, payload []byte) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, computeSignature(secret, ts, payload))
+}
+
+// Verify checks the signature header of the given payload was computed with
+// the secret no longer than tolerance ago, meant for the partners receiving them
+func Verify(secret, header string, payload []byte, now time.Time, tolerance time.Duration) error {
+	var ts, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			signature = value
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || signature == "" {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(computeSignature(secret, ts, payload))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func computeSignature(secret, ts string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
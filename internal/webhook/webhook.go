@@ -0,0 +1,415 @@
+// Package webhook contains the domain logic of the webhook subscriptions,
+// letting partner systems be called back on the domain events
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nachoconques0/schwarz-challenge/internal/coupon"
+	internalErrors "github.com/nachoconques0/schwarz-challenge/internal/errors"
+	"github.com/nachoconques0/schwarz-challenge/internal/outbox"
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+)
+
+var (
+	// ErrSubscriptionInvalidURL used when the subscription URL is not an absolute http(s) URL
+	ErrSubscriptionInvalidURL = internalErrors.NewWrongInput("webhook url must be an absolute http or https url")
+	// ErrSubscriptionUnresolvableURL used when the host of the subscription URL can not be resolved
+	ErrSubscriptionUnresolvableURL = internalErrors.NewWrongInput("webhook url host can not be resolved")
+	// ErrSubscriptionPrivateURL used when the subscription URL points to a loopback, private or link local address
+	ErrSubscriptionPrivateURL = internalErrors.NewWrongInput("webhook url must point to a public address")
+	// ErrSubscriptionEmptyEventTypes used when the subscription has no event types
+	ErrSubscriptionEmptyEventTypes = internalErrors.NewWrongInput("webhook event types are empty")
+	// ErrSubscriptionUnknownEventType used when the subscription has an unknown event type
+	ErrSubscriptionUnknownEventType = internalErrors.NewWrongInput("webhook event type is unknown")
+	// ErrSubscriptionShortSecret used when the subscription secret is too short
+	ErrSubscriptionShortSecret = internalErrors.NewWrongInput("webhook secret must have at least 16 characters")
+	// ErrSubscriptionEmptyUpdate used when an update request changes nothing
+	ErrSubscriptionEmptyUpdate = internalErrors.NewWrongInput("webhook update request is empty")
+	// ErrSubscriptionNotFound used when the webhook subscription is not found
+	ErrSubscriptionNotFound = internalErrors.NewNotFound("webhook subscription not found")
+	// ErrDeliveryNotFound used when the webhook delivery is not found
+	ErrDeliveryNotFound = internalErrors.NewNotFound("webhook delivery not found")
+)
+
+const (
+	// MinSecretLength is the minimum length of the secrets given by the partners
+	MinSecretLength = 16
+	// secretBytes is the number of random bytes of the generated secrets
+	secretBytes = 32
+)
+
+// Delivery statuses
+const (
+	// StatusPending is the status of the deliveries not sent yet or being retried
+	StatusPending = "pending"
+	// StatusDelivered is the status of the deliveries accepted by the partner
+	StatusDelivered = "delivered"
+	// StatusDead is the status of the deliveries that failed too many times
+	StatusDead = "dead"
+)
+
+// EventTypes lists the domain events partners can subscribe to
+var EventTypes = []string{
+	shoppingcart.EventCreated,
+	shoppingcart.EventCouponApplied,
+	coupon.EventExhausted,
+}
+
+// Subscription defines a partner endpoint called back on the given event types
+type Subscription struct {
+	// ID Unique Identifier of the subscription
+	ID uuid.UUID `json:"id,omitempty"`
+	// URL receiving the events
+	URL string `json:"url,omitempty"`
+	// EventTypes the partner is subscribed to
+	EventTypes EventTypeList `json:"event_types,omitempty"`
+	// Secret used to sign the payloads, only returned once created
+	Secret string `json:"secret,omitempty"`
+	// Timestamp when it was created
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	// Timestamp of the last update
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// Redacted returns the subscription without its secret
+func (s Subscription) Redacted() Subscription {
+	s.Secret = ""
+	return s
+}
+
+// Subscribed checks if the subscription receives the given event type
+func (s *Subscription) Subscribed(eventType string) bool {
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Update sets the fields given in the update request
+func (s *Subscription) Update(req UpdateRequest) {
+	if req.URL != nil {
+		s.URL = *req.URL
+	}
+	if req.EventTypes != nil {
+		s.EventTypes = *req.EventTypes
+	}
+	if req.Secret != nil {
+		s.Secret = *req.Secret
+	}
+}
+
+// CreateRequest defines needed field to create a subscription
+type CreateRequest struct {
+	URL        string        `json:"url,omitempty" jsonschema:"required,pattern=^https?://"`
+	EventTypes EventTypeList `json:"event_types,omitempty" jsonschema:"required,minItems=1,uniqueItems,enum=shopping_cart.created|shopping_cart.coupon_applied|coupon.exhausted"`
+	// Secret is generated when empty
+	Secret string `json:"secret,omitempty" jsonschema:"minLength=16"`
+}
+
+// Validate validates the create request
+func (r CreateRequest) Validate() error {
+	if err := validateURL(r.URL); err != nil {
+		return err
+	}
+	if err := r.EventTypes.Validate(); err != nil {
+		return err
+	}
+	if r.Secret != "" && len(r.Secret) < MinSecretLength {
+		return ErrSubscriptionShortSecret
+	}
+	return nil
+}
+
+// UpdateRequest defines the fields that can be changed on a subscription,
+// only the given ones are updated
+type UpdateRequest struct {
+	URL        *string        `json:"url,omitempty" jsonschema:"pattern=^https?://"`
+	EventTypes *EventTypeList `json:"event_types,omitempty" jsonschema:"minItems=1,uniqueItems,enum=shopping_cart.created|shopping_cart.coupon_applied|coupon.exhausted"`
+	Secret     *string        `json:"secret,omitempty" jsonschema:"minLength=16"`
+}
+
+// Validate validates the update request
+func (r UpdateRequest) Validate() error {
+	if r.URL == nil && r.EventTypes == nil && r.Secret == nil {
+		return ErrSubscriptionEmptyUpdate
+	}
+	if r.URL != nil {
+		if err := validateURL(*r.URL); err != nil {
+			return err
+		}
+	}
+	if r.EventTypes != nil {
+		if err := r.EventTypes.Validate(); err != nil {
+			return err
+		}
+	}
+	if r.Secret != nil && len(*r.Secret) < MinSecretLength {
+		return ErrSubscriptionShortSecret
+	}
+	return nil
+}
+
+// validateURL checks the URL is an absolute http(s) URL, rejecting the hosts
+// given as an address that is not public. The host names are resolved by
+// ResolveURL, and the addresses are checked again when posting the deliveries
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrSubscriptionInvalidURL
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !isPublicIP(ip) {
+		return ErrSubscriptionPrivateURL
+	}
+	return nil
+}
+
+// ResolveURL checks the host of a valid subscription URL only resolves to
+// public addresses, so the webhooks can not reach the service own network
+func ResolveURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ErrSubscriptionInvalidURL
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil || len(addrs) == 0 {
+		return ErrSubscriptionUnresolvableURL
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return ErrSubscriptionPrivateURL
+		}
+	}
+	return nil
+}
+
+// isPublicIP returns whether ip is not a loopback, private, link local,
+// multicast or unspecified address
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// New returns a new Subscription instance, generating its secret when not given
+func New(req CreateRequest) (*Subscription, error) {
+	secret := req.Secret
+	if secret == "" {
+		generated, err := generateSecret()
+		if err != nil {
+			return nil, err
+		}
+		secret = generated
+	}
+	return &Subscription{
+		ID:         uuid.New(),
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+		Secret:     secret,
+	}, nil
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// EventTypeList contains the event types of a subscription in json format
+type EventTypeList []string
+
+// Validate checks the list is not empty and only has known event types
+func (l EventTypeList) Validate() error {
+	if len(l) == 0 {
+		return ErrSubscriptionEmptyEventTypes
+	}
+	for _, t := range l {
+		known := false
+		for _, k := range EventTypes {
+			if t == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return ErrSubscriptionUnknownEventType
+		}
+	}
+	return nil
+}
+
+// Value for DB
+func (l EventTypeList) Value() (driver.Value, error) {
+	if l == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(l)
+}
+
+// Scan will unmarshall the event types
+func (l *EventTypeList) Scan(src interface{}) error {
+	switch t := src.(type) {
+	case string:
+		return json.Unmarshal([]byte(t), l)
+	case []byte:
+		return json.Unmarshal(t, l)
+	case nil:
+		*l = nil
+		return nil
+	}
+	return errors.New("err unmarshal event types")
+}
+
+// Payload is the body posted to the subscriptions
+type Payload struct {
+	ID            uuid.UUID       `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   uuid.UUID       `json:"aggregate_id"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// Delivery is the sending of an event to a subscription, retried until the
+// partner accepts it or it fails too many times and is kept as a dead letter
+type Delivery struct {
+	// ID Unique Identifier of the delivery
+	ID uuid.UUID `json:"id"`
+	// SubscriptionID is the subscription the event is sent to
+	SubscriptionID uuid.UUID `json:"subscription_id"`
+	// EventID is the ID of the outbox event sent
+	EventID uuid.UUID `json:"event_id"`
+	// EventType is the type of the event sent
+	EventType string `json:"event_type"`
+	// Payload is the signed body posted to the subscription
+	Payload json.RawMessage `json:"payload"`
+	// Status is pending, delivered or dead
+	Status string `json:"status"`
+	// Attempts is the number of failed deliveries so far
+	Attempts int `json:"attempts"`
+	// NextAttemptAt is the earliest moment the delivery is sent again
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	// LastError is the reason of the last failed delivery
+	LastError string `json:"last_error,omitempty"`
+	// DeliveredAt is the moment the partner accepted the delivery
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	// Timestamp when it was created
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewDelivery returns the pending delivery of the given event to the subscription
+func NewDelivery(s Subscription, e outbox.Event) (Delivery, error) {
+	payload, err := json.Marshal(Payload{
+		ID:            e.ID,
+		Type:          e.Type,
+		AggregateType: e.AggregateType,
+		AggregateID:   e.AggregateID,
+		OccurredAt:    e.OccurredAt,
+		Data:          e.Payload,
+	})
+	if err != nil {
+		return Delivery{}, err
+	}
+	now := time.Now()
+	return Delivery{
+		ID:             uuid.New(),
+		SubscriptionID: s.ID,
+		EventID:        e.ID,
+		EventType:      e.Type,
+		Payload:        payload,
+		Status:         StatusPending,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+	}, nil
+}
+
+// Service defines the available functions for the webhook Service
+type Service interface {
+	// CreateSubscription creates a new subscription, the only response carrying its secret
+	CreateSubscription(context.Context, CreateRequest) (*Subscription, error)
+	// ListSubscriptions returns every subscription
+	ListSubscriptions(context.Context) ([]Subscription, error)
+	// GetSubscription returns a subscription
+	GetSubscription(context.Context, uuid.UUID) (*Subscription, error)
+	// UpdateSubscription updates the url, event types or secret of a subscription
+	UpdateSubscription(context.Context, uuid.UUID, UpdateRequest) (*Subscription, error)
+	// DeleteSubscription deletes a subscription and its pending deliveries
+	DeleteSubscription(context.Context, uuid.UUID) error
+	// ListDeadLetters returns the deliveries that failed too many times
+	ListDeadLetters(context.Context) ([]Delivery, error)
+	// RetryDeadLetter sends a dead letter once more
+	RetryDeadLetter(context.Context, uuid.UUID) (*Delivery, error)
+}
+
+// Repository defines the available functions for the webhook repository
+type Repository interface {
+	// CreateSubscription stores a new subscription
+	CreateSubscription(context.Context, *Subscription) (*Subscription, error)
+	// GetSubscription returns a subscription
+	GetSubscription(context.Context, uuid.UUID) (*Subscription, error)
+	// ListSubscriptions returns every subscription
+	ListSubscriptions(context.Context) ([]Subscription, error)
+	// ListSubscriptionsForEvent returns the subscriptions receiving the given event type
+	ListSubscriptionsForEvent(ctx context.Context, eventType string) ([]Subscription, error)
+	// UpdateSubscription updates a subscription
+	UpdateSubscription(context.Context, *Subscription) (*Subscription, error)
+	// DeleteSubscription deletes a subscription and its deliveries
+	DeleteSubscription(context.Context, uuid.UUID) error
+	// AddDeliveries stores the given deliveries, skipping the events
+	// already delivered to the same subscription
+	AddDeliveries(context.Context, ...Delivery) error
+	// PendingDeliveries returns up to limit pending deliveries due at the given moment
+	PendingDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error)
+	// ClaimDeliveries returns the deliveries PendingDeliveries would return and
+	// keeps them from the other dispatchers until the given moment, by when they
+	// are due again unless marked. Claims never overlap, so the dispatchers of
+	// several instances never send the same deliveries at once
+	ClaimDeliveries(ctx context.Context, now, until time.Time, limit int) ([]Delivery, error)
+	// MarkDelivered records the delivery was accepted
+	MarkDelivered(ctx context.Context, id uuid.UUID, at time.Time) error
+	// MarkFailed records a failed delivery, sent again once next is reached
+	MarkFailed(ctx context.Context, id uuid.UUID, next time.Time, reason string) error
+	// MarkDead records a failed delivery that is not sent anymore
+	MarkDead(ctx context.Context, id uuid.UUID, reason string) error
+	// ListDeadLetters returns the dead deliveries
+	ListDeadLetters(context.Context) ([]Delivery, error)
+	// RetryDeadLetter makes a dead delivery pending again, due at the given moment
+	RetryDeadLetter(ctx context.Context, id uuid.UUID, at time.Time) (*Delivery, error)
+}
+
+// Server defines what are the different allowed http
+// endpoints that can be consumed
+type Server interface {
+	// CreateSubscription receives a request in order to create a subscription
+	CreateSubscription(w http.ResponseWriter, r *http.Request)
+	// ListSubscriptions returns a list of subscriptions
+	ListSubscriptions(w http.ResponseWriter, r *http.Request)
+	// GetSubscription returns a subscription
+	GetSubscription(w http.ResponseWriter, r *http.Request)
+	// UpdateSubscription receives a request in order to update a subscription
+	UpdateSubscription(w http.ResponseWriter, r *http.Request)
+	// DeleteSubscription receives a request in order to delete a subscription
+	DeleteSubscription(w http.ResponseWriter, r *http.Request)
+	// ListDeadLetters returns the deliveries that failed too many times
+	ListDeadLetters(w http.ResponseWriter, r *http.Request)
+	// RetryDeadLetter receives a request in order to send a dead letter once more
+	RetryDeadLetter(w http.ResponseWriter, r *http.Request)
+}
@@ -0,0 +1,196 @@
+package webhook_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	shoppingcart "github.com/nachoconques0/schwarz-challenge/internal/shopping_cart"
+	"github.com/nachoconques0/schwarz-challenge/internal/webhook"
+)
+
+const testSecret = "0123456789abcdef"
+
+func TestCreateRequestValidate(t *testing.T) {
+	testCases := map[string]struct {
+		req           webhook.CreateRequest
+		expectedError error
+	}{
+		"invalid url": {
+			req:           webhook.CreateRequest{URL: "partner.test/hook", EventTypes: webhook.EventTypeList{shoppingcart.EventCreated}},
+			expectedError: webhook.ErrSubscriptionInvalidURL,
+		},
+		"unsupported scheme": {
+			req:           webhook.CreateRequest{URL: "ftp://203.0.113.10/hook", EventTypes: webhook.EventTypeList{shoppingcart.EventCreated}},
+			expectedError: webhook.ErrSubscriptionInvalidURL,
+		},
+		"host name": {
+			req: webhook.CreateRequest{URL: "https://partner.invalid/hook", EventTypes: webhook.EventTypeList{shoppingcart.EventCreated}},
+		},
+		"loopback host": {
+			req:           webhook.CreateRequest{URL: "http://127.0.0.1:8080/hook", EventTypes: webhook.EventTypeList{shoppingcart.EventCreated}},
+			expectedError: webhook.ErrSubscriptionPrivateURL,
+		},
+		"loopback ipv6 host": {
+			req:           webhook.CreateRequest{URL: "http://[::1]/hook", EventTypes: webhook.EventTypeList{shoppingcart.EventCreated}},
+			expectedError: webhook.ErrSubscriptionPrivateURL,
+		},
+		"private host": {
+			req:           webhook.CreateRequest{URL: "https://10.0.0.12/hook", EventTypes: webhook.EventTypeList{shoppingcart.EventCreated}},
+			expectedError: webhook.ErrSubscriptionPrivateURL,
+		},
+		"link local host": {
+			req:           webhook.CreateRequest{URL: "http://169.254.169.254/latest/meta-data", EventTypes: webhook.EventTypeList{shoppingcart.EventCreated}},
+			expectedError: webhook.ErrSubscriptionPrivateURL,
+		},
+		"empty event types": {
+			req:           webhook.CreateRequest{URL: "https://203.0.113.10/hook"},
+			expectedError: webhook.ErrSubscriptionEmptyEventTypes,
+		},
+		"unknown event type": {
+			req:           webhook.CreateRequest{URL: "https://203.0.113.10/hook", EventTypes: webhook.EventTypeList{"coupon.created"}},
+			expectedError: webhook.ErrSubscriptionUnknownEventType,
+		},
+		"short secret": {
+			req:           webhook.CreateRequest{URL: "https://203.0.113.10/hook", EventTypes: webhook.EventTypeList{shoppingcart.EventCreated}, Secret: "short"},
+			expectedError: webhook.ErrSubscriptionShortSecret,
+		},
+		"valid": {
+			req: webhook.CreateRequest{URL: "https://203.0.113.10/hook", EventTypes: webhook.EventTypeList{shoppingcart.EventCreated}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedError, tc.req.Validate())
+		})
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := map[string]struct {
+		ctx           context.Context
+		url           string
+		expectedError error
+	}{
+		"unresolvable host": {
+			ctx:           context.Background(),
+			url:           "https://partner.invalid/hook",
+			expectedError: webhook.ErrSubscriptionUnresolvableURL,
+		},
+		"host resolving to loopback": {
+			ctx:           context.Background(),
+			url:           "http://localhost:8080/hook",
+			expectedError: webhook.ErrSubscriptionPrivateURL,
+		},
+		"canceled request": {
+			ctx:           canceled,
+			url:           "https://partner.invalid/hook",
+			expectedError: context.Canceled,
+		},
+		"public address": {
+			ctx: context.Background(),
+			url: "https://203.0.113.10/hook",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedError, webhook.ResolveURL(tc.ctx, tc.url))
+		})
+	}
+}
+
+func TestUpdateRequestValidate(t *testing.T) {
+	url := "https://203.0.113.10/hook"
+	invalidURL := "partner"
+	secret := "short"
+	empty := webhook.EventTypeList{}
+
+	assert.Equal(t, webhook.ErrSubscriptionEmptyUpdate, webhook.UpdateRequest{}.Validate())
+	assert.Equal(t, webhook.ErrSubscriptionInvalidURL, webhook.UpdateRequest{URL: &invalidURL}.Validate())
+	assert.Equal(t, webhook.ErrSubscriptionEmptyEventTypes, webhook.UpdateRequest{EventTypes: &empty}.Validate())
+	assert.Equal(t, webhook.ErrSubscriptionShortSecret, webhook.UpdateRequest{Secret: &secret}.Validate())
+	assert.Nil(t, webhook.UpdateRequest{URL: &url}.Validate())
+}
+
+func TestNew(t *testing.T) {
+	t.Run("generates a secret", func(t *testing.T) {
+		s, err := webhook.New(webhook.CreateRequest{URL: "https://203.0.113.10/hook"})
+		require.Nil(t, err)
+		assert.True(t, strings.HasPrefix(s.Secret, "whsec_"))
+
+		other, err := webhook.New(webhook.CreateRequest{URL: "https://203.0.113.10/hook"})
+		require.Nil(t, err)
+		assert.NotEqual(t, s.Secret, other.Secret)
+	})
+	t.Run("keeps the given secret", func(t *testing.T) {
+		s, err := webhook.New(webhook.CreateRequest{URL: "https://203.0.113.10/hook", Secret: testSecret})
+		require.Nil(t, err)
+		assert.Equal(t, testSecret, s.Secret)
+		assert.Empty(t, s.Redacted().Secret)
+	})
+}
+
+func TestSignature(t *testing.T) {
+	payload := []byte(`{"type":"coupon.exhausted"}`)
+	now := time.Now()
+	header := webhook.Sign(testSecret, now, payload)
+
+	testCases := map[string]struct {
+		secret        string
+		header        string
+		payload       []byte
+		now           time.Time
+		expectedError error
+	}{
+		"valid": {
+			secret:  testSecret,
+			header:  header,
+			payload: payload,
+			now:     now,
+		},
+		"other secret": {
+			secret:        "fedcba9876543210",
+			header:        header,
+			payload:       payload,
+			now:           now,
+			expectedError: webhook.ErrInvalidSignature,
+		},
+		"tampered payload": {
+			secret:        testSecret,
+			header:        header,
+			payload:       []byte(`{"type":"shopping_cart.created"}`),
+			now:           now,
+			expectedError: webhook.ErrInvalidSignature,
+		},
+		"too old": {
+			secret:        testSecret,
+			header:        header,
+			payload:       payload,
+			now:           now.Add(10 * time.Minute),
+			expectedError: webhook.ErrInvalidSignature,
+		},
+		"malformed header": {
+			secret:        testSecret,
+			header:        "v1=abc",
+			payload:       payload,
+			now:           now,
+			expectedError: webhook.ErrInvalidSignature,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := webhook.Verify(tc.secret, tc.header, tc.payload, tc.now, 5*time.Minute)
+			assert.Equal(t, tc.expectedError, err)
+		})
+	}
+}
@@ -0,0 +1,12 @@
+BEGIN;
+
+DROP INDEX IF EXISTS schwarz.shopping_cart_customer_id_coupon_id_idx;
+
+ALTER TABLE schwarz.coupon
+  DROP COLUMN IF EXISTS max_uses_per_customer,
+  DROP COLUMN IF EXISTS customer_id;
+
+ALTER TABLE schwarz.shopping_cart
+  DROP COLUMN IF EXISTS customer_id;
+
+COMMIT;
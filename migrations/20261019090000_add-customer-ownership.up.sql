@@ -0,0 +1,13 @@
+BEGIN;
+
+ALTER TABLE schwarz.shopping_cart
+  ADD COLUMN customer_id UUID;
+
+ALTER TABLE schwarz.coupon
+  ADD COLUMN customer_id UUID,
+  ADD COLUMN max_uses_per_customer INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX shopping_cart_customer_id_coupon_id_idx
+  ON schwarz.shopping_cart (customer_id, coupon_id);
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+DROP INDEX IF EXISTS schwarz.shopping_cart_coupon_id_idx;
+
+ALTER TABLE schwarz.coupon
+  DROP COLUMN IF EXISTS deleted_at,
+  DROP COLUMN IF EXISTS deactivated_at,
+  DROP COLUMN IF EXISTS valid_until,
+  DROP COLUMN IF EXISTS valid_from;
+
+COMMIT;
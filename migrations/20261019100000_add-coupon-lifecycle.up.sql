@@ -0,0 +1,12 @@
+BEGIN;
+
+ALTER TABLE schwarz.coupon
+  ADD COLUMN valid_from TIMESTAMPTZ,
+  ADD COLUMN valid_until TIMESTAMPTZ,
+  ADD COLUMN deactivated_at TIMESTAMPTZ,
+  ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX shopping_cart_coupon_id_idx
+  ON schwarz.shopping_cart (coupon_id);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE schwarz.shopping_cart
+  DROP COLUMN IF EXISTS coupon_applied_at;
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+ALTER TABLE schwarz.shopping_cart
+  ADD COLUMN coupon_applied_at TIMESTAMPTZ;
+
+UPDATE schwarz.shopping_cart
+  SET coupon_applied_at = updated_at
+  WHERE coupon_id IS NOT NULL
+    AND coupon_id <> '00000000-0000-0000-0000-000000000000';
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP INDEX IF EXISTS schwarz.coupon_code_idx;
+
+ALTER TABLE schwarz.coupon
+  DROP COLUMN IF EXISTS type,
+  DROP COLUMN IF EXISTS code;
+
+COMMIT;
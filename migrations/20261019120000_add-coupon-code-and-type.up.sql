@@ -0,0 +1,11 @@
+BEGIN;
+
+ALTER TABLE schwarz.coupon
+  ADD COLUMN code TEXT NOT NULL DEFAULT '',
+  ADD COLUMN type TEXT NOT NULL DEFAULT 'fixed';
+
+CREATE UNIQUE INDEX coupon_code_idx
+  ON schwarz.coupon (code)
+  WHERE code <> '' AND deleted_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE schwarz.shopping_cart DROP COLUMN IF EXISTS version;
+ALTER TABLE schwarz.coupon DROP COLUMN IF EXISTS version;
+
+COMMIT;
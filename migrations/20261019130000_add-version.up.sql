@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE schwarz.coupon ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE schwarz.shopping_cart ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+CREATE TABLE schwarz.idempotency_key (
+  scope TEXT NOT NULL,
+  key TEXT NOT NULL,
+  fingerprint TEXT NOT NULL,
+  status_code INT NOT NULL DEFAULT 0,
+  header JSONB DEFAULT NULL,
+  body BYTEA DEFAULT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  completed_at TIMESTAMPTZ DEFAULT NULL,
+  PRIMARY KEY (scope, key)
+);
+
+COMMIT;
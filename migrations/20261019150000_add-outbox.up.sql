@@ -0,0 +1,19 @@
+BEGIN;
+
+CREATE TABLE schwarz.outbox (
+  seq BIGSERIAL PRIMARY KEY,
+  id UUID NOT NULL UNIQUE,
+  aggregate_type TEXT NOT NULL,
+  aggregate_id UUID NOT NULL,
+  type TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  occurred_at TIMESTAMPTZ NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  published_at TIMESTAMPTZ DEFAULT NULL,
+  last_error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX outbox_pending_idx ON schwarz.outbox (seq) WHERE published_at IS NULL;
+
+COMMIT;
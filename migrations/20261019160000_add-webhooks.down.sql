@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS schwarz.webhook_delivery;
+DROP TABLE IF EXISTS schwarz.webhook_subscription;
+
+COMMIT;
@@ -0,0 +1,36 @@
+BEGIN;
+
+CREATE TABLE schwarz.webhook_subscription (
+  id UUID PRIMARY KEY,
+  url TEXT NOT NULL,
+  event_types JSONB NOT NULL DEFAULT '[]',
+  secret TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE schwarz.webhook_delivery (
+  id UUID PRIMARY KEY,
+  subscription_id UUID NOT NULL REFERENCES schwarz.webhook_subscription (id) ON DELETE CASCADE,
+  event_id UUID NOT NULL,
+  event_type TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending',
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  last_error TEXT NOT NULL DEFAULT '',
+  delivered_at TIMESTAMPTZ DEFAULT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX webhook_delivery_pending_idx ON schwarz.webhook_delivery (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_delivery_dead_idx ON schwarz.webhook_delivery (created_at) WHERE status = 'dead';
+
+-- Update triggers
+CREATE TRIGGER set_updated_at
+  BEFORE INSERT OR UPDATE ON schwarz.webhook_subscription
+  FOR EACH ROW
+  EXECUTE PROCEDURE schwarz.set_updated_at ();
+
+COMMIT;
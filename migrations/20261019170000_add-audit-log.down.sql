@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS schwarz.audit_log;
+DROP FUNCTION IF EXISTS schwarz.reject_audit_log_change ();
+
+COMMIT;
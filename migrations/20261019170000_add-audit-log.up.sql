@@ -0,0 +1,34 @@
+BEGIN;
+
+CREATE TABLE schwarz.audit_log (
+  seq BIGSERIAL PRIMARY KEY,
+  id UUID NOT NULL UNIQUE,
+  entity_type TEXT NOT NULL,
+  entity_id UUID NOT NULL,
+  action TEXT NOT NULL,
+  actor TEXT NOT NULL,
+  request_id TEXT NOT NULL DEFAULT '',
+  before JSONB DEFAULT NULL,
+  after JSONB DEFAULT NULL,
+  diff JSONB NOT NULL DEFAULT '{}',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX audit_log_entity_idx ON schwarz.audit_log (entity_type, entity_id, seq);
+
+-- The audit log is append only
+CREATE OR REPLACE FUNCTION schwarz.reject_audit_log_change ()
+    RETURNS TRIGGER
+    AS $plpgsql$
+BEGIN
+    RAISE EXCEPTION 'schwarz.audit_log is append only';
+END;
+$plpgsql$
+LANGUAGE plpgsql;
+
+CREATE TRIGGER reject_change
+  BEFORE UPDATE OR DELETE ON schwarz.audit_log
+  FOR EACH ROW
+  EXECUTE PROCEDURE schwarz.reject_audit_log_change ();
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP INDEX IF EXISTS schwarz.idempotency_key_created_at_idx;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+-- The expired keys are swept by their reservation time
+CREATE INDEX idempotency_key_created_at_idx ON schwarz.idempotency_key (created_at);
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP INDEX IF EXISTS schwarz.outbox_pending_aggregate_idx;
+DROP INDEX IF EXISTS schwarz.outbox_pending_idx;
+CREATE INDEX outbox_pending_idx ON schwarz.outbox (seq) WHERE published_at IS NULL;
+
+ALTER TABLE schwarz.outbox DROP COLUMN IF EXISTS dead_at;
+
+COMMIT;
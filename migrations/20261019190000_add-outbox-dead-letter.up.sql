@@ -0,0 +1,10 @@
+BEGIN;
+
+-- The events running out of attempts are kept but never delivered again
+ALTER TABLE schwarz.outbox ADD COLUMN dead_at TIMESTAMPTZ DEFAULT NULL;
+
+DROP INDEX IF EXISTS schwarz.outbox_pending_idx;
+CREATE INDEX outbox_pending_idx ON schwarz.outbox (seq) WHERE published_at IS NULL AND dead_at IS NULL;
+CREATE INDEX outbox_pending_aggregate_idx ON schwarz.outbox (aggregate_id, seq) WHERE published_at IS NULL AND dead_at IS NULL;
+
+COMMIT;
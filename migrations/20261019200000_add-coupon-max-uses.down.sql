@@ -0,0 +1,8 @@
+BEGIN;
+
+-- The coupons limited per customer were never flagged as used before
+UPDATE schwarz.coupon SET used = FALSE WHERE max_uses_per_customer > 0;
+
+ALTER TABLE schwarz.coupon DROP COLUMN IF EXISTS max_uses;
+
+COMMIT;
@@ -0,0 +1,17 @@
+BEGIN;
+
+-- Caps the redemptions of a coupon by every customer together. The single use
+-- coupons keep 1 and the ones assigned to a customer its max uses per customer,
+-- the ones limited per customer and open to everyone stay unlimited as created
+ALTER TABLE schwarz.coupon ADD COLUMN max_uses INTEGER NOT NULL DEFAULT 1;
+
+UPDATE schwarz.coupon
+  SET max_uses = CASE WHEN customer_id IS NULL THEN 2147483647 ELSE max_uses_per_customer END
+  WHERE max_uses_per_customer > 0;
+
+UPDATE schwarz.coupon c
+  SET used = TRUE
+  WHERE c.max_uses_per_customer > 0
+    AND (SELECT COUNT(*) FROM schwarz.shopping_cart sc WHERE sc.coupon_id = c.id) >= c.max_uses;
+
+COMMIT;
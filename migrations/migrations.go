@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migrations of the service schema, so
+// the binary can apply them and check the schema it runs against
+package migrations
+
+import "embed"
+
+// FS holds every <version>_<name>.up.sql and <version>_<name>.down.sql file
+//
+//go:embed *.sql
+var FS embed.FS